@@ -3,6 +3,7 @@ package cloud
 import (
 	"context"
 	"fmt"
+	"log"
 	"os"
 	"time"
 
@@ -36,8 +37,8 @@ func InitAWS() error {
 }
 
 // EC2 Instance Creation
-func CreateEC2Instance(instanceType, amiID, keyName, subnetID, securityGroupID, instanceName string) (*ec2.RunInstancesOutput, error) {
-    cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion("us-east-1"))
+func CreateEC2Instance(ctx context.Context, instanceType, amiID, keyName, subnetID, securityGroupID, instanceName, region string) (*ec2.RunInstancesOutput, error) {
+    cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
     if err != nil {
         return nil, fmt.Errorf("unable to load config: %v", err)
     }
@@ -57,18 +58,26 @@ func CreateEC2Instance(instanceType, amiID, keyName, subnetID, securityGroupID,
         TagSpecifications: []ec2types.TagSpecification{
             {
                 ResourceType: ec2types.ResourceTypeInstance,
-                Tags: []ec2types.Tag{
+                Tags: append([]ec2types.Tag{
                     {
                         Key:   aws.String("Name"),
                         Value: aws.String(instanceName),
                     },
-                },
+                }, ec2Tags(ctx)...),
             },
         },
     }
 
+    // Volumes carry the cost allocation tags too
+    if tags := ec2Tags(ctx); len(tags) > 0 {
+        input.TagSpecifications = append(input.TagSpecifications, ec2types.TagSpecification{
+            ResourceType: ec2types.ResourceTypeVolume,
+            Tags:         tags,
+        })
+    }
+
     // Run the instance
-    result, err := ec2Client.RunInstances(context.Background(), input)
+    result, err := ec2Client.RunInstances(ctx, input)
     if err != nil {
         return nil, fmt.Errorf("could not create EC2 instance: %v", err)
     }
@@ -84,9 +93,19 @@ func CreateEC2Instance(instanceType, amiID, keyName, subnetID, securityGroupID,
     return result, nil
 }
 
+// s3BucketConfiguration sets the bucket location; us-east-1 is the default and must not be sent as a constraint
+func s3BucketConfiguration(region string) *s3types.CreateBucketConfiguration {
+	if region == "" || region == "us-east-1" {
+		return nil
+	}
+	return &s3types.CreateBucketConfiguration{
+		LocationConstraint: s3types.BucketLocationConstraint(region),
+	}
+}
+
 // S3 Bucket Creation
-func CreateS3Bucket(bucketName string, enableVersioning bool, Region string) (*s3.CreateBucketOutput, error) {
-	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion("us-east-1"))
+func CreateS3Bucket(ctx context.Context, bucketName string, enableVersioning bool, region string) (*s3.CreateBucketOutput, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
 	if err != nil {
 		return nil, fmt.Errorf("unable to load config: %v", err)
 	}
@@ -94,16 +113,19 @@ func CreateS3Bucket(bucketName string, enableVersioning bool, Region string) (*s
 
 	// Create bucket
 	input := &s3.CreateBucketInput{
-		Bucket: aws.String(bucketName),
+		Bucket:                    aws.String(bucketName),
+		CreateBucketConfiguration: s3BucketConfiguration(region),
 	}
-	result, err := s3Client.CreateBucket(context.Background(), input)
+	result, err := s3Client.CreateBucket(ctx, input)
 	if err != nil {
 		return nil, fmt.Errorf("could not create S3 bucket: %v", err)
 	}
 
+	tagS3Bucket(ctx, s3Client, bucketName)
+
 	// Enable versioning if requested
 	if enableVersioning {
-		_, err = s3Client.PutBucketVersioning(context.Background(), &s3.PutBucketVersioningInput{
+		_, err = s3Client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
 			Bucket: aws.String(bucketName),
 			VersioningConfiguration: &s3types.VersioningConfiguration{
 				Status: s3types.BucketVersioningStatusEnabled,
@@ -116,12 +138,29 @@ func CreateS3Bucket(bucketName string, enableVersioning bool, Region string) (*s
 	return result, nil
 }
 
-// Lambda Function Creation
-// const lambdaExecutionRoleARN = "arn:aws:iam::173939030599:role/LambdaExecutionRole"
-const lambdaExecutionRoleARN = "arn:aws:iam::058264391220:role/LambdaExecutionRole"
+// tagS3Bucket applies the tags in ctx to a bucket, which cannot be tagged on create.
+// The bucket already exists, so a failure is logged rather than returned: failing the create would leave the
+// bucket in the account with no services record tracking it. Untagged buckets are still costed by name.
+func tagS3Bucket(ctx context.Context, s3Client *s3.Client, bucketName string) {
+	tags := s3Tags(ctx)
+	if len(tags) == 0 {
+		return
+	}
+	_, err := s3Client.PutBucketTagging(ctx, &s3.PutBucketTaggingInput{
+		Bucket:  aws.String(bucketName),
+		Tagging: &s3types.Tagging{TagSet: tags},
+	})
+	if err != nil {
+		log.Printf("Failed to tag S3 bucket %s, keeping it untagged: %v", bucketName, err)
+	}
+}
 
-func CreateLambdaFunction(functionName, handler, runtime, zipFilePath, region string) (*lambda.CreateFunctionOutput, error) {
-	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
+// Lambda Function Creation; functions run as the LAMBDA_EXECUTION_ROLE_ARN role
+func CreateLambdaFunction(ctx context.Context, functionName, handler, runtime, zipFilePath, region string) (*lambda.CreateFunctionOutput, error) {
+	if settings.LambdaExecutionRoleARN == "" {
+		return nil, fmt.Errorf("LAMBDA_EXECUTION_ROLE_ARN is not set")
+	}
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
 	if err != nil {
 		return nil, fmt.Errorf("unable to load config: %v", err)
 	}
@@ -135,15 +174,16 @@ func CreateLambdaFunction(functionName, handler, runtime, zipFilePath, region st
 
 	input := &lambda.CreateFunctionInput{
 		FunctionName: aws.String(functionName),
-		Role:         aws.String(lambdaExecutionRoleARN), // Use the hardcoded role here
+		Role:         aws.String(settings.LambdaExecutionRoleARN),
 		Handler:      aws.String(handler),
 		Runtime:      lambdatypes.Runtime(runtime),
 		Code: &lambdatypes.FunctionCode{
 			ZipFile: code,
 		},
+		Tags: TagsFromContext(ctx),
 	}
 
-	result, err := lambdaClient.CreateFunction(context.TODO(), input)
+	result, err := lambdaClient.CreateFunction(ctx, input)
 	if err != nil {
 		return nil, fmt.Errorf("could not create Lambda function: %v", err)
 	}
@@ -151,8 +191,8 @@ func CreateLambdaFunction(functionName, handler, runtime, zipFilePath, region st
 }
 
 // RDS Instance Creation
-func CreateRDSInstance(dbName, instanceID, instanceClass, engine, username, password string, allocatedStorage int32, subnetGroupName string) (*rds.CreateDBInstanceOutput, error) {
-	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion("us-east-1"))
+func CreateRDSInstance(ctx context.Context, dbName, instanceID, instanceClass, engine, username, password string, allocatedStorage int32, subnetGroupName, region string) (*rds.CreateDBInstanceOutput, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
 	if err != nil {
 		return nil, fmt.Errorf("unable to load config: %v", err)
 	}
@@ -167,9 +207,10 @@ func CreateRDSInstance(dbName, instanceID, instanceClass, engine, username, pass
 		MasterUserPassword:   aws.String(password),
 		AllocatedStorage:     aws.Int32(allocatedStorage),
 		DBSubnetGroupName:    aws.String(subnetGroupName), // Add the subnet group
+		Tags:                 rdsTags(ctx),
 	}
 
-	result, err := rdsClient.CreateDBInstance(context.TODO(), input)
+	result, err := rdsClient.CreateDBInstance(ctx, input)
 	if err != nil {
 		return nil, fmt.Errorf("could not create RDS instance: %v", err)
 	}
@@ -213,15 +254,15 @@ func CreateRDSInstance(dbName, instanceID, instanceClass, engine, username, pass
 // }
 
 // CloudFront Distribution Creation with S3 Integration with OAI
-func CreateCloudFrontDistribution(originDomainName, comment, region string, minTTL int64) (*cloudfront.CreateDistributionOutput, string, error) {
-    cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
+func CreateCloudFrontDistribution(ctx context.Context, originDomainName, comment, region string, minTTL int64) (*cloudfront.CreateDistributionWithTagsOutput, string, error) {
+    cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
     if err != nil {
         return nil, "", fmt.Errorf("unable to load config: %v", err)
     }
     cloudFrontClient := cloudfront.NewFromConfig(cfg)
 
     // Create an Origin Access Identity (OAI)
-    oaiResult, err := cloudFrontClient.CreateCloudFrontOriginAccessIdentity(context.TODO(), &cloudfront.CreateCloudFrontOriginAccessIdentityInput{
+    oaiResult, err := cloudFrontClient.CreateCloudFrontOriginAccessIdentity(ctx, &cloudfront.CreateCloudFrontOriginAccessIdentityInput{
         CloudFrontOriginAccessIdentityConfig: &cloudfronttypes.CloudFrontOriginAccessIdentityConfig{
             CallerReference: aws.String(fmt.Sprintf("caller-ref-%d", time.Now().UnixNano())),
             Comment:         aws.String(comment),
@@ -234,8 +275,9 @@ func CreateCloudFrontDistribution(originDomainName, comment, region string, minT
     // Retrieve the OAI Canonical User ID
     canonicalUserID := *oaiResult.CloudFrontOriginAccessIdentity.S3CanonicalUserId
 
-    // Create the CloudFront distribution
-    input := &cloudfront.CreateDistributionInput{
+    // Create the CloudFront distribution together with its tags, so it never exists untagged
+    distribution := &cloudfronttypes.DistributionConfigWithTags{
+        Tags: cloudFrontTags(ctx),
         DistributionConfig: &cloudfronttypes.DistributionConfig{
             CallerReference: aws.String(fmt.Sprintf("caller-ref-%d", time.Now().UnixNano())),
             Enabled:         aws.Bool(true),
@@ -270,7 +312,9 @@ func CreateCloudFrontDistribution(originDomainName, comment, region string, minT
         },
     }
 
-    result, err := cloudFrontClient.CreateDistribution(context.TODO(), input)
+    result, err := cloudFrontClient.CreateDistributionWithTags(ctx, &cloudfront.CreateDistributionWithTagsInput{
+        DistributionConfigWithTags: distribution,
+    })
     if err != nil {
         return nil, "", fmt.Errorf("could not create CloudFront distribution: %v", err)
     }
@@ -279,21 +323,24 @@ func CreateCloudFrontDistribution(originDomainName, comment, region string, minT
 }
 
 // CreateS3BucketWithPolicy creates an S3 bucket and attaches a policy to allow CloudFront access using OAI
-func CreateS3BucketWithPolicy(bucketName, region, oaiCanonicalUserID string) (*s3.CreateBucketOutput, error) {
-	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
+func CreateS3BucketWithPolicy(ctx context.Context, bucketName, region, oaiCanonicalUserID string) (*s3.CreateBucketOutput, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
 	if err != nil {
 		return nil, fmt.Errorf("unable to load config: %v", err)
 	}
 	s3Client := s3.NewFromConfig(cfg)
 
 	// Create the bucket
-	_, err = s3Client.CreateBucket(context.TODO(), &s3.CreateBucketInput{
-		Bucket: aws.String(bucketName),
+	_, err = s3Client.CreateBucket(ctx, &s3.CreateBucketInput{
+		Bucket:                    aws.String(bucketName),
+		CreateBucketConfiguration: s3BucketConfiguration(region),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("could not create S3 bucket: %v", err)
 	}
 
+	tagS3Bucket(ctx, s3Client, bucketName)
+
 	// Attach bucket policy
 	bucketPolicy := fmt.Sprintf(`{
 		"Version": "2012-10-17",
@@ -309,7 +356,7 @@ func CreateS3BucketWithPolicy(bucketName, region, oaiCanonicalUserID string) (*s
 		]
 	}`, oaiCanonicalUserID, bucketName)
 
-	_, err = s3Client.PutBucketPolicy(context.TODO(), &s3.PutBucketPolicyInput{
+	_, err = s3Client.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{
 		Bucket: aws.String(bucketName),
 		Policy: aws.String(bucketPolicy),
 	})
@@ -321,36 +368,33 @@ func CreateS3BucketWithPolicy(bucketName, region, oaiCanonicalUserID string) (*s
 }
 
 // VPC Creation
-func CreateVPC(cidrBlock, region, name string) (*ec2.CreateVpcOutput, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region))
+func CreateVPC(ctx context.Context, cidrBlock, region, name string) (*ec2.CreateVpcOutput, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
 	if err != nil {
 		return nil, fmt.Errorf("could not load AWS config: %v", err)
 	}
 
 	ec2Client := ec2.NewFromConfig(cfg)
 
-	// Create VPC
+	// Create the VPC with a Name tag and the cost allocation tags
 	input := &ec2.CreateVpcInput{
 		CidrBlock: aws.String(cidrBlock),
-	}
-
-	result, err := ec2Client.CreateVpc(context.TODO(), input)
-	if err != nil {
-		return nil, fmt.Errorf("could not create VPC: %v", err)
-	}
-
-	// Add a Name tag to the VPC
-	_, err = ec2Client.CreateTags(context.TODO(), &ec2.CreateTagsInput{
-		Resources: []string{*result.Vpc.VpcId},
-		Tags: []ec2types.Tag{
+		TagSpecifications: []ec2types.TagSpecification{
 			{
-				Key:   aws.String("Name"),
-				Value: aws.String(name),
+				ResourceType: ec2types.ResourceTypeVpc,
+				Tags: append([]ec2types.Tag{
+					{
+						Key:   aws.String("Name"),
+						Value: aws.String(name),
+					},
+				}, ec2Tags(ctx)...),
 			},
 		},
-	})
+	}
+
+	result, err := ec2Client.CreateVpc(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("could not tag VPC: %v", err)
+		return nil, fmt.Errorf("could not create VPC: %v", err)
 	}
 
 	return result, nil
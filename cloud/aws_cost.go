@@ -0,0 +1,404 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	costexplorertypes "github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+)
+
+// CostExplorerRegion is the only region serving the Cost Explorer API
+const CostExplorerRegion = "us-east-1"
+
+// Cost Explorer keeps resource-level cost for the last 14 days only
+const resourceCostRetention = 14 * 24 * time.Hour
+
+// awsCostSource reads daily unblended cost from Cost Explorer. Costs are grouped by the mt-service-id tag, which
+// must be activated as a cost allocation tag; resources without tagged cost, such as adopted ones, are matched by
+// their resource ID, which needs resource-level data enabled in Cost Explorer.
+type awsCostSource struct{}
+
+func (awsCostSource) Name() string { return "aws-cost-explorer" }
+
+func (awsCostSource) DailyCosts(ctx context.Context, resources []CostResource, start, end time.Time) ([]DailyCost, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(CostExplorerRegion))
+	if err != nil {
+		return nil, err
+	}
+	client := costexplorer.NewFromConfig(cfg)
+
+	wanted := map[string]bool{}
+	for _, resource := range resources {
+		wanted[resource.ServiceID] = true
+	}
+	costs, err := awsTaggedCosts(ctx, client, wanted, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	tagged := map[string]bool{}
+	for _, cost := range costs {
+		tagged[cost.ServiceID] = true
+	}
+	byResourceID := map[string]string{}
+	for _, resource := range resources {
+		if tagged[resource.ServiceID] {
+			continue
+		}
+		id, err := AWSCostResourceID(ctx, resource.Service, resource.Config)
+		if err != nil {
+			log.Printf("No cost resource ID for service %s: %v", resource.ServiceID, err)
+			continue
+		}
+		byResourceID[id] = resource.ServiceID
+	}
+	if len(byResourceID) == 0 {
+		return costs, nil
+	}
+
+	if earliest := time.Now().UTC().Add(-resourceCostRetention).Truncate(24 * time.Hour); start.Before(earliest) {
+		start = earliest
+	}
+	if !start.Before(end) {
+		return costs, nil
+	}
+	untagged, err := awsResourceCosts(ctx, client, byResourceID, start, end)
+	return append(costs, untagged...), err
+}
+
+// awsTaggedCosts returns the daily cost of every resource whose mt-service-id tag is wanted
+func awsTaggedCosts(ctx context.Context, client *costexplorer.Client, wanted map[string]bool, start, end time.Time) ([]DailyCost, error) {
+	input := &costexplorer.GetCostAndUsageInput{
+		TimePeriod: &costexplorertypes.DateInterval{
+			Start: aws.String(start.Format("2006-01-02")),
+			End:   aws.String(end.Format("2006-01-02")),
+		},
+		Granularity: costexplorertypes.GranularityDaily,
+		Metrics:     []string{"UnblendedCost"},
+		GroupBy: []costexplorertypes.GroupDefinition{
+			{Type: costexplorertypes.GroupDefinitionTypeTag, Key: aws.String(TagServiceID)},
+		},
+	}
+
+	var costs []DailyCost
+	for {
+		output, err := client.GetCostAndUsage(ctx, input)
+		if err != nil {
+			return costs, fmt.Errorf("failed to fetch tagged cost: %w", err)
+		}
+		for _, result := range output.ResultsByTime {
+			for _, group := range result.Groups {
+				if len(group.Keys) == 0 {
+					continue
+				}
+				// Tag groups are keyed "mt-service-id$<value>"; untagged cost has an empty value
+				serviceID := strings.TrimPrefix(group.Keys[0], TagServiceID+"$")
+				if !wanted[serviceID] {
+					continue
+				}
+				cost, err := awsDailyCost(result, group)
+				if err != nil {
+					return costs, err
+				}
+				cost.ServiceID = serviceID
+				costs = append(costs, cost)
+			}
+		}
+		if output.NextPageToken == nil {
+			return costs, nil
+		}
+		input.NextPageToken = output.NextPageToken
+	}
+}
+
+// awsResourceCosts returns the daily cost of resources by their Cost Explorer resource ID
+func awsResourceCosts(ctx context.Context, client *costexplorer.Client, byResourceID map[string]string, start, end time.Time) ([]DailyCost, error) {
+	ids := make([]string, 0, len(byResourceID))
+	for id := range byResourceID {
+		ids = append(ids, id)
+	}
+	input := &costexplorer.GetCostAndUsageWithResourcesInput{
+		TimePeriod: &costexplorertypes.DateInterval{
+			Start: aws.String(start.Format("2006-01-02")),
+			End:   aws.String(end.Format("2006-01-02")),
+		},
+		Granularity: costexplorertypes.GranularityDaily,
+		Metrics:     []string{"UnblendedCost"},
+		Filter: &costexplorertypes.Expression{
+			Dimensions: &costexplorertypes.DimensionValues{
+				Key:    costexplorertypes.DimensionResourceId,
+				Values: ids,
+			},
+		},
+		GroupBy: []costexplorertypes.GroupDefinition{
+			{Type: costexplorertypes.GroupDefinitionTypeDimension, Key: aws.String(string(costexplorertypes.DimensionResourceId))},
+		},
+	}
+
+	var costs []DailyCost
+	for {
+		output, err := client.GetCostAndUsageWithResources(ctx, input)
+		if err != nil {
+			return costs, fmt.Errorf("failed to fetch resource cost: %w", err)
+		}
+		for _, result := range output.ResultsByTime {
+			for _, group := range result.Groups {
+				if len(group.Keys) == 0 {
+					continue
+				}
+				serviceID, ok := byResourceID[group.Keys[0]]
+				if !ok {
+					continue
+				}
+				cost, err := awsDailyCost(result, group)
+				if err != nil {
+					return costs, err
+				}
+				cost.ServiceID = serviceID
+				cost.ResourceID = group.Keys[0]
+				costs = append(costs, cost)
+			}
+		}
+		if output.NextPageToken == nil {
+			return costs, nil
+		}
+		input.NextPageToken = output.NextPageToken
+	}
+}
+
+// awsDailyCost reads the unblended cost of one group on one day
+func awsDailyCost(result costexplorertypes.ResultByTime, group costexplorertypes.Group) (DailyCost, error) {
+	day, err := costDay(aws.ToString(result.TimePeriod.Start))
+	if err != nil {
+		return DailyCost{}, fmt.Errorf("invalid cost date: %v", err)
+	}
+	metric, ok := group.Metrics["UnblendedCost"]
+	if !ok {
+		return DailyCost{Date: day, Currency: "USD"}, nil
+	}
+	amount, err := strconv.ParseFloat(aws.ToString(metric.Amount), 64)
+	if err != nil {
+		return DailyCost{}, fmt.Errorf("invalid cost amount %q: %v", aws.ToString(metric.Amount), err)
+	}
+	currency := aws.ToString(metric.Unit)
+	if currency == "" {
+		currency = "USD"
+	}
+	return DailyCost{Date: day, Amount: amount, Currency: currency}, nil
+}
+
+// AWSResourceCost returns the unblended cost of one resource over whole days from start to end (exclusive).
+// A resource tracked as a service is matched by its mt-service-id tag; without a service ID, or before any tagged
+// cost is reported, it is matched by resource ID, which Cost Explorer keeps for the last 14 days only.
+func AWSResourceCost(ctx context.Context, serviceID, resourceID string, start, end time.Time) (float64, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(CostExplorerRegion))
+	if err != nil {
+		return 0, err
+	}
+	client := costexplorer.NewFromConfig(cfg)
+	period := func() *costexplorertypes.DateInterval {
+		return &costexplorertypes.DateInterval{
+			Start: aws.String(start.Format("2006-01-02")),
+			End:   aws.String(end.Format("2006-01-02")),
+		}
+	}
+
+	if serviceID != "" {
+		output, err := client.GetCostAndUsage(ctx, &costexplorer.GetCostAndUsageInput{
+			TimePeriod:  period(),
+			Granularity: costexplorertypes.GranularityMonthly,
+			Metrics:     []string{"UnblendedCost"},
+			Filter: &costexplorertypes.Expression{
+				Tags: &costexplorertypes.TagValues{Key: aws.String(TagServiceID), Values: []string{serviceID}},
+			},
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to fetch tagged cost: %w", err)
+		}
+		total, err := awsTotalCost(output.ResultsByTime)
+		if err != nil || total > 0 || resourceID == "" {
+			return total, err
+		}
+	}
+	if resourceID == "" {
+		return 0, fmt.Errorf("no service ID or resource ID to fetch the cost of")
+	}
+
+	if earliest := time.Now().UTC().Add(-resourceCostRetention).Truncate(24 * time.Hour); start.Before(earliest) {
+		start = earliest
+	}
+	if !start.Before(end) {
+		return 0, nil
+	}
+	output, err := client.GetCostAndUsageWithResources(ctx, &costexplorer.GetCostAndUsageWithResourcesInput{
+		TimePeriod:  period(),
+		Granularity: costexplorertypes.GranularityMonthly,
+		Metrics:     []string{"UnblendedCost"},
+		Filter: &costexplorertypes.Expression{
+			Dimensions: &costexplorertypes.DimensionValues{
+				Key:    costexplorertypes.DimensionResourceId,
+				Values: []string{resourceID},
+			},
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch resource cost: %w", err)
+	}
+	return awsTotalCost(output.ResultsByTime)
+}
+
+// awsTotalCost adds up the unblended cost totals of ungrouped results
+func awsTotalCost(results []costexplorertypes.ResultByTime) (float64, error) {
+	var total float64
+	for _, result := range results {
+		metric, ok := result.Total["UnblendedCost"]
+		if !ok {
+			continue
+		}
+		amount, err := strconv.ParseFloat(aws.ToString(metric.Amount), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid cost amount %q: %v", aws.ToString(metric.Amount), err)
+		}
+		total += amount
+	}
+	return total, nil
+}
+
+// AWSCostResourceID returns the ID Cost Explorer reports a resource's cost under, from its stored config.
+// Resources recorded by name are looked up in the account.
+func AWSCostResourceID(ctx context.Context, service string, params Params) (string, error) {
+	// S3 and CloudFront are global; the others are looked up where they were created
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(awsRegion(params)))
+	if err != nil {
+		return "", err
+	}
+
+	switch service {
+	case AWSEC2:
+		if id := params.String("instance_id"); id != "" {
+			return id, nil
+		}
+		return resolveEC2InstanceID(ctx, cfg, params.String("instance_name"))
+	case AWSS3:
+		if bucket := params.String("bucket_name"); bucket != "" {
+			return bucket, nil
+		}
+	case AWSLambda:
+		return resolveLambdaARN(ctx, cfg, params.String("function_name"))
+	case AWSRDS:
+		return resolveRDSInstanceARN(ctx, cfg, params.String("instance_id"))
+	case AWSCloudFront:
+		return resolveCloudFrontARN(ctx, cfg, params.String("distribution_id"))
+	case AWSVPC:
+		if id := params.String("vpc_id"); id != "" {
+			return id, nil
+		}
+		return resolveVPCID(ctx, cfg, params.String("name"))
+	default:
+		return "", fmt.Errorf("cost lookup not supported for %s", service)
+	}
+	return "", fmt.Errorf("no resource identifier recorded for %s", service)
+}
+
+func resolveEC2InstanceID(ctx context.Context, cfg aws.Config, serviceName string) (string, error) {
+	svc := ec2.NewFromConfig(cfg)
+	input := &ec2.DescribeInstancesInput{
+		Filters: []ec2types.Filter{
+			{
+				Name:   aws.String("tag:Name"),
+				Values: []string{serviceName},
+			},
+		},
+	}
+
+	result, err := svc.DescribeInstances(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to describe EC2 instances: %w", err)
+	}
+
+	for _, reservation := range result.Reservations {
+		for _, instance := range reservation.Instances {
+			return *instance.InstanceId, nil
+		}
+	}
+	return "", fmt.Errorf("no EC2 instance found with name: %s", serviceName)
+}
+
+// resolveRDSInstanceARN returns the ARN of a DB instance, which Cost Explorer uses as its resource ID
+func resolveRDSInstanceARN(ctx context.Context, cfg aws.Config, dbName string) (string, error) {
+	rdsClient := rds.NewFromConfig(cfg)
+
+	output, err := rdsClient.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String(dbName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe RDS instances: %w", err)
+	}
+
+	for _, dbInstance := range output.DBInstances {
+		if aws.ToString(dbInstance.DBInstanceIdentifier) == dbName {
+			return aws.ToString(dbInstance.DBInstanceArn), nil
+		}
+	}
+
+	return "", fmt.Errorf("RDS instance with name %s not found", dbName)
+}
+
+func resolveLambdaARN(ctx context.Context, cfg aws.Config, functionName string) (string, error) {
+	lambdaClient := lambda.NewFromConfig(cfg)
+
+	output, err := lambdaClient.GetFunction(ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(functionName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("lambda function with name %s not found: %w", functionName, err)
+	}
+	if output.Configuration == nil {
+		return "", fmt.Errorf("lambda function with name %s not found", functionName)
+	}
+	return aws.ToString(output.Configuration.FunctionArn), nil
+}
+
+func resolveCloudFrontARN(ctx context.Context, cfg aws.Config, distributionID string) (string, error) {
+	output, err := cloudfront.NewFromConfig(cfg).GetDistribution(ctx, &cloudfront.GetDistributionInput{
+		Id: aws.String(distributionID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get CloudFront distribution %s: %w", distributionID, err)
+	}
+	return aws.ToString(output.Distribution.ARN), nil
+}
+
+func resolveVPCID(ctx context.Context, cfg aws.Config, vpcName string) (string, error) {
+	ec2Client := ec2.NewFromConfig(cfg)
+
+	output, err := ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{
+		Filters: []ec2types.Filter{
+			{
+				Name:   aws.String("tag:Name"),
+				Values: []string{vpcName},
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe VPCs: %w", err)
+	}
+
+	for _, vpc := range output.Vpcs {
+		return *vpc.VpcId, nil
+	}
+
+	return "", fmt.Errorf("VPC with name %s not found", vpcName)
+}
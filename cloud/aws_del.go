@@ -15,25 +15,16 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
-// LoadAWSConfig loads the AWS configuration
-func LoadAWSConfig() (aws.Config, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
-	}
-	return cfg, nil
-}
-
 // DeleteLambdaFunction deletes an AWS Lambda function
-func DeleteLambdaFunction(functionName string) (string, error) {
-	cfg, err := LoadAWSConfig()
+func DeleteLambdaFunction(ctx context.Context, functionName, region string) (string, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
 	if err != nil {
 		return "", err
 	}
 
 	client := lambda.NewFromConfig(cfg)
 
-	_, err = client.DeleteFunction(context.TODO(), &lambda.DeleteFunctionInput{
+	_, err = client.DeleteFunction(ctx, &lambda.DeleteFunctionInput{
 		FunctionName: aws.String(functionName),
 	})
 	if err != nil {
@@ -44,15 +35,15 @@ func DeleteLambdaFunction(functionName string) (string, error) {
 }
 
 // TerminateEC2Instance terminates an EC2 instance
-func TerminateEC2Instance(instanceID string) (interface{}, error) {
-	cfg, err := LoadAWSConfig()
+func TerminateEC2Instance(ctx context.Context, instanceID, region string) (interface{}, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
 	if err != nil {
 		return nil, err
 	}
 
 	client := ec2.NewFromConfig(cfg)
 
-	_, err = client.TerminateInstances(context.TODO(), &ec2.TerminateInstancesInput{
+	_, err = client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
 		InstanceIds: []string{instanceID},
 	})
 	if err != nil {
@@ -63,8 +54,8 @@ func TerminateEC2Instance(instanceID string) (interface{}, error) {
 }
 
 // DeleteS3Bucket deletes an S3 bucket
-func DeleteS3Bucket(bucketName string) (string, error) {
-	cfg, err := LoadAWSConfig()
+func DeleteS3Bucket(ctx context.Context, bucketName, region string) (string, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
 	if err != nil {
 		return "", err
 	}
@@ -72,7 +63,7 @@ func DeleteS3Bucket(bucketName string) (string, error) {
 	client := s3.NewFromConfig(cfg)
 
 	// List and delete all objects in the bucket
-	listObjects, err := client.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
+	listObjects, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
 		Bucket: aws.String(bucketName),
 	})
 	if err != nil {
@@ -80,7 +71,7 @@ func DeleteS3Bucket(bucketName string) (string, error) {
 	}
 
 	for _, obj := range listObjects.Contents {
-		_, err = client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{
 			Bucket: aws.String(bucketName),
 			Key:    obj.Key,
 		})
@@ -90,7 +81,7 @@ func DeleteS3Bucket(bucketName string) (string, error) {
 	}
 
 	// Delete the bucket
-	_, err = client.DeleteBucket(context.TODO(), &s3.DeleteBucketInput{
+	_, err = client.DeleteBucket(ctx, &s3.DeleteBucketInput{
 		Bucket: aws.String(bucketName),
 	})
 	if err != nil {
@@ -101,15 +92,15 @@ func DeleteS3Bucket(bucketName string) (string, error) {
 }
 
 // DeleteRDSInstance deletes an RDS instance
-func DeleteRDSInstance(instanceID string) (interface{}, error) {
-	cfg, err := LoadAWSConfig()
+func DeleteRDSInstance(ctx context.Context, instanceID, region string) (interface{}, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
 	if err != nil {
 		return nil, err
 	}
 
 	client := rds.NewFromConfig(cfg)
 
-	_, err = client.DeleteDBInstance(context.TODO(), &rds.DeleteDBInstanceInput{
+	_, err = client.DeleteDBInstance(ctx, &rds.DeleteDBInstanceInput{
 		DBInstanceIdentifier: aws.String(instanceID),
 		SkipFinalSnapshot:    aws.Bool(true), // Skip final snapshot for immediate deletion
 	})
@@ -121,8 +112,8 @@ func DeleteRDSInstance(instanceID string) (interface{}, error) {
 }
 
 // DeleteCloudFrontDistribution deletes a CloudFront distribution
-func DisableCloudFrontDistribution(distributionID string) (string, error) {
-	cfg, err := LoadAWSConfig()
+func DisableCloudFrontDistribution(ctx context.Context, distributionID, region string) (string, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
 	if err != nil {
 		return "", err
 	}
@@ -130,7 +121,7 @@ func DisableCloudFrontDistribution(distributionID string) (string, error) {
 	client := cloudfront.NewFromConfig(cfg)
 
 	// Get the distribution configuration
-	distConfig, err := client.GetDistributionConfig(context.TODO(), &cloudfront.GetDistributionConfigInput{
+	distConfig, err := client.GetDistributionConfig(ctx, &cloudfront.GetDistributionConfigInput{
 		Id: aws.String(distributionID),
 	})
 	if err != nil {
@@ -139,7 +130,7 @@ func DisableCloudFrontDistribution(distributionID string) (string, error) {
 
 	// Disable the distribution
 	distConfig.DistributionConfig.Enabled = aws.Bool(false)
-	_, err = client.UpdateDistribution(context.TODO(), &cloudfront.UpdateDistributionInput{
+	_, err = client.UpdateDistribution(ctx, &cloudfront.UpdateDistributionInput{
 		Id:                 aws.String(distributionID),
 		IfMatch:            distConfig.ETag,
 		DistributionConfig: distConfig.DistributionConfig,
@@ -152,8 +143,8 @@ func DisableCloudFrontDistribution(distributionID string) (string, error) {
 }
 
 // DeleteVPC deletes a VPC
-func DeleteVPC(vpcName string) (string, string, error) {
-	cfg, err := LoadAWSConfig()
+func DeleteVPC(ctx context.Context, vpcName, region string) (string, string, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
 	if err != nil {
 		return "", "", err
 	}
@@ -161,7 +152,7 @@ func DeleteVPC(vpcName string) (string, string, error) {
 	client := ec2.NewFromConfig(cfg)
 
 	// Resolve VPC ID from VPC name
-	vpcOutput, err := client.DescribeVpcs(context.TODO(), &ec2.DescribeVpcsInput{
+	vpcOutput, err := client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{
 		Filters: []ec2types.Filter{
 			{
 				Name:   aws.String("tag:Name"),
@@ -183,7 +174,7 @@ func DeleteVPC(vpcName string) (string, string, error) {
 	dependencyMessages := []string{}
 
 	// Check Internet Gateways
-	igwOutput, err := client.DescribeInternetGateways(context.TODO(), &ec2.DescribeInternetGatewaysInput{
+	igwOutput, err := client.DescribeInternetGateways(ctx, &ec2.DescribeInternetGatewaysInput{
 		Filters: []ec2types.Filter{
 			{
 				Name:   aws.String("attachment.vpc-id"),
@@ -199,7 +190,7 @@ func DeleteVPC(vpcName string) (string, string, error) {
 	}
 
 	// Check Subnets
-	subnetsOutput, err := client.DescribeSubnets(context.TODO(), &ec2.DescribeSubnetsInput{
+	subnetsOutput, err := client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
 		Filters: []ec2types.Filter{
 			{
 				Name:   aws.String("vpc-id"),
@@ -215,7 +206,7 @@ func DeleteVPC(vpcName string) (string, string, error) {
 	}
 
 	// Check Security Groups
-	sgOutput, err := client.DescribeSecurityGroups(context.TODO(), &ec2.DescribeSecurityGroupsInput{
+	sgOutput, err := client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
 		Filters: []ec2types.Filter{
 			{
 				Name:   aws.String("vpc-id"),
@@ -237,7 +228,7 @@ func DeleteVPC(vpcName string) (string, string, error) {
 
 	// Delete the Internet Gateways
 	for _, igw := range igwOutput.InternetGateways {
-		_, err = client.DetachInternetGateway(context.TODO(), &ec2.DetachInternetGatewayInput{
+		_, err = client.DetachInternetGateway(ctx, &ec2.DetachInternetGatewayInput{
 			InternetGatewayId: igw.InternetGatewayId,
 			VpcId:             aws.String(vpcID),
 		})
@@ -245,7 +236,7 @@ func DeleteVPC(vpcName string) (string, string, error) {
 			return "", "", fmt.Errorf("failed to detach internet gateway '%s': %w", *igw.InternetGatewayId, err)
 		}
 
-		_, err = client.DeleteInternetGateway(context.TODO(), &ec2.DeleteInternetGatewayInput{
+		_, err = client.DeleteInternetGateway(ctx, &ec2.DeleteInternetGatewayInput{
 			InternetGatewayId: igw.InternetGatewayId,
 		})
 		if err != nil {
@@ -254,7 +245,7 @@ func DeleteVPC(vpcName string) (string, string, error) {
 	}
 
 	// Finally, delete the VPC
-	_, err = client.DeleteVpc(context.TODO(), &ec2.DeleteVpcInput{
+	_, err = client.DeleteVpc(ctx, &ec2.DeleteVpcInput{
 		VpcId: aws.String(vpcID),
 	})
 	if err != nil {
@@ -0,0 +1,231 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// isAWSNotFound reports whether err is an AWS API error with one of the given codes
+func isAWSNotFound(err error, codes ...string) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, code := range codes {
+		if apiErr.ErrorCode() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// DescribeEC2Instance returns the live state of an EC2 instance
+func DescribeEC2Instance(ctx context.Context, instanceID, region string) (*Description, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := ec2.NewFromConfig(cfg)
+
+	output, err := client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	})
+	if err != nil {
+		if isAWSNotFound(err, "InvalidInstanceID.NotFound", "InvalidInstanceID.Malformed") {
+			return &Description{Exists: false}, nil
+		}
+		return nil, fmt.Errorf("failed to describe EC2 instance: %w", err)
+	}
+
+	for _, reservation := range output.Reservations {
+		for _, instance := range reservation.Instances {
+			state := string(instance.State.Name)
+			return &Description{
+				Exists: instance.State.Name != ec2types.InstanceStateNameTerminated,
+				State:  state,
+				Attributes: Params{
+					"instance_type": string(instance.InstanceType),
+				},
+			}, nil
+		}
+	}
+	return &Description{Exists: false}, nil
+}
+
+// DescribeS3Bucket checks that an S3 bucket still exists
+func DescribeS3Bucket(ctx context.Context, bucketName, region string) (*Description, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(cfg)
+
+	_, err = client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		if isAWSNotFound(err, "NotFound", "NoSuchBucket") {
+			return &Description{Exists: false}, nil
+		}
+		return nil, fmt.Errorf("failed to describe S3 bucket: %w", err)
+	}
+
+	versioning, err := client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch S3 bucket versioning: %w", err)
+	}
+
+	return &Description{
+		Exists: true,
+		State:  "available",
+		Attributes: Params{
+			"versioning": versioning.Status == "Enabled",
+		},
+	}, nil
+}
+
+// DescribeLambdaFunction returns the live state of a Lambda function
+func DescribeLambdaFunction(ctx context.Context, functionName, region string) (*Description, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := lambda.NewFromConfig(cfg)
+
+	output, err := client.GetFunction(ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(functionName),
+	})
+	if err != nil {
+		if isAWSNotFound(err, "ResourceNotFoundException") {
+			return &Description{Exists: false}, nil
+		}
+		return nil, fmt.Errorf("failed to describe Lambda function: %w", err)
+	}
+
+	return &Description{
+		Exists: true,
+		State:  string(output.Configuration.State),
+		Attributes: Params{
+			"runtime": string(output.Configuration.Runtime),
+			"handler": aws.ToString(output.Configuration.Handler),
+		},
+	}, nil
+}
+
+// DescribeRDSInstance returns the live state of an RDS instance
+func DescribeRDSInstance(ctx context.Context, instanceID, region string) (*Description, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := rds.NewFromConfig(cfg)
+
+	output, err := client.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String(instanceID),
+	})
+	if err != nil {
+		if isAWSNotFound(err, "DBInstanceNotFound", "DBInstanceNotFoundFault") {
+			return &Description{Exists: false}, nil
+		}
+		return nil, fmt.Errorf("failed to describe RDS instance: %w", err)
+	}
+	if len(output.DBInstances) == 0 {
+		return &Description{Exists: false}, nil
+	}
+
+	instance := output.DBInstances[0]
+	return &Description{
+		Exists: true,
+		State:  aws.ToString(instance.DBInstanceStatus),
+		Attributes: Params{
+			"instance_class":    aws.ToString(instance.DBInstanceClass),
+			"engine":            aws.ToString(instance.Engine),
+			"allocated_storage": aws.ToInt32(instance.AllocatedStorage),
+		},
+	}, nil
+}
+
+// DescribeCloudFrontDistribution returns the live state of a CloudFront distribution
+func DescribeCloudFrontDistribution(ctx context.Context, distributionID, region string) (*Description, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := cloudfront.NewFromConfig(cfg)
+
+	output, err := client.GetDistribution(ctx, &cloudfront.GetDistributionInput{
+		Id: aws.String(distributionID),
+	})
+	if err != nil {
+		if isAWSNotFound(err, "NoSuchDistribution") {
+			return &Description{Exists: false}, nil
+		}
+		return nil, fmt.Errorf("failed to describe CloudFront distribution: %w", err)
+	}
+
+	distribution := output.Distribution
+	attributes := Params{
+		"enabled": aws.ToBool(distribution.DistributionConfig.Enabled),
+	}
+	if behavior := distribution.DistributionConfig.DefaultCacheBehavior; behavior != nil {
+		attributes["min_ttl"] = aws.ToInt64(behavior.MinTTL)
+	}
+
+	return &Description{
+		Exists:     true,
+		State:      aws.ToString(distribution.Status),
+		Attributes: attributes,
+	}, nil
+}
+
+// DescribeVPC returns the live state of a VPC resolved by its Name tag
+func DescribeVPC(ctx context.Context, vpcName, region string) (*Description, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := ec2.NewFromConfig(cfg)
+
+	output, err := client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{
+		Filters: []ec2types.Filter{
+			{
+				Name:   aws.String("tag:Name"),
+				Values: []string{vpcName},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe VPCs by name: %w", err)
+	}
+	if len(output.Vpcs) == 0 {
+		return &Description{Exists: false}, nil
+	}
+
+	vpc := output.Vpcs[0]
+	return &Description{
+		Exists: true,
+		State:  string(vpc.State),
+		Attributes: Params{
+			"cidr_block": aws.ToString(vpc.CidrBlock),
+		},
+	}, nil
+}
@@ -0,0 +1,212 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ec2NameTag returns the Name tag of an EC2 resource
+func ec2NameTag(tags []ec2types.Tag) string {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == "Name" {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
+}
+
+// DiscoverEC2Instances lists the EC2 instances of a region that have not been terminated
+func DiscoverEC2Instances(ctx context.Context, region string) ([]DiscoveredResource, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := ec2.NewFromConfig(cfg)
+
+	var resources []DiscoveredResource
+	paginator := ec2.NewDescribeInstancesPaginator(client, &ec2.DescribeInstancesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list EC2 instances: %w", err)
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				if instance.State.Name == ec2types.InstanceStateNameTerminated || instance.State.Name == ec2types.InstanceStateNameShuttingDown {
+					continue
+				}
+
+				instanceID := aws.ToString(instance.InstanceId)
+				name := ec2NameTag(instance.Tags)
+				if name == "" {
+					name = instanceID
+				}
+				resources = append(resources, DiscoveredResource{
+					ID:      instanceID,
+					IDField: "instance_id", // Name tags are not unique
+					State:   string(instance.State.Name),
+					Config: Params{
+						"instance_type": string(instance.InstanceType),
+						"ami_id":        aws.ToString(instance.ImageId),
+						"key_name":      aws.ToString(instance.KeyName),
+						"subnet_id":     aws.ToString(instance.SubnetId),
+						"instance_name": name,
+						"region":        region,
+						"instance_id":   instanceID,
+					},
+				})
+			}
+		}
+	}
+	return resources, nil
+}
+
+// DiscoverS3Buckets lists the S3 buckets located in a region
+func DiscoverS3Buckets(ctx context.Context, region string) ([]DiscoveredResource, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(cfg)
+
+	var resources []DiscoveredResource
+	paginator := s3.NewListBucketsPaginator(client, &s3.ListBucketsInput{BucketRegion: aws.String(region)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list S3 buckets: %w", err)
+		}
+		for _, bucket := range page.Buckets {
+			name := aws.ToString(bucket.Name)
+			resources = append(resources, DiscoveredResource{
+				ID:      name,
+				IDField: "bucket_name",
+				State:   "available",
+				Config: Params{
+					"bucket_name": name,
+					"region":      region,
+				},
+			})
+		}
+	}
+	return resources, nil
+}
+
+// DiscoverLambdaFunctions lists the Lambda functions of a region
+func DiscoverLambdaFunctions(ctx context.Context, region string) ([]DiscoveredResource, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := lambda.NewFromConfig(cfg)
+
+	var resources []DiscoveredResource
+	paginator := lambda.NewListFunctionsPaginator(client, &lambda.ListFunctionsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Lambda functions: %w", err)
+		}
+		for _, function := range page.Functions {
+			name := aws.ToString(function.FunctionName)
+			resources = append(resources, DiscoveredResource{
+				ID:      name,
+				IDField: "function_name",
+				State:   string(function.State),
+				Config: Params{
+					"function_name": name,
+					"handler":       aws.ToString(function.Handler),
+					"runtime":       string(function.Runtime),
+					"region":        region,
+				},
+			})
+		}
+	}
+	return resources, nil
+}
+
+// DiscoverRDSInstances lists the RDS instances of a region
+func DiscoverRDSInstances(ctx context.Context, region string) ([]DiscoveredResource, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := rds.NewFromConfig(cfg)
+
+	var resources []DiscoveredResource
+	paginator := rds.NewDescribeDBInstancesPaginator(client, &rds.DescribeDBInstancesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list RDS instances: %w", err)
+		}
+		for _, instance := range page.DBInstances {
+			instanceID := aws.ToString(instance.DBInstanceIdentifier)
+			resources = append(resources, DiscoveredResource{
+				ID:      instanceID,
+				IDField: "instance_id",
+				State:   aws.ToString(instance.DBInstanceStatus),
+				Config: Params{
+					"db_name":           aws.ToString(instance.DBName),
+					"instance_id":       instanceID,
+					"instance_class":    aws.ToString(instance.DBInstanceClass),
+					"engine":            aws.ToString(instance.Engine),
+					"username":          aws.ToString(instance.MasterUsername),
+					"allocated_storage": aws.ToInt32(instance.AllocatedStorage),
+					"region":            region,
+				},
+			})
+		}
+	}
+	return resources, nil
+}
+
+// DiscoverVPCs lists the named, non-default VPCs of a region.
+// VPCs are described and deleted by their Name tag, so unnamed VPCs cannot be managed and are skipped.
+func DiscoverVPCs(ctx context.Context, region string) ([]DiscoveredResource, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := ec2.NewFromConfig(cfg)
+
+	var resources []DiscoveredResource
+	paginator := ec2.NewDescribeVpcsPaginator(client, &ec2.DescribeVpcsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list VPCs: %w", err)
+		}
+		for _, vpc := range page.Vpcs {
+			name := ec2NameTag(vpc.Tags)
+			if name == "" || aws.ToBool(vpc.IsDefault) {
+				continue
+			}
+			resources = append(resources, DiscoveredResource{
+				ID:      aws.ToString(vpc.VpcId),
+				IDField: "vpc_id",
+				State:   string(vpc.State),
+				Config: Params{
+					"cidr_block": aws.ToString(vpc.CidrBlock),
+					"region":     region,
+					"name":       name,
+					"vpc_id":     aws.ToString(vpc.VpcId),
+				},
+			})
+		}
+	}
+	return resources, nil
+}
@@ -0,0 +1,68 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+)
+
+// SetEC2InstancePower starts or stops an EC2 instance
+func SetEC2InstancePower(ctx context.Context, instanceID, region string, running bool) (*PowerResult, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := ec2.NewFromConfig(cfg)
+
+	if running {
+		output, err := client.StartInstances(ctx, &ec2.StartInstancesInput{InstanceIds: []string{instanceID}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to start EC2 instance: %w", err)
+		}
+		state := "pending"
+		if len(output.StartingInstances) > 0 && output.StartingInstances[0].CurrentState != nil {
+			state = string(output.StartingInstances[0].CurrentState.Name)
+		}
+		return &PowerResult{State: state, Result: output}, nil
+	}
+
+	output, err := client.StopInstances(ctx, &ec2.StopInstancesInput{InstanceIds: []string{instanceID}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to stop EC2 instance: %w", err)
+	}
+	state := "stopping"
+	if len(output.StoppingInstances) > 0 && output.StoppingInstances[0].CurrentState != nil {
+		state = string(output.StoppingInstances[0].CurrentState.Name)
+	}
+	return &PowerResult{State: state, Result: output}, nil
+}
+
+// SetRDSInstancePower starts or stops an RDS instance.
+// AWS starts a stopped instance again by itself after seven days.
+func SetRDSInstancePower(ctx context.Context, instanceID, region string, running bool) (*PowerResult, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := rds.NewFromConfig(cfg)
+
+	if running {
+		output, err := client.StartDBInstance(ctx, &rds.StartDBInstanceInput{DBInstanceIdentifier: aws.String(instanceID)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to start RDS instance: %w", err)
+		}
+		return &PowerResult{State: aws.ToString(output.DBInstance.DBInstanceStatus), Result: output}, nil
+	}
+
+	output, err := client.StopDBInstance(ctx, &rds.StopDBInstanceInput{DBInstanceIdentifier: aws.String(instanceID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to stop RDS instance: %w", err)
+	}
+	return &PowerResult{State: aws.ToString(output.DBInstance.DBInstanceStatus), Result: output}, nil
+}
@@ -0,0 +1,284 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+)
+
+// AWS catalog names, stored as `service` in sessions and services
+const (
+	AWSEC2        = "Amazon EC2 (Elastic Compute Cloud)"
+	AWSS3         = "Amazon S3 (Simple Storage Service)"
+	AWSLambda     = "AWS Lambda"
+	AWSRDS        = "Amazon RDS (Relational Database Service)"
+	AWSCloudFront = "AWS CloudFront"
+	AWSVPC        = "Amazon VPC (Virtual Private Cloud)"
+)
+
+func init() {
+	RegisterProvider(NewAWSProvider())
+}
+
+// NewAWSProvider returns the provider backed by the AWS SDK
+func NewAWSProvider() Provider {
+	return &provider{
+		name: "aws",
+		services: []ServiceType{
+			&service{
+				provider:        "aws",
+				name:            AWSEC2,
+				path:            "create-ec2-instance",
+				identifierField: "instance_name",
+				required:        []string{"instance_type", "ami_id", "instance_name", "region"},
+				discover:        DiscoverEC2Instances,
+				updatable:       map[string]fieldKind{"instance_type": kindString},
+				update: func(ctx context.Context, plan *UpdatePlan) (interface{}, error) {
+					return ModifyEC2InstanceType(ctx, plan.Config.String("instance_id"), plan.Config.String("instance_type"), awsRegion(plan.Config))
+				},
+				power: func(ctx context.Context, config Params, running bool) (*PowerResult, error) {
+					return SetEC2InstancePower(ctx, config.String("instance_id"), awsRegion(config), running)
+				},
+				priced:   []string{"instance_type", "region"},
+				estimate: estimateEC2,
+				create: func(ctx context.Context, params Params) (*CreateResult, error) {
+					result, err := CreateEC2Instance(ctx, params.String("instance_type"), params.String("ami_id"), params.String("key_name"), params.String("subnet_id"), params.String("security_group_id"), params.String("instance_name"), params.String("region"))
+					if err != nil {
+						return nil, err
+					}
+					if len(result.Instances) == 0 || result.Instances[0].InstanceId == nil {
+						return nil, fmt.Errorf("failed to fetch instance ID: RunInstances returned no instance")
+					}
+					config := Params{
+						"instance_type":     params.String("instance_type"),
+						"ami_id":            params.String("ami_id"),
+						"key_name":          params.String("key_name"),
+						"subnet_id":         params.String("subnet_id"),
+						"security_group_id": params.String("security_group_id"),
+						"instance_name":     params.String("instance_name"),
+						"region":            params.String("region"),
+						"instance_id":       *result.Instances[0].InstanceId,
+					}
+					return &CreateResult{Config: config, Result: result}, nil
+				},
+				describe: func(ctx context.Context, config Params) (*Description, error) {
+					return DescribeEC2Instance(ctx, config.String("instance_id"), awsRegion(config))
+				},
+				delete: func(ctx context.Context, config Params) (*DeleteResult, error) {
+					result, err := TerminateEC2Instance(ctx, config.String("instance_id"), awsRegion(config))
+					if err != nil {
+						return nil, err
+					}
+					return &DeleteResult{Message: "Amazon EC2 instance deleted successfully", Result: result, Deleted: true}, nil
+				},
+			},
+			&service{
+				provider:        "aws",
+				name:            AWSS3,
+				path:            "create-s3-bucket",
+				identifierField: "bucket_name",
+				required:        []string{"bucket_name", "region"},
+				discover:        DiscoverS3Buckets,
+				updatable:       map[string]fieldKind{"versioning": kindBool},
+				update: func(ctx context.Context, plan *UpdatePlan) (interface{}, error) {
+					return SetS3BucketVersioning(ctx, plan.Config.String("bucket_name"), plan.Config.Bool("versioning"), awsRegion(plan.Config))
+				},
+				priced:   []string{"region"},
+				estimate: estimateS3,
+				create: func(ctx context.Context, params Params) (*CreateResult, error) {
+					result, err := CreateS3Bucket(ctx, params.String("bucket_name"), params.Bool("versioning"), params.String("region"))
+					if err != nil {
+						return nil, err
+					}
+					config := Params{
+						"bucket_name": params.String("bucket_name"),
+						"versioning":  params.Bool("versioning"),
+						"region":      params.String("region"),
+					}
+					return &CreateResult{Config: config, Result: result}, nil
+				},
+				describe: func(ctx context.Context, config Params) (*Description, error) {
+					return DescribeS3Bucket(ctx, config.String("bucket_name"), awsRegion(config))
+				},
+				delete: func(ctx context.Context, config Params) (*DeleteResult, error) {
+					result, err := DeleteS3Bucket(ctx, config.String("bucket_name"), awsRegion(config))
+					if err != nil {
+						return nil, err
+					}
+					return &DeleteResult{Message: "Amazon S3 bucket deleted successfully", Result: result, Deleted: true}, nil
+				},
+			},
+			&service{
+				provider:        "aws",
+				name:            AWSLambda,
+				path:            "create-lambda-function",
+				identifierField: "function_name",
+				required:        []string{"function_name", "handler", "runtime", "zip_file_path", "region"},
+				discover:        DiscoverLambdaFunctions,
+				updatable:       map[string]fieldKind{"zip_file_path": kindString, "handler": kindString, "runtime": kindString},
+				update: func(ctx context.Context, plan *UpdatePlan) (interface{}, error) {
+					// The code is uploaded again only when a new zip file is given
+					return UpdateLambdaFunction(ctx, plan.Config.String("function_name"), plan.Changes.String("handler"), plan.Changes.String("runtime"), plan.Changes.String("zip_file_path"), awsRegion(plan.Config))
+				},
+				create: func(ctx context.Context, params Params) (*CreateResult, error) {
+					result, err := CreateLambdaFunction(ctx, params.String("function_name"), params.String("handler"), params.String("runtime"), params.String("zip_file_path"), params.String("region"))
+					if err != nil {
+						return nil, err
+					}
+					config := Params{
+						"function_name": params.String("function_name"),
+						"handler":       params.String("handler"),
+						"runtime":       params.String("runtime"),
+						"zip_file_path": params.String("zip_file_path"),
+						"region":        params.String("region"),
+					}
+					return &CreateResult{Config: config, Result: result}, nil
+				},
+				describe: func(ctx context.Context, config Params) (*Description, error) {
+					return DescribeLambdaFunction(ctx, config.String("function_name"), awsRegion(config))
+				},
+				delete: func(ctx context.Context, config Params) (*DeleteResult, error) {
+					result, err := DeleteLambdaFunction(ctx, config.String("function_name"), awsRegion(config))
+					if err != nil {
+						return nil, err
+					}
+					return &DeleteResult{Message: "AWS Lambda function deleted successfully", Result: result, Deleted: true}, nil
+				},
+			},
+			&service{
+				provider:        "aws",
+				name:            AWSRDS,
+				path:            "create-rds-instance",
+				identifierField: "instance_id",
+				required:        []string{"db_name", "instance_id", "instance_class", "engine", "username", "password", "allocated_storage", "region"},
+				discover:        DiscoverRDSInstances,
+				updatable:       map[string]fieldKind{"instance_class": kindString, "allocated_storage": kindInt},
+				checkUpdate: func(config, changes Params) error {
+					if _, ok := changes["allocated_storage"]; ok && changes.Int("allocated_storage") < config.Int("allocated_storage") {
+						return fmt.Errorf("allocated_storage can only be increased")
+					}
+					return nil
+				},
+				update: func(ctx context.Context, plan *UpdatePlan) (interface{}, error) {
+					return ModifyRDSInstance(ctx, plan.Config.String("instance_id"), plan.Changes.String("instance_class"), int32(plan.Changes.Int("allocated_storage")), awsRegion(plan.Config))
+				},
+				power: func(ctx context.Context, config Params, running bool) (*PowerResult, error) {
+					return SetRDSInstancePower(ctx, config.String("instance_id"), awsRegion(config), running)
+				},
+				priced:   []string{"instance_class", "engine", "allocated_storage", "region"},
+				estimate: estimateRDS,
+				create: func(ctx context.Context, params Params) (*CreateResult, error) {
+					result, err := CreateRDSInstance(ctx, params.String("db_name"), params.String("instance_id"), params.String("instance_class"), params.String("engine"), params.String("username"), params.String("password"), int32(params.Int("allocated_storage")), params.String("subnet_group_name"), params.String("region"))
+					if err != nil {
+						return nil, err
+					}
+					config := Params{
+						"db_name":           params.String("db_name"),
+						"instance_id":       params.String("instance_id"),
+						"instance_class":    params.String("instance_class"),
+						"engine":            params.String("engine"),
+						"username":          params.String("username"),
+						"password":          params.String("password"),
+						"allocated_storage": int32(params.Int("allocated_storage")),
+						"subnet_group_name": params.String("subnet_group_name"),
+						"region":            params.String("region"),
+					}
+					return &CreateResult{Config: config, Result: result}, nil
+				},
+				describe: func(ctx context.Context, config Params) (*Description, error) {
+					return DescribeRDSInstance(ctx, config.String("instance_id"), awsRegion(config))
+				},
+				delete: func(ctx context.Context, config Params) (*DeleteResult, error) {
+					result, err := DeleteRDSInstance(ctx, config.String("instance_id"), awsRegion(config))
+					if err != nil {
+						return nil, err
+					}
+					return &DeleteResult{Message: "Amazon RDS instance deleted successfully", Result: result, Deleted: true}, nil
+				},
+			},
+			&service{
+				provider:        "aws",
+				name:            AWSCloudFront,
+				path:            "create-cloudfront-distribution",
+				identifierField: "distribution_id",
+				required:        []string{"origin_domain_name", "region", "bucket_name"},
+				updatable:       map[string]fieldKind{"min_ttl": kindInt},
+				checkUpdate: func(config, changes Params) error {
+					if changes.Int("min_ttl") < 0 {
+						return fmt.Errorf("min_ttl cannot be negative")
+					}
+					return nil
+				},
+				update: func(ctx context.Context, plan *UpdatePlan) (interface{}, error) {
+					return UpdateCloudFrontMinTTL(ctx, plan.Config.String("distribution_id"), int64(plan.Config.Int("min_ttl")), awsRegion(plan.Config))
+				},
+				create: func(ctx context.Context, params Params) (*CreateResult, error) {
+					distributionResult, oaiCanonicalUserID, err := CreateCloudFrontDistribution(ctx, params.String("origin_domain_name"), params.String("comment"), params.String("region"), int64(params.Int("min_ttl")))
+					if err != nil {
+						return nil, err
+					}
+
+					// Create the S3 Bucket and attach the policy
+					_, err = CreateS3BucketWithPolicy(ctx, params.String("bucket_name"), params.String("region"), oaiCanonicalUserID)
+					if err != nil {
+						return nil, fmt.Errorf("could not create S3 bucket or attach policy: %v", err)
+					}
+
+					config := Params{
+						"origin_domain_name": params.String("origin_domain_name"),
+						"comment":            params.String("comment"),
+						"region":             params.String("region"),
+						"min_ttl":            int64(params.Int("min_ttl")),
+						"bucket_name":        params.String("bucket_name"),
+						"distribution_id":    *distributionResult.Distribution.Id,
+					}
+					return &CreateResult{Config: config, Result: distributionResult}, nil
+				},
+				describe: func(ctx context.Context, config Params) (*Description, error) {
+					return DescribeCloudFrontDistribution(ctx, config.String("distribution_id"), awsRegion(config))
+				},
+				delete: func(ctx context.Context, config Params) (*DeleteResult, error) {
+					result, err := DisableCloudFrontDistribution(ctx, config.String("distribution_id"), awsRegion(config))
+					if err != nil {
+						return nil, err
+					}
+					return &DeleteResult{Message: "AWS CloudFront distribution disabled successfully", Result: result, Deleted: true}, nil
+				},
+			},
+			&service{
+				provider:        "aws",
+				name:            AWSVPC,
+				path:            "create-vpc",
+				identifierField: "name",
+				required:        []string{"cidr_block", "region", "name"},
+				discover:        DiscoverVPCs,
+				create: func(ctx context.Context, params Params) (*CreateResult, error) {
+					result, err := CreateVPC(ctx, params.String("cidr_block"), params.String("region"), params.String("name"))
+					if err != nil {
+						return nil, err
+					}
+					if result.Vpc == nil || result.Vpc.VpcId == nil {
+						return nil, fmt.Errorf("failed to fetch VPC ID: CreateVpc returned no VPC")
+					}
+					config := Params{
+						"cidr_block": params.String("cidr_block"),
+						"region":     params.String("region"),
+						"name":       params.String("name"),
+						"vpc_id":     *result.Vpc.VpcId,
+					}
+					return &CreateResult{Config: config, Result: result}, nil
+				},
+				describe: func(ctx context.Context, config Params) (*Description, error) {
+					return DescribeVPC(ctx, config.String("name"), awsRegion(config))
+				},
+				delete: func(ctx context.Context, config Params) (*DeleteResult, error) {
+					result, status, err := DeleteVPC(ctx, config.String("name"), awsRegion(config))
+					if err != nil {
+						return nil, err
+					}
+					// DeleteVPC reports dependencies instead of failing
+					return &DeleteResult{Message: result, Result: result, Deleted: status == "deleted"}, nil
+				},
+			},
+		},
+	}
+}
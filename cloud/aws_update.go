@@ -0,0 +1,221 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// updateWaitTimeout bounds how long an update waits for a resource to settle between steps
+const updateWaitTimeout = 10 * time.Minute
+
+// ModifyEC2InstanceType changes the instance type of an EC2 instance.
+// The type can only be changed while the instance is stopped, so a running instance is stopped and started again,
+// also when the new type is rejected.
+func ModifyEC2InstanceType(ctx context.Context, instanceID, instanceType, region string) (string, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return "", err
+	}
+
+	client := ec2.NewFromConfig(cfg)
+
+	output, err := client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe EC2 instance: %w", err)
+	}
+	if len(output.Reservations) == 0 || len(output.Reservations[0].Instances) == 0 {
+		return "", fmt.Errorf("EC2 instance '%s' not found", instanceID)
+	}
+	wasRunning := output.Reservations[0].Instances[0].State.Name != ec2types.InstanceStateNameStopped
+
+	if wasRunning {
+		_, err = client.StopInstances(ctx, &ec2.StopInstancesInput{InstanceIds: []string{instanceID}})
+		if err != nil {
+			return "", fmt.Errorf("failed to stop EC2 instance: %w", err)
+		}
+		err = ec2.NewInstanceStoppedWaiter(client).Wait(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{instanceID}}, updateWaitTimeout)
+		if err != nil {
+			return "", fmt.Errorf("EC2 instance did not stop: %w", err)
+		}
+	}
+
+	_, err = client.ModifyInstanceAttribute(ctx, &ec2.ModifyInstanceAttributeInput{
+		InstanceId:   aws.String(instanceID),
+		InstanceType: &ec2types.AttributeValue{Value: aws.String(instanceType)},
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to change EC2 instance type: %w", err)
+		// A rejected type must not leave the instance stopped; restart it even if the request was cancelled
+		if wasRunning {
+			if _, startErr := client.StartInstances(context.WithoutCancel(ctx), &ec2.StartInstancesInput{InstanceIds: []string{instanceID}}); startErr != nil {
+				return "", fmt.Errorf("%w; the EC2 instance was left stopped and failed to restart: %v", err, startErr)
+			}
+		}
+		return "", err
+	}
+
+	if wasRunning {
+		_, err = client.StartInstances(ctx, &ec2.StartInstancesInput{InstanceIds: []string{instanceID}})
+		if err != nil {
+			return "", fmt.Errorf("instance type changed but the EC2 instance failed to start: %w", err)
+		}
+	}
+
+	return fmt.Sprintf("EC2 instance '%s' changed to %s", instanceID, instanceType), nil
+}
+
+// SetS3BucketVersioning enables or suspends versioning on an S3 bucket
+func SetS3BucketVersioning(ctx context.Context, bucketName string, enabled bool, region string) (string, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return "", err
+	}
+
+	client := s3.NewFromConfig(cfg)
+
+	// Versioning cannot be turned off once enabled, only suspended
+	status := s3types.BucketVersioningStatusSuspended
+	if enabled {
+		status = s3types.BucketVersioningStatusEnabled
+	}
+	_, err = client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(bucketName),
+		VersioningConfiguration: &s3types.VersioningConfiguration{Status: status},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to set S3 bucket versioning: %w", err)
+	}
+
+	return fmt.Sprintf("Versioning of S3 bucket '%s' set to %s", bucketName, status), nil
+}
+
+// UpdateLambdaFunction uploads new code from zipFilePath and changes the handler and runtime of a Lambda function.
+// Empty arguments are left unchanged.
+func UpdateLambdaFunction(ctx context.Context, functionName, handler, runtime, zipFilePath, region string) (string, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return "", err
+	}
+
+	client := lambda.NewFromConfig(cfg)
+
+	if zipFilePath != "" {
+		code, err := os.ReadFile(zipFilePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read zip file: %v", err)
+		}
+		_, err = client.UpdateFunctionCode(ctx, &lambda.UpdateFunctionCodeInput{
+			FunctionName: aws.String(functionName),
+			ZipFile:      code,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to update Lambda function code: %w", err)
+		}
+	}
+
+	if handler != "" || runtime != "" {
+		// A configuration change is rejected while the code update is still in progress
+		if zipFilePath != "" {
+			err = lambda.NewFunctionUpdatedV2Waiter(client).Wait(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(functionName)}, updateWaitTimeout)
+			if err != nil {
+				return "", fmt.Errorf("Lambda function code update did not complete: %w", err)
+			}
+		}
+
+		input := &lambda.UpdateFunctionConfigurationInput{FunctionName: aws.String(functionName)}
+		if handler != "" {
+			input.Handler = aws.String(handler)
+		}
+		if runtime != "" {
+			input.Runtime = lambdatypes.Runtime(runtime)
+		}
+		_, err = client.UpdateFunctionConfiguration(ctx, input)
+		if err != nil {
+			return "", fmt.Errorf("failed to update Lambda function configuration: %w", err)
+		}
+	}
+
+	return fmt.Sprintf("Lambda function '%s' updated successfully", functionName), nil
+}
+
+// ModifyRDSInstance changes the instance class and allocated storage (in GB) of an RDS instance immediately.
+// Empty or zero arguments are left unchanged.
+func ModifyRDSInstance(ctx context.Context, instanceID, instanceClass string, allocatedStorage int32, region string) (*rds.ModifyDBInstanceOutput, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := rds.NewFromConfig(cfg)
+
+	input := &rds.ModifyDBInstanceInput{
+		DBInstanceIdentifier: aws.String(instanceID),
+		ApplyImmediately:     aws.Bool(true),
+	}
+	if instanceClass != "" {
+		input.DBInstanceClass = aws.String(instanceClass)
+	}
+	if allocatedStorage > 0 {
+		input.AllocatedStorage = aws.Int32(allocatedStorage)
+	}
+
+	result, err := client.ModifyDBInstance(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to modify RDS instance: %w", err)
+	}
+	return result, nil
+}
+
+// UpdateCloudFrontMinTTL changes the minimum TTL of a CloudFront distribution's default cache behavior
+func UpdateCloudFrontMinTTL(ctx context.Context, distributionID string, minTTL int64, region string) (*cloudfront.UpdateDistributionOutput, error) {
+	cfg, err := LoadAWSConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := cloudfront.NewFromConfig(cfg)
+
+	current, err := client.GetDistributionConfig(ctx, &cloudfront.GetDistributionConfigInput{
+		Id: aws.String(distributionID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CloudFront distribution config: %w", err)
+	}
+
+	distributionConfig := current.DistributionConfig
+	behavior := distributionConfig.DefaultCacheBehavior
+	behavior.MinTTL = aws.Int64(minTTL)
+
+	// The default and maximum TTLs cannot be lower than the minimum
+	if behavior.DefaultTTL != nil && *behavior.DefaultTTL < minTTL {
+		behavior.DefaultTTL = aws.Int64(minTTL)
+	}
+	if behavior.MaxTTL != nil && *behavior.MaxTTL < minTTL {
+		behavior.MaxTTL = aws.Int64(minTTL)
+	}
+
+	result, err := client.UpdateDistribution(ctx, &cloudfront.UpdateDistributionInput{
+		Id:                 aws.String(distributionID),
+		IfMatch:            current.ETag,
+		DistributionConfig: distributionConfig,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update CloudFront distribution: %w", err)
+	}
+	return result, nil
+}
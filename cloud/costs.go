@@ -0,0 +1,62 @@
+package cloud
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CostResource is a tracked resource whose actual cost is collected
+type CostResource struct {
+	ServiceID string // hex _id of the services record, also the value of its mt-service-id tag
+	Service   string // catalog name
+	Config    Params
+}
+
+// DailyCost is the actual cost of one resource on one day, as billed by the provider
+type DailyCost struct {
+	ServiceID  string
+	ResourceID string    // provider ID the cost was matched by; empty when matched by tag
+	Date       time.Time // midnight UTC
+	Amount     float64
+	Currency   string
+}
+
+// CostSource reads the actual daily cost of resources from a provider's billing data
+type CostSource interface {
+	Name() string // stored with every cost point, e.g. "aws-cost-explorer"
+	// DailyCosts returns the cost of each resource on every day from start up to end, both midnight UTC.
+	// Costs found before a failure are returned with the error.
+	DailyCosts(ctx context.Context, resources []CostResource, start, end time.Time) ([]DailyCost, error)
+}
+
+var (
+	costSourcesMu sync.RWMutex
+	costSources   = map[string]CostSource{
+		"aws": awsCostSource{},
+	}
+)
+
+// UseCostSource replaces the billing data source of a provider; a nil source stops collecting its costs
+func UseCostSource(provider string, source CostSource) {
+	costSourcesMu.Lock()
+	defer costSourcesMu.Unlock()
+	if source == nil {
+		delete(costSources, provider)
+		return
+	}
+	costSources[provider] = source
+}
+
+// GetCostSource returns the billing data source of a provider
+func GetCostSource(provider string) (CostSource, bool) {
+	costSourcesMu.RLock()
+	defer costSourcesMu.RUnlock()
+	source, ok := costSources[provider]
+	return source, ok
+}
+
+// costDay truncates a billing date such as "2024-06-01" to midnight UTC
+func costDay(date string) (time.Time, error) {
+	return time.Parse("2006-01-02", date)
+}
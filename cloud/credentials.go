@@ -0,0 +1,207 @@
+package cloud
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"multitenant/secrets"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+// Tenant is the cloud identity an organization or group provisions its resources with.
+// Calls made without a tenant in their context use the process's default credentials.
+type Tenant struct {
+	Key               string            // identifies the tenant in the credential cache, e.g. "group:<group_id>"
+	AWSRoleARN        string            // role assumed for every AWS call
+	AWSExternalID     string            // external ID required by the role's trust policy
+	GCPProjectID      string            // project resources are created in
+	GCPServiceAccount string            // service account impersonated for every GCP call
+	AWSRegions        []string          // AWS regions the tenant may provision in, empty for any
+	GCPRegions        []string          // GCP regions the tenant may provision in, empty for any
+	Tags              map[string]string // custom tags applied to every resource besides the standard ones
+}
+
+type tenantContextKey struct{}
+
+// WithTenant returns a copy of ctx whose cloud calls run under the tenant's credentials
+func WithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant stored in ctx, or nil
+func TenantFromContext(ctx context.Context) *Tenant {
+	tenant, _ := ctx.Value(tenantContextKey{}).(*Tenant)
+	return tenant
+}
+
+var (
+	credentialsMu   sync.Mutex
+	awsCredentials  = map[string]*aws.CredentialsCache{} // assumed role credentials per tenant
+	gcpTokenSources = map[string]oauth2.TokenSource{}    // impersonated tokens per tenant
+)
+
+// roleSessionNameInvalid matches characters STS does not accept in a role session name
+var roleSessionNameInvalid = regexp.MustCompile(`[^\w+=,.@-]`)
+
+// LoadAWSConfig loads the AWS configuration, assuming the role of the tenant in ctx if there is one
+func LoadAWSConfig(ctx context.Context, optFns ...func(*config.LoadOptions) error) (aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if _, err := serverSecret(ctx, secrets.AWSCredentials); err == nil {
+		cfg.Credentials = secretCredentials{}
+	} else if !errors.Is(err, secrets.ErrNotFound) {
+		return aws.Config{}, err
+	}
+
+	tenant := TenantFromContext(ctx)
+	if tenant == nil || tenant.AWSRoleARN == "" {
+		return cfg, nil
+	}
+
+	cfg.Credentials = assumeRoleCredentials(cfg, tenant)
+	return cfg, nil
+}
+
+// serverSecret returns one of the server's own credentials, or ErrNotFound when they come from the environment
+func serverSecret(ctx context.Context, name string) (string, error) {
+	if secretStore == nil {
+		return "", secrets.ErrNotFound
+	}
+	return secretStore.Get(ctx, name)
+}
+
+// secretCredentials provides the access key of the aws_credentials secret, a JSON object with access_key_id,
+// secret_access_key and an optional session_token. The secret is read on every retrieval, so a rotated key is
+// used as soon as the secrets cache refreshes it.
+type secretCredentials struct{}
+
+func (secretCredentials) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	value, err := serverSecret(ctx, secrets.AWSCredentials)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	var key struct {
+		AccessKeyID     string `json:"access_key_id"`
+		SecretAccessKey string `json:"secret_access_key"`
+		SessionToken    string `json:"session_token"`
+	}
+	if err := json.Unmarshal([]byte(value), &key); err != nil || key.AccessKeyID == "" || key.SecretAccessKey == "" {
+		return aws.Credentials{}, errors.New("aws_credentials secret must be a JSON object with access_key_id and secret_access_key")
+	}
+	return aws.Credentials{
+		AccessKeyID:     key.AccessKeyID,
+		SecretAccessKey: key.SecretAccessKey,
+		SessionToken:    key.SessionToken,
+		Source:          "secrets:" + secrets.AWSCredentials,
+	}, nil
+}
+
+// assumeRoleCredentials returns the cached credentials for the tenant's role.
+// The cache refreshes them through STS shortly before they expire.
+func assumeRoleCredentials(base aws.Config, tenant *Tenant) *aws.CredentialsCache {
+	key := tenant.Key + "|" + tenant.AWSRoleARN + "|" + tenant.AWSExternalID
+
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+
+	if cached, ok := awsCredentials[key]; ok {
+		return cached
+	}
+
+	sessionName := roleSessionNameInvalid.ReplaceAllString("multitenant-"+tenant.Key, "-")
+	if len(sessionName) > 64 {
+		sessionName = sessionName[:64]
+	}
+
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(base), tenant.AWSRoleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = sessionName
+		if tenant.AWSExternalID != "" {
+			o.ExternalID = aws.String(tenant.AWSExternalID)
+		}
+	})
+	cache := aws.NewCredentialsCache(provider)
+	awsCredentials[key] = cache
+	return cache
+}
+
+// gcpClientOptions returns the client options impersonating the service account of the tenant in ctx. Without a
+// tenant, calls use the service account key of the gcp_credentials secret, or the default credentials.
+func gcpClientOptions(ctx context.Context) ([]option.ClientOption, error) {
+	var base []option.ClientOption
+	key := ""
+	serviceAccountKey, err := serverSecret(ctx, secrets.GCPCredentials)
+	if err == nil {
+		base = []option.ClientOption{option.WithCredentialsJSON([]byte(serviceAccountKey))}
+		// A rotated key impersonates through new token sources
+		sum := sha256.Sum256([]byte(serviceAccountKey))
+		key = "|" + hex.EncodeToString(sum[:8])
+	} else if !errors.Is(err, secrets.ErrNotFound) {
+		return nil, err
+	}
+
+	tenant := TenantFromContext(ctx)
+	if tenant == nil || tenant.GCPServiceAccount == "" {
+		return base, nil
+	}
+
+	key = tenant.Key + "|" + tenant.GCPServiceAccount + key
+
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+
+	tokenSource, ok := gcpTokenSources[key]
+	if !ok {
+		// The token source outlives the request, so it must not be bound to its context
+		var err error
+		tokenSource, err = impersonate.CredentialsTokenSource(context.Background(), impersonate.CredentialsConfig{
+			TargetPrincipal: tenant.GCPServiceAccount,
+			Scopes:          []string{"https://www.googleapis.com/auth/cloud-platform"},
+		}, base...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to impersonate service account %s: %v", tenant.GCPServiceAccount, err)
+		}
+		gcpTokenSources[key] = tokenSource
+	}
+
+	return []option.ClientOption{option.WithTokenSource(tokenSource)}, nil
+}
+
+// ProjectID returns the GCP project of the tenant in ctx, falling back to FetchProjectID
+func ProjectID(ctx context.Context) (string, error) {
+	if tenant := TenantFromContext(ctx); tenant != nil && tenant.GCPProjectID != "" {
+		return tenant.GCPProjectID, nil
+	}
+	return FetchProjectID()
+}
+
+// InvalidateTenant drops the cached credentials of a tenant so the next call picks up its new settings
+func InvalidateTenant(key string) {
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+
+	for cached := range awsCredentials {
+		if strings.HasPrefix(cached, key+"|") {
+			delete(awsCredentials, cached)
+		}
+	}
+	for cached := range gcpTokenSources {
+		if strings.HasPrefix(cached, key+"|") {
+			delete(gcpTokenSources, cached)
+		}
+	}
+}
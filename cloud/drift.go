@@ -0,0 +1,53 @@
+package cloud
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kinds of drift between a services record and the resource it tracks
+const (
+	DriftMissing    = "missing"    // the record is active but the resource is gone
+	DriftModified   = "modified"   // the resource no longer matches the stored config
+	DriftUnexpected = "unexpected" // the record is deleted but the resource still exists
+)
+
+// stoppedStates are the provider states of a resource that exists but is not running, lowercased
+var stoppedStates = map[string]bool{
+	"stopped":    true, // EC2, RDS
+	"stopping":   true,
+	"terminated": true, // Compute Engine reports stopped instances as TERMINATED
+	"suspended":  true,
+	"suspending": true,
+}
+
+// LiveStatus maps a description to the service_status it implies: "missing", "stopped" or "running"
+func LiveStatus(d *Description) string {
+	if !d.Exists {
+		return "missing"
+	}
+	if stoppedStates[strings.ToLower(d.State)] {
+		return "stopped"
+	}
+	return "running"
+}
+
+// ConfigDrift lists the attributes of a live resource that differ from the stored config.
+// Only fields present in both are compared, so attributes a describe call does not report are never flagged.
+func ConfigDrift(config, attributes Params) []string {
+	var differences []string
+	for key, live := range attributes {
+		stored, ok := config[key]
+		if !ok || stored == nil || live == nil {
+			continue
+		}
+		expected, found := fmt.Sprint(stored), fmt.Sprint(live)
+		if expected == "" || strings.EqualFold(expected, found) {
+			continue
+		}
+		differences = append(differences, fmt.Sprintf("%s: expected %s, found %s", key, expected, found))
+	}
+	sort.Strings(differences)
+	return differences
+}
@@ -0,0 +1,453 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"multitenant/models"
+	"strconv"
+	"strings"
+
+	billing "cloud.google.com/go/billing/apiv1"
+	"cloud.google.com/go/billing/apiv1/billingpb"
+	"github.com/aws/aws-sdk-go-v2/service/pricing/types"
+	"google.golang.org/api/iterator"
+)
+
+// ExpectedHoursParam is the estimate input giving how many hours of the quarter a service is expected to run.
+// It is not part of any service's config; the full quarter is priced when it is missing.
+const ExpectedHoursParam = "expected_hours"
+
+// Defaults priced when a config leaves a field out, matching what the create calls would use
+const (
+	defaultEC2InstanceType = "t2.micro"
+	defaultRDSInstanceType = "db.t3.micro"
+	defaultRDSEngine       = "mysql"
+	defaultRDSStorageGB    = 20
+	defaultStorageGB       = 1024 // buckets are priced as holding 1TB
+	defaultGCPRegion       = "us-central1"
+	defaultGCEMachineType  = "e2-medium"
+	defaultSQLTier         = "db-n1-standard-1"
+	defaultSQLVersion      = "MYSQL_8_0"
+	defaultSQLDiskGB       = 10
+
+	// gkeClusterFeeHourly is the GKE management fee charged per cluster
+	gkeClusterFeeHourly = 0.10
+)
+
+// rdsEngines maps RDS engine names to the databaseEngine values of the AWS price list
+var rdsEngines = map[string]string{
+	"mysql":    "MySQL",
+	"postgres": "PostgreSQL",
+	"mariadb":  "MariaDB",
+}
+
+// cloudSQLEngines maps database_version prefixes to the engine names in Cloud SQL SKU descriptions
+var cloudSQLEngines = map[string]string{
+	"MYSQL":     "MySQL",
+	"POSTGRES":  "PostgreSQL",
+	"SQLSERVER": "SQL Server",
+}
+
+// pricedHours returns the hours of the quarter a service is priced for
+func pricedHours(params Params) float64 {
+	hours := params.Float(ExpectedHoursParam)
+	if hours <= 0 || hours > hoursPerQuarter {
+		return hoursPerQuarter
+	}
+	return hours
+}
+
+// quarterlyCost prices a USD/hour rate for the hours a service is expected to run this quarter
+func quarterlyCost(pricePerHour float64, params Params) float64 {
+	return roundCost(pricePerHour * pricedHours(params))
+}
+
+// quarterlyStorageCost prices a USD/GB/month rate for a quarter
+func quarterlyStorageCost(pricePerGBMonth, gb float64) float64 {
+	return roundCost(pricePerGBMonth * gb * 3)
+}
+
+// stringOr returns the string under key, or fallback when it is missing
+func stringOr(params Params, key, fallback string) string {
+	if value := params.String(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// floatOr returns the number under key, or fallback when it is missing or not positive
+func floatOr(params Params, key string, fallback float64) float64 {
+	if value := params.Float(key); value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// AWS price list keys; regionCode selects the region
+func ec2InstanceKey(instanceType, region string) PriceKey {
+	return PriceKey{Provider: "aws", Service: "AmazonEC2", Region: region, Attributes: map[string]string{
+		"instanceType":    instanceType,
+		"operatingSystem": "Linux",
+		"tenancy":         "Shared",
+		"preInstalledSw":  "NA",
+		"capacitystatus":  "Used",
+	}}
+}
+
+func s3StorageKey(region string) PriceKey {
+	return PriceKey{Provider: "aws", Service: "AmazonS3", Region: region, Attributes: map[string]string{
+		"productFamily": "Storage",
+		"storageClass":  "General Purpose",
+	}}
+}
+
+func rdsInstanceKey(instanceClass, databaseEngine, region string) PriceKey {
+	return PriceKey{Provider: "aws", Service: "AmazonRDS", Region: region, Attributes: map[string]string{
+		"instanceType":     instanceClass,
+		"databaseEngine":   databaseEngine,
+		"deploymentOption": "Single-AZ",
+	}}
+}
+
+func rdsStorageKey(databaseEngine, region string) PriceKey {
+	return PriceKey{Provider: "aws", Service: "AmazonRDS", Region: region, Attributes: map[string]string{
+		"productFamily":    "Database Storage",
+		"volumeType":       "General Purpose",
+		"databaseEngine":   databaseEngine,
+		"deploymentOption": "Single-AZ",
+	}}
+}
+
+// fetchAWSPrice fetches the on-demand price of a key from the AWS Pricing API
+func fetchAWSPrice(key PriceKey) (models.CatalogPrice, error) {
+	filters := []types.Filter{termMatch("regionCode", key.Region)}
+	for field, value := range key.Attributes {
+		filters = append(filters, termMatch(field, value))
+	}
+	price, unit, err := fetchAWSServicePrice(key.Service, filters)
+	if err != nil {
+		return models.CatalogPrice{}, err
+	}
+	return key.catalogPrice(price, unit, "aws-pricing-api"), nil
+}
+
+// estimateEC2 prices a Linux on-demand instance of the requested type in the requested region
+func estimateEC2(params Params) (float64, error) {
+	prices, err := lookupPrices(ec2InstanceKey(stringOr(params, "instance_type", defaultEC2InstanceType), awsRegion(params)))
+	if err != nil {
+		return 0, err
+	}
+	return quarterlyCost(prices[0], params), nil
+}
+
+// estimateS3 prices Standard storage of storage_gb in the bucket's region
+func estimateS3(params Params) (float64, error) {
+	prices, err := lookupPrices(s3StorageKey(awsRegion(params)))
+	if err != nil {
+		return 0, err
+	}
+	return quarterlyStorageCost(prices[0], floatOr(params, "storage_gb", defaultStorageGB)), nil
+}
+
+// estimateRDS prices a Single-AZ instance of the requested class and engine plus its General Purpose storage
+func estimateRDS(params Params) (float64, error) {
+	engine := stringOr(params, "engine", defaultRDSEngine)
+	databaseEngine, ok := rdsEngines[strings.ToLower(engine)]
+	if !ok {
+		return 0, fmt.Errorf("no pricing for RDS engine '%s'", engine)
+	}
+	region := awsRegion(params)
+
+	prices, err := lookupPrices(
+		rdsInstanceKey(stringOr(params, "instance_class", defaultRDSInstanceType), databaseEngine, region),
+		rdsStorageKey(databaseEngine, region),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return roundCost(quarterlyCost(prices[0], params) + quarterlyStorageCost(prices[1], floatOr(params, "allocated_storage", defaultRDSStorageGB))), nil
+}
+
+// gcpSKU selects a Cloud Catalog SKU by description and usage unit
+type gcpSKU struct {
+	match func(description string) bool
+	unit  string // e.g. "h", "GiBy.h" or "GiBy.mo"
+}
+
+// GCP catalog keys. The attributes name the kind of SKU and what selects it; gcpSKUFor turns them into a matcher.
+func gceKey(family, resource, region string) PriceKey {
+	return PriceKey{Provider: "gcp", Service: GCPComputeEngine, Region: region, Attributes: map[string]string{
+		"sku": "instance", "family": family, "resource": resource,
+	}}
+}
+
+func cloudSQLKey(engine, resource, region string) PriceKey {
+	return PriceKey{Provider: "gcp", Service: GCPCloudSQL, Region: region, Attributes: map[string]string{
+		"sku": "cloudsql", "engine": engine, "resource": resource,
+	}}
+}
+
+func gcsStorageKey(region string) PriceKey {
+	return PriceKey{Provider: "gcp", Service: GCPCloudStorage, Region: strings.ToLower(region), Attributes: map[string]string{
+		"sku": "storage", "class": "Standard",
+	}}
+}
+
+// gcpSKUFor returns the matcher of a GCP catalog key
+func gcpSKUFor(key PriceKey) (gcpSKU, error) {
+	attributes := key.Attributes
+	resource := attributes["resource"]
+	units := map[string]string{"Core": "h", "Ram": "GiBy.h", "vCPU": "h", "RAM": "GiBy.h", "Standard storage": "GiBy.mo"}
+
+	switch attributes["sku"] {
+	case "instance":
+		return gcpSKU{match: onDemandInstanceSKU(attributes["family"], resource), unit: units[resource]}, nil
+	case "cloudsql":
+		prefix := "Cloud SQL for " + attributes["engine"] + ": Zonal - " + resource
+		return gcpSKU{match: func(d string) bool { return strings.HasPrefix(d, prefix) }, unit: units[resource]}, nil
+	case "storage":
+		prefix := attributes["class"] + " Storage"
+		return gcpSKU{match: func(d string) bool { return strings.HasPrefix(d, prefix) }, unit: "GiBy.mo"}, nil
+	}
+	return gcpSKU{}, fmt.Errorf("unknown GCP price key %s", key)
+}
+
+// fetchGCPSKUPrices fetches the USD unit prices of keys of one catalog service, in one pass over its SKUs
+func fetchGCPSKUPrices(serviceName string, keys []PriceKey) ([]models.CatalogPrice, error) {
+	skus := make([]gcpSKU, len(keys))
+	for i, key := range keys {
+		sku, err := gcpSKUFor(key)
+		if err != nil {
+			return nil, err
+		}
+		skus[i] = sku
+	}
+
+	ctx := context.Background()
+	client, err := billing.NewCloudCatalogClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Catalog client: %v", err)
+	}
+	defer client.Close()
+
+	parent := ""
+	services := client.ListServices(ctx, &billingpb.ListServicesRequest{})
+	for {
+		svc, err := services.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error while iterating services: %v", err)
+		}
+		if svc.DisplayName == serviceName {
+			parent = svc.Name
+			break
+		}
+	}
+	if parent == "" {
+		return nil, fmt.Errorf("GCP service %s not found in the Cloud Catalog", serviceName)
+	}
+
+	prices := make([]models.CatalogPrice, len(keys))
+	found := make([]bool, len(keys))
+	remaining := len(keys)
+	it := client.ListSkus(ctx, &billingpb.ListSkusRequest{Parent: parent})
+	for remaining > 0 {
+		sku, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error while iterating SKUs: %v", err)
+		}
+		if len(sku.PricingInfo) == 0 {
+			continue
+		}
+		expression := sku.PricingInfo[0].PricingExpression
+		for i, want := range skus {
+			if found[i] || expression.UsageUnit != want.unit || !skuInRegion(sku, keys[i].Region) || !want.match(sku.Description) {
+				continue
+			}
+			// The first tier is often a free allowance; price at the first paid tier
+			for _, rate := range expression.TieredRates {
+				price := float64(rate.UnitPrice.Units) + float64(rate.UnitPrice.Nanos)/1e9
+				if price > 0 {
+					prices[i], found[i] = keys[i].catalogPrice(price, want.unit, "gcp-cloud-catalog"), true
+					remaining--
+					break
+				}
+			}
+		}
+	}
+	for i := range keys {
+		if !found[i] {
+			return nil, fmt.Errorf("pricing data not found for %s", keys[i])
+		}
+	}
+	return prices, nil
+}
+
+func skuInRegion(sku *billingpb.Sku, region string) bool {
+	for _, r := range sku.ServiceRegions {
+		if strings.EqualFold(r, region) {
+			return true
+		}
+	}
+	return false
+}
+
+// gcpLocation returns the region of a GCP config, deriving it from the zone when needed
+func gcpLocation(params Params) string {
+	if region := params.String("region"); region != "" {
+		return region
+	}
+	if match := gcpZonePattern.FindStringSubmatch(params.String("zone")); match != nil {
+		return match[1]
+	}
+	return defaultGCPRegion
+}
+
+// machineShape is the family, vCPUs and memory of a machine type
+type machineShape struct {
+	family   string // e.g. "N1", "E2", "N2D"
+	vcpus    float64
+	memoryGB float64
+}
+
+// gcpSharedCoreShapes are the shared-core E2 types, billed as a fraction of a vCPU
+var gcpSharedCoreShapes = map[string]machineShape{
+	"e2-micro":  {family: "E2", vcpus: 0.25, memoryGB: 1},
+	"e2-small":  {family: "E2", vcpus: 0.5, memoryGB: 2},
+	"e2-medium": {family: "E2", vcpus: 1, memoryGB: 4},
+}
+
+// parseMachineType reads the shape of predefined ("n2-standard-4") and custom ("n1-custom-4-16384") machine types
+func parseMachineType(machineType string) (machineShape, error) {
+	if shape, ok := gcpSharedCoreShapes[machineType]; ok {
+		return shape, nil
+	}
+
+	parts := strings.Split(machineType, "-")
+	if len(parts) < 3 {
+		return machineShape{}, fmt.Errorf("no pricing for machine type '%s'", machineType)
+	}
+	family := strings.ToUpper(parts[0])
+	vcpus, err := strconv.Atoi(parts[2])
+	if err != nil || vcpus <= 0 {
+		return machineShape{}, fmt.Errorf("no pricing for machine type '%s'", machineType)
+	}
+
+	if parts[1] == "custom" && len(parts) == 4 {
+		memoryMB, err := strconv.Atoi(parts[3])
+		if err != nil || memoryMB <= 0 {
+			return machineShape{}, fmt.Errorf("no pricing for machine type '%s'", machineType)
+		}
+		return machineShape{family: family, vcpus: float64(vcpus), memoryGB: float64(memoryMB) / 1024}, nil
+	}
+
+	// GB of memory per vCPU; N1 sizes its classes differently from the later families
+	perVCPU := map[string]float64{"standard": 4, "highmem": 8, "highcpu": 1}
+	if family == "N1" {
+		perVCPU = map[string]float64{"standard": 3.75, "highmem": 6.5, "highcpu": 0.9}
+	}
+	memory, ok := perVCPU[parts[1]]
+	if !ok || len(parts) != 3 {
+		return machineShape{}, fmt.Errorf("no pricing for machine type '%s'", machineType)
+	}
+	return machineShape{family: family, vcpus: float64(vcpus), memoryGB: memory * float64(vcpus)}, nil
+}
+
+// onDemandInstanceSKU matches the on-demand core or RAM SKU of a machine family, e.g. "N1 Predefined Instance Core running in Americas"
+func onDemandInstanceSKU(family, resource string) func(string) bool {
+	return func(description string) bool {
+		if !strings.HasPrefix(description, family+" ") || !strings.Contains(description, "Instance "+resource) {
+			return false
+		}
+		for _, other := range []string{"Preemptible", "Spot", "Commitment", "Custom", "Sole Tenancy", "Extended"} {
+			if strings.Contains(description, other) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// gceHourlyPrice returns the on-demand USD/hour price of a machine type in a region
+func gceHourlyPrice(machineType, region string) (float64, error) {
+	shape, err := parseMachineType(machineType)
+	if err != nil {
+		return 0, err
+	}
+	prices, err := lookupPrices(gceKey(shape.family, "Core", region), gceKey(shape.family, "Ram", region))
+	if err != nil {
+		return 0, err
+	}
+	return shape.vcpus*prices[0] + shape.memoryGB*prices[1], nil
+}
+
+// estimateComputeEngine prices a VM of the requested machine type in its region
+func estimateComputeEngine(params Params) (float64, error) {
+	hourly, err := gceHourlyPrice(stringOr(params, "machine_type", defaultGCEMachineType), gcpLocation(params))
+	if err != nil {
+		return 0, err
+	}
+	return quarterlyCost(hourly, params), nil
+}
+
+// estimateGKE prices node_count nodes of the requested machine type plus the cluster management fee
+func estimateGKE(params Params) (float64, error) {
+	hourly, err := gceHourlyPrice(stringOr(params, "machine_type", defaultGCEMachineType), gcpLocation(params))
+	if err != nil {
+		return 0, err
+	}
+	nodes := floatOr(params, "node_count", 1)
+	return quarterlyCost(hourly*nodes+gkeClusterFeeHourly, params), nil
+}
+
+// estimateCloudStorage prices Standard storage of storage_gb in the bucket's location
+func estimateCloudStorage(params Params) (float64, error) {
+	prices, err := lookupPrices(gcsStorageKey(gcpLocation(params)))
+	if err != nil {
+		return 0, err
+	}
+	return quarterlyStorageCost(prices[0], floatOr(params, "storage_gb", defaultStorageGB)), nil
+}
+
+// parseSQLTier reads the vCPUs and memory of a Cloud SQL tier such as "db-n1-standard-2" or "db-custom-2-7680"
+func parseSQLTier(tier string) (machineShape, error) {
+	machineType := strings.TrimPrefix(tier, "db-")
+	if strings.HasPrefix(machineType, "custom-") {
+		machineType = "n1-" + machineType
+	}
+	shape, err := parseMachineType(machineType)
+	if err != nil || machineType == tier {
+		return machineShape{}, fmt.Errorf("no pricing for Cloud SQL tier '%s'", tier)
+	}
+	return shape, nil
+}
+
+// estimateCloudSQL prices the vCPUs, memory and SSD storage of a zonal instance for its engine
+func estimateCloudSQL(params Params) (float64, error) {
+	shape, err := parseSQLTier(stringOr(params, "tier", defaultSQLTier))
+	if err != nil {
+		return 0, err
+	}
+	version := stringOr(params, "database_version", defaultSQLVersion)
+	engine, ok := cloudSQLEngines[strings.SplitN(version, "_", 2)[0]]
+	if !ok {
+		return 0, fmt.Errorf("no pricing for Cloud SQL version '%s'", version)
+	}
+
+	region := gcpLocation(params)
+	prices, err := lookupPrices(
+		cloudSQLKey(engine, "vCPU", region),
+		cloudSQLKey(engine, "RAM", region),
+		cloudSQLKey(engine, "Standard storage", region),
+	)
+	if err != nil {
+		return 0, err
+	}
+	hourly := shape.vcpus*prices[0] + shape.memoryGB*prices[1]
+	return roundCost(quarterlyCost(hourly, params) + quarterlyStorageCost(prices[2], floatOr(params, "disk_size_gb", defaultSQLDiskGB))), nil
+}
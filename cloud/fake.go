@@ -0,0 +1,438 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrFakeFailure is returned by fake operations that were chosen to fail
+var ErrFakeFailure = errors.New("simulated cloud failure")
+
+// FakeOptions controls the behaviour of the in-memory providers
+type FakeOptions struct {
+	Latency     time.Duration // delay added to every create, describe and delete
+	FailureRate float64       // probability in [0, 1] that an operation fails with ErrFakeFailure
+	Seed        int64         // seed for IDs and failures so runs are reproducible
+}
+
+// fakeOptions are the FAKE_CLOUD_LATENCY, FAKE_CLOUD_FAILURE_RATE and FAKE_CLOUD_SEED settings
+func fakeOptions() FakeOptions {
+	return FakeOptions{
+		Latency:     settings.FakeLatency,
+		FailureRate: settings.FakeFailureRate,
+		Seed:        settings.FakeSeed,
+	}
+}
+
+// FakeResource is a resource held by the fake cloud
+type FakeResource struct {
+	Provider  string
+	Service   string
+	ID        string
+	State     string
+	Config    Params
+	Tags      Tags // tags or labels applied on create
+	CreatedAt time.Time
+}
+
+// fakeIDField describes an identifier the real provider assigns on create
+type fakeIDField struct {
+	field  string
+	prefix string
+}
+
+// fakeAssignedIDs lists the config fields filled in by the provider rather than the user
+var fakeAssignedIDs = map[string]fakeIDField{
+	AWSEC2:        {field: "instance_id", prefix: "i-"},
+	AWSCloudFront: {field: "distribution_id", prefix: "E"},
+	AWSVPC:        {field: "vpc_id", prefix: "vpc-"},
+}
+
+// fakeHourlyPrices are the list prices used for estimates, per hour or per GB-month for storage
+var fakeHourlyPrices = map[string]float64{
+	AWSEC2:           0.0116,
+	AWSS3:            0.023,
+	AWSLambda:        0.0020,
+	AWSRDS:           0.017,
+	AWSCloudFront:    0.0085,
+	AWSVPC:           0.005,
+	GCPComputeEngine: 0.0104,
+	GCPCloudStorage:  0.020,
+	GCPGKE:           0.10,
+	GCPBigQuery:      0.02,
+	GCPCloudSQL:      0.0105,
+}
+
+// fakeStorageServices are priced per GB-month instead of per hour
+var fakeStorageServices = map[string]bool{
+	AWSS3:           true,
+	GCPCloudStorage: true,
+	GCPBigQuery:     true,
+}
+
+// FakeCloud is an in-memory stand-in for AWS and GCP.
+// It mirrors the catalog of the real providers so routes, validation and stored configs stay identical.
+type FakeCloud struct {
+	opts FakeOptions
+
+	mu        sync.Mutex
+	rng       *rand.Rand
+	sequence  int
+	resources map[string]*FakeResource // keyed by provider/service/identifier
+}
+
+// NewFakeCloud returns an empty fake cloud
+func NewFakeCloud(opts FakeOptions) *FakeCloud {
+	return &FakeCloud{
+		opts:      opts,
+		rng:       rand.New(rand.NewSource(opts.Seed)),
+		resources: map[string]*FakeResource{},
+	}
+}
+
+// UseFakeCloud replaces the registered AWS and GCP providers with in-memory fakes
+func UseFakeCloud(opts FakeOptions) *FakeCloud {
+	fake := NewFakeCloud(opts)
+	RegisterProvider(fake.Provider(NewAWSProvider()))
+	RegisterProvider(fake.Provider(NewGCPProvider()))
+	return fake
+}
+
+// Provider returns a fake copy of a real provider's catalog backed by this cloud
+func (f *FakeCloud) Provider(real Provider) Provider {
+	services := make([]ServiceType, 0, len(real.Services()))
+	for _, svc := range real.Services() {
+		services = append(services, f.service(svc))
+	}
+	return &provider{name: real.Name(), services: services}
+}
+
+// Resources returns a snapshot of every resource currently held by the fake cloud
+func (f *FakeCloud) Resources() []FakeResource {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	list := make([]FakeResource, 0, len(f.resources))
+	for _, resource := range f.resources {
+		list = append(list, *resource)
+	}
+	return list
+}
+
+// Reset removes every resource and restarts the ID and failure sequence
+func (f *FakeCloud) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rng = rand.New(rand.NewSource(f.opts.Seed))
+	f.sequence = 0
+	f.resources = map[string]*FakeResource{}
+}
+
+// Add places a resource in the fake cloud without going through Create, as if it had been created outside the platform.
+// The config must hold the service's identifier field.
+func (f *FakeCloud) Add(providerName, service string, config Params) error {
+	svc, err := LookupService(providerName, service)
+	if err != nil {
+		return err
+	}
+	identifier := config.String(svc.IdentifierField())
+	if identifier == "" {
+		return fmt.Errorf("config has no %s", svc.IdentifierField())
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if assigned, ok := fakeAssignedIDs[service]; ok && config.String(assigned.field) == "" {
+		f.sequence++
+		config[assigned.field] = fmt.Sprintf("%s%08x%04x", assigned.prefix, f.rng.Uint32(), f.sequence)
+	}
+	f.resources[fakeKey(providerName, service, identifier)] = &FakeResource{
+		Provider:  providerName,
+		Service:   service,
+		ID:        identifier,
+		State:     "running",
+		Config:    config,
+		CreatedAt: time.Now(),
+	}
+	return nil
+}
+
+func fakeKey(provider, service, identifier string) string {
+	return provider + "/" + service + "/" + identifier
+}
+
+// simulate waits for the configured latency and decides whether the operation fails
+func (f *FakeCloud) simulate(ctx context.Context, params Params) error {
+	if f.opts.Latency > 0 {
+		select {
+		case <-time.After(f.opts.Latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// Callers can force a failure to exercise error paths in the UI
+	if params.Bool("simulate_failure") {
+		return ErrFakeFailure
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.opts.FailureRate > 0 && f.rng.Float64() < f.opts.FailureRate {
+		return ErrFakeFailure
+	}
+	return nil
+}
+
+// service wraps a real service type, keeping its metadata and validation
+func (f *FakeCloud) service(real ServiceType) ServiceType {
+	providerName, name, identifierField := real.Provider(), real.Name(), real.IdentifierField()
+
+	return &fakeService{
+		ServiceType: real,
+		estimate: func(params Params) (float64, error) {
+			price, ok := fakeHourlyPrices[name]
+			if !ok {
+				return 0, ErrCostNotSupported
+			}
+			if fakeStorageServices[name] {
+				return quarterlyStorageCost(price, floatOr(params, "storage_gb", defaultStorageGB)), nil
+			}
+			return quarterlyCost(price*floatOr(params, "node_count", 1), params), nil
+		},
+		create: func(ctx context.Context, params Params) (*CreateResult, error) {
+			if err := f.simulate(ctx, params); err != nil {
+				return nil, fmt.Errorf("failed to create %s: %w", name, err)
+			}
+
+			config := Params{}
+			for key, value := range params {
+				if key != "simulate_failure" {
+					config[key] = value
+				}
+			}
+
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			if assigned, ok := fakeAssignedIDs[name]; ok {
+				f.sequence++
+				config[assigned.field] = fmt.Sprintf("%s%08x%04x", assigned.prefix, f.rng.Uint32(), f.sequence)
+			}
+
+			identifier := config.String(identifierField)
+			key := fakeKey(providerName, name, identifier)
+			if _, exists := f.resources[key]; exists {
+				return nil, fmt.Errorf("%s '%s' already exists", name, identifier)
+			}
+			f.resources[key] = &FakeResource{
+				Provider:  providerName,
+				Service:   name,
+				ID:        identifier,
+				State:     "running",
+				Config:    config,
+				Tags:      TagsFromContext(ctx),
+				CreatedAt: time.Now(),
+			}
+
+			return &CreateResult{
+				Config: config,
+				Result: map[string]interface{}{"id": identifier, "state": "running", "fake": true},
+			}, nil
+		},
+		describe: func(ctx context.Context, config Params) (*Description, error) {
+			if err := f.simulate(ctx, nil); err != nil {
+				return nil, fmt.Errorf("failed to describe %s: %w", name, err)
+			}
+
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			resource, ok := f.resources[fakeKey(providerName, name, config.String(identifierField))]
+			if !ok {
+				return &Description{Exists: false}, nil
+			}
+
+			attributes := Params{}
+			for key, value := range resource.Config {
+				attributes[key] = value
+			}
+			return &Description{Exists: true, State: resource.State, Attributes: attributes}, nil
+		},
+		delete: func(ctx context.Context, config Params) (*DeleteResult, error) {
+			if err := f.simulate(ctx, nil); err != nil {
+				return nil, fmt.Errorf("failed to delete %s: %w", name, err)
+			}
+
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			identifier := config.String(identifierField)
+			key := fakeKey(providerName, name, identifier)
+			if _, ok := f.resources[key]; !ok {
+				return nil, fmt.Errorf("%s '%s' not found", name, identifier)
+			}
+			delete(f.resources, key)
+
+			return &DeleteResult{
+				Message: fmt.Sprintf("%s deleted successfully", name),
+				Result:  map[string]interface{}{"id": identifier, "state": "deleted", "fake": true},
+				Deleted: true,
+			}, nil
+		},
+		update: func(ctx context.Context, plan *UpdatePlan) (*UpdateResult, error) {
+			if !supportsUpdate(real) {
+				return nil, ErrUpdateNotSupported
+			}
+			if err := f.simulate(ctx, nil); err != nil {
+				return nil, fmt.Errorf("failed to update %s: %w", name, err)
+			}
+
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			identifier := plan.Config.String(identifierField)
+			resource, ok := f.resources[fakeKey(providerName, name, identifier)]
+			if !ok {
+				return nil, fmt.Errorf("%s '%s' not found", name, identifier)
+			}
+			config := Params{}
+			for key, value := range plan.Config {
+				config[key] = value
+			}
+			resource.Config = config
+
+			return &UpdateResult{
+				Config: plan.Config,
+				Result: map[string]interface{}{"id": identifier, "changes": plan.Changes, "fake": true},
+			}, nil
+		},
+		power: func(ctx context.Context, config Params, running bool) (*PowerResult, error) {
+			if !SupportsPower(real) {
+				return nil, ErrPowerNotSupported
+			}
+			if err := f.simulate(ctx, nil); err != nil {
+				return nil, fmt.Errorf("failed to change power state of %s: %w", name, err)
+			}
+
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			identifier := config.String(identifierField)
+			resource, ok := f.resources[fakeKey(providerName, name, identifier)]
+			if !ok {
+				return nil, fmt.Errorf("%s '%s' not found", name, identifier)
+			}
+			resource.State = "stopped"
+			if running {
+				resource.State = "running"
+			}
+
+			return &PowerResult{
+				State:  resource.State,
+				Result: map[string]interface{}{"id": identifier, "state": resource.State, "fake": true},
+			}, nil
+		},
+		discover: func(ctx context.Context, region string) ([]DiscoveredResource, error) {
+			if !supportsDiscovery(real) {
+				return nil, ErrDiscoveryNotSupported
+			}
+			if err := f.simulate(ctx, nil); err != nil {
+				return nil, fmt.Errorf("failed to discover %s: %w", name, err)
+			}
+
+			idField := identifierField
+			if assigned, ok := fakeAssignedIDs[name]; ok {
+				idField = assigned.field
+			}
+
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			var resources []DiscoveredResource
+			for _, resource := range f.resources {
+				if resource.Provider != providerName || resource.Service != name || !strings.EqualFold(resource.Config.String("region"), region) {
+					continue
+				}
+				config := Params{}
+				for key, value := range resource.Config {
+					config[key] = value
+				}
+				resources = append(resources, DiscoveredResource{
+					ID:      config.String(idField),
+					IDField: idField,
+					State:   resource.State,
+					Config:  config,
+				})
+			}
+			return resources, nil
+		},
+	}
+}
+
+// fakeService keeps the real service's metadata and validation and replaces every cloud call
+type fakeService struct {
+	ServiceType
+	estimate func(params Params) (float64, error)
+	create   func(ctx context.Context, params Params) (*CreateResult, error)
+	describe func(ctx context.Context, config Params) (*Description, error)
+	delete   func(ctx context.Context, config Params) (*DeleteResult, error)
+	update   func(ctx context.Context, plan *UpdatePlan) (*UpdateResult, error)
+	power    func(ctx context.Context, config Params, running bool) (*PowerResult, error)
+	discover func(ctx context.Context, region string) ([]DiscoveredResource, error)
+}
+
+func (s *fakeService) EstimateCost(params Params) (float64, error) {
+	return s.estimate(params)
+}
+
+func (s *fakeService) Create(ctx context.Context, params Params) (*CreateResult, error) {
+	return s.create(ctx, params)
+}
+
+func (s *fakeService) Describe(ctx context.Context, config Params) (*Description, error) {
+	return s.describe(ctx, config)
+}
+
+func (s *fakeService) Delete(ctx context.Context, config Params) (*DeleteResult, error) {
+	return s.delete(ctx, config)
+}
+
+func (s *fakeService) Update(ctx context.Context, plan *UpdatePlan) (*UpdateResult, error) {
+	return s.update(ctx, plan)
+}
+
+func (s *fakeService) Start(ctx context.Context, config Params) (*PowerResult, error) {
+	return s.power(ctx, config, true)
+}
+
+func (s *fakeService) Stop(ctx context.Context, config Params) (*PowerResult, error) {
+	return s.power(ctx, config, false)
+}
+
+func (s *fakeService) Discover(ctx context.Context, region string) ([]DiscoveredResource, error) {
+	return s.discover(ctx, region)
+}
+
+// UseBackend selects the cloud implementation by name: "" or "live" keeps the real SDK providers, "fake" swaps in the in-memory cloud
+func UseBackend(name string) error {
+	switch name {
+	case "", "live":
+		return nil
+	case "fake":
+		UseFakeCloud(fakeOptions())
+		// Fake resources are never billed
+		UseCostSource("aws", nil)
+		UseCostSource("gcp", nil)
+		return nil
+	default:
+		return fmt.Errorf("unknown cloud backend %q", name)
+	}
+}
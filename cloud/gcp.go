@@ -60,11 +60,13 @@ func FetchProjectID() (string, error) {
 }
 
 // CreateComputeEngineInstance creates a GCP Compute Engine instance
-func CreateComputeEngineInstance(req models.GCPInstanceRequest) (*compute.Operation, error) {
-	ctx := context.Background()
-
+func CreateComputeEngineInstance(ctx context.Context, req models.GCPInstanceRequest) (*compute.Operation, error) {
 	// Create the Compute Engine client
-	client, err := compute.NewInstancesRESTClient(ctx)
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := compute.NewInstancesRESTClient(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Compute Engine client: %v", err)
 	}
@@ -102,6 +104,7 @@ func CreateComputeEngineInstance(req models.GCPInstanceRequest) (*compute.Operat
 				},
 			},
 		},
+		Labels: gcpLabels(ctx),
 	}
 
 	// Define the request
@@ -121,15 +124,19 @@ func CreateComputeEngineInstance(req models.GCPInstanceRequest) (*compute.Operat
 }
 
 // CreateCloudStorage creates a Cloud Storage bucket
-func CreateCloudStorage(bucketName, region string) (*storage.BucketHandle, error) {
+func CreateCloudStorage(ctx context.Context, bucketName, region string) (*storage.BucketHandle, error) {
 	// Fetch the project ID dynamically
-	projectID, err := FetchProjectID()
+	projectID, err := ProjectID(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
 	}
 
 	// Proceed with bucket creation
-	client, err := storage.NewClient(context.Background())
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := storage.NewClient(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Cloud Storage client: %v", err)
 	}
@@ -138,8 +145,9 @@ func CreateCloudStorage(bucketName, region string) (*storage.BucketHandle, error
 	bucket := client.Bucket(bucketName)
 
 	// Create bucket with the specified region
-	if err := bucket.Create(context.Background(), projectID, &storage.BucketAttrs{
+	if err := bucket.Create(ctx, projectID, &storage.BucketAttrs{
 		Location: region,
+		Labels:   gcpLabels(ctx),
 	}); err != nil {
 		return nil, fmt.Errorf("failed to create bucket: %v", err)
 	}
@@ -148,17 +156,19 @@ func CreateCloudStorage(bucketName, region string) (*storage.BucketHandle, error
 }
 
 // CreateGKECluster creates a GKE cluster
-func CreateGKECluster(clusterName, zone, region, machineType, network, subnetwork string, nodeCount int) (*containerpb.Operation, error) {
-	ctx := context.Background()
-
+func CreateGKECluster(ctx context.Context, clusterName, zone, region, machineType, network, subnetwork string, nodeCount int) (*containerpb.Operation, error) {
 	// Fetch project ID dynamically
-	projectID, err := FetchProjectID()
+	projectID, err := ProjectID(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch project ID: %v", err)
 	}
 
 	// Create the GKE client
-	client, err := container.NewClusterManagerClient(ctx)
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := container.NewClusterManagerClient(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create GKE client: %v", err)
 	}
@@ -172,8 +182,9 @@ func CreateGKECluster(clusterName, zone, region, machineType, network, subnetwor
 		NodeConfig: &containerpb.NodeConfig{
 			MachineType: machineType,
 		},
-		Network:    fmt.Sprintf("projects/%s/global/networks/%s", projectID, network),
-		Subnetwork: fmt.Sprintf("regions/%s/subnetworks/%s", region, subnetwork),
+		Network:        fmt.Sprintf("projects/%s/global/networks/%s", projectID, network),
+		Subnetwork:     fmt.Sprintf("regions/%s/subnetworks/%s", region, subnetwork),
+		ResourceLabels: gcpLabels(ctx),
 	}
 
 	// Create the request
@@ -192,14 +203,17 @@ func CreateGKECluster(clusterName, zone, region, machineType, network, subnetwor
 }
 
 // CreateBigQueryDataset creates a BigQuery dataset
-func CreateBigQueryDataset(datasetID, region string) (*bigquery.Dataset, error) {
-	projectID, err := FetchProjectID() // Dynamically fetch the project ID
+func CreateBigQueryDataset(ctx context.Context, datasetID, region string) (*bigquery.Dataset, error) {
+	projectID, err := ProjectID(ctx) // Dynamically fetch the project ID
 	if err != nil {
 		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
 	}
 
-	ctx := context.Background()
-	client, err := bigquery.NewClient(ctx, projectID)
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := bigquery.NewClient(ctx, projectID, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create BigQuery client: %v", err)
 	}
@@ -209,6 +223,7 @@ func CreateBigQueryDataset(datasetID, region string) (*bigquery.Dataset, error)
 	dataset := client.Dataset(datasetID)
 	meta := &bigquery.DatasetMetadata{
 		Location: region,
+		Labels:   gcpLabels(ctx),
 	}
 
 	// Create the dataset
@@ -221,9 +236,12 @@ func CreateBigQueryDataset(datasetID, region string) (*bigquery.Dataset, error)
 }
 
 // CreateCloudSQLInstance creates a Cloud SQL instance
-func CreateCloudSQLInstance(instanceName, projectID, region, tier, databaseVersion string) (*sqladmin.Operation, error) {
-	ctx := context.Background()
-	client, err := sqladmin.NewService(ctx)
+func CreateCloudSQLInstance(ctx context.Context, instanceName, projectID, region, tier, databaseVersion string) (*sqladmin.Operation, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sqladmin.NewService(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Cloud SQL client: %v", err)
 	}
@@ -234,7 +252,8 @@ func CreateCloudSQLInstance(instanceName, projectID, region, tier, databaseVersi
 		Region:          region,
 		DatabaseVersion: databaseVersion,
 		Settings: &sqladmin.Settings{
-			Tier: tier,
+			Tier:       tier,
+			UserLabels: gcpLabels(ctx),
 		},
 	}
 
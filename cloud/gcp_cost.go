@@ -0,0 +1,291 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
+)
+
+// billingTablePattern matches a fully qualified table such as "billing-admin.billing.gcp_billing_export_v1_0A1B2C"
+var billingTablePattern = regexp.MustCompile(`^([a-z0-9-:.]+)\.([A-Za-z0-9_]+)\.([A-Za-z0-9_]+)$`)
+
+// gcpCostRow is the cost of one resource on one day, summed from billing export rows
+type gcpCostRow struct {
+	Day          string  `bigquery:"day"`           // 2006-01-02
+	ServiceID    string  `bigquery:"service_id"`    // value of the mt-service-id label, if any
+	ResourceName string  `bigquery:"resource_name"` // resource.name, only in the detailed export
+	Amount       float64 `bigquery:"amount"`        // cost net of credits
+	Currency     string  `bigquery:"currency"`
+}
+
+// gcpBillingQuery sums the export per day, service label and resource name. Export rows of a day keep arriving
+// for a few days, so partitions are scanned from the day before the range.
+const gcpBillingQuery = `
+SELECT
+  FORMAT_DATE('%%F', DATE(usage_start_time)) AS day,
+  IFNULL((SELECT value FROM UNNEST(labels) WHERE key = @tag LIMIT 1), '') AS service_id,
+  %s AS resource_name,
+  SUM(cost) + SUM(IFNULL((SELECT SUM(credit.amount) FROM UNNEST(credits) AS credit), 0)) AS amount,
+  ANY_VALUE(currency) AS currency
+FROM ` + "`%s`" + `
+WHERE usage_start_time >= @start AND usage_start_time < @end
+  AND _PARTITIONTIME >= TIMESTAMP_SUB(@start, INTERVAL 1 DAY)
+  AND project.id = @project
+GROUP BY day, service_id, resource_name
+HAVING service_id IN UNNEST(@ids) OR resource_name IN UNNEST(@names)`
+
+// gcpBillingSource reads daily cost from the Cloud Billing export to BigQuery. Costs are attributed by the
+// mt-service-id label, or by resource name when the table is the detailed (resource-level) export.
+type gcpBillingSource struct {
+	table    string
+	detailed bool
+}
+
+// NewGCPBillingSource returns the cost source reading a billing export table given as project.dataset.table.
+// The table is queried with the server's own credentials, since it usually lives in the billing account's
+// project rather than in the tenants' ones.
+func NewGCPBillingSource(table string) (CostSource, error) {
+	match := billingTablePattern.FindStringSubmatch(table)
+	if match == nil {
+		return nil, fmt.Errorf("invalid billing export table %q, expected project.dataset.table", table)
+	}
+	return &gcpBillingSource{
+		table:    table,
+		detailed: strings.HasPrefix(match[3], "gcp_billing_export_resource_v1_"),
+	}, nil
+}
+
+func (s *gcpBillingSource) Name() string { return "gcp-billing-export" }
+
+func (s *gcpBillingSource) DailyCosts(ctx context.Context, resources []CostResource, start, end time.Time) ([]DailyCost, error) {
+	project, err := ProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
+	}
+	ids, names := gcpCostKeys(resources)
+	if !s.detailed {
+		names = []string{}
+	}
+
+	client, err := bigquery.NewClient(ctx, billingTablePattern.FindStringSubmatch(s.table)[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create BigQuery client: %v", err)
+	}
+	defer client.Close()
+
+	resourceName := "''"
+	if s.detailed {
+		resourceName = "IFNULL(resource.name, '')"
+	}
+	query := client.Query(fmt.Sprintf(gcpBillingQuery, resourceName, s.table))
+	query.Parameters = []bigquery.QueryParameter{
+		{Name: "tag", Value: TagServiceID},
+		{Name: "start", Value: start},
+		{Name: "end", Value: end},
+		{Name: "project", Value: project},
+		{Name: "ids", Value: ids},
+		{Name: "names", Value: names},
+	}
+
+	it, err := query.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query billing export: %v", err)
+	}
+	var rows []gcpCostRow
+	for {
+		var row gcpCostRow
+		err := it.Next(&row)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read billing export: %v", err)
+		}
+		rows = append(rows, row)
+	}
+	return attributeGCPCosts(rows, resources)
+}
+
+// gcpCostKeys returns the service IDs and resource names the export rows are matched by
+func gcpCostKeys(resources []CostResource) ([]string, []string) {
+	ids := make([]string, 0, len(resources))
+	names := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		ids = append(ids, resource.ServiceID)
+		if name := gcpResourceName(resource); name != "" {
+			names = append(names, name)
+		}
+	}
+	return ids, names
+}
+
+// gcpResourceName is the name a resource is billed under: the value of its service type's identifier field
+func gcpResourceName(resource CostResource) string {
+	svc, err := LookupService("gcp", resource.Service)
+	if err != nil {
+		return ""
+	}
+	return resource.Config.String(svc.IdentifierField())
+}
+
+// attributeGCPCosts assigns cost rows to resources, by label first and then by resource name.
+// Rows labelled for another service are never matched by name, and names shared by two resources are ignored.
+func attributeGCPCosts(rows []gcpCostRow, resources []CostResource) ([]DailyCost, error) {
+	wanted := map[string]bool{}
+	byName := map[string]string{}
+	for _, resource := range resources {
+		wanted[resource.ServiceID] = true
+		name := gcpResourceName(resource)
+		if name == "" {
+			continue
+		}
+		if _, taken := byName[name]; taken {
+			byName[name] = ""
+			continue
+		}
+		byName[name] = resource.ServiceID
+	}
+
+	type dayKey struct {
+		serviceID string
+		day       string
+	}
+	totals := map[dayKey]*DailyCost{}
+	for _, row := range rows {
+		serviceID, resourceID := row.ServiceID, ""
+		if serviceID == "" {
+			serviceID, resourceID = byName[row.ResourceName], row.ResourceName
+		}
+		if serviceID == "" || !wanted[serviceID] {
+			continue
+		}
+
+		key := dayKey{serviceID, row.Day}
+		cost, ok := totals[key]
+		if !ok {
+			day, err := costDay(row.Day)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cost date: %v", err)
+			}
+			cost = &DailyCost{ServiceID: serviceID, ResourceID: resourceID, Date: day, Currency: row.Currency}
+			totals[key] = cost
+		}
+		cost.Amount += row.Amount
+	}
+
+	costs := make([]DailyCost, 0, len(totals))
+	for _, cost := range totals {
+		if cost.Currency == "" {
+			cost.Currency = "USD"
+		}
+		costs = append(costs, *cost)
+	}
+	sort.Slice(costs, func(i, j int) bool {
+		if costs[i].ServiceID != costs[j].ServiceID {
+			return costs[i].ServiceID < costs[j].ServiceID
+		}
+		return costs[i].Date.Before(costs[j].Date)
+	})
+	return costs, nil
+}
+
+// gcpExportRow is the part of a billing export row the fixture keeps, in the export's JSON form
+type gcpExportRow struct {
+	UsageStartTime time.Time `json:"usage_start_time"`
+	Project        struct {
+		ID string `json:"id"`
+	} `json:"project"`
+	Labels []struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	} `json:"labels"`
+	Resource struct {
+		Name string `json:"name"`
+	} `json:"resource"`
+	Cost     float64 `json:"cost"`
+	Currency string  `json:"currency"`
+	Credits  []struct {
+		Amount float64 `json:"amount"`
+	} `json:"credits"`
+}
+
+// gcpBillingFixture serves billing export rows from a JSON file instead of BigQuery
+type gcpBillingFixture struct {
+	rows []gcpExportRow
+}
+
+// LoadGCPBillingFixture reads a JSON array of billing export rows, such as cloud/testdata/gcp_billing_export.json,
+// into a cost source that never calls BigQuery. The rows are moved in time so the latest day is yesterday, which
+// keeps the fixture inside the days a sync collects.
+func LoadGCPBillingFixture(path string) (CostSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read billing fixture: %v", err)
+	}
+	var rows []gcpExportRow
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("failed to parse billing fixture %s: %v", path, err)
+	}
+	return &gcpBillingFixture{rows: rows}, nil
+}
+
+func (f *gcpBillingFixture) Name() string { return "gcp-billing-fixture" }
+
+func (f *gcpBillingFixture) DailyCosts(ctx context.Context, resources []CostResource, start, end time.Time) ([]DailyCost, error) {
+	// Fixture rows without a project match every tenant
+	project, _ := ProjectID(ctx)
+
+	var latest time.Time
+	for _, row := range f.rows {
+		if row.UsageStartTime.After(latest) {
+			latest = row.UsageStartTime
+		}
+	}
+	yesterday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	shift := yesterday.Sub(latest.UTC().Truncate(24 * time.Hour))
+
+	type rowKey struct {
+		day, serviceID, resourceName string
+	}
+	summed := map[rowKey]*gcpCostRow{}
+	for _, row := range f.rows {
+		if row.Project.ID != "" && project != "" && row.Project.ID != project {
+			continue
+		}
+		usage := row.UsageStartTime.Add(shift).UTC()
+		if usage.Before(start) || !usage.Before(end) {
+			continue
+		}
+
+		key := rowKey{day: usage.Format("2006-01-02"), resourceName: row.Resource.Name}
+		for _, label := range row.Labels {
+			if label.Key == TagServiceID {
+				key.serviceID = label.Value
+				break
+			}
+		}
+		total, ok := summed[key]
+		if !ok {
+			total = &gcpCostRow{Day: key.day, ServiceID: key.serviceID, ResourceName: key.resourceName, Currency: row.Currency}
+			summed[key] = total
+		}
+		total.Amount += row.Cost
+		for _, credit := range row.Credits {
+			total.Amount += credit.Amount
+		}
+	}
+
+	rows := make([]gcpCostRow, 0, len(summed))
+	for _, row := range summed {
+		rows = append(rows, *row)
+	}
+	return attributeGCPCosts(rows, resources)
+}
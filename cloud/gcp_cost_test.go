@@ -0,0 +1,101 @@
+package cloud
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGCPBillingFixtureDailyCosts(t *testing.T) {
+	source, err := LoadGCPBillingFixture("testdata/gcp_billing_export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		vmID       = "665f1c2e8b3a4d00000000a1"
+		bucketID   = "665f1c2e8b3a4d00000000b2"
+		labelledID = "665f1c2e8b3a4d0012345678"
+		sqlID      = "665f1c2e8b3a4d00000000c3"
+		otherID    = "665f1c2e8b3a4d00000000d4"
+	)
+	resources := []CostResource{
+		{ServiceID: vmID, Service: GCPComputeEngine, Config: Params{"name": "demo-vm"}},
+		{ServiceID: bucketID, Service: GCPCloudStorage, Config: Params{"bucket_name": "demo-bucket"}},
+		{ServiceID: labelledID, Service: GCPComputeEngine, Config: Params{"name": "renamed-vm"}},
+		// Rows named demo-sql are labelled for another service, so they are not matched by name
+		{ServiceID: sqlID, Service: GCPCloudSQL, Config: Params{"instance_name": "demo-sql"}},
+		// other-vm is billed to another project
+		{ServiceID: otherID, Service: GCPComputeEngine, Config: Params{"name": "other-vm"}},
+	}
+	ctx := WithTenant(context.Background(), &Tenant{Key: "group:test", GCPProjectID: "demo-project"})
+
+	// The fixture's three days end yesterday
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	costs, err := source.DailyCosts(ctx, resources, today.AddDate(0, 0, -7), today)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type dayKey struct {
+		serviceID string
+		date      time.Time
+	}
+	got := map[dayKey]DailyCost{}
+	for _, cost := range costs {
+		got[dayKey{cost.ServiceID, cost.Date}] = cost
+	}
+	want := map[string]struct {
+		amount     float64
+		resourceID string
+	}{
+		vmID:       {0.75, "demo-vm"}, // two rows, less a credit
+		bucketID:   {0.02, "demo-bucket"},
+		labelledID: {0.75, ""},
+	}
+	if len(got) != 3*len(want) {
+		t.Errorf("got %d daily costs, want %d: %+v", len(got), 3*len(want), costs)
+	}
+	for serviceID, expected := range want {
+		for days := 1; days <= 3; days++ {
+			date := today.AddDate(0, 0, -days)
+			cost, ok := got[dayKey{serviceID, date}]
+			if !ok {
+				t.Errorf("no cost for %s on %s", serviceID, date.Format("2006-01-02"))
+				continue
+			}
+			if math.Abs(cost.Amount-expected.amount) > 1e-9 || cost.ResourceID != expected.resourceID || cost.Currency != "USD" {
+				t.Errorf("%s on %s = %+v, want %v USD matched by %q", serviceID, date.Format("2006-01-02"), cost, expected.amount, expected.resourceID)
+			}
+		}
+	}
+
+	// Only rows inside the range are returned
+	costs, err = source.DailyCosts(ctx, resources, today.AddDate(0, 0, -1), today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(costs) != len(want) {
+		t.Errorf("got %d costs for yesterday, want %d: %+v", len(costs), len(want), costs)
+	}
+	for _, cost := range costs {
+		if !cost.Date.Equal(today.AddDate(0, 0, -1)) {
+			t.Errorf("cost on %s outside the range", cost.Date.Format("2006-01-02"))
+		}
+	}
+
+	// Rows are matched within the tenant's project
+	other := WithTenant(context.Background(), &Tenant{Key: "group:other", GCPProjectID: "other-project"})
+	costs, err = source.DailyCosts(other, resources, today.AddDate(0, 0, -7), today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(costs) != 3 {
+		t.Fatalf("got %d costs in other-project, want 3: %+v", len(costs), costs)
+	}
+	for _, cost := range costs {
+		if cost.ServiceID != otherID || math.Abs(cost.Amount-0.9) > 1e-9 {
+			t.Errorf("other-project cost = %+v, want 0.9 for %s", cost, otherID)
+		}
+	}
+}
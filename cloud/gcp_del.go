@@ -15,17 +15,19 @@ import (
 )
 
 // DeleteComputeEngineInstance deletes a GCP Compute Engine instance
-func DeleteComputeEngineInstance(instanceName, zone string) (string, error) {
-	ctx := context.Background()
-
-	client, err := compute.NewInstancesRESTClient(ctx)
+func DeleteComputeEngineInstance(ctx context.Context, instanceName, zone string) (string, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return "", err
+	}
+	client, err := compute.NewInstancesRESTClient(ctx, opts...)
 	if err != nil {
 		return "", fmt.Errorf("failed to create Compute Engine client: %v", err)
 	}
 	defer client.Close()
 
 	// Fetch the project ID dynamically
-	projectID, err := FetchProjectID()
+	projectID, err := ProjectID(ctx)
 	if err != nil {
 		return "", fmt.Errorf("unable to fetch project ID: %v", err)
 	}
@@ -45,10 +47,12 @@ func DeleteComputeEngineInstance(instanceName, zone string) (string, error) {
 }
 
 // DeleteCloudStorage deletes a GCP Cloud Storage bucket
-func DeleteCloudStorage(bucketName string) (string, error) {
-	ctx := context.Background()
-
-	client, err := storage.NewClient(ctx)
+func DeleteCloudStorage(ctx context.Context, bucketName string) (string, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return "", err
+	}
+	client, err := storage.NewClient(ctx, opts...)
 	if err != nil {
 		return "", fmt.Errorf("failed to create Cloud Storage client: %v", err)
 	}
@@ -81,17 +85,19 @@ func DeleteCloudStorage(bucketName string) (string, error) {
 }
 
 // DeleteGKECluster deletes a GCP GKE cluster
-func DeleteGKECluster(clusterName, zone string) (string, error) {
-	ctx := context.Background()
-
-	client, err := container.NewClusterManagerClient(ctx)
+func DeleteGKECluster(ctx context.Context, clusterName, zone string) (string, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return "", err
+	}
+	client, err := container.NewClusterManagerClient(ctx, opts...)
 	if err != nil {
 		return "", fmt.Errorf("failed to create GKE client: %v", err)
 	}
 	defer client.Close()
 
 	// Fetch project ID dynamically
-	projectID, err := FetchProjectID()
+	projectID, err := ProjectID(ctx)
 	if err != nil {
 		return "", fmt.Errorf("unable to fetch project ID: %v", err)
 	}
@@ -109,14 +115,17 @@ func DeleteGKECluster(clusterName, zone string) (string, error) {
 }
 
 // DeleteBigQueryDataset deletes a GCP BigQuery dataset
-func DeleteBigQueryDataset(datasetID string) (string, error) {
-	projectID, err := FetchProjectID()
+func DeleteBigQueryDataset(ctx context.Context, datasetID string) (string, error) {
+	projectID, err := ProjectID(ctx)
 	if err != nil {
 		return "", fmt.Errorf("unable to fetch project ID: %v", err)
 	}
 
-	ctx := context.Background()
-	client, err := bigquery.NewClient(ctx, projectID)
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return "", err
+	}
+	client, err := bigquery.NewClient(ctx, projectID, opts...)
 	if err != nil {
 		return "", fmt.Errorf("failed to create BigQuery client: %v", err)
 	}
@@ -134,15 +143,18 @@ func DeleteBigQueryDataset(datasetID string) (string, error) {
 }
 
 // DeleteCloudSQLInstance deletes a GCP Cloud SQL instance
-func DeleteCloudSQLInstance(instanceName string) (string, error) {
-	ctx := context.Background()
-	client, err := sqladmin.NewService(ctx)
+func DeleteCloudSQLInstance(ctx context.Context, instanceName string) (string, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return "", err
+	}
+	client, err := sqladmin.NewService(ctx, opts...)
 	if err != nil {
 		return "", fmt.Errorf("failed to create Cloud SQL client: %v", err)
 	}
 
 	// Fetch the project ID dynamically
-	projectID, err := FetchProjectID()
+	projectID, err := ProjectID(ctx)
 	if err != nil {
 		return "", fmt.Errorf("unable to fetch project ID: %v", err)
 	}
@@ -0,0 +1,222 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+
+	"cloud.google.com/go/bigquery"
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	container "cloud.google.com/go/container/apiv1"
+	"cloud.google.com/go/container/apiv1/containerpb"
+	"cloud.google.com/go/storage"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/sqladmin/v1"
+	"google.golang.org/grpc/codes"
+)
+
+// isGCPNotFound reports whether err is a "not found" error from a Google Cloud API
+func isGCPNotFound(err error) bool {
+	var apiErr *apierror.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.HTTPCode() == http.StatusNotFound {
+			return true
+		}
+		if apiErr.GRPCStatus() != nil && apiErr.GRPCStatus().Code() == codes.NotFound {
+			return true
+		}
+	}
+	var googleErr *googleapi.Error
+	if errors.As(err, &googleErr) {
+		return googleErr.Code == http.StatusNotFound
+	}
+	return false
+}
+
+// DescribeComputeEngineInstance returns the live state of a Compute Engine instance
+func DescribeComputeEngineInstance(ctx context.Context, instanceName, zone string) (*Description, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := compute.NewInstancesRESTClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Compute Engine client: %v", err)
+	}
+	defer client.Close()
+
+	projectID, err := ProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
+	}
+
+	instance, err := client.Get(ctx, &computepb.GetInstanceRequest{
+		Project:  projectID,
+		Zone:     zone,
+		Instance: instanceName,
+	})
+	if err != nil {
+		if isGCPNotFound(err) {
+			return &Description{Exists: false}, nil
+		}
+		return nil, fmt.Errorf("failed to describe Compute Engine instance: %v", err)
+	}
+
+	return &Description{
+		Exists: true,
+		State:  instance.GetStatus(),
+		Attributes: Params{
+			"machine_type": path.Base(instance.GetMachineType()),
+		},
+	}, nil
+}
+
+// DescribeCloudStorage checks that a Cloud Storage bucket still exists
+func DescribeCloudStorage(ctx context.Context, bucketName string) (*Description, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Storage client: %v", err)
+	}
+	defer client.Close()
+
+	attrs, err := client.Bucket(bucketName).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotExist) {
+			return &Description{Exists: false}, nil
+		}
+		return nil, fmt.Errorf("failed to describe bucket: %v", err)
+	}
+
+	return &Description{
+		Exists: true,
+		State:  "available",
+		Attributes: Params{
+			"location": attrs.Location,
+		},
+	}, nil
+}
+
+// DescribeGKECluster returns the live state of a GKE cluster
+func DescribeGKECluster(ctx context.Context, clusterName, zone string) (*Description, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := container.NewClusterManagerClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GKE client: %v", err)
+	}
+	defer client.Close()
+
+	projectID, err := ProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
+	}
+
+	cluster, err := client.GetCluster(ctx, &containerpb.GetClusterRequest{
+		Name: fmt.Sprintf("projects/%s/locations/%s/clusters/%s", projectID, zone, clusterName),
+	})
+	if err != nil {
+		if isGCPNotFound(err) {
+			return &Description{Exists: false}, nil
+		}
+		return nil, fmt.Errorf("failed to describe GKE cluster: %v", err)
+	}
+
+	attributes := Params{
+		"node_count": int(cluster.GetCurrentNodeCount()),
+	}
+	if cluster.GetNodeConfig() != nil {
+		attributes["machine_type"] = cluster.GetNodeConfig().GetMachineType()
+	}
+
+	return &Description{
+		Exists:     true,
+		State:      cluster.GetStatus().String(),
+		Attributes: attributes,
+	}, nil
+}
+
+// DescribeBigQueryDataset checks that a BigQuery dataset still exists
+func DescribeBigQueryDataset(ctx context.Context, datasetID string) (*Description, error) {
+	projectID, err := ProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
+	}
+
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := bigquery.NewClient(ctx, projectID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create BigQuery client: %v", err)
+	}
+	defer client.Close()
+
+	meta, err := client.Dataset(datasetID).Metadata(ctx)
+	if err != nil {
+		if isGCPNotFound(err) {
+			return &Description{Exists: false}, nil
+		}
+		return nil, fmt.Errorf("failed to describe BigQuery dataset: %v", err)
+	}
+
+	return &Description{
+		Exists: true,
+		State:  "available",
+		Attributes: Params{
+			"location": meta.Location,
+		},
+	}, nil
+}
+
+// DescribeCloudSQLInstance returns the live state of a Cloud SQL instance
+func DescribeCloudSQLInstance(ctx context.Context, instanceName string) (*Description, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sqladmin.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud SQL client: %v", err)
+	}
+
+	projectID, err := ProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
+	}
+
+	instance, err := client.Instances.Get(projectID, instanceName).Do()
+	if err != nil {
+		if isGCPNotFound(err) {
+			return &Description{Exists: false}, nil
+		}
+		return nil, fmt.Errorf("failed to describe Cloud SQL instance: %v", err)
+	}
+
+	attributes := Params{}
+	state := instance.State
+	if instance.Settings != nil {
+		attributes["tier"] = instance.Settings.Tier
+
+		// Stopped instances stay RUNNABLE; only the activation policy tells them apart
+		if instance.Settings.ActivationPolicy == "NEVER" && state == "RUNNABLE" {
+			state = "STOPPED"
+		}
+	}
+
+	return &Description{
+		Exists:     true,
+		State:      state,
+		Attributes: attributes,
+	}, nil
+}
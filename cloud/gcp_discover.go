@@ -0,0 +1,263 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	container "cloud.google.com/go/container/apiv1"
+	"cloud.google.com/go/container/apiv1/containerpb"
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/sqladmin/v1"
+)
+
+// gcpZoneRegion returns the region a zone belongs to, or "" for a name that is not a zone
+func gcpZoneRegion(zone string) string {
+	if match := gcpZonePattern.FindStringSubmatch(zone); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// DiscoverComputeEngineInstances lists the Compute Engine instances in the zones of a region
+func DiscoverComputeEngineInstances(ctx context.Context, region string) ([]DiscoveredResource, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := compute.NewInstancesRESTClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Compute Engine client: %v", err)
+	}
+	defer client.Close()
+
+	projectID, err := ProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
+	}
+
+	var resources []DiscoveredResource
+	it := client.AggregatedList(ctx, &computepb.AggregatedListInstancesRequest{Project: projectID})
+	for {
+		pair, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Compute Engine instances: %v", err)
+		}
+
+		// Keys look like "zones/us-central1-a"
+		zone := path.Base(pair.Key)
+		if gcpZoneRegion(zone) != region {
+			continue
+		}
+		for _, instance := range pair.Value.GetInstances() {
+			resources = append(resources, DiscoveredResource{
+				ID:      instance.GetName(),
+				IDField: "name",
+				State:   instance.GetStatus(),
+				Config: Params{
+					"name":         instance.GetName(),
+					"zone":         zone,
+					"machine_type": path.Base(instance.GetMachineType()),
+					"region":       region,
+				},
+			})
+		}
+	}
+	return resources, nil
+}
+
+// DiscoverCloudStorageBuckets lists the Cloud Storage buckets located in a region or multi-region
+func DiscoverCloudStorageBuckets(ctx context.Context, region string) ([]DiscoveredResource, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Storage client: %v", err)
+	}
+	defer client.Close()
+
+	projectID, err := ProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
+	}
+
+	var resources []DiscoveredResource
+	it := client.Buckets(ctx, projectID)
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Cloud Storage buckets: %v", err)
+		}
+		if !strings.EqualFold(attrs.Location, region) {
+			continue
+		}
+		resources = append(resources, DiscoveredResource{
+			ID:      attrs.Name,
+			IDField: "bucket_name",
+			State:   "available",
+			Config: Params{
+				"bucket_name": attrs.Name,
+				"region":      region,
+			},
+		})
+	}
+	return resources, nil
+}
+
+// DiscoverGKEClusters lists the GKE clusters whose location is a region or one of its zones
+func DiscoverGKEClusters(ctx context.Context, region string) ([]DiscoveredResource, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := container.NewClusterManagerClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GKE client: %v", err)
+	}
+	defer client.Close()
+
+	projectID, err := ProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
+	}
+
+	output, err := client.ListClusters(ctx, &containerpb.ListClustersRequest{
+		Parent: fmt.Sprintf("projects/%s/locations/-", projectID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list GKE clusters: %v", err)
+	}
+
+	var resources []DiscoveredResource
+	for _, cluster := range output.GetClusters() {
+		location := cluster.GetLocation()
+		if location != region && gcpZoneRegion(location) != region {
+			continue
+		}
+
+		config := Params{
+			"cluster_name": cluster.GetName(),
+			"zone":         location, // clusters are described and deleted by location, which may be the region itself
+			"region":       region,
+			"node_count":   int(cluster.GetCurrentNodeCount()),
+		}
+		if cluster.GetNodeConfig() != nil {
+			config["machine_type"] = cluster.GetNodeConfig().GetMachineType()
+		}
+		resources = append(resources, DiscoveredResource{
+			ID:      cluster.GetName(),
+			IDField: "cluster_name",
+			State:   cluster.GetStatus().String(),
+			Config:  config,
+		})
+	}
+	return resources, nil
+}
+
+// DiscoverBigQueryDatasets lists the BigQuery datasets located in a region or multi-region
+func DiscoverBigQueryDatasets(ctx context.Context, region string) ([]DiscoveredResource, error) {
+	projectID, err := ProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
+	}
+
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := bigquery.NewClient(ctx, projectID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create BigQuery client: %v", err)
+	}
+	defer client.Close()
+
+	var resources []DiscoveredResource
+	it := client.Datasets(ctx)
+	for {
+		dataset, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list BigQuery datasets: %v", err)
+		}
+
+		// The listing does not include the location
+		meta, err := dataset.Metadata(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe BigQuery dataset %s: %v", dataset.DatasetID, err)
+		}
+		if !strings.EqualFold(meta.Location, region) {
+			continue
+		}
+		resources = append(resources, DiscoveredResource{
+			ID:      dataset.DatasetID,
+			IDField: "dataset_id",
+			State:   "available",
+			Config: Params{
+				"dataset_id": dataset.DatasetID,
+				"region":     region,
+			},
+		})
+	}
+	return resources, nil
+}
+
+// DiscoverCloudSQLInstances lists the Cloud SQL instances of a region
+func DiscoverCloudSQLInstances(ctx context.Context, region string) ([]DiscoveredResource, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sqladmin.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud SQL client: %v", err)
+	}
+
+	projectID, err := ProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
+	}
+
+	var resources []DiscoveredResource
+	err = client.Instances.List(projectID).Pages(ctx, func(page *sqladmin.InstancesListResponse) error {
+		for _, instance := range page.Items {
+			if instance.Region != region {
+				continue
+			}
+			config := Params{
+				"instance_name":    instance.Name,
+				"region":           region,
+				"database_version": instance.DatabaseVersion,
+			}
+			if instance.Settings != nil {
+				config["tier"] = instance.Settings.Tier
+			}
+			resources = append(resources, DiscoveredResource{
+				ID:      instance.Name,
+				IDField: "instance_name",
+				State:   instance.State,
+				Config:  config,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Cloud SQL instances: %v", err)
+	}
+	return resources, nil
+}
@@ -0,0 +1,79 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/sqladmin/v1"
+)
+
+// SetComputeEngineInstancePower starts or stops a Compute Engine instance
+func SetComputeEngineInstancePower(ctx context.Context, instanceName, zone string, running bool) (*PowerResult, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := compute.NewInstancesRESTClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Compute Engine client: %v", err)
+	}
+	defer client.Close()
+
+	projectID, err := ProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
+	}
+
+	if running {
+		_, err = client.Start(ctx, &computepb.StartInstanceRequest{
+			Project:  projectID,
+			Zone:     zone,
+			Instance: instanceName,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to start Compute Engine instance: %v", err)
+		}
+		return &PowerResult{State: "STAGING", Result: fmt.Sprintf("Compute Engine instance '%s' is starting", instanceName)}, nil
+	}
+
+	_, err = client.Stop(ctx, &computepb.StopInstanceRequest{
+		Project:  projectID,
+		Zone:     zone,
+		Instance: instanceName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to stop Compute Engine instance: %v", err)
+	}
+	return &PowerResult{State: "STOPPING", Result: fmt.Sprintf("Compute Engine instance '%s' is stopping", instanceName)}, nil
+}
+
+// SetCloudSQLInstancePower starts or stops a Cloud SQL instance through its activation policy
+func SetCloudSQLInstancePower(ctx context.Context, instanceName string, running bool) (*PowerResult, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sqladmin.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud SQL client: %v", err)
+	}
+
+	projectID, err := ProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
+	}
+
+	policy, state := "NEVER", "STOPPED"
+	if running {
+		policy, state = "ALWAYS", "RUNNABLE"
+	}
+	op, err := client.Instances.Patch(projectID, instanceName, &sqladmin.DatabaseInstance{
+		Settings: &sqladmin.Settings{ActivationPolicy: policy},
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to set Cloud SQL activation policy: %v", err)
+	}
+	return &PowerResult{State: state, Result: op}, nil
+}
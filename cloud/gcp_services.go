@@ -0,0 +1,252 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+
+	"multitenant/models"
+)
+
+// GCP catalog names, stored as `service` in sessions and services
+const (
+	GCPComputeEngine = "Compute Engine"
+	GCPCloudStorage  = "Cloud Storage"
+	GCPGKE           = "Google Kubernetes Engine (GKE)"
+	GCPBigQuery      = "BigQuery"
+	GCPCloudSQL      = "Cloud SQL"
+)
+
+func init() {
+	RegisterProvider(NewGCPProvider())
+}
+
+// NewGCPProvider returns the provider backed by the Google Cloud client libraries
+func NewGCPProvider() Provider {
+	return &provider{
+		name: "gcp",
+		services: []ServiceType{
+			&service{
+				provider:        "gcp",
+				name:            GCPComputeEngine,
+				path:            "create-compute-engine",
+				identifierField: "name",
+				required:        []string{"name", "zone", "machine_type", "image_project", "image_family", "network", "subnetwork", "region"},
+				discover:        DiscoverComputeEngineInstances,
+				zonal:           true,
+				updatable:       map[string]fieldKind{"machine_type": kindString},
+				update: func(ctx context.Context, plan *UpdatePlan) (interface{}, error) {
+					return SetComputeEngineMachineType(ctx, plan.Config.String("name"), plan.Config.String("zone"), plan.Config.String("machine_type"))
+				},
+				power: func(ctx context.Context, config Params, running bool) (*PowerResult, error) {
+					return SetComputeEngineInstancePower(ctx, config.String("name"), config.String("zone"), running)
+				},
+				priced:   []string{"machine_type", "zone", "region"},
+				estimate: estimateComputeEngine,
+				create: func(ctx context.Context, params Params) (*CreateResult, error) {
+					// Fetch Project ID dynamically
+					projectID, err := ProjectID(ctx)
+					if err != nil {
+						return nil, fmt.Errorf("failed to fetch GCP project ID: %v", err)
+					}
+
+					_, err = CreateComputeEngineInstance(ctx, models.GCPInstanceRequest{
+						Name:           params.String("name"),
+						ProjectID:      projectID,
+						Zone:           params.String("zone"),
+						MachineType:    params.String("machine_type"),
+						ImageProject:   params.String("image_project"),
+						ImageFamily:    params.String("image_family"),
+						Network:        params.String("network"),
+						Subnetwork:     params.String("subnetwork"),
+						ServiceAccount: params.String("service_account"),
+						Region:         params.String("region"),
+					})
+					if err != nil {
+						return nil, err
+					}
+					config := Params{
+						"name":            params.String("name"),
+						"zone":            params.String("zone"),
+						"machine_type":    params.String("machine_type"),
+						"image_project":   params.String("image_project"),
+						"image_family":    params.String("image_family"),
+						"network":         params.String("network"),
+						"subnetwork":      params.String("subnetwork"),
+						"service_account": params.String("service_account"),
+						"region":          params.String("region"),
+					}
+					return &CreateResult{Config: config}, nil
+				},
+				describe: func(ctx context.Context, config Params) (*Description, error) {
+					return DescribeComputeEngineInstance(ctx, config.String("name"), config.String("zone"))
+				},
+				delete: func(ctx context.Context, config Params) (*DeleteResult, error) {
+					result, err := DeleteComputeEngineInstance(ctx, config.String("name"), config.String("zone"))
+					if err != nil {
+						return nil, err
+					}
+					return &DeleteResult{Message: "Compute Engine instance deleted successfully", Result: result, Deleted: true}, nil
+				},
+			},
+			&service{
+				provider:        "gcp",
+				name:            GCPCloudStorage,
+				path:            "create-cloud-storage",
+				identifierField: "bucket_name",
+				required:        []string{"bucket_name", "region"},
+				discover:        DiscoverCloudStorageBuckets,
+				priced:          []string{"region"},
+				estimate:        estimateCloudStorage,
+				create: func(ctx context.Context, params Params) (*CreateResult, error) {
+					_, err := CreateCloudStorage(ctx, params.String("bucket_name"), params.String("region"))
+					if err != nil {
+						return nil, err
+					}
+					config := Params{
+						"bucket_name": params.String("bucket_name"),
+						"region":      params.String("region"),
+					}
+					return &CreateResult{Config: config}, nil
+				},
+				describe: func(ctx context.Context, config Params) (*Description, error) {
+					return DescribeCloudStorage(ctx, config.String("bucket_name"))
+				},
+				delete: func(ctx context.Context, config Params) (*DeleteResult, error) {
+					result, err := DeleteCloudStorage(ctx, config.String("bucket_name"))
+					if err != nil {
+						return nil, err
+					}
+					return &DeleteResult{Message: "Cloud Storage bucket deleted successfully", Result: result, Deleted: true}, nil
+				},
+			},
+			&service{
+				provider:        "gcp",
+				name:            GCPGKE,
+				path:            "create-GKE-cluster",
+				identifierField: "cluster_name",
+				required:        []string{"cluster_name", "zone", "region", "machine_type", "network", "subnetwork", "node_count"},
+				discover:        DiscoverGKEClusters,
+				zonal:           true,
+				priced:          []string{"machine_type", "node_count", "zone", "region"},
+				estimate:        estimateGKE,
+				updatable:       map[string]fieldKind{"node_count": kindInt},
+				checkUpdate: func(config, changes Params) error {
+					if changes.Int("node_count") < 1 {
+						return fmt.Errorf("node_count must be at least 1")
+					}
+					return nil
+				},
+				update: func(ctx context.Context, plan *UpdatePlan) (interface{}, error) {
+					return ResizeGKECluster(ctx, plan.Config.String("cluster_name"), plan.Config.String("zone"), plan.Config.String("node_pool"), plan.Config.Int("node_count"))
+				},
+				create: func(ctx context.Context, params Params) (*CreateResult, error) {
+					operation, err := CreateGKECluster(ctx, params.String("cluster_name"), params.String("zone"), params.String("region"), params.String("machine_type"), params.String("network"), params.String("subnetwork"), params.Int("node_count"))
+					if err != nil {
+						return nil, err
+					}
+					config := Params{
+						"cluster_name": params.String("cluster_name"),
+						"zone":         params.String("zone"),
+						"region":       params.String("region"),
+						"machine_type": params.String("machine_type"),
+						"network":      params.String("network"),
+						"subnetwork":   params.String("subnetwork"),
+						"node_count":   params.Int("node_count"),
+					}
+					return &CreateResult{Config: config, Result: operation}, nil
+				},
+				describe: func(ctx context.Context, config Params) (*Description, error) {
+					return DescribeGKECluster(ctx, config.String("cluster_name"), config.String("zone"))
+				},
+				delete: func(ctx context.Context, config Params) (*DeleteResult, error) {
+					result, err := DeleteGKECluster(ctx, config.String("cluster_name"), config.String("zone"))
+					if err != nil {
+						return nil, err
+					}
+					return &DeleteResult{Message: "GKE cluster deleted successfully", Result: result, Deleted: true}, nil
+				},
+			},
+			&service{
+				provider:        "gcp",
+				name:            GCPBigQuery,
+				path:            "create-bigquery-dataset",
+				identifierField: "dataset_id",
+				required:        []string{"dataset_id", "region"},
+				discover:        DiscoverBigQueryDatasets,
+				create: func(ctx context.Context, params Params) (*CreateResult, error) {
+					dataset, err := CreateBigQueryDataset(ctx, params.String("dataset_id"), params.String("region"))
+					if err != nil {
+						return nil, err
+					}
+					config := Params{
+						"dataset_id": params.String("dataset_id"),
+						"region":     params.String("region"),
+					}
+					return &CreateResult{Config: config, Result: dataset}, nil
+				},
+				describe: func(ctx context.Context, config Params) (*Description, error) {
+					return DescribeBigQueryDataset(ctx, config.String("dataset_id"))
+				},
+				delete: func(ctx context.Context, config Params) (*DeleteResult, error) {
+					result, err := DeleteBigQueryDataset(ctx, config.String("dataset_id"))
+					if err != nil {
+						return nil, err
+					}
+					return &DeleteResult{Message: "BigQuery dataset deleted successfully", Result: result, Deleted: true}, nil
+				},
+			},
+			&service{
+				provider:        "gcp",
+				name:            GCPCloudSQL,
+				path:            "create-cloud-SQL",
+				identifierField: "instance_name",
+				required:        []string{"instance_name", "region", "tier", "database_version"},
+				discover:        DiscoverCloudSQLInstances,
+				updatable:       map[string]fieldKind{"tier": kindString, "disk_size_gb": kindInt},
+				checkUpdate: func(config, changes Params) error {
+					if _, ok := changes["disk_size_gb"]; ok && changes.Int("disk_size_gb") < config.Int("disk_size_gb") {
+						return fmt.Errorf("disk_size_gb can only be increased")
+					}
+					return nil
+				},
+				update: func(ctx context.Context, plan *UpdatePlan) (interface{}, error) {
+					return PatchCloudSQLInstance(ctx, plan.Config.String("instance_name"), plan.Changes.String("tier"), int64(plan.Changes.Int("disk_size_gb")))
+				},
+				power: func(ctx context.Context, config Params, running bool) (*PowerResult, error) {
+					return SetCloudSQLInstancePower(ctx, config.String("instance_name"), running)
+				},
+				priced:   []string{"tier", "database_version", "region"},
+				estimate: estimateCloudSQL,
+				create: func(ctx context.Context, params Params) (*CreateResult, error) {
+					// Fetch Project ID dynamically
+					projectID, err := ProjectID(ctx)
+					if err != nil {
+						return nil, fmt.Errorf("failed to fetch GCP project ID: %v", err)
+					}
+
+					result, err := CreateCloudSQLInstance(ctx, params.String("instance_name"), projectID, params.String("region"), params.String("tier"), params.String("database_version"))
+					if err != nil {
+						return nil, err
+					}
+					config := Params{
+						"instance_name":    params.String("instance_name"),
+						"region":           params.String("region"),
+						"tier":             params.String("tier"),
+						"database_version": params.String("database_version"),
+					}
+					return &CreateResult{Config: config, Result: result}, nil
+				},
+				describe: func(ctx context.Context, config Params) (*Description, error) {
+					return DescribeCloudSQLInstance(ctx, config.String("instance_name"))
+				},
+				delete: func(ctx context.Context, config Params) (*DeleteResult, error) {
+					result, err := DeleteCloudSQLInstance(ctx, config.String("instance_name"))
+					if err != nil {
+						return nil, err
+					}
+					return &DeleteResult{Message: "Cloud SQL instance deleted successfully", Result: result, Deleted: true}, nil
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,153 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	container "cloud.google.com/go/container/apiv1"
+	"cloud.google.com/go/container/apiv1/containerpb"
+	"google.golang.org/api/sqladmin/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// SetComputeEngineMachineType changes the machine type of a Compute Engine instance.
+// The type can only be changed while the instance is stopped, so a running instance is stopped and started again.
+func SetComputeEngineMachineType(ctx context.Context, instanceName, zone, machineType string) (string, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return "", err
+	}
+	client, err := compute.NewInstancesRESTClient(ctx, opts...)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Compute Engine client: %v", err)
+	}
+	defer client.Close()
+
+	projectID, err := ProjectID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to fetch project ID: %v", err)
+	}
+
+	instance, err := client.Get(ctx, &computepb.GetInstanceRequest{
+		Project:  projectID,
+		Zone:     zone,
+		Instance: instanceName,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe Compute Engine instance: %v", err)
+	}
+	wasRunning := instance.GetStatus() != "TERMINATED" && instance.GetStatus() != "STOPPED"
+
+	if wasRunning {
+		op, err := client.Stop(ctx, &computepb.StopInstanceRequest{
+			Project:  projectID,
+			Zone:     zone,
+			Instance: instanceName,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to stop Compute Engine instance: %v", err)
+		}
+		if err := op.Wait(ctx); err != nil {
+			return "", fmt.Errorf("Compute Engine instance did not stop: %v", err)
+		}
+	}
+
+	op, err := client.SetMachineType(ctx, &computepb.SetMachineTypeInstanceRequest{
+		Project:  projectID,
+		Zone:     zone,
+		Instance: instanceName,
+		InstancesSetMachineTypeRequestResource: &computepb.InstancesSetMachineTypeRequest{
+			MachineType: proto.String(fmt.Sprintf("zones/%s/machineTypes/%s", zone, machineType)),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to change Compute Engine machine type: %v", err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return "", fmt.Errorf("failed to change Compute Engine machine type: %v", err)
+	}
+
+	if wasRunning {
+		_, err = client.Start(ctx, &computepb.StartInstanceRequest{
+			Project:  projectID,
+			Zone:     zone,
+			Instance: instanceName,
+		})
+		if err != nil {
+			return "", fmt.Errorf("machine type changed but the Compute Engine instance failed to start: %v", err)
+		}
+	}
+
+	return fmt.Sprintf("Compute Engine instance '%s' changed to %s", instanceName, machineType), nil
+}
+
+// ResizeGKECluster sets the number of nodes of a GKE cluster's node pool.
+// An empty nodePool resizes the cluster's first pool, which is the only one for clusters created by the platform.
+func ResizeGKECluster(ctx context.Context, clusterName, location, nodePool string, nodeCount int) (*containerpb.Operation, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := container.NewClusterManagerClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GKE client: %v", err)
+	}
+	defer client.Close()
+
+	projectID, err := ProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
+	}
+
+	clusterPath := fmt.Sprintf("projects/%s/locations/%s/clusters/%s", projectID, location, clusterName)
+	if nodePool == "" {
+		cluster, err := client.GetCluster(ctx, &containerpb.GetClusterRequest{Name: clusterPath})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe GKE cluster: %v", err)
+		}
+		if len(cluster.GetNodePools()) == 0 {
+			return nil, fmt.Errorf("GKE cluster '%s' has no node pools", clusterName)
+		}
+		nodePool = cluster.GetNodePools()[0].GetName()
+	}
+
+	op, err := client.SetNodePoolSize(ctx, &containerpb.SetNodePoolSizeRequest{
+		Name:      fmt.Sprintf("%s/nodePools/%s", clusterPath, nodePool),
+		NodeCount: int32(nodeCount),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resize GKE cluster: %v", err)
+	}
+	return op, nil
+}
+
+// PatchCloudSQLInstance changes the tier and data disk size (in GB) of a Cloud SQL instance.
+// Empty or zero arguments are left unchanged.
+func PatchCloudSQLInstance(ctx context.Context, instanceName, tier string, diskSizeGB int64) (*sqladmin.Operation, error) {
+	opts, err := gcpClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sqladmin.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud SQL client: %v", err)
+	}
+
+	projectID, err := ProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch project ID: %v", err)
+	}
+
+	settings := &sqladmin.Settings{Tier: tier}
+	if diskSizeGB > 0 {
+		settings.DataDiskSizeGb = diskSizeGB
+	}
+
+	op, err := client.Instances.Patch(projectID, instanceName, &sqladmin.DatabaseInstance{Settings: settings}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to update Cloud SQL instance: %v", err)
+	}
+	return op, nil
+}
@@ -0,0 +1,93 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LegacyAWSRegion is where EC2, S3 and RDS resources were created before the region was recorded in their config
+const LegacyAWSRegion = "us-east-1"
+
+var (
+	awsRegionPattern = regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]?)?-[a-z]+-\d$`)
+	gcpRegionPattern = regexp.MustCompile(`^[a-z]+-[a-z]+\d+$`)
+	gcpZonePattern   = regexp.MustCompile(`^([a-z]+-[a-z]+\d+)-[a-z]$`)
+
+	// multi-regions accepted by Cloud Storage and BigQuery
+	gcpMultiRegionPattern = regexp.MustCompile(`^(?i)(us|eu|asia)$`)
+)
+
+// ValidRegion reports whether region is a well-formed region name for the provider
+func ValidRegion(provider, region string) bool {
+	switch provider {
+	case "aws":
+		return awsRegionPattern.MatchString(region)
+	case "gcp":
+		return gcpRegionPattern.MatchString(region) || gcpMultiRegionPattern.MatchString(region)
+	}
+	return false
+}
+
+// validateLocation checks the format of the region, and of the zone for zonal services
+func validateLocation(provider string, zonal bool, params Params) error {
+	region := params.String("region")
+
+	if !ValidRegion(provider, region) {
+		return fmt.Errorf("invalid %s region '%s'", strings.ToUpper(provider), region)
+	}
+
+	if provider == "gcp" && zonal {
+		zone := params.String("zone")
+		match := gcpZonePattern.FindStringSubmatch(zone)
+		if match == nil {
+			return fmt.Errorf("invalid GCP zone '%s'", zone)
+		}
+		if match[1] != region {
+			return fmt.Errorf("zone '%s' is not in region '%s'", zone, region)
+		}
+	}
+	return nil
+}
+
+// AllowedRegions returns the regions the tenant in ctx may use for a provider.
+// Without a tenant list the deployment wide ALLOWED_AWS_REGIONS / ALLOWED_GCP_REGIONS apply; an empty result allows any region.
+func AllowedRegions(ctx context.Context, provider string) []string {
+	if tenant := TenantFromContext(ctx); tenant != nil {
+		switch provider {
+		case "aws":
+			if len(tenant.AWSRegions) > 0 {
+				return tenant.AWSRegions
+			}
+		case "gcp":
+			if len(tenant.GCPRegions) > 0 {
+				return tenant.GCPRegions
+			}
+		}
+	}
+
+	return settings.AllowedRegions[provider]
+}
+
+// CheckRegionAllowed rejects regions outside the allow-list of the tenant in ctx
+func CheckRegionAllowed(ctx context.Context, provider, region string) error {
+	allowed := AllowedRegions(ctx, provider)
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, candidate := range allowed {
+		if candidate == region {
+			return nil
+		}
+	}
+	return fmt.Errorf("region '%s' is not allowed; allowed %s regions: %s", region, strings.ToUpper(provider), strings.Join(allowed, ", "))
+}
+
+// awsRegion returns the region stored in a service config
+func awsRegion(config Params) string {
+	if region := config.String("region"); region != "" {
+		return region
+	}
+	return LegacyAWSRegion
+}
@@ -0,0 +1,225 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	billing "cloud.google.com/go/billing/apiv1"
+	"cloud.google.com/go/billing/apiv1/billingpb"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/pricing"
+	"github.com/aws/aws-sdk-go-v2/service/pricing/types"
+	"google.golang.org/api/iterator"
+)
+
+// Estimates are quarterly
+const hoursPerQuarter = float64(24 * 90) // 24 hours/day * 90 days
+
+// roundCost rounds to 2 decimal places for clarity
+func roundCost(cost float64) float64 {
+	return math.Round(cost*100) / 100
+}
+
+// AccruedCost prorates a quarterly estimate over the time a service has been running.
+// A zero end means the service is still running.
+func AccruedCost(quarterlyEstimate float64, start, end time.Time) float64 {
+	if start.IsZero() {
+		return 0
+	}
+	if end.IsZero() {
+		end = time.Now()
+	}
+	hours := end.Sub(start).Hours()
+	if hours <= 0 {
+		return 0
+	}
+	return roundCost(quarterlyEstimate * hours / hoursPerQuarter)
+}
+
+// PeriodCost scales a quarterly estimate to the part of a budget period that is left. The service runs for its
+// expected hours, or for the rest of the period when it has none.
+func PeriodCost(quarterlyEstimate float64, params Params, left time.Duration) float64 {
+	run := left.Hours()
+	if expected := params.Float(ExpectedHoursParam); expected > 0 && expected < run {
+		run = expected
+	}
+	if run <= 0 {
+		return 0
+	}
+	return roundCost(quarterlyEstimate * run / pricedHours(params))
+}
+
+// HourlyRate is the cost per running hour behind a quarterly estimate
+func HourlyRate(quarterlyEstimate float64, params Params) float64 {
+	return quarterlyEstimate / pricedHours(params)
+}
+
+// fetchAWSServicePrice returns the first on-demand USD price matching the filters, with its unit
+func fetchAWSServicePrice(serviceCode string, filters []types.Filter) (float64, string, error) {
+	// Load AWS configuration (pricing data is only available in us-east-1 region)
+	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("us-east-1"))
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to load AWS config: %v", err)
+	}
+
+	// Create AWS Pricing client
+	client := pricing.NewFromConfig(cfg)
+
+	// Define input for Pricing API
+	input := &pricing.GetProductsInput{
+		ServiceCode:   aws.String(serviceCode),
+		Filters:       filters,
+		FormatVersion: aws.String("aws_v1"),
+	}
+
+	// Fetch pricing data from AWS Pricing API
+	result, err := client.GetProducts(context.TODO(), input)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to fetch AWS pricing data: %v", err)
+	}
+
+	// Parse the pricing data to extract the hourly price
+	for _, priceItem := range result.PriceList {
+		var priceData map[string]interface{}
+		if err := json.Unmarshal([]byte(priceItem), &priceData); err != nil {
+			continue // Skip items that fail to parse
+		}
+
+		if terms, ok := priceData["terms"].(map[string]interface{}); ok {
+			if onDemand, ok := terms["OnDemand"].(map[string]interface{}); ok {
+				for _, term := range onDemand {
+					if priceDimensions, ok := term.(map[string]interface{})["priceDimensions"].(map[string]interface{}); ok {
+						for _, dimension := range priceDimensions {
+							if pricePerUnit, ok := dimension.(map[string]interface{})["pricePerUnit"].(map[string]interface{}); ok {
+								if usdPrice, ok := pricePerUnit["USD"].(string); ok {
+									// Convert price to float64
+									price, err := strconv.ParseFloat(usdPrice, 64)
+									if err != nil {
+										continue
+									}
+									unit, _ := dimension.(map[string]interface{})["unit"].(string)
+									return price, unit, nil
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return 0, "", fmt.Errorf("price data not found for service: %s", serviceCode)
+}
+
+// termMatch builds an AWS Pricing API filter
+func termMatch(field, value string) types.Filter {
+	return types.Filter{Field: aws.String(field), Value: aws.String(value), Type: types.FilterTypeTermMatch}
+}
+
+// FetchGCPServicePrice fetches the dynamic pricing for a GCP service using Billing API.
+func FetchGCPServicePrice(service string) (float64, string, error) {
+	ctx := context.Background()
+
+	// Create a Cloud Catalog client
+	client, err := billing.NewCloudCatalogClient(ctx)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create Cloud Catalog client: %v", err)
+	}
+	defer client.Close()
+
+	// Map service names to their display names in the Cloud Catalog
+	serviceMap := map[string]string{
+		"Compute Engine": "Compute Engine",
+		"Cloud Storage":  "Cloud Storage",
+		"Cloud SQL":      "Cloud SQL",
+	}
+
+	displayName, exists := serviceMap[service]
+	if !exists {
+		return 0, "", fmt.Errorf("unsupported GCP service: %s", service)
+	}
+
+	// List all services and find the matching one
+	req := &billingpb.ListServicesRequest{}
+	it := client.ListServices(ctx, req)
+	for {
+		svc, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return 0, "", fmt.Errorf("error while iterating services: %v", err)
+		}
+
+		// Match service by display name
+		if svc.DisplayName == displayName {
+			// List SKUs for the matched service
+			skuReq := &billingpb.ListSkusRequest{
+				Parent: svc.Name,
+			}
+			skuIt := client.ListSkus(ctx, skuReq)
+			for {
+				sku, err := skuIt.Next()
+				if err == iterator.Done {
+					break
+				}
+				if err != nil {
+					return 0, "", fmt.Errorf("error while iterating SKUs: %v", err)
+				}
+
+				// **Compute Engine**: Focus on general-purpose n1-standard-1 vCPUs
+				if service == "Compute Engine" && sku.Category.ResourceFamily == "Compute" {
+					if strings.Contains(sku.Description, "N1 Predefined Instance Core") {
+						if sku.PricingInfo != nil && len(sku.PricingInfo) > 0 {
+							pricing := sku.PricingInfo[0].PricingExpression
+							if len(pricing.TieredRates) > 0 && pricing.UsageUnit == "h" {
+								price := pricing.TieredRates[0].UnitPrice
+								return float64(price.Units) + float64(price.Nanos)/1e9, pricing.UsageUnitDescription, nil
+							}
+						}
+					}
+				}
+
+				// **Cloud SQL**: Focus on MySQL, PostgreSQL, or SQL Server SKUs
+				if service == "Cloud SQL" && sku.Category.ResourceFamily == "Database" {
+					// Match descriptions for typical configurations
+					if strings.Contains(sku.Description, "MySQL") ||
+						strings.Contains(sku.Description, "PostgreSQL") ||
+						strings.Contains(sku.Description, "SQL Server") ||
+						strings.Contains(sku.Description, "db-n1-standard-4") {
+
+						// Ensure pricing info exists
+						if sku.PricingInfo != nil && len(sku.PricingInfo) > 0 {
+							pricing := sku.PricingInfo[0].PricingExpression
+
+							// Ensure hourly pricing
+							if len(pricing.TieredRates) > 0 && pricing.UsageUnit == "h" {
+								// Extract the first tier price
+								price := pricing.TieredRates[0].UnitPrice
+								return float64(price.Units) + float64(price.Nanos)/1e9, pricing.UsageUnitDescription, nil
+							}
+						}
+					}
+				}
+				// **Cloud Storage**: No changes, already correct
+				if service == "Cloud Storage" && sku.Category.ResourceFamily == "Storage" {
+					if strings.Contains(sku.Description, "Standard Storage") && sku.PricingInfo != nil {
+						pricing := sku.PricingInfo[0].PricingExpression
+						if len(pricing.TieredRates) > 0 && pricing.UsageUnit == "GiBy.mo" {
+							price := pricing.TieredRates[0].UnitPrice
+							return float64(price.Units) + float64(price.Nanos)/1e9, pricing.UsageUnitDescription, nil
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return 0, "", fmt.Errorf("pricing data not found for service: %s", service)
+}
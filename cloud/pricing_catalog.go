@@ -0,0 +1,360 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"multitenant/models"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrPriceNotCached is returned by a PriceStore for keys it holds no price for
+var ErrPriceNotCached = errors.New("price not in the pricing catalog")
+
+// PriceKey identifies one unit price: a SKU of a provider's service in a region
+type PriceKey struct {
+	Provider   string
+	Service    string // AWS service code or GCP catalog service name
+	Region     string
+	Attributes map[string]string // AWS price list attributes, or the SKU selectors of gcpSKUFor
+}
+
+// String returns the canonical form of the key, with the attributes sorted
+func (k PriceKey) String() string {
+	attributes := make([]string, 0, len(k.Attributes))
+	for name, value := range k.Attributes {
+		attributes = append(attributes, name+"="+value)
+	}
+	sort.Strings(attributes)
+	return strings.Join([]string{k.Provider, k.Service, k.Region, strings.Join(attributes, ",")}, "|")
+}
+
+// catalogPrice builds the catalog entry of a price fetched for the key
+func (k PriceKey) catalogPrice(price float64, unit, source string) models.CatalogPrice {
+	return models.CatalogPrice{
+		Key:        k.String(),
+		Provider:   k.Provider,
+		Service:    k.Service,
+		Region:     k.Region,
+		Attributes: k.Attributes,
+		Price:      price,
+		Unit:       unit,
+		Source:     source,
+		FetchedAt:  time.Now(),
+	}
+}
+
+// PriceStore holds the pricing catalog
+type PriceStore interface {
+	GetPrice(key string) (*models.CatalogPrice, error) // ErrPriceNotCached when missing
+	PutPrices(prices []models.CatalogPrice) error
+	ListPrices() ([]models.CatalogPrice, error)
+}
+
+var (
+	pricingMu      sync.RWMutex
+	priceStore     PriceStore
+	priceMaxAge    time.Duration
+	pricingOffline bool
+)
+
+// UsePriceStore makes estimates read prices from store. Prices older than maxAge (never when zero) are fetched again.
+// Offline stores never call the pricing APIs: a missing price fails the estimate.
+func UsePriceStore(store PriceStore, maxAge time.Duration, offline bool) {
+	pricingMu.Lock()
+	defer pricingMu.Unlock()
+	priceStore, priceMaxAge, pricingOffline = store, maxAge, offline
+}
+
+func pricingSettings() (PriceStore, time.Duration, bool) {
+	pricingMu.RLock()
+	defer pricingMu.RUnlock()
+	return priceStore, priceMaxAge, pricingOffline
+}
+
+// lookupPrices returns the price of each key, from the catalog when it holds a fresh one.
+// Missing and stale keys are fetched together and written back; a stale price is used if the fetch fails.
+func lookupPrices(keys ...PriceKey) ([]float64, error) {
+	store, maxAge, offline := pricingSettings()
+	if store == nil {
+		fetched, err := fetchPrices(keys)
+		if err != nil {
+			return nil, err
+		}
+		return catalogPrices(fetched), nil
+	}
+
+	prices := make([]float64, len(keys))
+	stale := map[int]*models.CatalogPrice{}
+	var missing []PriceKey
+	var missingAt []int
+	for i, key := range keys {
+		cached, err := store.GetPrice(key.String())
+		if err != nil && !errors.Is(err, ErrPriceNotCached) {
+			return nil, err
+		}
+		if cached != nil && (offline || maxAge == 0 || time.Since(cached.FetchedAt) < maxAge) {
+			prices[i] = cached.Price
+			continue
+		}
+		if cached != nil {
+			stale[i] = cached
+		}
+		missing = append(missing, key)
+		missingAt = append(missingAt, i)
+	}
+	if len(missing) == 0 {
+		return prices, nil
+	}
+	if offline {
+		return nil, fmt.Errorf("%w: %s", ErrPriceNotCached, missing[0])
+	}
+
+	fetched, err := fetchPrices(missing)
+	if err != nil {
+		if len(stale) < len(missing) {
+			return nil, err
+		}
+		log.Printf("Using stale prices, refetch failed: %v", err)
+		for _, i := range missingAt {
+			prices[i] = stale[i].Price
+		}
+		return prices, nil
+	}
+	if err := store.PutPrices(fetched); err != nil {
+		log.Printf("Failed to cache prices: %v", err)
+	}
+	for j, i := range missingAt {
+		prices[i] = fetched[j].Price
+	}
+	return prices, nil
+}
+
+func catalogPrices(entries []models.CatalogPrice) []float64 {
+	prices := make([]float64, len(entries))
+	for i, entry := range entries {
+		prices[i] = entry.Price
+	}
+	return prices
+}
+
+// fetchPrices fetches keys of any provider from the pricing APIs, in the order given.
+// AWS keys are fetched one by one; GCP keys of the same service share one pass over the Cloud Catalog.
+func fetchPrices(keys []PriceKey) ([]models.CatalogPrice, error) {
+	fetched := make([]models.CatalogPrice, len(keys))
+	gcpByService := map[string][]int{}
+	for i, key := range keys {
+		switch key.Provider {
+		case "aws":
+			price, err := fetchAWSPrice(key)
+			if err != nil {
+				return nil, err
+			}
+			fetched[i] = price
+		case "gcp":
+			gcpByService[key.Service] = append(gcpByService[key.Service], i)
+		default:
+			return nil, fmt.Errorf("no pricing source for provider %q", key.Provider)
+		}
+	}
+
+	for service, indexes := range gcpByService {
+		serviceKeys := make([]PriceKey, len(indexes))
+		for j, i := range indexes {
+			serviceKeys[j] = keys[i]
+		}
+		prices, err := fetchGCPSKUPrices(service, serviceKeys)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range indexes {
+			fetched[i] = prices[j]
+		}
+	}
+	return fetched, nil
+}
+
+// RefreshPricingCatalog fetches again every price in the catalog, plus the defaults of the allowed regions
+// so that first estimates are served locally too
+func RefreshPricingCatalog() (*models.PricingRefreshResult, error) {
+	result := &models.PricingRefreshResult{StartedAt: time.Now()}
+	store, _, offline := pricingSettings()
+	if store == nil || offline {
+		return nil, errors.New("no pricing catalog to refresh")
+	}
+
+	cached, err := store.ListPrices()
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]PriceKey{}
+	for _, entry := range cached {
+		if entry.Source == "fixture" {
+			continue
+		}
+		key := PriceKey{Provider: entry.Provider, Service: entry.Service, Region: entry.Region, Attributes: entry.Attributes}
+		keys[key.String()] = key
+	}
+	for _, key := range seedPriceKeys() {
+		keys[key.String()] = key
+	}
+
+	// One request per AWS key and one catalog pass per GCP service; a failure only loses its own batch
+	batches := map[string][]PriceKey{}
+	for _, key := range keys {
+		batch := key.String()
+		if key.Provider == "gcp" {
+			batch = "gcp|" + key.Service
+		}
+		batches[batch] = append(batches[batch], key)
+	}
+	for _, batch := range batches {
+		fetched, err := fetchPrices(batch)
+		if err != nil {
+			result.Failed += len(batch)
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		if err := store.PutPrices(fetched); err != nil {
+			return result, err
+		}
+		result.Refreshed += len(fetched)
+	}
+	return result, nil
+}
+
+// seedPriceKeys lists the prices of the default configurations in each allowed region, or in the default regions
+func seedPriceKeys() []PriceKey {
+	awsRegions := AllowedRegions(context.Background(), "aws")
+	if len(awsRegions) == 0 {
+		awsRegions = []string{LegacyAWSRegion}
+	}
+	gcpRegions := AllowedRegions(context.Background(), "gcp")
+	if len(gcpRegions) == 0 {
+		gcpRegions = []string{defaultGCPRegion}
+	}
+
+	var keys []PriceKey
+	for _, region := range awsRegions {
+		keys = append(keys,
+			ec2InstanceKey(defaultEC2InstanceType, region),
+			s3StorageKey(region),
+			rdsInstanceKey(defaultRDSInstanceType, rdsEngines[defaultRDSEngine], region),
+			rdsStorageKey(rdsEngines[defaultRDSEngine], region),
+		)
+	}
+	for _, region := range gcpRegions {
+		keys = append(keys,
+			gceKey("E2", "Core", region), gceKey("E2", "Ram", region),
+			gceKey("N1", "Core", region), gceKey("N1", "Ram", region),
+			gcsStorageKey(region),
+		)
+		for _, resource := range []string{"vCPU", "RAM", "Standard storage"} {
+			keys = append(keys, cloudSQLKey("MySQL", resource, region))
+		}
+	}
+	return keys
+}
+
+// PricingCatalogStatus reports the size and freshness of the pricing catalog, or nil when estimates use the APIs directly
+func PricingCatalogStatus() (*models.PricingCatalogStatus, error) {
+	store, maxAge, offline := pricingSettings()
+	if store == nil {
+		return nil, nil
+	}
+	entries, err := store.ListPrices()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.PricingCatalogStatus{Entries: len(entries), Offline: offline}
+	if maxAge > 0 {
+		status.MaxAge = maxAge.String()
+	}
+	for i := range entries {
+		fetchedAt := entries[i].FetchedAt
+		if status.Oldest == nil || fetchedAt.Before(*status.Oldest) {
+			status.Oldest = &fetchedAt
+		}
+		if status.Newest == nil || fetchedAt.After(*status.Newest) {
+			status.Newest = &fetchedAt
+		}
+		if !offline && maxAge > 0 && time.Since(fetchedAt) >= maxAge {
+			status.Stale++
+		}
+	}
+	return status, nil
+}
+
+// LoadPriceFixture reads a pricing catalog from a JSON file holding an array of entries with provider, service,
+// region, attributes, price and unit. Keys and fetch times are filled in.
+func LoadPriceFixture(path string) ([]models.CatalogPrice, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pricing fixture: %v", err)
+	}
+	var entries []models.CatalogPrice
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid pricing fixture %s: %v", path, err)
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		key := PriceKey{Provider: entry.Provider, Service: entry.Service, Region: entry.Region, Attributes: entry.Attributes}
+		entry.Key = key.String()
+		entry.Source = "fixture"
+		if entry.FetchedAt.IsZero() {
+			entry.FetchedAt = time.Now()
+		}
+	}
+	return entries, nil
+}
+
+// MemoryPriceStore is a PriceStore kept in memory, used with fixture catalogs
+type MemoryPriceStore struct {
+	mu     sync.RWMutex
+	prices map[string]models.CatalogPrice
+}
+
+// NewMemoryPriceStore returns a store holding the given prices
+func NewMemoryPriceStore(prices []models.CatalogPrice) *MemoryPriceStore {
+	store := &MemoryPriceStore{prices: map[string]models.CatalogPrice{}}
+	store.PutPrices(prices)
+	return store
+}
+
+func (s *MemoryPriceStore) GetPrice(key string) (*models.CatalogPrice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	price, ok := s.prices[key]
+	if !ok {
+		return nil, ErrPriceNotCached
+	}
+	return &price, nil
+}
+
+func (s *MemoryPriceStore) PutPrices(prices []models.CatalogPrice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, price := range prices {
+		s.prices[price.Key] = price
+	}
+	return nil
+}
+
+func (s *MemoryPriceStore) ListPrices() ([]models.CatalogPrice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	prices := make([]models.CatalogPrice, 0, len(s.prices))
+	for _, price := range s.prices {
+		prices = append(prices, price)
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Key < prices[j].Key })
+	return prices, nil
+}
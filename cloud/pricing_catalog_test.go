@@ -0,0 +1,129 @@
+package cloud
+
+import (
+	"errors"
+	"math"
+	"multitenant/models"
+	"testing"
+	"time"
+)
+
+// useFixtureCatalog serves estimates from cloud/testdata/pricing_catalog.json until the test ends
+func useFixtureCatalog(t *testing.T) []models.CatalogPrice {
+	t.Helper()
+	entries, err := LoadPriceFixture("testdata/pricing_catalog.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	UsePriceStore(NewMemoryPriceStore(entries), 0, true)
+	t.Cleanup(func() { UsePriceStore(nil, 0, false) })
+	return entries
+}
+
+func TestLoadPriceFixture(t *testing.T) {
+	entries := useFixtureCatalog(t)
+	if len(entries) != 14 {
+		t.Fatalf("loaded %d prices, want 14", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.Source != "fixture" || entry.FetchedAt.IsZero() {
+			t.Errorf("%s: source %q fetched at %v, want a fixture with a fetch time", entry.Key, entry.Source, entry.FetchedAt)
+		}
+	}
+
+	// The fixture must hold the keys the estimates look up
+	tests := []struct {
+		key   PriceKey
+		price float64
+		unit  string
+	}{
+		{ec2InstanceKey("t2.micro", "us-east-1"), 0.0116, "Hrs"},
+		{ec2InstanceKey("m5.large", "us-east-1"), 0.096, "Hrs"},
+		{s3StorageKey("us-east-1"), 0.023, "GB-Mo"},
+		{rdsInstanceKey("db.t3.micro", "MySQL", "us-east-1"), 0.017, "Hrs"},
+		{rdsStorageKey("MySQL", "us-east-1"), 0.115, "GB-Mo"},
+		{gceKey("E2", "Core", "us-central1"), 0.021811, "h"},
+		{gceKey("N1", "Ram", "us-central1"), 0.004237, "GiBy.h"},
+		{gcsStorageKey("US-CENTRAL1"), 0.02, "GiBy.mo"},
+		{cloudSQLKey("MySQL", "Standard storage", "us-central1"), 0.17, "GiBy.mo"},
+	}
+	store, _, _ := pricingSettings()
+	for _, tt := range tests {
+		price, err := store.GetPrice(tt.key.String())
+		if err != nil {
+			t.Errorf("%s: %v", tt.key, err)
+			continue
+		}
+		if price.Price != tt.price || price.Unit != tt.unit {
+			t.Errorf("%s = %v %s, want %v %s", tt.key, price.Price, price.Unit, tt.price, tt.unit)
+		}
+	}
+}
+
+func TestEstimatesFromFixtureCatalog(t *testing.T) {
+	useFixtureCatalog(t)
+
+	tests := []struct {
+		name     string
+		estimate func(Params) (float64, error)
+		params   Params
+		want     float64
+	}{
+		{"EC2 default type", estimateEC2, Params{}, roundCost(0.0116 * hoursPerQuarter)},
+		{"EC2 expected hours", estimateEC2, Params{"instance_type": "t3.small", ExpectedHoursParam: 100.0}, roundCost(0.0208 * 100)},
+		{"S3", estimateS3, Params{"region": "us-east-1"}, quarterlyStorageCost(0.023, defaultStorageGB)},
+		{"Compute Engine shared core", estimateComputeEngine, Params{"zone": "us-central1-a"}, roundCost((1*0.021811 + 4*0.002923) * hoursPerQuarter)},
+		{"Compute Engine N1", estimateComputeEngine, Params{"machine_type": "n1-standard-2"}, roundCost((2*0.031611 + 7.5*0.004237) * hoursPerQuarter)},
+		{"Cloud Storage", estimateCloudStorage, Params{"storage_gb": 10.0}, quarterlyStorageCost(0.02, 10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.estimate(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-tt.want) > 0.005 {
+				t.Fatalf("estimate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// An offline catalog never calls the pricing APIs for a missing price
+	if _, err := estimateEC2(Params{"region": "eu-west-1"}); !errors.Is(err, ErrPriceNotCached) {
+		t.Fatalf("estimate outside the fixture: %v, want ErrPriceNotCached", err)
+	}
+}
+
+func TestLookupPricesMaxAge(t *testing.T) {
+	// Keys of an unknown provider are never fetched, so a refetch always fails without calling any API
+	fresh := PriceKey{Provider: "test", Service: "fresh", Region: "local"}
+	stale := PriceKey{Provider: "test", Service: "stale", Region: "local"}
+	missing := PriceKey{Provider: "test", Service: "missing", Region: "local"}
+	freshPrice := fresh.catalogPrice(1.5, "h", "fixture")
+	stalePrice := stale.catalogPrice(2.5, "h", "fixture")
+	stalePrice.FetchedAt = time.Now().Add(-2 * time.Hour)
+	store := NewMemoryPriceStore([]models.CatalogPrice{freshPrice, stalePrice})
+
+	UsePriceStore(store, time.Hour, false)
+	t.Cleanup(func() { UsePriceStore(nil, 0, false) })
+
+	prices, err := lookupPrices(fresh, stale)
+	if err != nil {
+		t.Fatalf("stale price not used when the refetch failed: %v", err)
+	}
+	if prices[0] != 1.5 || prices[1] != 2.5 {
+		t.Fatalf("prices = %v, want [1.5 2.5]", prices)
+	}
+	if _, err := lookupPrices(fresh, missing); err == nil {
+		t.Fatal("missing price with a failed fetch succeeded")
+	}
+
+	// Offline, any cached price is served however old it is
+	UsePriceStore(store, time.Hour, true)
+	if _, err := lookupPrices(stale); err != nil {
+		t.Fatalf("offline stale price: %v", err)
+	}
+	if _, err := lookupPrices(missing); !errors.Is(err, ErrPriceNotCached) {
+		t.Fatalf("offline missing price: %v, want ErrPriceNotCached", err)
+	}
+}
@@ -0,0 +1,418 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrCostNotSupported is returned by EstimateCost for services without a pricing model
+var ErrCostNotSupported = errors.New("cost calculation is not supported for this service")
+
+// ErrDiscoveryNotSupported is returned by Discover for services whose resources cannot be listed
+var ErrDiscoveryNotSupported = errors.New("discovery is not supported for this service")
+
+// ErrPowerNotSupported is returned by Start and Stop for services that cannot be stopped while keeping their data
+var ErrPowerNotSupported = errors.New("start and stop are not supported for this service")
+
+// ErrUpdateNotSupported is returned by PlanUpdate and Update for services that cannot be changed in place
+var ErrUpdateNotSupported = errors.New("updates are not supported for this service")
+
+// Params holds a service configuration as sent by the UI and stored in the `config` of a session/service
+type Params map[string]interface{}
+
+// String returns the string value stored under key, or "" when missing
+func (p Params) String(key string) string {
+	value, _ := p[key].(string)
+	return value
+}
+
+// Int returns the numeric value stored under key as an int.
+// JSON payloads decode numbers as float64 while MongoDB returns int32/int64.
+func (p Params) Int(key string) int {
+	switch value := p[key].(type) {
+	case int:
+		return value
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	case float64:
+		return int(value)
+	}
+	return 0
+}
+
+// Float returns the numeric value stored under key as a float64
+func (p Params) Float(key string) float64 {
+	switch value := p[key].(type) {
+	case int:
+		return float64(value)
+	case int32:
+		return float64(value)
+	case int64:
+		return float64(value)
+	case float64:
+		return value
+	}
+	return 0
+}
+
+// Bool returns the boolean value stored under key
+func (p Params) Bool(key string) bool {
+	value, _ := p[key].(bool)
+	return value
+}
+
+// Require checks that every key is present and not empty
+func (p Params) Require(keys ...string) error {
+	var missing []string
+	for _, key := range keys {
+		value, ok := p[key]
+		if !ok || value == nil || value == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required fields: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// CreateResult is returned by ServiceType.Create
+type CreateResult struct {
+	Config Params      // configuration to persist, including IDs assigned by the provider
+	Result interface{} // raw provider response returned to the UI
+}
+
+// Description is the live state of a resource as reported by its provider
+type Description struct {
+	Exists     bool   // false when the resource no longer exists
+	State      string // provider specific state, e.g. "running", "stopped", "available"
+	Attributes Params // live configuration values that can be compared to the stored config
+}
+
+// DeleteResult is returned by ServiceType.Delete
+type DeleteResult struct {
+	Message string      // human readable outcome
+	Result  interface{} // raw provider response
+	Deleted bool        // false when the provider refused the deletion (e.g. VPC dependencies)
+}
+
+// PowerResult is returned by ServiceType.Start and ServiceType.Stop
+type PowerResult struct {
+	State  string      // provider state right after the call, e.g. "stopping" or "pending"
+	Result interface{} // raw provider response
+}
+
+// DiscoveredResource is a resource found in an account by ServiceType.Discover
+type DiscoveredResource struct {
+	ID      string // provider assigned unique ID, e.g. the instance ID or bucket name
+	IDField string // config key the ID is stored under
+	State   string // provider specific state
+	Config  Params // config as Create would have stored it, so Describe and Delete work on it
+}
+
+// UpdatePlan is a validated change to a resource's config, built by ServiceType.PlanUpdate
+type UpdatePlan struct {
+	Config  Params // config after the update
+	Changes Params // fields whose value changes, converted to the type stored in the config
+}
+
+// UpdateResult is returned by ServiceType.Update
+type UpdateResult struct {
+	Config Params      // configuration to persist as the new revision
+	Result interface{} // raw provider response
+}
+
+// ServiceType describes one kind of cloud resource that users can provision
+type ServiceType interface {
+	Provider() string             // provider name, "aws" or "gcp"
+	Name() string                 // catalog name stored as `service` in sessions and services
+	Path() string                 // route under /user used to create the service
+	IdentifierField() string      // config key identifying the resource in delete requests and status updates
+	Validate(params Params) error // checks required fields and the region (and zone) format
+	EstimateCost(params Params) (float64, error)
+	Create(ctx context.Context, params Params) (*CreateResult, error)
+	Describe(ctx context.Context, config Params) (*Description, error)
+	Delete(ctx context.Context, config Params) (*DeleteResult, error)
+	Discover(ctx context.Context, region string) ([]DiscoveredResource, error) // lists the resources of this type in a region
+	PlanUpdate(config, changes Params) (*UpdatePlan, error)                    // checks a change against the stored config without calling the provider
+	Update(ctx context.Context, plan *UpdatePlan) (*UpdateResult, error)
+	Start(ctx context.Context, config Params) (*PowerResult, error) // starts a stopped resource
+	Stop(ctx context.Context, config Params) (*PowerResult, error)  // stops a resource, keeping its disks and data
+}
+
+// Provider groups the service types offered by one cloud
+type Provider interface {
+	Name() string
+	Services() []ServiceType
+}
+
+var (
+	registryMu sync.RWMutex
+	providers  = map[string]Provider{}
+)
+
+// RegisterProvider adds a provider to the registry, replacing any provider with the same name
+func RegisterProvider(p Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	providers[p.Name()] = p
+}
+
+// GetProvider returns the registered provider with the given name
+func GetProvider(name string) (Provider, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+// Providers returns all registered providers sorted by name
+func Providers() []Provider {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	list := make([]Provider, 0, len(providers))
+	for _, p := range providers {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// AllServices returns the service types of every registered provider
+func AllServices() []ServiceType {
+	var services []ServiceType
+	for _, p := range Providers() {
+		services = append(services, p.Services()...)
+	}
+	return services
+}
+
+// LookupService finds a service type by provider and catalog name
+func LookupService(provider, name string) (ServiceType, error) {
+	p, ok := GetProvider(provider)
+	if !ok {
+		return nil, fmt.Errorf("unsupported cloud provider: %s", provider)
+	}
+	for _, svc := range p.Services() {
+		if svc.Name() == name {
+			return svc, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported %s service: %s", provider, name)
+}
+
+// provider is the Provider implementation used by the built-in clouds
+type provider struct {
+	name     string
+	services []ServiceType
+}
+
+func (p *provider) Name() string            { return p.name }
+func (p *provider) Services() []ServiceType { return p.services }
+
+// service is a ServiceType assembled from plain functions
+type service struct {
+	provider        string
+	name            string
+	path            string
+	identifierField string
+	required        []string
+	zonal           bool     // true when the resource lives in a zone of its region
+	priced          []string // config fields the estimate depends on
+	estimate        func(params Params) (float64, error)
+	create          func(ctx context.Context, params Params) (*CreateResult, error)
+	describe        func(ctx context.Context, config Params) (*Description, error)
+	delete          func(ctx context.Context, config Params) (*DeleteResult, error)
+	discover        func(ctx context.Context, region string) ([]DiscoveredResource, error)
+	updatable       map[string]fieldKind // config fields that can be changed in place
+	checkUpdate     func(config, changes Params) error
+	update          func(ctx context.Context, plan *UpdatePlan) (interface{}, error)
+	power           func(ctx context.Context, config Params, running bool) (*PowerResult, error) // starts (true) or stops the resource
+}
+
+// fieldKind is the type a config field is stored as
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindInt
+	kindBool
+)
+
+// convert returns value as the kind, or false when it has another type
+func (k fieldKind) convert(value interface{}) (interface{}, bool) {
+	switch k {
+	case kindString:
+		s, ok := value.(string)
+		return s, ok && s != ""
+	case kindInt:
+		switch v := value.(type) {
+		case int:
+			return v, true
+		case int32:
+			return int(v), true
+		case int64:
+			return int(v), true
+		case float64:
+			return int(v), v == math.Trunc(v) // JSON numbers decode as float64
+		}
+		return nil, false
+	case kindBool:
+		b, ok := value.(bool)
+		return b, ok
+	}
+	return nil, false
+}
+
+func (s *service) Provider() string        { return s.provider }
+func (s *service) Name() string            { return s.name }
+func (s *service) Path() string            { return s.path }
+func (s *service) IdentifierField() string { return s.identifierField }
+
+func (s *service) Validate(params Params) error {
+	if err := params.Require(s.required...); err != nil {
+		return err
+	}
+	return validateLocation(s.provider, s.zonal, params)
+}
+
+func (s *service) EstimateCost(params Params) (float64, error) {
+	if s.estimate == nil {
+		return 0, ErrCostNotSupported
+	}
+	return s.estimate(params)
+}
+
+func (s *service) Create(ctx context.Context, params Params) (*CreateResult, error) {
+	return s.create(ctx, params)
+}
+
+func (s *service) Describe(ctx context.Context, config Params) (*Description, error) {
+	return s.describe(ctx, config)
+}
+
+func (s *service) Delete(ctx context.Context, config Params) (*DeleteResult, error) {
+	return s.delete(ctx, config)
+}
+
+func (s *service) Discover(ctx context.Context, region string) ([]DiscoveredResource, error) {
+	if s.discover == nil {
+		return nil, ErrDiscoveryNotSupported
+	}
+	return s.discover(ctx, region)
+}
+
+func (s *service) PlanUpdate(config, changes Params) (*UpdatePlan, error) {
+	if s.update == nil {
+		return nil, ErrUpdateNotSupported
+	}
+
+	plan := &UpdatePlan{Config: Params{}, Changes: Params{}}
+	for key, value := range config {
+		plan.Config[key] = value
+	}
+	for key, value := range changes {
+		kind, ok := s.updatable[key]
+		if !ok {
+			return nil, fmt.Errorf("'%s' cannot be changed; updatable fields: %s", key, strings.Join(s.updatableFields(), ", "))
+		}
+		converted, ok := kind.convert(value)
+		if !ok {
+			return nil, fmt.Errorf("invalid value for '%s'", key)
+		}
+		if fmt.Sprint(converted) == fmt.Sprint(config[key]) {
+			continue
+		}
+		plan.Config[key] = converted
+		plan.Changes[key] = converted
+	}
+	if len(plan.Changes) == 0 {
+		return nil, errors.New("the update does not change the service")
+	}
+
+	if s.checkUpdate != nil {
+		if err := s.checkUpdate(config, plan.Changes); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+func (s *service) Update(ctx context.Context, plan *UpdatePlan) (*UpdateResult, error) {
+	if s.update == nil {
+		return nil, ErrUpdateNotSupported
+	}
+	result, err := s.update(ctx, plan)
+	if err != nil {
+		return nil, err
+	}
+	return &UpdateResult{Config: plan.Config, Result: result}, nil
+}
+
+func (s *service) Start(ctx context.Context, config Params) (*PowerResult, error) {
+	if s.power == nil {
+		return nil, ErrPowerNotSupported
+	}
+	return s.power(ctx, config, true)
+}
+
+func (s *service) Stop(ctx context.Context, config Params) (*PowerResult, error) {
+	if s.power == nil {
+		return nil, ErrPowerNotSupported
+	}
+	return s.power(ctx, config, false)
+}
+
+// updatableFields returns the names of the fields that can be changed, sorted
+func (s *service) updatableFields() []string {
+	fields := make([]string, 0, len(s.updatable))
+	for key := range s.updatable {
+		fields = append(fields, key)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// builtin returns the service assembled from plain functions behind a service type, unwrapping fakes.
+// It returns false for service types implemented outside this package.
+func builtin(svc ServiceType) (*service, bool) {
+	if fake, ok := svc.(*fakeService); ok {
+		svc = fake.ServiceType
+	}
+	s, ok := svc.(*service)
+	return s, ok
+}
+
+// supportsDiscovery reports whether a service type can list its resources
+func supportsDiscovery(svc ServiceType) bool {
+	s, ok := builtin(svc)
+	return !ok || s.discover != nil
+}
+
+// SupportsPower reports whether a service type can be started and stopped
+func SupportsPower(svc ServiceType) bool {
+	s, ok := builtin(svc)
+	return !ok || s.power != nil
+}
+
+// PricedFields returns the config fields the estimate of a service type depends on
+func PricedFields(svc ServiceType) []string {
+	if s, ok := builtin(svc); ok {
+		return s.priced
+	}
+	return nil
+}
+
+// supportsUpdate reports whether a service type can be changed in place
+func supportsUpdate(svc ServiceType) bool {
+	s, ok := builtin(svc)
+	return !ok || s.update != nil
+}
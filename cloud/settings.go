@@ -0,0 +1,20 @@
+package cloud
+
+import (
+	"multitenant/config"
+	"multitenant/secrets"
+)
+
+// settings are the deployment's cloud settings, applied by Configure
+var settings = config.Cloud{Org: config.DefaultOrg}
+
+// secretStore holds the server's own cloud credentials, when they are kept as secrets
+var secretStore *secrets.Cache
+
+// Configure applies the server's cloud settings and selects the backend they name. Calls made without a tenant
+// use the aws_credentials and gcp_credentials secrets of store when it holds them.
+func Configure(cfg *config.Config, store *secrets.Cache) error {
+	settings = cfg.Cloud
+	secretStore = store
+	return UseBackend(cfg.Cloud.Backend)
+}
@@ -0,0 +1,156 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cloudfronttypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Standard tags (AWS) and labels (GCP) applied to every resource the platform creates
+const (
+	TagOrg       = "mt-org"
+	TagGroup     = "mt-group"
+	TagUser      = "mt-user"
+	TagSession   = "mt-session"
+	TagServiceID = "mt-service-id" // _id of the services record tracking the resource
+)
+
+// tagPrefix is reserved for the standard tags; tenants cannot set custom tags under it
+const tagPrefix = "mt-"
+
+// maxCustomTags leaves room for the standard tags under the AWS limit of 50 tags per resource
+const maxCustomTags = 40
+
+// OrgTag returns the value of the mt-org tag, set with MT_ORG
+func OrgTag() string {
+	return settings.Org
+}
+
+// Tags are the key/value pairs applied to a resource on create
+type Tags map[string]string
+
+type tagsContextKey struct{}
+
+// WithTags returns a copy of ctx whose creates apply the given tags
+func WithTags(ctx context.Context, tags Tags) context.Context {
+	return context.WithValue(ctx, tagsContextKey{}, tags)
+}
+
+// TagsFromContext returns the tags stored in ctx, or nil
+func TagsFromContext(ctx context.Context) Tags {
+	tags, _ := ctx.Value(tagsContextKey{}).(Tags)
+	return tags
+}
+
+// StandardTags builds the tags applied to a resource: the tenant's custom tags overlaid with the standard ones
+func StandardTags(custom map[string]string, org, group, user, session, serviceID string) Tags {
+	tags := Tags{}
+	for key, value := range custom {
+		tags[key] = value
+	}
+	tags[TagOrg] = org
+	tags[TagGroup] = group
+	tags[TagUser] = user
+	tags[TagSession] = session
+	tags[TagServiceID] = serviceID
+	return tags
+}
+
+// ValidateCustomTags checks tenant supplied tags. Keys must also be usable as GCP label keys.
+func ValidateCustomTags(tags map[string]string) error {
+	if len(tags) > maxCustomTags {
+		return fmt.Errorf("at most %d custom tags are allowed", maxCustomTags)
+	}
+	for key, value := range tags {
+		if strings.HasPrefix(key, tagPrefix) {
+			return fmt.Errorf("tag '%s' uses the reserved prefix '%s'", key, tagPrefix)
+		}
+		if !gcpLabelKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid tag key '%s': use lowercase letters, digits, '-' and '_', starting with a letter", key)
+		}
+		if len(value) > 63 {
+			return fmt.Errorf("value of tag '%s' is too long", key)
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of tags in order so requests are deterministic
+func (t Tags) sortedKeys() []string {
+	keys := make([]string, 0, len(t))
+	for key := range t {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var (
+	gcpLabelKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+	gcpLabelInvalid    = regexp.MustCompile(`[^a-z0-9_-]`)
+)
+
+// gcpLabels converts tags to GCP labels, which only allow lowercase letters, digits, '-' and '_' up to 63 characters
+func gcpLabels(ctx context.Context) map[string]string {
+	tags := TagsFromContext(ctx)
+	if len(tags) == 0 {
+		return nil
+	}
+	labels := make(map[string]string, len(tags))
+	for key, value := range tags {
+		value = gcpLabelInvalid.ReplaceAllString(strings.ToLower(value), "_")
+		if len(value) > 63 {
+			value = value[:63]
+		}
+		labels[key] = value
+	}
+	return labels
+}
+
+// ec2Tags converts the tags in ctx for EC2 and VPC resources
+func ec2Tags(ctx context.Context) []ec2types.Tag {
+	tags := TagsFromContext(ctx)
+	list := make([]ec2types.Tag, 0, len(tags))
+	for _, key := range tags.sortedKeys() {
+		list = append(list, ec2types.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	return list
+}
+
+// s3Tags converts the tags in ctx for S3 buckets
+func s3Tags(ctx context.Context) []s3types.Tag {
+	tags := TagsFromContext(ctx)
+	list := make([]s3types.Tag, 0, len(tags))
+	for _, key := range tags.sortedKeys() {
+		list = append(list, s3types.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	return list
+}
+
+// rdsTags converts the tags in ctx for RDS instances
+func rdsTags(ctx context.Context) []rdstypes.Tag {
+	tags := TagsFromContext(ctx)
+	list := make([]rdstypes.Tag, 0, len(tags))
+	for _, key := range tags.sortedKeys() {
+		list = append(list, rdstypes.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	return list
+}
+
+// cloudFrontTags converts the tags in ctx for CloudFront distributions
+func cloudFrontTags(ctx context.Context) *cloudfronttypes.Tags {
+	tags := TagsFromContext(ctx)
+	list := make([]cloudfronttypes.Tag, 0, len(tags))
+	for _, key := range tags.sortedKeys() {
+		list = append(list, cloudfronttypes.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	return &cloudfronttypes.Tags{Items: list}
+}
@@ -0,0 +1,41 @@
+// Command secrets-stub serves a local Secrets Manager for the aws secrets backend:
+//
+//	go run ./cmd/secrets-stub -addr :4566 -secrets secrets/testdata/secrets.json
+//	SECRETS_BACKEND=aws SECRETS_AWS_ENDPOINT=http://localhost:4566 SECRETS_AWS_REGION=us-east-1 \
+//	SECRETS_AWS_PREFIX=multitenant/ go run main.go
+//
+// The server signs its requests, so AWS credentials must be set, e.g. AWS_ACCESS_KEY_ID=test and
+// AWS_SECRET_ACCESS_KEY=test. Rotate a secret with a PutSecretValue call, e.g.
+//
+//	aws --endpoint-url http://localhost:4566 secretsmanager put-secret-value \
+//	--secret-id multitenant/jwt_secret --secret-string new-signing-key
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"multitenant/secrets/secretstest"
+	"net/http"
+	"os"
+)
+
+func main() {
+	addr := flag.String("addr", ":4566", "address to listen on")
+	path := flag.String("secrets", "", "JSON file of secret values by ID")
+	flag.Parse()
+
+	values := map[string]string{}
+	if *path != "" {
+		data, err := os.ReadFile(*path)
+		if err != nil {
+			log.Fatal("Failed to read secrets:", err)
+		}
+		if err := json.Unmarshal(data, &values); err != nil {
+			log.Fatal("Failed to parse secrets:", err)
+		}
+	}
+
+	log.Printf("Secrets Manager stub listening on %s with %d secrets", *addr, len(values))
+	log.Fatal(http.ListenAndServe(*addr, secretstest.NewSecretsManager(values)))
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config files hold the same settings as the environment, keyed by variable name in either case:
+//
+//	# config.yaml
+//	http_addr: ":8080"
+//	cors_allowed_origins: [http://localhost:4200, https://console.example.com]
+//	reconcile_interval: 30m
+//
+//	# config.toml
+//	HTTP_ADDR = ":8080"
+//	CORS_ALLOWED_ORIGINS = ["http://localhost:4200", "https://console.example.com"]
+//	RECONCILE_INTERVAL = "30m"
+//
+// Lists may also be written as comma separated strings. Nested sections are not supported.
+
+func parseYAML(data []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return settingValues(raw)
+}
+
+func parseTOML(data []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return settingValues(raw)
+}
+
+// settingValues turns the top-level keys of a decoded file into settings, joining lists with commas
+func settingValues(raw map[string]interface{}) (map[string]string, error) {
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case nil:
+			values[strings.ToUpper(key)] = ""
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				switch item.(type) {
+				case map[string]interface{}, []interface{}:
+					return nil, fmt.Errorf("%s: lists may only hold plain values", key)
+				}
+				items = append(items, settingValue(item))
+			}
+			values[strings.ToUpper(key)] = strings.Join(items, ",")
+		case map[string]interface{}, []map[string]interface{}:
+			return nil, fmt.Errorf("%s: nested sections are not supported", key)
+		default:
+			values[strings.ToUpper(key)] = settingValue(v)
+		}
+	}
+	return values, nil
+}
+
+// settingValue writes a plain value the way it would be set in the environment
+func settingValue(value interface{}) string {
+	if t, ok := value.(time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadFile(t *testing.T) {
+	want := map[string]string{
+		"HTTP_ADDR":               ":8080",
+		"CORS_ALLOWED_ORIGINS":    "http://localhost:4200,https://console.example.com",
+		"RECONCILE_INTERVAL":      "30m",
+		"FAKE_CLOUD_FAILURE_RATE": "0.25",
+		"FAKE_CLOUD_SEED":         "1000",
+		"AUDIT_LOG":               "true",
+		"MT_ORG":                  "acme # not a comment",
+	}
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"settings.yaml", `
+http_addr: ":8080"
+cors_allowed_origins: [http://localhost:4200, https://console.example.com]
+reconcile_interval: 30m # comment
+FAKE_CLOUD_FAILURE_RATE: 0.25
+fake_cloud_seed: 1000
+audit_log: true
+mt_org: "acme # not a comment"
+`},
+		{"settings.yml", `
+HTTP_ADDR: ":8080"
+CORS_ALLOWED_ORIGINS:
+  - http://localhost:4200
+  - https://console.example.com
+RECONCILE_INTERVAL: "30m"
+FAKE_CLOUD_FAILURE_RATE: 0.25
+FAKE_CLOUD_SEED: 1000
+AUDIT_LOG: true
+MT_ORG: 'acme # not a comment'
+`},
+		{"settings.toml", `
+# settings
+HTTP_ADDR = ":8080"
+cors_allowed_origins = ["http://localhost:4200", 'https://console.example.com']
+RECONCILE_INTERVAL = "30m" # comment
+fake_cloud_failure_rate = 0.25
+FAKE_CLOUD_SEED = 1_000
+AUDIT_LOG = true
+"MT_ORG" = "acme # not a comment"
+`},
+		{"comma-separated.toml", `
+HTTP_ADDR = ':8080'
+CORS_ALLOWED_ORIGINS = "http://localhost:4200,https://console.example.com"
+RECONCILE_INTERVAL = """30m"""
+FAKE_CLOUD_FAILURE_RATE = 0.25
+FAKE_CLOUD_SEED = 1000
+AUDIT_LOG = true
+MT_ORG = "acme # not a comment"
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readFile(writeConfig(t, tt.name, tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("readFile = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadFileEmptyValue(t *testing.T) {
+	got, err := readFile(writeConfig(t, "empty.yaml", "self_url:\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := got["SELF_URL"]; !ok || value != "" {
+		t.Fatalf("SELF_URL = %q, %v, want an empty setting", value, ok)
+	}
+}
+
+func TestReadFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"nested.yaml", "mongo:\n  database: multitenant\n"},
+		{"nested-list.yaml", "cors_allowed_origins:\n  - origin: http://localhost:4200\n"},
+		{"invalid.yaml", "http_addr: [\n"},
+		{"table.toml", "[mongo]\ndatabase = \"multitenant\"\n"},
+		{"inline-table.toml", "MONGO = { database = \"multitenant\" }\n"},
+		{"array-of-tables.toml", "[[origins]]\nurl = \"http://localhost:4200\"\n"},
+		{"nested-array.toml", "CORS_ALLOWED_ORIGINS = [[\"a\"], [\"b\"]]\n"},
+		{"bare-string.toml", "HTTP_ADDR = :8080\n"},
+		{"missing-value.toml", "HTTP_ADDR\n"},
+		{"unterminated.toml", "HTTP_ADDR = \":8080\n"},
+		{"duplicate.toml", "HTTP_ADDR = \":8080\"\nHTTP_ADDR = \":9090\"\n"},
+		{"settings.json", "{\"HTTP_ADDR\": \":8080\"}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if values, err := readFile(writeConfig(t, tt.name, tt.content)); err == nil {
+				t.Fatalf("readFile succeeded with %v, want an error", values)
+			}
+		})
+	}
+
+	if _, err := readFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("readFile of a missing file succeeded")
+	}
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -3,7 +3,6 @@ package db
 import (
     "context"
     "fmt"
-    "multitenant/config"
     "multitenant/models"
     "strings"
     "go.mongodb.org/mongo-driver/bson"
@@ -93,7 +92,14 @@ func AddManager(username, password, email string, groupLimit int) models.Manager
         }
     }
 
-    clientOptions := options.Client().ApplyURI(config.MongoURI)
+    uri, err := mongoURI()
+    if err != nil {
+        return models.ManagerResponse{
+            Success: false,
+            Message: fmt.Sprintf("Could not connect to MongoDB: %v", err),
+        }
+    }
+    clientOptions := options.Client().ApplyURI(uri)
     client, err := mongo.Connect(context.Background(), clientOptions)
     if err != nil {
         return models.ManagerResponse{
@@ -104,8 +110,8 @@ func AddManager(username, password, email string, groupLimit int) models.Manager
     defer client.Disconnect(context.TODO())
 
     // Define collections
-    managerCollection := client.Database("mydatabase").Collection("managers")
-    userCollection := client.Database("mydatabase").Collection("users")
+    managerCollection := client.Database(databaseName).Collection("managers")
+    userCollection := client.Database(databaseName).Collection("users")
 
     // Check if a manager with the same username already exists
     var existingManager models.Manager
@@ -227,7 +233,14 @@ func RemoveManager(username string) models.ManagerResponse {
         }
     }
 
-    clientOptions := options.Client().ApplyURI(config.MongoURI)
+    uri, err := mongoURI()
+    if err != nil {
+        return models.ManagerResponse{
+            Success: false,
+            Message: fmt.Sprintf("could not connect to MongoDB: %v", err),
+        }
+    }
+    clientOptions := options.Client().ApplyURI(uri)
     client, err := mongo.Connect(context.Background(), clientOptions)
     if err != nil {
         return models.ManagerResponse{
@@ -238,8 +251,8 @@ func RemoveManager(username string) models.ManagerResponse {
     defer client.Disconnect(context.TODO())
 
     // Define collections
-    managerCollection := client.Database("mydatabase").Collection("managers")
-    userCollection := client.Database("mydatabase").Collection("users")
+    managerCollection := client.Database(databaseName).Collection("managers")
+    userCollection := client.Database(databaseName).Collection("users")
 
     // Check if the manager exists in the managers collection
     var existingManager models.Manager
@@ -0,0 +1,308 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"multitenant/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Spend a budget alert rule is evaluated against
+const (
+	AlertBasisActual   = "actual"
+	AlertBasisForecast = "forecast"
+)
+
+// ErrSessionsBlocked is returned when a hard-limit alert blocks a group from starting sessions
+var ErrSessionsBlocked = errors.New("new sessions are blocked by a budget hard limit")
+
+func GetBudgetAlertsCollection() *mongo.Collection {
+	return newClient.Database(databaseName).Collection("budget_alerts")
+}
+
+// EnsureBudgetAlertIndexes creates the unique index that lets each rule fire once per budget period
+func EnsureBudgetAlertIndexes() error {
+	_, err := GetBudgetAlertsCollection().Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "group_id", Value: 1},
+			{Key: "period_start", Value: -1},
+			{Key: "basis", Value: 1},
+			{Key: "threshold", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create budget alert indexes: %v", err)
+	}
+	return nil
+}
+
+// ValidAlertBasis reports whether rules can be evaluated against the given spend
+func ValidAlertBasis(basis string) bool {
+	return basis == AlertBasisActual || basis == AlertBasisForecast
+}
+
+// SetBudgetAlertRules replaces the alert rules of a group
+func SetBudgetAlertRules(groupID string, rules []models.BudgetAlertRule) error {
+	update := bson.M{"$set": bson.M{"alert_rules": rules}}
+	if len(rules) == 0 {
+		update = bson.M{"$unset": bson.M{"alert_rules": ""}}
+	}
+	result, err := GetGroupsCollection().UpdateOne(context.Background(), bson.M{"group_id": groupID}, update)
+	if err != nil {
+		return fmt.Errorf("failed to save alert rules: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("group %s not found", groupID)
+	}
+	return nil
+}
+
+// alertSpend returns the spend of a group on a basis with its percentage of the budget plus rollover
+func alertSpend(group models.Group, basis string, forecast *models.SpendForecast) (float64, float64) {
+	spend := group.BudgetAccrued
+	if basis == AlertBasisForecast {
+		spend = forecast.Expected
+	}
+	available := group.Budget + group.BudgetRollover
+	if available <= 0 {
+		return roundAmount(spend), 0
+	}
+	return roundAmount(spend), roundAmount(spend / available * 100)
+}
+
+// alertPeriod is the start of the period alerts are deduplicated in; zero for budgets without periods
+func alertPeriod(group models.Group) time.Time {
+	if group.PeriodStart == nil {
+		return time.Time{}
+	}
+	return *group.PeriodStart
+}
+
+// GetBudgetAlertStatus returns a group's alert rules, its current spend and the rules fired in this period
+func GetBudgetAlertStatus(groupID string) (*models.BudgetAlertStatus, error) {
+	group, err := currentGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.BudgetAlertStatus{
+		GroupID: groupID,
+		Rules:   group.AlertRules,
+		Budget:  group.Budget + group.BudgetRollover,
+		Block:   group.SessionBlock,
+	}
+	if status.Rules == nil {
+		status.Rules = []models.BudgetAlertRule{}
+	}
+	forecast, err := forecastGroup(*group, "", time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	status.Actual, status.ActualPercent = alertSpend(*group, AlertBasisActual, forecast)
+	status.Forecast, status.ForecastPercent = alertSpend(*group, AlertBasisForecast, forecast)
+
+	cursor, err := GetBudgetAlertsCollection().Find(context.Background(),
+		bson.M{"group_id": groupID, "period_start": alertPeriod(*group)},
+		options.Find().SetSort(bson.D{{Key: "fired_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list budget alerts: %v", err)
+	}
+	status.Fired = []models.BudgetAlert{}
+	if err := cursor.All(context.Background(), &status.Fired); err != nil {
+		return nil, fmt.Errorf("failed to decode budget alerts: %v", err)
+	}
+	return status, nil
+}
+
+// EvaluateBudgetAlerts fires the rules of a group whose threshold its spend has crossed and that have not fired in
+// the current period, notifying the manager of each. A hard-limit rule that fires blocks new sessions; a block
+// whose rule no longer holds, e.g. after the budget was raised, is lifted. It returns the alerts fired.
+func EvaluateBudgetAlerts(groupID string) ([]models.BudgetAlert, error) {
+	group, err := currentGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	available := group.Budget + group.BudgetRollover
+	forecast, err := forecastGroup(*group, "", time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	var fired []models.BudgetAlert
+	blocking := false
+	for _, rule := range group.AlertRules {
+		spend, percent := alertSpend(*group, rule.Basis, forecast)
+		if available <= 0 || percent < rule.Threshold {
+			// Without periods a rule would only ever fire once; it fires again after spend falls back under it
+			if group.PeriodStart == nil {
+				if err := rearmBudgetAlert(groupID, rule); err != nil {
+					return fired, err
+				}
+			}
+			continue
+		}
+		if rule.HardLimit && group.SessionBlock != nil &&
+			group.SessionBlock.Basis == rule.Basis && group.SessionBlock.Threshold == rule.Threshold {
+			blocking = true
+		}
+
+		alert := models.BudgetAlert{
+			GroupID:     groupID,
+			Threshold:   rule.Threshold,
+			Basis:       rule.Basis,
+			HardLimit:   rule.HardLimit,
+			PeriodStart: alertPeriod(*group),
+			Spend:       spend,
+			Budget:      available,
+			Percent:     percent,
+			FiredAt:     time.Now(),
+		}
+		result, err := GetBudgetAlertsCollection().InsertOne(context.Background(), alert)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				continue
+			}
+			return fired, fmt.Errorf("failed to record budget alert: %v", err)
+		}
+		alert.ID, _ = result.InsertedID.(primitive.ObjectID)
+		fired = append(fired, alert)
+
+		blocked := false
+		if rule.HardLimit && group.SessionBlock == nil {
+			if blocked, err = blockSessions(groupID, rule); err != nil {
+				return fired, err
+			}
+			if blocked {
+				group.SessionBlock = &models.SessionBlock{Threshold: rule.Threshold, Basis: rule.Basis}
+				blocking = true
+			}
+		}
+		notifyBudgetAlert(*group, alert, blocked)
+	}
+
+	if group.SessionBlock != nil && !blocking {
+		if _, err := LiftSessionBlock(groupID); err != nil {
+			return fired, err
+		}
+		notifySessionsUnblocked(groupID, "its spend is back under the hard limit")
+	}
+	return fired, nil
+}
+
+// rearmBudgetAlert forgets that a rule of a group without budget periods fired
+func rearmBudgetAlert(groupID string, rule models.BudgetAlertRule) error {
+	_, err := GetBudgetAlertsCollection().DeleteOne(context.Background(), bson.M{
+		"group_id":     groupID,
+		"period_start": time.Time{},
+		"basis":        rule.Basis,
+		"threshold":    rule.Threshold,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to re-arm budget alert: %v", err)
+	}
+	return nil
+}
+
+// ResetBudgetAlerts lets every rule of a group without budget periods fire again, e.g. after its budget changed.
+// Groups with periods are re-armed by the next period.
+func ResetBudgetAlerts(groupID string) error {
+	_, err := GetBudgetAlertsCollection().DeleteMany(context.Background(), bson.M{"group_id": groupID, "period_start": time.Time{}})
+	if err != nil {
+		return fmt.Errorf("failed to reset budget alerts: %v", err)
+	}
+	return nil
+}
+
+// blockSessions sets a group's session block unless one is already set
+func blockSessions(groupID string, rule models.BudgetAlertRule) (bool, error) {
+	result, err := GetGroupsCollection().UpdateOne(context.Background(),
+		bson.M{"group_id": groupID, "session_block": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"session_block": models.SessionBlock{
+			Threshold: rule.Threshold,
+			Basis:     rule.Basis,
+			BlockedAt: time.Now(),
+		}}})
+	if err != nil {
+		return false, fmt.Errorf("failed to block sessions: %v", err)
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// LiftSessionBlock lets a group start sessions again. The rule that set the block does not fire again until the
+// next period. It reports whether a block was lifted.
+func LiftSessionBlock(groupID string) (bool, error) {
+	result, err := GetGroupsCollection().UpdateOne(context.Background(),
+		bson.M{"group_id": groupID, "session_block": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"session_block": ""}})
+	if err != nil {
+		return false, fmt.Errorf("failed to lift session block: %v", err)
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// CheckSessionsAllowed returns ErrSessionsBlocked, with the rule that set the block, while a group is blocked
+func CheckSessionsAllowed(groupID string) error {
+	group, err := currentGroup(groupID)
+	if err != nil {
+		return err
+	}
+	if block := group.SessionBlock; block != nil {
+		return fmt.Errorf("%w: %s spend reached %g%% of the budget", ErrSessionsBlocked, block.Basis, block.Threshold)
+	}
+	return nil
+}
+
+func notifyBudgetAlert(group models.Group, alert models.BudgetAlert, blocked bool) {
+	spend := "Actual spend"
+	if alert.Basis == AlertBasisForecast {
+		spend = "Forecast spend"
+	}
+	message := fmt.Sprintf("%s of group %s is $%.2f, %.1f%% of its $%.2f budget, crossing the %g%% alert.",
+		spend, group.GroupName, alert.Spend, alert.Percent, alert.Budget, alert.Threshold)
+	if blocked {
+		message += " New sessions are blocked until the period ends or the block is lifted."
+	}
+
+	details := map[string]interface{}{
+		"threshold":  alert.Threshold,
+		"basis":      alert.Basis,
+		"spend":      alert.Spend,
+		"budget":     alert.Budget,
+		"percent":    alert.Percent,
+		"hard_limit": alert.HardLimit,
+		"blocked":    blocked,
+	}
+	if group.PeriodStart != nil && group.PeriodEnd != nil {
+		details["period_start"] = *group.PeriodStart
+		details["period_end"] = *group.PeriodEnd
+	}
+	err := SendManagerNotification(group.GroupID, models.Notification{
+		Type:    "budget_alert",
+		GroupID: group.GroupID,
+		Message: message,
+		Action:  fmt.Sprintf("GET /manager/groups/%s/alerts", group.GroupID),
+		Details: details,
+	})
+	if err != nil {
+		log.Printf("Failed to notify the manager of group %s of a budget alert: %v", group.GroupID, err)
+	}
+}
+
+func notifySessionsUnblocked(groupID, reason string) {
+	err := SendManagerNotification(groupID, models.Notification{
+		Type:    "sessions_unblocked",
+		GroupID: groupID,
+		Message: fmt.Sprintf("New sessions are allowed again in group %s: %s.", groupID, reason),
+	})
+	if err != nil {
+		log.Printf("Failed to notify the manager of group %s of a lifted block: %v", groupID, err)
+	}
+}
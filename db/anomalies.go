@@ -0,0 +1,277 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"multitenant/cloud"
+	"multitenant/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// a day is compared with this many days before it
+	anomalyBaselineDays = 14
+
+	// days of cost a series needs before its days are checked
+	minAnomalyBaselineDays = 7
+
+	// standard deviations above the baseline mean that make a day anomalous
+	anomalyZThreshold = 3.0
+
+	// smallest increase over the baseline reported, so cents of noise on idle services are ignored
+	anomalyMinIncrease = 5.0
+
+	// the spread of a flat series is taken to be at least this fraction of its mean
+	anomalyMinSpread = 0.1
+)
+
+func GetCostAnomaliesCollection() *mongo.Collection {
+	return newClient.Database(databaseName).Collection("cost_anomalies")
+}
+
+// EnsureCostAnomalyIndexes creates the unique index that reports each anomalous day once
+func EnsureCostAnomalyIndexes() error {
+	_, err := GetCostAnomaliesCollection().Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "group_id", Value: 1},
+			{Key: "service_id", Value: 1},
+			{Key: "date", Value: -1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create cost anomaly indexes: %v", err)
+	}
+	return nil
+}
+
+// ListCostAnomalies returns the latest anomalies of a group, newest day first
+func ListCostAnomalies(groupID string, limit int64) ([]models.CostAnomaly, error) {
+	cursor, err := GetCostAnomaliesCollection().Find(context.Background(), bson.M{"group_id": groupID},
+		options.Find().SetSort(bson.D{{Key: "date", Value: -1}, {Key: "z_score", Value: -1}}).SetLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cost anomalies: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	anomalies := []models.CostAnomaly{}
+	if err := cursor.All(context.Background(), &anomalies); err != nil {
+		return nil, fmt.Errorf("failed to decode cost anomalies: %v", err)
+	}
+	return anomalies, nil
+}
+
+// DetectCostAnomalies checks the days from start up to end of each service of a group, and of the group as a whole,
+// against the mean and spread of the 14 days before. Days whose z-score reaches 3 are recorded and notified to the
+// group's manager and the service's owner; days already recorded are not notified again. It returns the new anomalies.
+func DetectCostAnomalies(groupID string, start, end time.Time) ([]models.CostAnomaly, error) {
+	points, err := findCostPoints(bson.M{
+		"group_id": groupID,
+		"date":     bson.M{"$gte": start.AddDate(0, 0, -anomalyBaselineDays), "$lt": end},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	groupDaily := map[time.Time]float64{}
+	serviceDaily := map[primitive.ObjectID]map[time.Time]float64{}
+	for _, point := range points {
+		day := point.Date.UTC()
+		groupDaily[day] += point.Amount
+		if serviceDaily[point.ServiceID] == nil {
+			serviceDaily[point.ServiceID] = map[time.Time]float64{}
+		}
+		serviceDaily[point.ServiceID][day] += point.Amount
+	}
+
+	var detected []models.CostAnomaly
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		explained := false
+		for serviceID, daily := range serviceDaily {
+			anomaly, ok := scoreCostDay(daily, day)
+			if !ok {
+				continue
+			}
+			explained = true
+
+			record, err := GetServiceRecord(serviceID)
+			if err != nil {
+				// The record is gone, but the anomaly still names the service it was billed to
+				record = &models.ServiceRecord{ID: serviceID, GroupID: groupID}
+				for _, point := range points {
+					if point.ServiceID == serviceID {
+						record.Service, record.Username = point.Service, point.Username
+						break
+					}
+				}
+			}
+			id := serviceID
+			anomaly.GroupID = groupID
+			anomaly.ServiceID = &id
+			anomaly.Service = record.Service
+			anomaly.Resource = resourceName(*record)
+			anomaly.Username = record.Username
+
+			recorded, err := recordCostAnomaly(&anomaly)
+			if err != nil {
+				return detected, err
+			}
+			if recorded {
+				detected = append(detected, anomaly)
+				notifyCostAnomaly(anomaly)
+			}
+		}
+
+		// A group's cost rising across many services is reported when no single service stands out
+		if explained {
+			continue
+		}
+		anomaly, ok := scoreCostDay(groupDaily, day)
+		if !ok {
+			continue
+		}
+		anomaly.GroupID = groupID
+		recorded, err := recordCostAnomaly(&anomaly)
+		if err != nil {
+			return detected, err
+		}
+		if recorded {
+			detected = append(detected, anomaly)
+			notifyCostAnomaly(anomaly)
+		}
+	}
+	return detected, nil
+}
+
+// scoreCostDay compares the cost of a day with the days before it. The baseline starts at the first day of the
+// series, so a service is not judged against days before it existed; days without cost inside it count as zero.
+func scoreCostDay(daily map[time.Time]float64, day time.Time) (models.CostAnomaly, bool) {
+	var first time.Time
+	for d := range daily {
+		if first.IsZero() || d.Before(first) {
+			first = d
+		}
+	}
+	baselineStart := day.AddDate(0, 0, -anomalyBaselineDays)
+	if first.After(baselineStart) {
+		baselineStart = first
+	}
+
+	count := int(day.Sub(baselineStart).Hours() / 24)
+	if count < minAnomalyBaselineDays {
+		return models.CostAnomaly{}, false
+	}
+	mean, sumSquares := 0.0, 0.0
+	for d := baselineStart; d.Before(day); d = d.AddDate(0, 0, 1) {
+		mean += daily[d]
+	}
+	mean /= float64(count)
+	for d := baselineStart; d.Before(day); d = d.AddDate(0, 0, 1) {
+		sumSquares += math.Pow(daily[d]-mean, 2)
+	}
+	stdDev := math.Sqrt(sumSquares / float64(count))
+
+	amount := daily[day]
+	spread := math.Max(stdDev, anomalyMinSpread*mean)
+	if amount-mean < anomalyMinIncrease || spread == 0 {
+		return models.CostAnomaly{}, false
+	}
+	z := (amount - mean) / spread
+	if z < anomalyZThreshold {
+		return models.CostAnomaly{}, false
+	}
+	return models.CostAnomaly{
+		Date:       day,
+		Amount:     roundAmount(amount),
+		Baseline:   roundAmount(mean),
+		StdDev:     roundAmount(stdDev),
+		ZScore:     math.Round(z*100) / 100,
+		DetectedAt: time.Now(),
+	}, true
+}
+
+// recordCostAnomaly stores an anomaly, reporting false when its day was already recorded
+func recordCostAnomaly(anomaly *models.CostAnomaly) (bool, error) {
+	result, err := GetCostAnomaliesCollection().InsertOne(context.Background(), anomaly)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to record cost anomaly: %v", err)
+	}
+	anomaly.ID, _ = result.InsertedID.(primitive.ObjectID)
+	return true, nil
+}
+
+// resourceName names a service by its registry identifier, such as the instance ID or bucket name, falling back
+// to the record ID
+func resourceName(record models.ServiceRecord) string {
+	if svc, err := cloud.LookupService(record.Provider, record.Service); err == nil {
+		if identifier := cloud.Params(record.Config).String(svc.IdentifierField()); identifier != "" {
+			return identifier
+		}
+	}
+	return record.ID.Hex()
+}
+
+func notifyCostAnomaly(anomaly models.CostAnomaly) {
+	day := anomaly.Date.Format("Jan 02, 2006")
+	details := map[string]interface{}{
+		"date":     anomaly.Date,
+		"amount":   anomaly.Amount,
+		"baseline": anomaly.Baseline,
+		"std_dev":  anomaly.StdDev,
+		"z_score":  anomaly.ZScore,
+	}
+
+	if anomaly.ServiceID == nil {
+		err := SendManagerNotification(anomaly.GroupID, models.Notification{
+			Type:    "cost_anomaly",
+			GroupID: anomaly.GroupID,
+			Message: fmt.Sprintf("Group %s cost $%.2f on %s against a usual $%.2f a day, spread across its services.",
+				anomaly.GroupID, anomaly.Amount, day, anomaly.Baseline),
+			Action:  fmt.Sprintf("GET /manager/groups/%s/costs", anomaly.GroupID),
+			Details: details,
+		})
+		if err != nil {
+			log.Printf("Failed to notify the manager of group %s of a cost anomaly: %v", anomaly.GroupID, err)
+		}
+		return
+	}
+
+	serviceID := anomaly.ServiceID.Hex()
+	details["service_id"] = serviceID
+	details["service"] = anomaly.Service
+	details["resource"] = anomaly.Resource
+	details["username"] = anomaly.Username
+	message := fmt.Sprintf("The %s %s of %s cost $%.2f on %s against a usual $%.2f a day (z-score %.1f).",
+		anomaly.Service, anomaly.Resource, anomaly.Username, anomaly.Amount, day, anomaly.Baseline, anomaly.ZScore)
+
+	err := SendManagerNotification(anomaly.GroupID, models.Notification{
+		Type:    "cost_anomaly",
+		GroupID: anomaly.GroupID,
+		Message: message,
+		Action:  fmt.Sprintf("GET /manager/groups/%s/services/%s", anomaly.GroupID, serviceID),
+		Details: details,
+	})
+	if err != nil {
+		log.Printf("Failed to notify the manager of group %s of a cost anomaly: %v", anomaly.GroupID, err)
+	}
+	if anomaly.Username == "" {
+		return
+	}
+	err = NotifyUser(anomaly.Username,
+		fmt.Sprintf("Your %s %s cost $%.2f on %s against a usual $%.2f a day. Check that it is not left running or serving unexpected traffic.",
+			anomaly.Service, anomaly.Resource, anomaly.Amount, day, anomaly.Baseline),
+		fmt.Sprintf("GET /user/services/%s/costs", serviceID))
+	if err != nil {
+		log.Printf("Failed to notify %s of a cost anomaly: %v", anomaly.Username, err)
+	}
+}
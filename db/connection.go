@@ -3,26 +3,48 @@ package db
 import (
  "context"
  "multitenant/config"
+ "multitenant/secrets"
  
  "go.mongodb.org/mongo-driver/mongo"
  "go.mongodb.org/mongo-driver/mongo/options"
 )
  
 var client *mongo.Client
+
+// settings are the server settings the package was connected with, databaseName the database it uses and
+// secretStore the secrets holding its URI
+var (
+ settings     *config.Config
+ databaseName string
+ secretStore  *secrets.Cache
+)
  
-// ConnectMongoDB initializes the MongoDB client
-func ConnectMongoDB() error {
- clientOptions := options.Client().ApplyURI(config.MongoURI)
- var err error
+// ConnectMongoDB initializes the MongoDB client shared by every collection of the package with the mongo_uri secret
+func ConnectMongoDB(cfg *config.Config, store *secrets.Cache) error {
+ uri, err := store.Get(context.Background(), secrets.MongoURI)
+ if err != nil {
+     return err
+ }
+ clientOptions := options.Client().ApplyURI(uri)
  client, err = mongo.Connect(context.Background(), clientOptions)
  if err != nil {
      return err
  }
+ settings = cfg
+ databaseName = cfg.Mongo.Database
+ secretStore = store
+ Client = client
+ newClient = client
  
  // Ping MongoDB to verify connection
  return client.Ping(context.Background(), nil)
 }
  
+// mongoURI returns the current MongoDB URI, so connections opened after a rotation use the new one
+func mongoURI() (string, error) {
+ return secretStore.Get(context.Background(), secrets.MongoURI)
+}
+ 
 // DisconnectMongoDB closes the MongoDB connection
 func DisconnectMongoDB() {
  if client != nil {
@@ -32,10 +54,10 @@ func DisconnectMongoDB() {
  
 // GetConfigurationCollection returns the MongoDB collection for storing configurations
 func GetConfigurationCollection() *mongo.Collection {
-    return client.Database("mydatabase").Collection("configurations")
+    return client.Database(databaseName).Collection("configurations")
 }
  
 // GetUserSessionCollection returns the MongoDB collection for user sessions
 func GetServiceSessionCollection() *mongo.Collection {
-    return client.Database("mydatabase").Collection("service_sessions")
+    return client.Database(databaseName).Collection("service_sessions")
 }
\ No newline at end of file
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"multitenant/cloud"
+	"multitenant/models"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func GetCostSeriesCollection() *mongo.Collection {
+	return newClient.Database(databaseName).Collection("cost_series")
+}
+
+// EnsureCostSeriesIndexes creates the unique index on a service's daily points and the index used by group series
+func EnsureCostSeriesIndexes() error {
+	_, err := GetCostSeriesCollection().Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "service_id", Value: 1}, {Key: "date", Value: 1}, {Key: "source", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "date", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create cost series indexes: %v", err)
+	}
+	return nil
+}
+
+// ListServicesToCost returns the records whose actual cost is collected: every active record, plus records
+// deleted or expired since the given time, whose last days are still billed
+func ListServicesToCost(since time.Time) ([]models.ServiceRecord, error) {
+	cursor, err := GetServicesCollection().Find(context.Background(), bson.M{
+		"$or": bson.A{
+			bson.M{"service_status": bson.M{"$nin": bson.A{"deleted", "expired"}}},
+			bson.M{"end_timestamp": bson.M{"$gte": since}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services to cost: %v", err)
+	}
+	var records []models.ServiceRecord
+	if err := cursor.All(context.Background(), &records); err != nil {
+		return nil, fmt.Errorf("failed to decode services: %v", err)
+	}
+	return records, nil
+}
+
+// RecordDailyCost stores the cost of a service on one day and accrues it to the group's budget. Billing data for
+// recent days is revised, so each sync accrues only the change since the amount last accrued; the ledger key
+// carries the point's revision, which keeps a retried sync from accruing twice. It returns the amount accrued.
+func RecordDailyCost(record models.ServiceRecord, source string, cost cloud.DailyCost) (float64, error) {
+	var point models.CostPoint
+	err := GetCostSeriesCollection().FindOneAndUpdate(context.Background(),
+		bson.M{"service_id": record.ID, "date": cost.Date, "source": source},
+		bson.M{
+			"$set": bson.M{
+				"group_id":  record.GroupID,
+				"username":  record.Username,
+				"provider":  record.Provider,
+				"service":   record.Service,
+				"amount":    cost.Amount,
+				"currency":  cost.Currency,
+				"synced_at": time.Now(),
+			},
+			"$setOnInsert": bson.M{"accrued": 0.0, "revision": 0},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&point)
+	if err != nil {
+		return 0, fmt.Errorf("failed to store daily cost: %v", err)
+	}
+
+	delta := roundAmount(point.Amount - point.Accrued)
+	if delta == 0 {
+		return 0, nil
+	}
+	key := fmt.Sprintf("%s:%s:%s:%d", source, record.ID.Hex(), cost.Date.Format("2006-01-02"), point.Revision+1)
+	note := fmt.Sprintf("%s cost of %s", source, cost.Date.Format("2006-01-02"))
+	if point.Revision > 0 {
+		note += " (revised)"
+	}
+	if _, err := RecordAccrual(record.GroupID, &record.ID, delta, cost.Date, key, note); err != nil {
+		return 0, err
+	}
+
+	// A concurrent sync that moved the revision first accrued the same change under the same key
+	_, err = GetCostSeriesCollection().UpdateOne(context.Background(),
+		bson.M{"_id": point.ID, "revision": point.Revision},
+		bson.M{"$set": bson.M{"accrued": point.Amount}, "$inc": bson.M{"revision": 1}})
+	if err != nil {
+		return delta, fmt.Errorf("failed to mark daily cost accrued: %v", err)
+	}
+	return delta, nil
+}
+
+// GetGroupCostSeries returns a group's actual cost per day and per service from start up to end
+func GetGroupCostSeries(groupID string, start, end time.Time) (*models.CostSeries, error) {
+	points, err := findCostPoints(bson.M{"group_id": groupID, "date": bson.M{"$gte": start, "$lt": end}})
+	if err != nil {
+		return nil, err
+	}
+	series := buildCostSeries(points, start, end)
+	series.GroupID = groupID
+
+	byService := map[primitive.ObjectID]*models.ServiceCost{}
+	for _, point := range points {
+		cost, ok := byService[point.ServiceID]
+		if !ok {
+			cost = &models.ServiceCost{ServiceID: point.ServiceID, Service: point.Service, Username: point.Username}
+			byService[point.ServiceID] = cost
+		}
+		cost.Amount += point.Amount
+	}
+	series.Services = make([]models.ServiceCost, 0, len(byService))
+	for _, cost := range byService {
+		cost.Amount = roundAmount(cost.Amount)
+		series.Services = append(series.Services, *cost)
+	}
+	sort.Slice(series.Services, func(i, j int) bool { return series.Services[i].Amount > series.Services[j].Amount })
+	return series, nil
+}
+
+// GetServiceCostSeries returns a service's actual cost per day from start up to end, with the stored points
+func GetServiceCostSeries(record models.ServiceRecord, start, end time.Time) (*models.CostSeries, error) {
+	points, err := findCostPoints(bson.M{"service_id": record.ID, "date": bson.M{"$gte": start, "$lt": end}})
+	if err != nil {
+		return nil, err
+	}
+	series := buildCostSeries(points, start, end)
+	series.GroupID = record.GroupID
+	series.ServiceID = record.ID.Hex()
+	series.Points = points
+	return series, nil
+}
+
+func findCostPoints(filter bson.M) ([]models.CostPoint, error) {
+	cursor, err := GetCostSeriesCollection().Find(context.Background(), filter,
+		options.Find().SetSort(bson.D{{Key: "date", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daily costs: %v", err)
+	}
+	points := []models.CostPoint{}
+	if err := cursor.All(context.Background(), &points); err != nil {
+		return nil, fmt.Errorf("failed to decode daily costs: %v", err)
+	}
+	return points, nil
+}
+
+// buildCostSeries totals points per day, including days without cost so charts have no gaps
+func buildCostSeries(points []models.CostPoint, start, end time.Time) *models.CostSeries {
+	series := &models.CostSeries{Start: start, End: end, Currency: "USD", Daily: []models.DailyCost{}}
+
+	daily := map[time.Time]float64{}
+	for _, point := range points {
+		daily[point.Date.UTC()] += point.Amount
+		series.Total += point.Amount
+		if point.Currency != "" {
+			series.Currency = point.Currency
+		}
+		if series.LastSync == nil || point.SyncedAt.After(*series.LastSync) {
+			synced := point.SyncedAt
+			series.LastSync = &synced
+		}
+	}
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		series.Daily = append(series.Daily, models.DailyCost{Date: day, Amount: roundAmount(daily[day])})
+	}
+	series.Total = roundAmount(series.Total)
+	return series
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multitenant/cloud"
+	"multitenant/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OrgCredentialsID is the scope_id of the organization wide credentials
+const OrgCredentialsID = "default"
+
+func GetTenantCredentialsCollection() *mongo.Collection {
+	return newClient.Database(databaseName).Collection("tenant_credentials")
+}
+
+// SaveTenantCredentials creates or replaces the credentials of an organization or group
+func SaveTenantCredentials(creds models.TenantCredentials) error {
+	creds.UpdatedAt = time.Now()
+
+	filter := bson.M{"scope": creds.Scope, "scope_id": creds.ScopeID}
+	_, err := GetTenantCredentialsCollection().ReplaceOne(context.Background(), filter, creds, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save credentials: %v", err)
+	}
+	return nil
+}
+
+// GetTenantCredentials returns the credentials registered for a scope, or nil when there are none
+func GetTenantCredentials(scope, scopeID string) (*models.TenantCredentials, error) {
+	var creds models.TenantCredentials
+	err := GetTenantCredentialsCollection().FindOne(context.Background(), bson.M{"scope": scope, "scope_id": scopeID}).Decode(&creds)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch credentials: %v", err)
+	}
+	return &creds, nil
+}
+
+// DeleteTenantCredentials removes the credentials of a scope
+func DeleteTenantCredentials(scope, scopeID string) (bool, error) {
+	result, err := GetTenantCredentialsCollection().DeleteOne(context.Background(), bson.M{"scope": scope, "scope_id": scopeID})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete credentials: %v", err)
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// ResolveTenantCredentials returns the settings cloud calls for a group run under.
+// Fields the group leaves empty are inherited from the organization; nil means the default credential chain and any region.
+func ResolveTenantCredentials(groupID string) (*models.TenantCredentials, error) {
+	org, err := GetTenantCredentials(models.CredentialScopeOrg, OrgCredentialsID)
+	if err != nil {
+		return nil, err
+	}
+	if groupID == "" {
+		return org, nil
+	}
+
+	group, err := GetTenantCredentials(models.CredentialScopeGroup, groupID)
+	if err != nil || group == nil || org == nil {
+		if group != nil {
+			return group, err
+		}
+		return org, err
+	}
+
+	// An AWS role and its external ID always come from the same scope
+	if group.AWSRoleARN == "" {
+		group.AWSRoleARN, group.AWSExternalID = org.AWSRoleARN, org.AWSExternalID
+	}
+	if group.GCPProjectID == "" {
+		group.GCPProjectID = org.GCPProjectID
+	}
+	if group.GCPServiceAccount == "" {
+		group.GCPServiceAccount = org.GCPServiceAccount
+	}
+	if len(group.AWSRegions) == 0 {
+		group.AWSRegions = org.AWSRegions
+	}
+	if len(group.GCPRegions) == 0 {
+		group.GCPRegions = org.GCPRegions
+	}
+	// Custom tags combine, the group's winning on conflicts
+	if len(org.Tags) > 0 {
+		tags := make(map[string]string, len(org.Tags)+len(group.Tags))
+		for key, value := range org.Tags {
+			tags[key] = value
+		}
+		for key, value := range group.Tags {
+			tags[key] = value
+		}
+		group.Tags = tags
+	}
+	return group, nil
+}
+
+// TenantContext returns a copy of ctx whose cloud calls run under the credentials of the group, or of its organization
+func TenantContext(ctx context.Context, groupID string) (context.Context, error) {
+	creds, err := ResolveTenantCredentials(groupID)
+	if err != nil {
+		return nil, err
+	}
+	if creds == nil {
+		return ctx, nil
+	}
+
+	return cloud.WithTenant(ctx, &cloud.Tenant{
+		Key:               creds.Key(),
+		AWSRoleARN:        creds.AWSRoleARN,
+		AWSExternalID:     creds.AWSExternalID,
+		GCPProjectID:      creds.GCPProjectID,
+		GCPServiceAccount: creds.GCPServiceAccount,
+		AWSRegions:        creds.AWSRegions,
+		GCPRegions:        creds.GCPRegions,
+		Tags:              creds.Tags,
+	}), nil
+}
+
+// GetGroupIDByMember returns the ID of the group the user belongs to
+func GetGroupIDByMember(username string) (string, error) {
+	var group struct {
+		GroupID string `bson:"group_id"`
+	}
+	err := GetGroupsCollection().FindOne(context.Background(), bson.M{"members": username}).Decode(&group)
+	if err != nil {
+		return "", fmt.Errorf("group not found for the user: %v", err)
+	}
+	return group.GroupID, nil
+}
+
+// EnsureTenantCredentialsIndexes creates the unique (scope, scope_id) index
+func EnsureTenantCredentialsIndexes() error {
+	_, err := GetTenantCredentialsCollection().Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "scope", Value: 1}, {Key: "scope_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create tenant credentials indexes: %v", err)
+	}
+	return nil
+}
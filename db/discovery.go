@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multitenant/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrAlreadyAdopted is returned when a discovered resource already has a services record
+var ErrAlreadyAdopted = errors.New("resource has already been adopted")
+
+func GetDiscoveredResourcesCollection() *mongo.Collection {
+	return newClient.Database(databaseName).Collection("discovered_resources")
+}
+
+// EnsureDiscoveryIndexes creates the unique index identifying a discovered resource within a group
+func EnsureDiscoveryIndexes() error {
+	_, err := GetDiscoveredResourcesCollection().Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "group_id", Value: 1},
+			{Key: "provider", Value: 1},
+			{Key: "service", Value: 1},
+			{Key: "resource_id", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create discovery indexes: %v", err)
+	}
+	return nil
+}
+
+// IsResourceTracked reports whether an active services record already tracks a resource, in any group
+func IsResourceTracked(provider, service, idField, resourceID string) (bool, error) {
+	count, err := GetServicesCollection().CountDocuments(context.Background(), bson.M{
+		"provider":          provider,
+		"service":           service,
+		"config." + idField: resourceID,
+		"service_status":    bson.M{"$nin": bson.A{"deleted", "expired"}},
+	}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, fmt.Errorf("failed to check tracked services: %v", err)
+	}
+	return count > 0, nil
+}
+
+// SaveDiscoveredResource records a resource seen by a scan, keeping when it was first seen
+func SaveDiscoveredResource(resource models.DiscoveredResource) error {
+	filter := bson.M{
+		"group_id":    resource.GroupID,
+		"provider":    resource.Provider,
+		"service":     resource.Service,
+		"resource_id": resource.ResourceID,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"id_field":  resource.IDField,
+			"region":    resource.Region,
+			"state":     resource.State,
+			"config":    resource.Config,
+			"last_seen": resource.LastSeen,
+		},
+		"$setOnInsert": bson.M{"first_seen": resource.LastSeen},
+	}
+
+	_, err := GetDiscoveredResourcesCollection().UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save discovered resource: %v", err)
+	}
+	return nil
+}
+
+// PruneDiscoveredResources removes the unadopted resources of a scanned region that the scan started at scanStart no longer saw
+func PruneDiscoveredResources(groupID, provider, service, region string, scanStart time.Time) error {
+	_, err := GetDiscoveredResourcesCollection().DeleteMany(context.Background(), bson.M{
+		"group_id":           groupID,
+		"provider":           provider,
+		"service":            service,
+		"region":             region,
+		"last_seen":          bson.M{"$lt": scanStart},
+		"adopted_service_id": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prune discovered resources: %v", err)
+	}
+	return nil
+}
+
+// ListDiscoveredResources returns the resources discovered for a group, adopted ones only when includeAdopted is set
+func ListDiscoveredResources(groupID string, includeAdopted bool) ([]models.DiscoveredResource, error) {
+	filter := bson.M{"group_id": groupID}
+	if !includeAdopted {
+		filter["adopted_service_id"] = bson.M{"$exists": false}
+	}
+
+	cursor, err := GetDiscoveredResourcesCollection().Find(context.Background(), filter,
+		options.Find().SetSort(bson.D{{Key: "provider", Value: 1}, {Key: "service", Value: 1}, {Key: "resource_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list discovered resources: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	resources := []models.DiscoveredResource{}
+	if err := cursor.All(context.Background(), &resources); err != nil {
+		return nil, fmt.Errorf("failed to decode discovered resources: %v", err)
+	}
+	return resources, nil
+}
+
+// GetDiscoveredResource fetches a discovered resource of a group by its ID
+func GetDiscoveredResource(groupID string, id primitive.ObjectID) (*models.DiscoveredResource, error) {
+	var resource models.DiscoveredResource
+	err := GetDiscoveredResourcesCollection().FindOne(context.Background(), bson.M{"_id": id, "group_id": groupID}).Decode(&resource)
+	if err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+// AdoptDiscoveredResource creates the services record of a discovered resource so it counts against the group's budget
+// and can be managed through the API. The resource is claimed first so concurrent adoptions create a single record.
+func AdoptDiscoveredResource(resource *models.DiscoveredResource, username, manager, status string, estimatedCost float64) (primitive.ObjectID, error) {
+	var group models.Group
+	if err := GetGroupsCollection().FindOne(context.Background(), bson.M{"group_id": resource.GroupID}).Decode(&group); err != nil {
+		return primitive.NilObjectID, fmt.Errorf("group not found: %v", err)
+	}
+
+	serviceID := primitive.NewObjectID()
+	result, err := GetDiscoveredResourcesCollection().UpdateOne(context.Background(),
+		bson.M{"_id": resource.ID, "adopted_service_id": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"adopted_service_id": serviceID}},
+	)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("failed to claim discovered resource: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return primitive.NilObjectID, ErrAlreadyAdopted
+	}
+
+	_, err = GetServicesCollection().InsertOne(context.Background(), bson.M{
+		"_id":            serviceID,
+		"username":       username,
+		"groupname":      group.GroupName,
+		"group_id":       resource.GroupID,
+		"provider":       resource.Provider,
+		"service":        resource.Service,
+		"group_budget":   group.Budget,
+		"estimated_cost": estimatedCost,
+		"config":         resource.Config,
+		"timestamp":      time.Now(),
+		"service_status": status,
+		"live_state":     resource.State,
+		"adopted_by":     manager,
+	})
+	if err != nil {
+		// Release the claim so the adoption can be retried
+		GetDiscoveredResourcesCollection().UpdateOne(context.Background(),
+			bson.M{"_id": resource.ID}, bson.M{"$unset": bson.M{"adopted_service_id": ""}})
+		return primitive.NilObjectID, fmt.Errorf("failed to create services record: %v", err)
+	}
+
+	// The resource is already running, so its estimate is committed even past the budget
+	if estimatedCost > 0 {
+		if err := AdjustServiceCommitment(serviceID, PeriodCost(group.PeriodEnd, estimatedCost, resource.Config), true, "resource adopted"); err != nil {
+			return serviceID, err
+		}
+	}
+	return serviceID, nil
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"multitenant/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// activeExpiring matches active services that have an expiry
+var activeExpiring = bson.M{
+	"service_status": bson.M{"$nin": bson.A{"deleted", "expired"}},
+	"expires_at":     bson.M{"$exists": true},
+}
+
+// GetGroupTTL returns the default and maximum service lifetimes of a group; zero means none
+func GetGroupTTL(groupID string) (defaultTTL, maxTTL time.Duration, err error) {
+	var group models.Group
+	if err := GetGroupsCollection().FindOne(context.Background(), bson.M{"group_id": groupID}).Decode(&group); err != nil {
+		return 0, 0, fmt.Errorf("group not found: %v", err)
+	}
+	return time.Duration(group.DefaultTTLHours) * time.Hour, time.Duration(group.MaxTTLHours) * time.Hour, nil
+}
+
+// SetGroupTTL stores the default and maximum service lifetimes of a group in hours; zero removes them
+func SetGroupTTL(groupID string, defaultHours, maxHours int) error {
+	set, unset := bson.M{}, bson.M{}
+	for field, hours := range map[string]int{"default_ttl_hours": defaultHours, "max_ttl_hours": maxHours} {
+		if hours > 0 {
+			set[field] = hours
+		} else {
+			unset[field] = ""
+		}
+	}
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	result, err := GetGroupsCollection().UpdateOne(context.Background(), bson.M{"group_id": groupID}, update)
+	if err != nil {
+		return fmt.Errorf("failed to update group TTL: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("group %s not found", groupID)
+	}
+	return nil
+}
+
+// SetServiceExpiry moves the expiry of a service, so its owner is warned again before the new time
+func SetServiceExpiry(id primitive.ObjectID, expiresAt time.Time) error {
+	_, err := GetServicesCollection().UpdateOne(context.Background(), bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"expires_at": expiresAt},
+		"$unset": bson.M{"expiry_warned_at": "", "expiry_failed_at": ""},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update service expiry: %v", err)
+	}
+	return nil
+}
+
+// ListServicesToWarn returns the active services expiring at or before the given time whose owner was not warned yet
+func ListServicesToWarn(before time.Time) ([]models.ServiceRecord, error) {
+	return findExpiring(bson.M{
+		"expires_at":       bson.M{"$lte": before},
+		"expiry_warned_at": bson.M{"$exists": false},
+	})
+}
+
+// MarkExpiryWarned records that the owner was warned of an expiry. It reports false when the expiry changed
+// in the meantime, so the warning is not recorded against the new time.
+func MarkExpiryWarned(id primitive.ObjectID, expiresAt time.Time) (bool, error) {
+	result, err := GetServicesCollection().UpdateOne(context.Background(),
+		bson.M{"_id": id, "expires_at": expiresAt, "expiry_warned_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"expiry_warned_at": time.Now()}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to record expiry warning: %v", err)
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// ListExpiredServices returns the active services whose expiry has passed. Services whose deletion failed
+// since retryAfter are left out.
+func ListExpiredServices(now, retryAfter time.Time) ([]models.ServiceRecord, error) {
+	return findExpiring(bson.M{
+		"expires_at":       bson.M{"$lte": now},
+		"expiry_failed_at": bson.M{"$not": bson.M{"$gte": retryAfter}},
+	})
+}
+
+// RecordExpiryFailure stamps a failed expiry deletion on a services record, reporting whether it is the first
+func RecordExpiryFailure(id primitive.ObjectID) (bool, error) {
+	var before struct {
+		FailedAt *time.Time `bson:"expiry_failed_at"`
+	}
+	err := GetServicesCollection().FindOneAndUpdate(context.Background(), bson.M{"_id": id},
+		bson.M{"$set": bson.M{"expiry_failed_at": time.Now()}},
+		options.FindOneAndUpdate().SetProjection(bson.M{"expiry_failed_at": 1}),
+	).Decode(&before)
+	if err != nil {
+		return false, fmt.Errorf("failed to record expiry failure: %v", err)
+	}
+	return before.FailedAt == nil, nil
+}
+
+func findExpiring(filter bson.M) ([]models.ServiceRecord, error) {
+	for key, value := range activeExpiring {
+		if _, ok := filter[key]; !ok {
+			filter[key] = value
+		}
+	}
+
+	cursor, err := GetServicesCollection().Find(context.Background(), filter,
+		options.Find().SetSort(bson.D{{Key: "expires_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expiring services: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	var records []models.ServiceRecord
+	if err := cursor.All(context.Background(), &records); err != nil {
+		return nil, fmt.Errorf("failed to decode services: %v", err)
+	}
+	return records, nil
+}
+
+// NotifyUser saves a notification for a user. action names the API call that acts on it, if any.
+func NotifyUser(username, message, action string) error {
+	_, err := GetNotificationsCollection().InsertOne(context.Background(), models.Notification{
+		Username:  username,
+		Message:   message,
+		Action:    action,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save notification: %v", err)
+	}
+	return nil
+}
+
+// ListUserNotifications returns the latest notifications of a user, newest first
+func ListUserNotifications(username string, limit int64) ([]models.Notification, error) {
+	cursor, err := GetNotificationsCollection().Find(context.Background(), bson.M{"username": username},
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	notifications := []models.Notification{}
+	if err := cursor.All(context.Background(), &notifications); err != nil {
+		return nil, fmt.Errorf("failed to decode notifications: %v", err)
+	}
+	return notifications, nil
+}
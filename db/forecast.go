@@ -0,0 +1,212 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"multitenant/cloud"
+	"multitenant/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// a service's run rate is taken from this many recent days of actual cost
+	forecastHistoryDays = 14
+
+	// days of actual cost a service needs before its history replaces its estimate
+	minForecastHistoryDays = 3
+
+	// relative error assumed for estimates, which do not average out over days
+	estimateUncertainty = 0.25
+
+	// z-score of the 80% band reported with forecasts
+	forecastZ          = 1.2816
+	forecastConfidence = 0.8
+
+	// budgets without periods are forecast this far ahead, the length of an estimate quarter
+	defaultForecastHorizon = 90 * 24 * time.Hour
+)
+
+// ForecastGroupSpend forecasts a group's spend by the end of its budget period. excludeSession leaves out what an
+// approved session holds, so the session can be priced again against the forecast.
+func ForecastGroupSpend(groupID, excludeSession string) (*models.SpendForecast, error) {
+	group, err := currentGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	return forecastGroup(*group, excludeSession, time.Now().UTC())
+}
+
+func forecastGroup(group models.Group, excludeSession string, now time.Time) (*models.SpendForecast, error) {
+	start, end := forecastWindow(group, now)
+	forecast := &models.SpendForecast{
+		GroupID:     group.GroupID,
+		PeriodStart: group.PeriodStart,
+		PeriodEnd:   end,
+		Budget:      group.Budget + group.BudgetRollover,
+		Accrued:     roundAmount(group.BudgetAccrued),
+		Confidence:  forecastConfidence,
+		Services:    []models.ServiceForecast{},
+	}
+
+	historyStart := now.Truncate(24*time.Hour).AddDate(0, 0, -forecastHistoryDays)
+	cursor, err := GetServicesCollection().Find(context.Background(), bson.M{
+		"group_id": group.GroupID,
+		"$or": bson.A{
+			bson.M{"service_status": bson.M{"$nin": bson.A{"deleted", "expired"}}},
+			bson.M{"end_timestamp": bson.M{"$gte": historyStart}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services to forecast: %v", err)
+	}
+	var records []models.ServiceRecord
+	if err := cursor.All(context.Background(), &records); err != nil {
+		return nil, fmt.Errorf("failed to decode services: %v", err)
+	}
+
+	points, err := findCostPoints(bson.M{"group_id": group.GroupID, "date": bson.M{"$gte": historyStart}})
+	if err != nil {
+		return nil, err
+	}
+	history := map[primitive.ObjectID][]models.CostPoint{}
+	for _, point := range points {
+		history[point.ServiceID] = append(history[point.ServiceID], point)
+	}
+
+	variance := 0.0
+	expected := forecast.Accrued
+	for _, record := range records {
+		service, serviceVariance := forecastService(record, history[record.ID], start, end, now)
+		forecast.Services = append(forecast.Services, service)
+		expected += service.Expected
+		variance += serviceVariance
+	}
+
+	pending, err := pendingCommitments(group.GroupID, excludeSession)
+	if err != nil {
+		return nil, err
+	}
+	forecast.Pending = roundAmount(pending)
+	expected += pending
+	variance += math.Pow(estimateUncertainty*pending, 2)
+
+	band := forecastZ * math.Sqrt(variance)
+	forecast.Expected = roundAmount(expected)
+	forecast.Low = roundAmount(math.Max(expected-band, forecast.Accrued))
+	forecast.High = roundAmount(expected + band)
+	forecast.Remaining = roundAmount(forecast.Budget - expected)
+	forecast.OverBudget = expected > forecast.Budget+0.005
+	return forecast, nil
+}
+
+// forecastWindow returns the times a group's services are forecast between: its current period, or now and the
+// default horizon for budgets without periods, whose accrued cost has no start to forecast unbilled cost from.
+func forecastWindow(group models.Group, now time.Time) (time.Time, time.Time) {
+	if group.PeriodStart != nil && group.PeriodEnd != nil {
+		return *group.PeriodStart, *group.PeriodEnd
+	}
+	return now, now.Add(defaultForecastHorizon)
+}
+
+// forecastService forecasts the cost a service adds from the end of its billed cost, but not before start or its
+// creation, to the end of the period, with the variance of that forecast. Services with enough recent actual cost
+// are forecast from its daily mean and spread; the others from the hourly rate of their estimate.
+func forecastService(record models.ServiceRecord, points []models.CostPoint, start, end, now time.Time) (models.ServiceForecast, float64) {
+	forecast := models.ServiceForecast{
+		ServiceID: record.ID,
+		Service:   record.Service,
+		Username:  record.Username,
+		From:      start,
+		Until:     end,
+	}
+
+	// Daily totals from the first billed day to the last, days without cost counting as zero
+	daily := map[time.Time]float64{}
+	var first, last time.Time
+	for _, point := range points {
+		day := point.Date.UTC()
+		daily[day] += point.Amount
+		if first.IsZero() || day.Before(first) {
+			first = day
+		}
+		if day.After(last) {
+			last = day
+		}
+	}
+	if billed := last.AddDate(0, 0, 1); !last.IsZero() && billed.After(forecast.From) {
+		forecast.From = billed
+	}
+	if record.Timestamp.After(forecast.From) {
+		forecast.From = record.Timestamp
+	}
+
+	// Cost stops when the service was deleted, expires or is stopped
+	if record.EndTimestamp != nil && record.EndTimestamp.Before(forecast.Until) {
+		forecast.Until = *record.EndTimestamp
+	}
+	if record.ExpiresAt != nil && record.ExpiresAt.Before(forecast.Until) {
+		forecast.Until = *record.ExpiresAt
+	}
+	if record.ServiceStatus == "stopped" && now.Before(forecast.Until) {
+		forecast.Until = now
+	}
+	hours := math.Max(forecast.Until.Sub(forecast.From).Hours(), 0)
+	config := cloud.Params(record.Config)
+	if expected := config.Float(cloud.ExpectedHoursParam); expected > 0 && expected < hours {
+		hours = expected
+	}
+	days := hours / 24
+
+	var variance float64
+	if count := int(last.Sub(first).Hours()/24) + 1; !last.IsZero() && count >= minForecastHistoryDays {
+		mean, sumSquares := 0.0, 0.0
+		for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+			mean += daily[day]
+		}
+		mean /= float64(count)
+		for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+			sumSquares += math.Pow(daily[day]-mean, 2)
+		}
+		forecast.Method = "history"
+		forecast.DailyRate = mean
+		// Daily costs vary independently, so the spread of their sum grows with the square root of the days
+		variance = days * sumSquares / float64(count)
+	} else {
+		forecast.Method = "estimate"
+		forecast.DailyRate = cloud.HourlyRate(record.EstimatedCost, config) * 24
+		variance = math.Pow(estimateUncertainty*forecast.DailyRate*days, 2)
+	}
+
+	expected := forecast.DailyRate * days
+	band := forecastZ * math.Sqrt(variance)
+	forecast.Expected = roundAmount(expected)
+	forecast.Low = roundAmount(math.Max(expected-band, 0))
+	forecast.High = roundAmount(expected + band)
+	forecast.DailyRate = roundAmount(forecast.DailyRate)
+	return forecast, variance
+}
+
+// pendingCommitments is the budget held by a group's approved sessions that have not created their service
+func pendingCommitments(groupID, excludeSession string) (float64, error) {
+	filter := bson.M{"group_id": groupID, "committed_cost": bson.M{"$gt": 0}}
+	if excludeSession != "" {
+		filter["session_id"] = bson.M{"$ne": excludeSession}
+	}
+	cursor, err := GetUserSessionCollection().Find(context.Background(), filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pending sessions: %v", err)
+	}
+	var sessions []commitmentHolder
+	if err := cursor.All(context.Background(), &sessions); err != nil {
+		return 0, fmt.Errorf("failed to decode sessions: %v", err)
+	}
+	total := 0.0
+	for _, session := range sessions {
+		total += session.Committed
+	}
+	return total, nil
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"multitenant/cloud"
+	"multitenant/models"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestForecastServiceStart(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	day := func(offset int) time.Time { return now.Truncate(24*time.Hour).AddDate(0, 0, offset) }
+	periodStart, periodEnd := day(-14), day(16)
+	periodic := models.Group{PeriodStart: &periodStart, PeriodEnd: &periodEnd}
+
+	// billed returns a daily cost point for each day between two offsets from today
+	billed := func(from, to int) []models.CostPoint {
+		var points []models.CostPoint
+		for offset := from; offset <= to; offset++ {
+			points = append(points, models.CostPoint{Date: day(offset), Amount: 2})
+		}
+		return points
+	}
+
+	tests := []struct {
+		name    string
+		group   models.Group
+		created time.Time
+		points  []models.CostPoint
+		from    time.Time
+	}{
+		{"no period, no cost points", models.Group{}, day(-30), nil, now},
+		{"no period, billed before now", models.Group{}, day(-30), billed(-5, -2), now},
+		{"no period, billed through today", models.Group{}, day(-30), billed(-5, 0), day(1)},
+		{"period, no cost points", periodic, day(-30), nil, periodStart},
+		{"period, created during it", periodic, day(-3), nil, day(-3)},
+		{"period, billed during it", periodic, day(-30), billed(-5, -2), day(-1)},
+		{"period, billed before it", periodic, day(-30), billed(-20, -16), periodStart},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := models.ServiceRecord{
+				ID:            primitive.NewObjectID(),
+				Service:       cloud.AWSS3,
+				EstimatedCost: 219,
+				Config:        map[string]interface{}{},
+				ServiceStatus: "running",
+				Timestamp:     tt.created,
+			}
+			start, end := forecastWindow(tt.group, now)
+			forecast, _ := forecastService(record, tt.points, start, end, now)
+			if !forecast.From.Equal(tt.from) {
+				t.Fatalf("From = %v, want %v", forecast.From, tt.from)
+			}
+
+			rate := forecast.DailyRate
+			if forecast.Method == "estimate" {
+				rate = cloud.HourlyRate(record.EstimatedCost, cloud.Params(record.Config)) * 24
+			}
+			want := roundAmount(rate * end.Sub(tt.from).Hours() / 24)
+			if forecast.Expected != want {
+				t.Fatalf("Expected = %v, want %v from %v to %v", forecast.Expected, want, tt.from, end)
+			}
+		})
+	}
+}
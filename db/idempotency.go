@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multitenant/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	IdempotencyStatusProcessing = "processing"
+	IdempotencyStatusCompleted  = "completed"
+
+	// how long a completed key is kept around for replays
+	idempotencyRetention = 24 * time.Hour
+)
+
+func GetIdempotencyKeysCollection() *mongo.Collection {
+	return newClient.Database(databaseName).Collection("idempotency_keys")
+}
+
+// EnsureIdempotencyIndexes creates the unique (username, key) index used as the lock and the TTL index for expiry
+func EnsureIdempotencyIndexes() error {
+	_, err := GetIdempotencyKeysCollection().Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "username", Value: 1}, {Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create idempotency indexes: %v", err)
+	}
+	return nil
+}
+
+// AcquireIdempotencyKey tries to lock an Idempotency-Key for the given user.
+// It returns acquired=true when the caller owns the key and must process the request.
+// Otherwise the existing record is returned so the caller can replay or reject the request.
+func AcquireIdempotencyKey(username, key, fingerprint, method, path string, lockTTL time.Duration) (*models.IdempotencyRecord, bool, error) {
+	now := time.Now()
+	record := models.IdempotencyRecord{
+		Key:         key,
+		Username:    username,
+		Fingerprint: fingerprint,
+		Method:      method,
+		Path:        path,
+		Status:      IdempotencyStatusProcessing,
+		LockedUntil: now.Add(lockTTL),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(idempotencyRetention),
+	}
+
+	// The unique index makes the insert the lock: only one concurrent request can win it
+	_, err := GetIdempotencyKeysCollection().InsertOne(context.Background(), record)
+	if err == nil {
+		return &record, true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, false, fmt.Errorf("failed to store idempotency key: %v", err)
+	}
+
+	var existing models.IdempotencyRecord
+	filter := bson.M{"username": username, "key": key}
+	err = GetIdempotencyKeysCollection().FindOne(context.Background(), filter).Decode(&existing)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// The previous holder released the key in between, let the client retry
+			return nil, false, fmt.Errorf("idempotency key '%s' was released concurrently, please retry", key)
+		}
+		return nil, false, fmt.Errorf("failed to fetch idempotency key: %v", err)
+	}
+
+	// Take over a stale lock left behind by a request that never finished
+	if existing.Status == IdempotencyStatusProcessing && existing.Fingerprint == fingerprint && existing.LockedUntil.Before(now) {
+		takeover := bson.M{
+			"username":     username,
+			"key":          key,
+			"status":       IdempotencyStatusProcessing,
+			"locked_until": bson.M{"$lt": now},
+		}
+		update := bson.M{"$set": bson.M{"locked_until": now.Add(lockTTL)}}
+		result, err := GetIdempotencyKeysCollection().UpdateOne(context.Background(), takeover, update)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to take over idempotency key: %v", err)
+		}
+		if result.ModifiedCount == 1 {
+			existing.LockedUntil = now.Add(lockTTL)
+			return &existing, true, nil
+		}
+	}
+
+	return &existing, false, nil
+}
+
+// CompleteIdempotencyKey stores the response of the original request so retries can replay it
+func CompleteIdempotencyKey(username, key string, statusCode int, contentType string, body []byte) error {
+	filter := bson.M{"username": username, "key": key}
+	update := bson.M{
+		"$set": bson.M{
+			"status":        IdempotencyStatusCompleted,
+			"status_code":   statusCode,
+			"content_type":  contentType,
+			"response_body": body,
+			"completed_at":  time.Now(),
+		},
+	}
+
+	_, err := GetIdempotencyKeysCollection().UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %v", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey removes a key whose request failed so that the client can retry with the same key
+func ReleaseIdempotencyKey(username, key string) error {
+	filter := bson.M{"username": username, "key": key, "status": IdempotencyStatusProcessing}
+	_, err := GetIdempotencyKeysCollection().DeleteOne(context.Background(), filter)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"multitenant/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Ledger entry types
+const (
+	LedgerCommitment = "commitment"
+	LedgerRelease    = "release"
+	LedgerAccrual    = "accrual"
+)
+
+// ErrBudgetExceeded is returned when a commitment does not fit in the group's remaining budget
+var ErrBudgetExceeded = errors.New("remaining budget exceeded")
+
+// ErrCommitmentConflict is returned when the commitment of a session or service kept changing under a request
+var ErrCommitmentConflict = errors.New("the budget commitment was changed by another request")
+
+// budgetTolerance absorbs rounding in the running totals when checking a commitment against the budget
+const budgetTolerance = 0.005
+
+func GetBudgetLedgerCollection() *mongo.Collection {
+	return newClient.Database(databaseName).Collection("budget_ledger")
+}
+
+// EnsureBudgetLedgerIndexes creates the index listing a group's entries and the unique index on accrual keys
+func EnsureBudgetLedgerIndexes() error {
+	_, err := GetBudgetLedgerCollection().Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create budget ledger indexes: %v", err)
+	}
+	return nil
+}
+
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// groupSpent is the committed plus accrued amount of a group document
+var groupSpent = bson.M{"$add": bson.A{
+	bson.M{"$ifNull": bson.A{"$budget_committed", 0}},
+	bson.M{"$ifNull": bson.A{"$budget_accrued", 0}},
+}}
+
+// adjustCommitted moves a group's committed total by delta in one update. Unless forced, an increase only
+// applies while it fits in the remaining budget of the period, so concurrent commitments cannot spend the same amount twice.
+func adjustCommitted(groupID string, delta float64, force bool) error {
+	filter := bson.M{"group_id": groupID}
+	if delta > 0 && !force {
+		// Checks are against the current period
+		if _, err := currentGroup(groupID); err != nil {
+			return err
+		}
+		filter["$expr"] = bson.M{"$lte": bson.A{
+			bson.M{"$add": bson.A{groupSpent, delta}},
+			bson.M{"$add": bson.A{"$budget", bson.M{"$ifNull": bson.A{"$budget_rollover", 0}}, budgetTolerance}},
+		}}
+	}
+
+	result, err := GetGroupsCollection().UpdateOne(context.Background(), filter, bson.M{"$inc": bson.M{"budget_committed": delta}})
+	if err != nil {
+		return fmt.Errorf("failed to update committed budget: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		if _, ok := filter["$expr"]; ok {
+			if count, _ := GetGroupsCollection().CountDocuments(context.Background(), bson.M{"group_id": groupID}); count > 0 {
+				return ErrBudgetExceeded
+			}
+		}
+		return fmt.Errorf("group %s not found", groupID)
+	}
+	return nil
+}
+
+// commitmentHolder is the part of a session or services record that holds budget
+type commitmentHolder struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	ServiceID primitive.ObjectID `bson:"service_id"` // sessions only; services records are keyed by _id
+	SessionID string             `bson:"session_id"`
+	Username  string             `bson:"username"`
+	GroupID   string             `bson:"group_id"`
+	Committed float64            `bson:"committed_cost"`
+}
+
+// setCommitment moves the budget held by one session or services record to target(current). The group total
+// is adjusted first and the record is then updated only if its commitment did not change meanwhile; otherwise
+// the group adjustment is reverted and the move retried.
+func setCommitment(collection *mongo.Collection, filter bson.M, isService, force bool, note string, target func(current float64) float64) error {
+	for attempt := 0; attempt < 3; attempt++ {
+		var holder commitmentHolder
+		if err := collection.FindOne(context.Background(), filter).Decode(&holder); err != nil {
+			return fmt.Errorf("failed to read budget commitment: %w", err)
+		}
+		amount := roundAmount(math.Max(target(holder.Committed), 0))
+		delta := roundAmount(amount - holder.Committed)
+		if delta == 0 {
+			return nil
+		}
+		if err := adjustCommitted(holder.GroupID, delta, force); err != nil {
+			return err
+		}
+
+		swap := bson.M{"_id": holder.ID, "committed_cost": holder.Committed}
+		if holder.Committed == 0 {
+			swap["committed_cost"] = bson.M{"$in": bson.A{nil, 0}}
+		}
+		result, err := collection.UpdateOne(context.Background(), swap, bson.M{"$set": bson.M{"committed_cost": amount}})
+		if err != nil || result.MatchedCount == 0 {
+			if revertErr := adjustCommitted(holder.GroupID, -delta, true); revertErr != nil {
+				log.Printf("Failed to revert commitment of group %s by %.2f: %v", holder.GroupID, delta, revertErr)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to update budget commitment: %v", err)
+			}
+			continue
+		}
+
+		entry := models.LedgerEntry{
+			GroupID:   holder.GroupID,
+			Type:      LedgerCommitment,
+			Amount:    delta,
+			SessionID: holder.SessionID,
+			Username:  holder.Username,
+			Note:      note,
+			Timestamp: time.Now(),
+		}
+		if delta < 0 {
+			entry.Type, entry.Amount = LedgerRelease, -delta
+		}
+		serviceID := holder.ServiceID
+		if isService {
+			serviceID = holder.ID
+		}
+		if !serviceID.IsZero() {
+			entry.ServiceID = &serviceID
+		}
+		if _, err := GetBudgetLedgerCollection().InsertOne(context.Background(), entry); err != nil {
+			log.Printf("Failed to record %s of %.2f for group %s: %v", entry.Type, entry.Amount, entry.GroupID, err)
+		}
+		return nil
+	}
+	return ErrCommitmentConflict
+}
+
+// CommitSessionBudget holds amount of the group's budget for an approved session, replacing what the session
+// held before. It fails with ErrBudgetExceeded when the increase does not fit in the remaining budget.
+func CommitSessionBudget(sessionID string, amount float64) error {
+	return setCommitment(GetUserSessionCollection(), bson.M{"session_id": sessionID}, false, false, "estimate approved",
+		func(float64) float64 { return amount })
+}
+
+// ReleaseSessionBudget gives back the budget held by a session that will not create its service
+func ReleaseSessionBudget(sessionID, note string) error {
+	return setCommitment(GetUserSessionCollection(), bson.M{"session_id": sessionID}, false, true, note,
+		func(float64) float64 { return 0 })
+}
+
+// AdjustServiceCommitment moves the budget held by a service by delta, e.g. when an update changes its estimate.
+// Unforced increases fail with ErrBudgetExceeded when they do not fit in the remaining budget.
+func AdjustServiceCommitment(id primitive.ObjectID, delta float64, force bool, note string) error {
+	return setCommitment(GetServicesCollection(), bson.M{"_id": id}, true, force, note,
+		func(current float64) float64 { return current + delta })
+}
+
+// ReleaseServiceBudget gives back the budget still held by a deleted or expired service
+func ReleaseServiceBudget(id primitive.ObjectID, note string) error {
+	return setCommitment(GetServicesCollection(), bson.M{"_id": id}, true, true, note,
+		func(float64) float64 { return 0 })
+}
+
+// RecordAccrual records actual cost incurred at a time (now when zero) against a group's budget. Cost incurred
+// before the current period counts towards the closed period it belongs to. The service's commitment, if any, is
+// drawn down by the same amount since that part of its estimate is now spent. A non-empty key makes the call
+// idempotent: it reports false when an accrual with that key was already recorded.
+func RecordAccrual(groupID string, serviceID *primitive.ObjectID, amount float64, incurredAt time.Time, key, note string) (bool, error) {
+	amount = roundAmount(amount)
+	if incurredAt.IsZero() {
+		incurredAt = time.Now()
+	}
+	group, err := currentGroup(groupID)
+	if err != nil {
+		return false, err
+	}
+
+	entry := models.LedgerEntry{
+		GroupID:    groupID,
+		Type:       LedgerAccrual,
+		Amount:     amount,
+		ServiceID:  serviceID,
+		Key:        key,
+		Note:       note,
+		IncurredAt: &incurredAt,
+		Timestamp:  time.Now(),
+	}
+	if _, err := GetBudgetLedgerCollection().InsertOne(context.Background(), entry); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to record accrual: %v", err)
+	}
+
+	late := group.PeriodStart != nil && incurredAt.Before(*group.PeriodStart)
+	if late {
+		if _, err := accrueToClosedPeriod(groupID, incurredAt, amount); err != nil {
+			return true, err
+		}
+	} else {
+		result, err := GetGroupsCollection().UpdateOne(context.Background(),
+			bson.M{"group_id": groupID}, bson.M{"$inc": bson.M{"budget_accrued": amount}})
+		if err != nil {
+			return true, fmt.Errorf("failed to update accrued budget: %v", err)
+		}
+		if result.MatchedCount == 0 {
+			return true, fmt.Errorf("group %s not found", groupID)
+		}
+	}
+
+	// The commitment covers the current period only
+	if serviceID != nil && amount > 0 && !late {
+		err := setCommitment(GetServicesCollection(), bson.M{"_id": *serviceID}, true, true, "drawn down by accrual",
+			func(current float64) float64 { return current - amount })
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// GetBudgetBalance returns the budget, committed and accrued totals of a group in its current period
+func GetBudgetBalance(groupID string) (*models.BudgetBalance, error) {
+	group, err := currentGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.BudgetBalance{
+		GroupID:     groupID,
+		Budget:      group.Budget,
+		Rollover:    group.BudgetRollover,
+		Committed:   roundAmount(group.BudgetCommitted),
+		Accrued:     roundAmount(group.BudgetAccrued),
+		Remaining:   roundAmount(group.Budget + group.BudgetRollover - group.BudgetCommitted - group.BudgetAccrued),
+		Period:      group.BudgetPeriod,
+		PeriodStart: group.PeriodStart,
+		PeriodEnd:   group.PeriodEnd,
+	}, nil
+}
+
+// ListBudgetLedger returns a group's latest ledger entries, newest first
+func ListBudgetLedger(groupID string, limit int) ([]models.LedgerEntry, error) {
+	cursor, err := GetBudgetLedgerCollection().Find(context.Background(), bson.M{"group_id": groupID},
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list budget ledger: %v", err)
+	}
+	entries := []models.LedgerEntry{}
+	if err := cursor.All(context.Background(), &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode budget ledger: %v", err)
+	}
+	return entries, nil
+}
+
+// BackfillBudgetCommitments commits the estimate of active services created before the ledger existed,
+// whether or not it fits in the budget since the resources are already running
+func BackfillBudgetCommitments() (int, error) {
+	cursor, err := GetServicesCollection().Find(context.Background(), bson.M{
+		"committed_cost": bson.M{"$exists": false},
+		"estimated_cost": bson.M{"$gt": 0},
+		"service_status": bson.M{"$nin": bson.A{"deleted", "expired"}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list services to backfill: %v", err)
+	}
+	var records []models.ServiceRecord
+	if err := cursor.All(context.Background(), &records); err != nil {
+		return 0, fmt.Errorf("failed to decode services: %v", err)
+	}
+
+	backfilled := 0
+	groups := map[string]*models.Group{}
+	for _, record := range records {
+		group, ok := groups[record.GroupID]
+		if !ok {
+			if group, err = currentGroup(record.GroupID); err != nil {
+				log.Printf("Skipping budget backfill of service %s: %v", record.ID.Hex(), err)
+				continue
+			}
+			groups[record.GroupID] = group
+		}
+		amount := PeriodCost(group.PeriodEnd, record.EstimatedCost, record.Config)
+		if err := AdjustServiceCommitment(record.ID, amount, true, "backfilled from estimate"); err != nil {
+			return backfilled, err
+		}
+		backfilled++
+	}
+	return backfilled, nil
+}
+
+// ReleaseStaleSessionCommitments gives back the budget of approved sessions that created nothing since before.
+// The session must be estimated again before it can create its service.
+func ReleaseStaleSessionCommitments(before time.Time) (int, error) {
+	cursor, err := GetUserSessionCollection().Find(context.Background(), bson.M{
+		"committed_cost": bson.M{"$gt": 0},
+		"estimated_at":   bson.M{"$lt": before},
+		"config":         bson.M{"$exists": false},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list stale sessions: %v", err)
+	}
+	var sessions []struct {
+		SessionID   string    `bson:"session_id"`
+		EstimatedAt time.Time `bson:"estimated_at"`
+	}
+	if err := cursor.All(context.Background(), &sessions); err != nil {
+		return 0, fmt.Errorf("failed to decode sessions: %v", err)
+	}
+
+	released := 0
+	for _, session := range sessions {
+		// Withdraw the approval first so the session cannot create its service without the budget;
+		// a session estimated again since it was listed is left alone
+		result, err := GetUserSessionCollection().UpdateOne(context.Background(),
+			bson.M{"session_id": session.SessionID, "estimated_at": session.EstimatedAt, "config": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"status": "in-progress"}})
+		if err != nil {
+			return released, fmt.Errorf("failed to withdraw session approval: %v", err)
+		}
+		if result.MatchedCount == 0 {
+			continue
+		}
+		if err := ReleaseSessionBudget(session.SessionID, "session abandoned"); err != nil {
+			return released, err
+		}
+		released++
+	}
+	return released, nil
+}
@@ -10,7 +10,7 @@ import (
 
 // AuthenticateUser checks if the user exists with the correct credentials and returns the tag
 func AuthenticateUser(username, password string) (bool, string, error) {
-	collection := client.Database("mydatabase").Collection("users")
+	collection := client.Database(databaseName).Collection("users")
 
 	// Check if the user exists with the given username and password
 	var user models.User
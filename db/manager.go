@@ -4,7 +4,6 @@ import (
     "context"
     "fmt"
     "log"
-    "multitenant/config"
     "multitenant/models"
     "strings"
     "crypto/rand"
@@ -12,31 +11,22 @@ import (
  
     "go.mongodb.org/mongo-driver/bson"
     "go.mongodb.org/mongo-driver/mongo"
-    "go.mongodb.org/mongo-driver/mongo/options"
 )
  
 var Client *mongo.Client
  
 func GetManagerCollection() *mongo.Collection {
-    return Client.Database("mydatabase").Collection("managers")
+    return Client.Database(databaseName).Collection("managers")
 }
  
 func GetGroupsCollection() *mongo.Collection {
-    return Client.Database("mydatabase").Collection("groups")
+    return Client.Database(databaseName).Collection("groups")
 }
  
 func GetUsersCollection() *mongo.Collection {
-    return Client.Database("mydatabase").Collection("users")
+    return Client.Database(databaseName).Collection("users")
 }
  
-// Initialize MongoDB connection
-func InitMongoDB() {
-    var err error
-    Client, err = mongo.Connect(context.Background(), options.Client().ApplyURI(config.MongoURI))
-    if err != nil {
-        log.Fatal(err)
-    }
-}
  
 // CreateUser creates a new user with validations
 func CreateUser(username, password, email string) models.UserResponse {
@@ -440,6 +430,9 @@ func AddBudget(manager, groupID string, budget float64) models.UserResponse {
             Status:  "error",
         }
     }
+    if err := ResetBudgetAlerts(groupID); err != nil {
+        log.Printf("Failed to re-arm the budget alerts of group %s: %v", groupID, err)
+    }
  
     return models.UserResponse{
         Message: fmt.Sprintf("Budget successfully allocated to group '%s'", group.GroupName),
@@ -496,6 +489,13 @@ func UpdateBudget(manager, groupName string, budget float64) models.UserResponse
             Status:  "error",
         }
     }
+
+    // Alerts fired against the old budget are re-armed
+    if budget != group.Budget {
+        if err := ResetBudgetAlerts(group.GroupID); err != nil {
+            log.Printf("Failed to re-arm the budget alerts of group %s: %v", group.GroupID, err)
+        }
+    }
  
     return models.UserResponse{
         Message: fmt.Sprintf("Budget successfully updated for group '%s'", groupName),
@@ -0,0 +1,301 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"multitenant/cloud"
+	"multitenant/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// periodMonths is the length of each budget period
+var periodMonths = map[string]int{
+	"monthly":   1,
+	"quarterly": 3,
+	"yearly":    12,
+}
+
+// ValidBudgetPeriod reports whether a budget period is supported; the empty period turns resets off
+func ValidBudgetPeriod(period string) bool {
+	_, ok := periodMonths[period]
+	return ok || period == ""
+}
+
+func GetBudgetPeriodsCollection() *mongo.Collection {
+	return newClient.Database(databaseName).Collection("budget_periods")
+}
+
+// EnsureBudgetPeriodIndexes creates the unique index on a group's period starts
+func EnsureBudgetPeriodIndexes() error {
+	_, err := GetBudgetPeriodsCollection().Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "group_id", Value: 1}, {Key: "start", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create budget period indexes: %v", err)
+	}
+	return nil
+}
+
+// periodBounds returns the period of the given length that contains t, counting whole periods from anchor
+func periodBounds(period string, anchor, t time.Time) (time.Time, time.Time) {
+	months := periodMonths[period]
+	elapsed := (t.Year()-anchor.Year())*12 + int(t.Month()) - int(anchor.Month())
+	elapsed -= ((elapsed % months) + months) % months
+
+	start := anchor.AddDate(0, elapsed, 0)
+	for start.After(t) {
+		start = start.AddDate(0, -months, 0)
+	}
+	for !start.AddDate(0, months, 0).After(t) {
+		start = start.AddDate(0, months, 0)
+	}
+	return start, start.AddDate(0, months, 0)
+}
+
+// SetBudgetPeriod makes a group's budget reset every period, counted from anchor, carrying at most rolloverCap of
+// unspent budget into the next period. An empty period turns resets off. When the current period changes, the
+// running one is closed into the history without a rollover and the new one starts with nothing accrued.
+func SetBudgetPeriod(groupID, period string, anchor time.Time, rolloverCap float64) (*models.BudgetBalance, error) {
+	var group models.Group
+	if err := GetGroupsCollection().FindOne(context.Background(), bson.M{"group_id": groupID}).Decode(&group); err != nil {
+		return nil, fmt.Errorf("group not found: %v", err)
+	}
+
+	now := time.Now().UTC()
+	set := bson.M{"budget_period": period, "period_anchor": anchor, "rollover_cap": rolloverCap}
+	unset := bson.M{}
+	var start, end time.Time
+	if period == "" {
+		unset = bson.M{"budget_period": "", "period_anchor": "", "period_start": "", "period_end": "", "rollover_cap": "", "budget_rollover": ""}
+		set = bson.M{}
+	} else {
+		start, end = periodBounds(period, anchor, now)
+		set["period_start"], set["period_end"] = start, end
+	}
+
+	changed := group.PeriodStart == nil || !group.PeriodStart.Equal(start) || group.PeriodEnd == nil || !group.PeriodEnd.Equal(end)
+	if changed && period != "" {
+		set["budget_accrued"] = 0.0
+		set["budget_rollover"] = 0.0
+		unset["session_block"] = ""
+	}
+
+	// The totals must not move while the running period is closed
+	filter := bson.M{
+		"group_id":         groupID,
+		"budget_accrued":   totalFilter(group.BudgetAccrued),
+		"budget_committed": totalFilter(group.BudgetCommitted),
+	}
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	result, err := GetGroupsCollection().UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set budget period: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrCommitmentConflict
+	}
+
+	if changed && group.PeriodStart != nil && group.PeriodEnd != nil {
+		closed := closedPeriod(group, now, 0)
+		if now.Before(closed.End) {
+			closed.End = now
+		}
+		saveClosedPeriod(closed)
+	}
+	if changed && period != "" {
+		renewServiceCommitments(groupID, end.Sub(now))
+	}
+	return GetBudgetBalance(groupID)
+}
+
+// totalFilter matches a running total as read, including totals never written
+func totalFilter(value float64) interface{} {
+	if value == 0 {
+		return bson.M{"$in": bson.A{nil, 0}}
+	}
+	return value
+}
+
+// currentGroup reads a group, first moving it into the period that contains now if its period has ended
+func currentGroup(groupID string) (*models.Group, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		var group models.Group
+		if err := GetGroupsCollection().FindOne(context.Background(), bson.M{"group_id": groupID}).Decode(&group); err != nil {
+			return nil, fmt.Errorf("group not found: %v", err)
+		}
+		rolled, err := rollBudgetPeriod(group, time.Now().UTC())
+		if err != nil {
+			return nil, err
+		}
+		if rolled {
+			continue
+		}
+		return &group, nil
+	}
+	return nil, ErrCommitmentConflict
+}
+
+// rollBudgetPeriod closes a group's ended period and opens the one containing now. Accruals reset; unspent
+// budget up to the rollover cap is carried over; running services hold their estimate for the new period.
+// It reports whether the group changed, whoever changed it.
+func rollBudgetPeriod(group models.Group, now time.Time) (bool, error) {
+	if group.BudgetPeriod == "" || group.PeriodEnd == nil || group.PeriodAnchor == nil || now.Before(*group.PeriodEnd) {
+		return false, nil
+	}
+
+	start, end := periodBounds(group.BudgetPeriod, *group.PeriodAnchor, now)
+	unspent := group.Budget + group.BudgetRollover - group.BudgetCommitted - group.BudgetAccrued
+	carry := roundAmount(math.Min(math.Max(unspent, 0), group.RolloverCap))
+
+	// Only the request that sees the period as read closes it; accruals landing meanwhile make it retry
+	result, err := GetGroupsCollection().UpdateOne(context.Background(),
+		bson.M{
+			"group_id":         group.GroupID,
+			"period_end":       *group.PeriodEnd,
+			"budget_accrued":   totalFilter(group.BudgetAccrued),
+			"budget_committed": totalFilter(group.BudgetCommitted),
+		},
+		bson.M{
+			"$set": bson.M{
+				"period_start":    start,
+				"period_end":      end,
+				"budget_accrued":  0.0,
+				"budget_rollover": carry,
+			},
+			// A hard limit blocks sessions for the rest of its period only
+			"$unset": bson.M{"session_block": ""},
+		},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to roll budget period: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return true, nil
+	}
+
+	saveClosedPeriod(closedPeriod(group, now, carry))
+	renewServiceCommitments(group.GroupID, end.Sub(now))
+	return true, nil
+}
+
+// closedPeriod builds the history entry of a group's current period
+func closedPeriod(group models.Group, now time.Time, carry float64) models.BudgetPeriod {
+	return models.BudgetPeriod{
+		GroupID:     group.GroupID,
+		Period:      group.BudgetPeriod,
+		Start:       *group.PeriodStart,
+		End:         *group.PeriodEnd,
+		Budget:      group.Budget,
+		Rollover:    group.BudgetRollover,
+		Committed:   roundAmount(group.BudgetCommitted),
+		Accrued:     roundAmount(group.BudgetAccrued),
+		CarriedOver: carry,
+		ClosedAt:    now,
+	}
+}
+
+func saveClosedPeriod(period models.BudgetPeriod) {
+	_, err := GetBudgetPeriodsCollection().InsertOne(context.Background(), period)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		log.Printf("Failed to record budget period of group %s: %v", period.GroupID, err)
+	}
+}
+
+// PeriodCost is the part of a service's quarterly estimate that falls in the budget period ending at periodEnd.
+// Budgets without periods are checked against the whole estimate.
+func PeriodCost(periodEnd *time.Time, estimate float64, config map[string]interface{}) float64 {
+	if periodEnd == nil {
+		return estimate
+	}
+	return cloud.PeriodCost(estimate, cloud.Params(config), time.Until(*periodEnd))
+}
+
+// renewServiceCommitments makes each running service of a group hold its estimate for the time left in the period
+func renewServiceCommitments(groupID string, left time.Duration) {
+	cursor, err := GetServicesCollection().Find(context.Background(), bson.M{
+		"group_id":       groupID,
+		"estimated_cost": bson.M{"$gt": 0},
+		"service_status": bson.M{"$nin": bson.A{"deleted", "expired"}},
+	})
+	if err != nil {
+		log.Printf("Failed to list services of group %s to renew commitments: %v", groupID, err)
+		return
+	}
+	var records []models.ServiceRecord
+	if err := cursor.All(context.Background(), &records); err != nil {
+		log.Printf("Failed to decode services of group %s: %v", groupID, err)
+		return
+	}
+
+	for _, record := range records {
+		amount := cloud.PeriodCost(record.EstimatedCost, cloud.Params(record.Config), left)
+		err := setCommitment(GetServicesCollection(), bson.M{"_id": record.ID}, true, true, "budget period started",
+			func(float64) float64 { return amount })
+		if err != nil {
+			log.Printf("Failed to renew commitment of service %s: %v", record.ID.Hex(), err)
+		}
+	}
+}
+
+// RollBudgetPeriods moves every group whose budget period ended into its current period
+func RollBudgetPeriods() (int, error) {
+	cursor, err := GetGroupsCollection().Find(context.Background(), bson.M{
+		"budget_period": bson.M{"$exists": true},
+		"period_end":    bson.M{"$lte": time.Now().UTC()},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list groups to roll: %v", err)
+	}
+	var groups []models.Group
+	if err := cursor.All(context.Background(), &groups); err != nil {
+		return 0, fmt.Errorf("failed to decode groups: %v", err)
+	}
+
+	rolled := 0
+	for _, group := range groups {
+		if _, err := currentGroup(group.GroupID); err != nil {
+			return rolled, err
+		}
+		rolled++
+	}
+	return rolled, nil
+}
+
+// ListBudgetPeriods returns the closed periods of a group, latest first
+func ListBudgetPeriods(groupID string) ([]models.BudgetPeriod, error) {
+	cursor, err := GetBudgetPeriodsCollection().Find(context.Background(), bson.M{"group_id": groupID},
+		options.Find().SetSort(bson.D{{Key: "start", Value: -1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list budget periods: %v", err)
+	}
+	periods := []models.BudgetPeriod{}
+	if err := cursor.All(context.Background(), &periods); err != nil {
+		return nil, fmt.Errorf("failed to decode budget periods: %v", err)
+	}
+	return periods, nil
+}
+
+// accrueToClosedPeriod adds a late accrual to the closed period it was incurred in.
+// It reports false when no closed period covers the time.
+func accrueToClosedPeriod(groupID string, incurredAt time.Time, amount float64) (bool, error) {
+	result, err := GetBudgetPeriodsCollection().UpdateOne(context.Background(),
+		bson.M{"group_id": groupID, "start": bson.M{"$lte": incurredAt}, "end": bson.M{"$gt": incurredAt}},
+		bson.M{"$inc": bson.M{"accrued": amount}})
+	if err != nil {
+		return false, fmt.Errorf("failed to update budget period: %v", err)
+	}
+	return result.MatchedCount > 0, nil
+}
@@ -0,0 +1,223 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"multitenant/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func GetPowerSchedulesCollection() *mongo.Collection {
+	return newClient.Database(databaseName).Collection("power_schedules")
+}
+
+// EnsurePowerScheduleIndexes allows a single schedule per service and indexes the group listings
+func EnsurePowerScheduleIndexes() error {
+	_, err := GetPowerSchedulesCollection().Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "service_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"service_id": bson.M{"$exists": true}}),
+		},
+		{Keys: bson.D{{Key: "group_id", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create power schedule indexes: %v", err)
+	}
+	return nil
+}
+
+// GetServiceRecord fetches a services record by its ID
+func GetServiceRecord(id primitive.ObjectID) (*models.ServiceRecord, error) {
+	var record models.ServiceRecord
+	if err := GetServicesCollection().FindOne(context.Background(), bson.M{"_id": id}).Decode(&record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// RecordPowerAction stores the status and provider state of a service after it was started or stopped
+func RecordPowerAction(id primitive.ObjectID, status, liveState string, action models.PowerAction) error {
+	_, err := GetServicesCollection().UpdateOne(context.Background(), bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"service_status":    status,
+			"live_state":        liveState,
+			"last_power_action": action,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record power action: %v", err)
+	}
+	return nil
+}
+
+// ListPowerTargets returns the active services of a group that a group schedule applies to
+func ListPowerTargets(groupID string) ([]models.ServiceRecord, error) {
+	cursor, err := GetServicesCollection().Find(context.Background(), bson.M{
+		"group_id":       groupID,
+		"service_status": bson.M{"$nin": bson.A{"deleted", "expired", "missing"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	var records []models.ServiceRecord
+	if err := cursor.All(context.Background(), &records); err != nil {
+		return nil, fmt.Errorf("failed to decode services: %v", err)
+	}
+	return records, nil
+}
+
+// SaveServiceSchedule creates or replaces the schedule of a single service
+func SaveServiceSchedule(schedule *models.PowerSchedule) error {
+	update := bson.M{
+		"$set": bson.M{
+			"group_id":      schedule.GroupID,
+			"start_cron":    schedule.StartCron,
+			"stop_cron":     schedule.StopCron,
+			"timezone":      schedule.Timezone,
+			"enabled":       schedule.Enabled,
+			"next_start_at": schedule.NextStartAt,
+			"next_stop_at":  schedule.NextStopAt,
+			"created_by":    schedule.CreatedBy,
+			"updated_at":    schedule.UpdatedAt,
+		},
+	}
+	result := GetPowerSchedulesCollection().FindOneAndUpdate(context.Background(),
+		bson.M{"service_id": schedule.ServiceID}, update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After))
+	if err := result.Decode(schedule); err != nil {
+		return fmt.Errorf("failed to save schedule: %v", err)
+	}
+	return nil
+}
+
+// CreateGroupSchedule stores a schedule applying to every service of a group
+func CreateGroupSchedule(schedule *models.PowerSchedule) error {
+	schedule.ServiceID = nil
+	result, err := GetPowerSchedulesCollection().InsertOne(context.Background(), schedule)
+	if err != nil {
+		return fmt.Errorf("failed to save schedule: %v", err)
+	}
+	schedule.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// GetServiceSchedule returns the schedule of a single service
+func GetServiceSchedule(serviceID primitive.ObjectID) (*models.PowerSchedule, error) {
+	var schedule models.PowerSchedule
+	if err := GetPowerSchedulesCollection().FindOne(context.Background(), bson.M{"service_id": serviceID}).Decode(&schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// DeleteServiceSchedule removes the schedule of a single service, reporting whether there was one
+func DeleteServiceSchedule(serviceID primitive.ObjectID) (bool, error) {
+	result, err := GetPowerSchedulesCollection().DeleteOne(context.Background(), bson.M{"service_id": serviceID})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete schedule: %v", err)
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// ListGroupSchedules returns the group-wide and per-service schedules of a group
+func ListGroupSchedules(groupID string) ([]models.PowerSchedule, error) {
+	cursor, err := GetPowerSchedulesCollection().Find(context.Background(), bson.M{"group_id": groupID},
+		options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	schedules := []models.PowerSchedule{}
+	if err := cursor.All(context.Background(), &schedules); err != nil {
+		return nil, fmt.Errorf("failed to decode schedules: %v", err)
+	}
+	return schedules, nil
+}
+
+// DeleteGroupSchedule removes a schedule of a group by ID, reporting whether it existed
+func DeleteGroupSchedule(groupID string, id primitive.ObjectID) (bool, error) {
+	result, err := GetPowerSchedulesCollection().DeleteOne(context.Background(), bson.M{"_id": id, "group_id": groupID})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete schedule: %v", err)
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// ListDuePowerSchedules returns the enabled schedules with a start or stop due at or before now
+func ListDuePowerSchedules(now time.Time) ([]models.PowerSchedule, error) {
+	cursor, err := GetPowerSchedulesCollection().Find(context.Background(), bson.M{
+		"enabled": true,
+		"$or": bson.A{
+			bson.M{"next_start_at": bson.M{"$lte": now}},
+			bson.M{"next_stop_at": bson.M{"$lte": now}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due schedules: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	var schedules []models.PowerSchedule
+	if err := cursor.All(context.Background(), &schedules); err != nil {
+		return nil, fmt.Errorf("failed to decode schedules: %v", err)
+	}
+	return schedules, nil
+}
+
+// ListScheduledServiceIDs returns the services of a group that have an enabled schedule of their own
+func ListScheduledServiceIDs(groupID string) (map[primitive.ObjectID]bool, error) {
+	cursor, err := GetPowerSchedulesCollection().Find(context.Background(), bson.M{
+		"group_id":   groupID,
+		"service_id": bson.M{"$exists": true},
+		"enabled":    true,
+	}, options.Find().SetProjection(bson.M{"service_id": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service schedules: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	var schedules []models.PowerSchedule
+	if err := cursor.All(context.Background(), &schedules); err != nil {
+		return nil, fmt.Errorf("failed to decode schedules: %v", err)
+	}
+	ids := make(map[primitive.ObjectID]bool, len(schedules))
+	for _, schedule := range schedules {
+		ids[*schedule.ServiceID] = true
+	}
+	return ids, nil
+}
+
+// ClaimPowerSchedule moves a due schedule on to its next runs. It reports false when another server already
+// claimed this run, so each run is executed once.
+func ClaimPowerSchedule(schedule models.PowerSchedule, nextStart, nextStop *time.Time) (bool, error) {
+	result, err := GetPowerSchedulesCollection().UpdateOne(context.Background(),
+		bson.M{
+			"_id":           schedule.ID,
+			"next_start_at": schedule.NextStartAt,
+			"next_stop_at":  schedule.NextStopAt,
+		},
+		bson.M{"$set": bson.M{"next_start_at": nextStart, "next_stop_at": nextStop}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim schedule: %v", err)
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// RecordPowerScheduleRun stores the outcome of a schedule's last action
+func RecordPowerScheduleRun(id primitive.ObjectID, run models.PowerScheduleRun) error {
+	_, err := GetPowerSchedulesCollection().UpdateOne(context.Background(), bson.M{"_id": id}, bson.M{"$set": bson.M{"last_run": run}})
+	if err != nil {
+		return fmt.Errorf("failed to record schedule run: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multitenant/cloud"
+	"multitenant/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func GetPricingCatalogCollection() *mongo.Collection {
+	return newClient.Database(databaseName).Collection("pricing_catalog")
+}
+
+// EnsurePricingCatalogIndexes creates the unique index on the price key
+func EnsurePricingCatalogIndexes() error {
+	_, err := GetPricingCatalogCollection().Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create pricing catalog indexes: %v", err)
+	}
+	return nil
+}
+
+// PricingCatalog is the cloud.PriceStore kept in the pricing_catalog collection
+type PricingCatalog struct{}
+
+func (PricingCatalog) GetPrice(key string) (*models.CatalogPrice, error) {
+	var price models.CatalogPrice
+	err := GetPricingCatalogCollection().FindOne(context.Background(), bson.M{"key": key}).Decode(&price)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, cloud.ErrPriceNotCached
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pricing catalog: %v", err)
+	}
+	return &price, nil
+}
+
+func (PricingCatalog) PutPrices(prices []models.CatalogPrice) error {
+	if len(prices) == 0 {
+		return nil
+	}
+	writes := make([]mongo.WriteModel, len(prices))
+	for i, price := range prices {
+		writes[i] = mongo.NewReplaceOneModel().SetFilter(bson.M{"key": price.Key}).SetReplacement(price).SetUpsert(true)
+	}
+	if _, err := GetPricingCatalogCollection().BulkWrite(context.Background(), writes, options.BulkWrite().SetOrdered(false)); err != nil {
+		return fmt.Errorf("failed to save prices: %v", err)
+	}
+	return nil
+}
+
+func (PricingCatalog) ListPrices() ([]models.CatalogPrice, error) {
+	cursor, err := GetPricingCatalogCollection().Find(context.Background(), bson.M{}, options.Find().SetSort(bson.D{{Key: "key", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pricing catalog: %v", err)
+	}
+	prices := []models.CatalogPrice{}
+	if err := cursor.All(context.Background(), &prices); err != nil {
+		return nil, fmt.Errorf("failed to decode pricing catalog: %v", err)
+	}
+	return prices, nil
+}
@@ -0,0 +1,346 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"multitenant/cloud"
+	"multitenant/models"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// deliveredReportRetention is how long delivered chargeback reports are kept
+const deliveredReportRetention = 400 * 24 * time.Hour
+
+func GetChargebackReportsCollection() *mongo.Collection {
+	return newClient.Database(databaseName).Collection("chargeback_reports")
+}
+
+func GetReportSchedulesCollection() *mongo.Collection {
+	return newClient.Database(databaseName).Collection("report_schedules")
+}
+
+// EnsureReportIndexes indexes the listings of delivered reports and schedules, and expires old reports
+func EnsureReportIndexes() error {
+	_, err := GetChargebackReportsCollection().Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "owner", Value: 1}, {Key: "generated_at", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "generated_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(deliveredReportRetention.Seconds())),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create report indexes: %v", err)
+	}
+	_, err = GetReportSchedulesCollection().Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "owner", Value: 1}}},
+		{Keys: bson.D{{Key: "enabled", Value: 1}, {Key: "next_run_at", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create report indexes: %v", err)
+	}
+	return nil
+}
+
+// GenerateChargebackReport totals the actual cost from start up to end of the groups of a manager, or of the whole
+// organization when manager is empty. Every service that ran in the range is listed, with its synced cost.
+func GenerateChargebackReport(manager string, start, end time.Time) (*models.ChargebackReport, error) {
+	groupFilter := bson.M{}
+	if manager != "" {
+		groupFilter["manager"] = manager
+	}
+	cursor, err := GetGroupsCollection().Find(context.Background(), groupFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list groups: %v", err)
+	}
+	var groups []models.Group
+	if err := cursor.All(context.Background(), &groups); err != nil {
+		return nil, fmt.Errorf("failed to decode groups: %v", err)
+	}
+	byGroup := make(map[string]models.Group, len(groups))
+	groupIDs := make([]string, 0, len(groups))
+	for _, group := range groups {
+		byGroup[group.GroupID] = group
+		groupIDs = append(groupIDs, group.GroupID)
+	}
+
+	// A manager's report only covers their groups; the organization's also covers groups since removed
+	pointFilter := bson.M{"date": bson.M{"$gte": start, "$lt": end}}
+	serviceFilter := bson.M{
+		"timestamp": bson.M{"$lt": end},
+		"$or": bson.A{
+			bson.M{"end_timestamp": bson.M{"$exists": false}},
+			bson.M{"end_timestamp": nil},
+			bson.M{"end_timestamp": bson.M{"$gte": start}},
+		},
+	}
+	if manager != "" {
+		pointFilter["group_id"] = bson.M{"$in": groupIDs}
+		serviceFilter["group_id"] = bson.M{"$in": groupIDs}
+	}
+
+	points, err := findCostPoints(pointFilter)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err = GetServicesCollection().Find(context.Background(), serviceFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %v", err)
+	}
+	var records []models.ServiceRecord
+	if err := cursor.All(context.Background(), &records); err != nil {
+		return nil, fmt.Errorf("failed to decode services: %v", err)
+	}
+
+	series := buildCostSeries(points, start, end)
+	report := &models.ChargebackReport{
+		Org:         cloud.OrgTag(),
+		Manager:     manager,
+		Start:       start,
+		End:         end,
+		Currency:    series.Currency,
+		LastSync:    series.LastSync,
+		GeneratedAt: time.Now(),
+	}
+
+	// Cost is charged to the group and owner on the services record; points of records since removed keep theirs
+	services := map[primitive.ObjectID]*models.ServiceChargeback{}
+	owners := map[primitive.ObjectID][2]string{}
+	for _, record := range records {
+		services[record.ID] = &models.ServiceChargeback{
+			ServiceID:     record.ID,
+			Provider:      record.Provider,
+			Service:       record.Service,
+			ServiceStatus: record.ServiceStatus,
+		}
+		owners[record.ID] = [2]string{record.GroupID, record.Username}
+	}
+	for _, point := range points {
+		service, ok := services[point.ServiceID]
+		if !ok {
+			service = &models.ServiceChargeback{
+				ServiceID:     point.ServiceID,
+				Provider:      point.Provider,
+				Service:       point.Service,
+				ServiceStatus: "deleted",
+			}
+			services[point.ServiceID] = service
+			owners[point.ServiceID] = [2]string{point.GroupID, point.Username}
+		}
+		service.Amount += point.Amount
+		if point.Amount != 0 {
+			service.Days++
+		}
+	}
+
+	// manager -> group -> user -> services
+	tree := map[string]map[string]map[string][]models.ServiceChargeback{}
+	for id, service := range services {
+		groupID, username := owners[id][0], owners[id][1]
+		owner := byGroup[groupID].Manager
+		if tree[owner] == nil {
+			tree[owner] = map[string]map[string][]models.ServiceChargeback{}
+		}
+		if tree[owner][groupID] == nil {
+			tree[owner][groupID] = map[string][]models.ServiceChargeback{}
+		}
+		service.Amount = roundAmount(service.Amount)
+		tree[owner][groupID][username] = append(tree[owner][groupID][username], *service)
+	}
+
+	report.Managers = []models.ManagerCost{}
+	for owner, groupsOfManager := range tree {
+		managerCost := models.ManagerCost{Manager: owner, Groups: []models.GroupCost{}}
+		for groupID, users := range groupsOfManager {
+			groupCost := models.GroupCost{GroupID: groupID, GroupName: byGroup[groupID].GroupName, Users: []models.UserCost{}}
+			for username, userServices := range users {
+				userCost := models.UserCost{Username: username, Services: userServices}
+				for _, service := range userServices {
+					userCost.Total += service.Amount
+				}
+				sort.Slice(userCost.Services, func(i, j int) bool {
+					return moreExpensive(userCost.Services[i].Amount, userCost.Services[j].Amount,
+						userCost.Services[i].ServiceID.Hex(), userCost.Services[j].ServiceID.Hex())
+				})
+				userCost.Total = roundAmount(userCost.Total)
+				groupCost.Total += userCost.Total
+				groupCost.Users = append(groupCost.Users, userCost)
+			}
+			sort.Slice(groupCost.Users, func(i, j int) bool {
+				return moreExpensive(groupCost.Users[i].Total, groupCost.Users[j].Total,
+					groupCost.Users[i].Username, groupCost.Users[j].Username)
+			})
+			groupCost.Total = roundAmount(groupCost.Total)
+			managerCost.Total += groupCost.Total
+			managerCost.Groups = append(managerCost.Groups, groupCost)
+		}
+		sort.Slice(managerCost.Groups, func(i, j int) bool {
+			return moreExpensive(managerCost.Groups[i].Total, managerCost.Groups[j].Total,
+				managerCost.Groups[i].GroupID, managerCost.Groups[j].GroupID)
+		})
+		managerCost.Total = roundAmount(managerCost.Total)
+		report.Total += managerCost.Total
+		report.Managers = append(report.Managers, managerCost)
+	}
+	sort.Slice(report.Managers, func(i, j int) bool {
+		return moreExpensive(report.Managers[i].Total, report.Managers[j].Total,
+			report.Managers[i].Manager, report.Managers[j].Manager)
+	})
+	report.Total = roundAmount(report.Total)
+	return report, nil
+}
+
+// moreExpensive orders report lines by amount, then by name so reports of equal amounts read the same every time
+func moreExpensive(a, b float64, nameA, nameB string) bool {
+	if a != b {
+		return a > b
+	}
+	return nameA < nameB
+}
+
+// SaveChargebackReport stores a delivered report
+func SaveChargebackReport(report *models.ChargebackReport) error {
+	result, err := GetChargebackReportsCollection().InsertOne(context.Background(), report)
+	if err != nil {
+		return fmt.Errorf("failed to save report: %v", err)
+	}
+	report.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// ListChargebackReports returns the latest reports delivered to a user, newest first, without their breakdown
+func ListChargebackReports(owner string, limit int64) ([]models.ChargebackReport, error) {
+	cursor, err := GetChargebackReportsCollection().Find(context.Background(), bson.M{"owner": owner},
+		options.Find().
+			SetSort(bson.D{{Key: "generated_at", Value: -1}}).
+			SetLimit(limit).
+			SetProjection(bson.M{"managers": 0}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reports: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	reports := []models.ChargebackReport{}
+	if err := cursor.All(context.Background(), &reports); err != nil {
+		return nil, fmt.Errorf("failed to decode reports: %v", err)
+	}
+	return reports, nil
+}
+
+// GetChargebackReport returns a report delivered to a user
+func GetChargebackReport(owner string, id primitive.ObjectID) (*models.ChargebackReport, error) {
+	var report models.ChargebackReport
+	err := GetChargebackReportsCollection().FindOne(context.Background(), bson.M{"_id": id, "owner": owner}).Decode(&report)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// CreateReportSchedule stores a report delivery schedule
+func CreateReportSchedule(schedule *models.ReportSchedule) error {
+	result, err := GetReportSchedulesCollection().InsertOne(context.Background(), schedule)
+	if err != nil {
+		return fmt.Errorf("failed to save schedule: %v", err)
+	}
+	schedule.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// ListReportSchedules returns the report schedules a user created
+func ListReportSchedules(owner string) ([]models.ReportSchedule, error) {
+	cursor, err := GetReportSchedulesCollection().Find(context.Background(), bson.M{"owner": owner},
+		options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	schedules := []models.ReportSchedule{}
+	if err := cursor.All(context.Background(), &schedules); err != nil {
+		return nil, fmt.Errorf("failed to decode schedules: %v", err)
+	}
+	return schedules, nil
+}
+
+// DeleteReportSchedule removes a report schedule of a user, reporting whether it existed
+func DeleteReportSchedule(owner string, id primitive.ObjectID) (bool, error) {
+	result, err := GetReportSchedulesCollection().DeleteOne(context.Background(), bson.M{"_id": id, "owner": owner})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete schedule: %v", err)
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// ListDueReportSchedules returns the enabled report schedules due at or before now
+func ListDueReportSchedules(now time.Time) ([]models.ReportSchedule, error) {
+	cursor, err := GetReportSchedulesCollection().Find(context.Background(), bson.M{
+		"enabled":     true,
+		"next_run_at": bson.M{"$lte": now},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due schedules: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	var schedules []models.ReportSchedule
+	if err := cursor.All(context.Background(), &schedules); err != nil {
+		return nil, fmt.Errorf("failed to decode schedules: %v", err)
+	}
+	return schedules, nil
+}
+
+// ClaimReportSchedule moves a due schedule on to its next run. It reports false when another server already
+// claimed this run, so each report is delivered once.
+func ClaimReportSchedule(schedule models.ReportSchedule, next *time.Time) (bool, error) {
+	result, err := GetReportSchedulesCollection().UpdateOne(context.Background(),
+		bson.M{"_id": schedule.ID, "next_run_at": schedule.NextRunAt},
+		bson.M{"$set": bson.M{"next_run_at": next}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim schedule: %v", err)
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// RecordReportRun stores the outcome of a schedule's last delivery
+func RecordReportRun(id primitive.ObjectID, run models.ReportRun) error {
+	_, err := GetReportSchedulesCollection().UpdateOne(context.Background(), bson.M{"_id": id}, bson.M{"$set": bson.M{"last_run": run}})
+	if err != nil {
+		return fmt.Errorf("failed to record schedule run: %v", err)
+	}
+	return nil
+}
+
+// NotifyReportDelivered tells the owner of a schedule that a report is ready to download
+func NotifyReportDelivered(report models.ChargebackReport) error {
+	// Managers only schedule reports of their own groups; any other owner is an admin
+	prefix := "/admin"
+	if report.Owner == report.Manager {
+		prefix = "/manager"
+	}
+	_, err := GetNotificationsCollection().InsertOne(context.Background(), models.Notification{
+		Manager: report.Owner,
+		Type:    "chargeback_report",
+		Message: fmt.Sprintf("The chargeback report for %s to %s is ready: $%.2f in total.",
+			report.Start.Format("Jan 02, 2006"), report.End.AddDate(0, 0, -1).Format("Jan 02, 2006"), report.Total),
+		Action: fmt.Sprintf("GET %s/reports/%s?format=csv", prefix, report.ID.Hex()),
+		Details: map[string]interface{}{
+			"report_id": report.ID.Hex(),
+			"start":     report.Start,
+			"end":       report.End,
+			"total":     report.Total,
+			"currency":  report.Currency,
+		},
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save notification: %v", err)
+	}
+	return nil
+}
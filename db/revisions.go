@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multitenant/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrServiceBusy is returned when another update of the same service is still running
+var ErrServiceBusy = errors.New("another update of this service is in progress")
+
+// ErrRevisionConflict is returned when the services record changed while an update was applied
+var ErrRevisionConflict = errors.New("the service was changed by another request")
+
+// serviceUpdateLockTTL is how long an update may hold a service before another update may take it over.
+// Resizes that stop and start an instance take several minutes.
+const serviceUpdateLockTTL = 30 * time.Minute
+
+func GetServiceRevisionsCollection() *mongo.Collection {
+	return newClient.Database(databaseName).Collection("service_revisions")
+}
+
+// EnsureRevisionIndexes creates the unique index numbering the revisions of a service
+func EnsureRevisionIndexes() error {
+	_, err := GetServiceRevisionsCollection().Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "service_id", Value: 1}, {Key: "revision", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create revision indexes: %v", err)
+	}
+	return nil
+}
+
+// ServiceRevisionNumber returns the config revision of a services record; records never updated are revision 1
+func ServiceRevisionNumber(service bson.M) int {
+	switch revision := service["revision"].(type) {
+	case int32:
+		return int(revision)
+	case int64:
+		return int(revision)
+	}
+	return 1
+}
+
+// LockServiceForUpdate marks a service as being updated so concurrent updates are refused
+func LockServiceForUpdate(id primitive.ObjectID) error {
+	result, err := GetServicesCollection().UpdateOne(context.Background(),
+		bson.M{
+			"_id": id,
+			"$or": bson.A{
+				bson.M{"updating_since": bson.M{"$exists": false}},
+				bson.M{"updating_since": bson.M{"$lt": time.Now().Add(-serviceUpdateLockTTL)}},
+			},
+		},
+		bson.M{"$set": bson.M{"updating_since": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to lock service: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrServiceBusy
+	}
+	return nil
+}
+
+// UnlockService releases the update lock of a service
+func UnlockService(id primitive.ObjectID) error {
+	_, err := GetServicesCollection().UpdateOne(context.Background(), bson.M{"_id": id}, bson.M{"$unset": bson.M{"updating_since": ""}})
+	if err != nil {
+		return fmt.Errorf("failed to unlock service: %v", err)
+	}
+	return nil
+}
+
+// RecordServiceRevision stores a new revision of a service's config, makes it the record's current config and
+// estimate, and releases the update lock
+func RecordServiceRevision(revision models.ServiceRevision) error {
+	if _, err := GetServiceRevisionsCollection().InsertOne(context.Background(), revision); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrRevisionConflict
+		}
+		return fmt.Errorf("failed to save service revision: %v", err)
+	}
+
+	_, err := GetServicesCollection().UpdateOne(context.Background(),
+		bson.M{"_id": revision.ServiceID},
+		bson.M{
+			"$set": bson.M{
+				"config":         revision.Config,
+				"estimated_cost": revision.EstimatedCost,
+				"revision":       revision.Revision,
+			},
+			"$unset": bson.M{"updating_since": ""},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update service config: %v", err)
+	}
+	return nil
+}
+
+// ListServiceRevisions returns the recorded revisions of a service, oldest first
+func ListServiceRevisions(serviceID primitive.ObjectID) ([]models.ServiceRevision, error) {
+	cursor, err := GetServiceRevisionsCollection().Find(context.Background(), bson.M{"service_id": serviceID},
+		options.Find().SetSort(bson.D{{Key: "revision", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service revisions: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	revisions := []models.ServiceRevision{}
+	if err := cursor.All(context.Background(), &revisions); err != nil {
+		return nil, fmt.Errorf("failed to decode service revisions: %v", err)
+	}
+	return revisions, nil
+}
@@ -0,0 +1,262 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"multitenant/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultServicePageSize = 50
+	MaxServicePageSize     = 200
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// serviceSortFields maps the accepted sort keys to services fields
+var serviceSortFields = map[string]string{
+	"created":        "timestamp",
+	"ended":          "end_timestamp",
+	"estimated_cost": "estimated_cost",
+	"service":        "service",
+	"provider":       "provider",
+	"status":         "service_status",
+}
+
+// ServiceFilter selects and orders services records. Empty fields match everything.
+type ServiceFilter struct {
+	Username string
+	GroupID  string
+	Provider string
+	Service  string
+	Status   string
+	Drifted  bool      // only records the reconciler flagged
+	From     time.Time // created at or after
+	To       time.Time // created before
+	Sort     string    // one of the serviceSortFields keys, "created" by default
+	Desc     bool
+	Cursor   string // next_cursor of the previous page
+	Limit    int
+}
+
+// EnsureServicesIndexes creates the indexes behind the user and group service listings and the expiry reaper
+func EnsureServicesIndexes() error {
+	_, err := GetServicesCollection().Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "username", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetSparse(true)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create services indexes: %v", err)
+	}
+	return nil
+}
+
+// ValidServiceSort reports whether a sort key is accepted by ListServices
+func ValidServiceSort(sort string) bool {
+	_, ok := serviceSortFields[sort]
+	return sort == "" || ok
+}
+
+// ListServices returns one page of services records matching the filter.
+// Pages are keyed on the sort value and _id of the last record so inserts between requests don't shift them.
+func ListServices(filter ServiceFilter) (*models.ServicePage, error) {
+	sortField := serviceSortFields[filter.Sort]
+	if sortField == "" {
+		sortField = "timestamp"
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultServicePageSize
+	}
+	if limit > MaxServicePageSize {
+		limit = MaxServicePageSize
+	}
+	direction, after := 1, "$gt"
+	if filter.Desc {
+		direction, after = -1, "$lt"
+	}
+
+	query := bson.M{}
+	if filter.Username != "" {
+		query["username"] = filter.Username
+	}
+	if filter.GroupID != "" {
+		query["group_id"] = filter.GroupID
+	}
+	if filter.Provider != "" {
+		query["provider"] = filter.Provider
+	}
+	if filter.Service != "" {
+		query["service"] = filter.Service
+	}
+	if filter.Status != "" {
+		query["service_status"] = filter.Status
+	}
+	if filter.Drifted {
+		query["drift"] = bson.M{"$ne": nil}
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		created := bson.M{}
+		if !filter.From.IsZero() {
+			created["$gte"] = filter.From
+		}
+		if !filter.To.IsZero() {
+			created["$lt"] = filter.To
+		}
+		query["timestamp"] = created
+	}
+
+	if filter.Cursor != "" {
+		value, id, err := decodeServiceCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		// Missing values sort as null: before every other value ascending, after them descending
+		ties := bson.M{sortField: value, "_id": bson.M{after: id}}
+		switch {
+		case value == nil && filter.Desc:
+			query["$or"] = bson.A{ties}
+		case value == nil:
+			query["$or"] = bson.A{bson.M{sortField: bson.M{"$ne": nil}}, ties}
+		case filter.Desc:
+			query["$or"] = bson.A{bson.M{sortField: bson.M{after: value}}, bson.M{sortField: nil}, ties}
+		default:
+			query["$or"] = bson.A{bson.M{sortField: bson.M{after: value}}, ties}
+		}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: sortField, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(limit + 1))
+
+	cursor, err := GetServicesCollection().Find(context.Background(), query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	var raw []bson.Raw
+	if err := cursor.All(context.Background(), &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode services: %v", err)
+	}
+
+	page := &models.ServicePage{Services: make([]models.ServiceRecord, 0, len(raw))}
+	for i, doc := range raw {
+		if i == limit {
+			last := raw[limit-1]
+			page.NextCursor, err = encodeServiceCursor(last.Lookup(sortField), last.Lookup("_id"))
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		var record models.ServiceRecord
+		if err := bson.Unmarshal(doc, &record); err != nil {
+			return nil, fmt.Errorf("failed to decode service: %v", err)
+		}
+		page.Services = append(page.Services, record)
+	}
+	return page, nil
+}
+
+// encodeServiceCursor packs the sort value and _id of a record into an opaque cursor
+func encodeServiceCursor(value, id bson.RawValue) (string, error) {
+	doc := bson.D{{Key: "v", Value: value}, {Key: "id", Value: id}}
+	if value.Type == 0 {
+		// records without the sort field sort as null
+		doc[0].Value = nil
+	}
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeServiceCursor unpacks a cursor written by encodeServiceCursor
+func decodeServiceCursor(cursor string) (interface{}, primitive.ObjectID, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, primitive.NilObjectID, ErrInvalidCursor
+	}
+	var decoded struct {
+		Value interface{}        `bson:"v"`
+		ID    primitive.ObjectID `bson:"id"`
+	}
+	if err := bson.Unmarshal(data, &decoded); err != nil {
+		return nil, primitive.NilObjectID, ErrInvalidCursor
+	}
+	return decoded.Value, decoded.ID, nil
+}
+
+// ListServicesToReconcile returns the records the reconciler checks: every active record, plus
+// records deleted since deletedSince so resources that survived their delete are caught. An empty groupID means all groups.
+// Records being updated are skipped, since a resize briefly stops the resource.
+func ListServicesToReconcile(groupID string, deletedSince time.Time) ([]models.ServiceRecord, error) {
+	query := bson.M{
+		"$or": bson.A{
+			bson.M{"service_status": bson.M{"$nin": bson.A{"deleted", "expired"}}},
+			bson.M{"end_timestamp": bson.M{"$gte": deletedSince}},
+		},
+		"updating_since": bson.M{"$not": bson.M{"$gte": time.Now().Add(-serviceUpdateLockTTL)}},
+	}
+	if groupID != "" {
+		query["group_id"] = groupID
+	}
+
+	cursor, err := GetServicesCollection().Find(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services to reconcile: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	var records []models.ServiceRecord
+	if err := cursor.All(context.Background(), &records); err != nil {
+		return nil, fmt.Errorf("failed to decode services: %v", err)
+	}
+	return records, nil
+}
+
+// RecordReconciliation stores the live state seen for a record and its drift, clearing the drift when nil.
+// An empty status leaves service_status unchanged.
+func RecordReconciliation(id primitive.ObjectID, liveState, status string, drift *models.ServiceDrift) error {
+	set := bson.M{
+		"live_state":    liveState,
+		"reconciled_at": time.Now(),
+	}
+	if status != "" {
+		set["service_status"] = status
+	}
+	update := bson.M{"$set": set}
+	if drift != nil {
+		set["drift"] = drift
+	} else {
+		update["$unset"] = bson.M{"drift": ""}
+	}
+
+	_, err := GetServicesCollection().UpdateOne(context.Background(), bson.M{"_id": id}, update)
+	if err != nil {
+		return fmt.Errorf("failed to record reconciliation: %v", err)
+	}
+	return nil
+}
+
+// GetGroupBudget returns the budget currently assigned to a group
+func GetGroupBudget(groupID string) (float64, error) {
+	var group models.Group
+	if err := GetGroupsCollection().FindOne(context.Background(), bson.M{"group_id": groupID}).Decode(&group); err != nil {
+		return 0, fmt.Errorf("group not found: %v", err)
+	}
+	return group.Budget, nil
+}
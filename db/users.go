@@ -9,11 +9,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"multitenant/config"
+	"multitenant/models"
 	"net/http"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -22,24 +23,52 @@ var newClient *mongo.Client
 
 // Collections
 func GetUserSessionCollection() *mongo.Collection {
-	return newClient.Database("mydatabase").Collection("user_sessions")
+	return newClient.Database(databaseName).Collection("user_sessions")
 }
 
 func GetServicesCollection() *mongo.Collection {
-	return newClient.Database("mydatabase").Collection("services")
+	return newClient.Database(databaseName).Collection("services")
 }
 
 func GetNotificationsCollection() *mongo.Collection {
-	return newClient.Database("mydatabase").Collection("notifications")
+	return newClient.Database(databaseName).Collection("notifications")
 }
 
-// Initialize MongoDB connection
-func init() {
-	var err error
-	newClient, err = mongo.Connect(context.Background(), options.Client().ApplyURI(config.MongoURI))
+// NotifyGroupManager saves a notification for the manager of a group
+func NotifyGroupManager(groupID, message string) error {
+	return SendManagerNotification(groupID, models.Notification{Message: message})
+}
+
+// SendManagerNotification saves a notification, such as a structured budget alert, for the manager of a group
+func SendManagerNotification(groupID string, notification models.Notification) error {
+	manager, err := GetManagerByGroupID(groupID)
+	if err != nil {
+		return err
+	}
+
+	notification.Manager = manager
+	notification.Timestamp = time.Now()
+	_, err = GetNotificationsCollection().InsertOne(context.Background(), notification)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to connect to MongoDB: %v", err))
+		return fmt.Errorf("failed to save notification: %v", err)
 	}
+	return nil
+}
+
+// ListManagerNotifications returns the latest notifications of a manager, newest first
+func ListManagerNotifications(manager string, limit int64) ([]models.Notification, error) {
+	cursor, err := GetNotificationsCollection().Find(context.Background(), bson.M{"manager": manager},
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	notifications := []models.Notification{}
+	if err := cursor.All(context.Background(), &notifications); err != nil {
+		return nil, fmt.Errorf("failed to decode notifications: %v", err)
+	}
+	return notifications, nil
 }
 
 // GenerateSessionID generates a unique session ID
@@ -78,9 +107,15 @@ func StartSession(username, provider string) (string, error) {
 	// 	}
 	// }
 
-	// Create and store session
+	// A hard-limit budget alert stops the group from starting anything new
+	if err := CheckSessionsAllowed(group.GroupID); err != nil {
+		return "", err
+	}
+
+	// Create and store session; service_id becomes the _id of the services record and the mt-service-id tag
 	sessionID := GenerateSessionID()
 	session := bson.M{
+		"service_id":   primitive.NewObjectID(),
 		"username":     username,
 		"groupname":    group.Groupname,
 		"group_id":     group.GroupID,
@@ -121,15 +156,18 @@ func UpdateSession(sessionID, service string) error {
 }
 
 // UpdateSessionWithCost updates the session with the estimated cost (quarterly) and status
-func UpdateSessionWithCost(sessionID string, estimatedCost float64, status string) (string, error) {
+// along with the configuration that was priced
+func UpdateSessionWithCost(sessionID string, estimatedCost float64, status string, estimateConfig bson.M) (string, error) {
 	// Define the filter to find the session by session ID
 	filter := bson.M{"session_id": sessionID}
 
 	// Define the update object
 	update := bson.M{
 		"$set": bson.M{
-			"estimated_cost": estimatedCost,
-			"status":         status,
+			"estimated_cost":  estimatedCost,
+			"status":          status,
+			"estimate_config": estimateConfig,
+			"estimated_at":    time.Now(),
 		},
 	}
 
@@ -158,7 +196,7 @@ func FinalizeSessionWithJWT(sessionID, token string) error {
 
 	// Create a new POST request
 	client := &http.Client{}
-	request, _ := http.NewRequest("POST", "http://localhost:8080/user/complete-session", bytes.NewReader(completeData))
+	request, _ := http.NewRequest("POST", settings.Server.SelfURL+"/user/complete-session", bytes.NewReader(completeData))
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("Authorization", token)
 
@@ -178,8 +216,11 @@ func FinalizeSessionWithJWT(sessionID, token string) error {
 	return nil
 }
 
-// DeleteSession deletes an incomplete session
+// DeleteSession deletes an incomplete session, giving back the budget it held
 func DeleteSession(sessionID string) error {
+	if err := ReleaseSessionBudget(sessionID, "session deleted"); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
 	_, err := GetUserSessionCollection().DeleteOne(context.Background(), bson.M{"session_id": sessionID})
 	return err
 }
@@ -211,6 +252,12 @@ func PushToServicesCollection(session bson.M, config bson.M) error {
 	filter := bson.M{"session_id": session["session_id"]}
 	update := bson.M{"$set": session}
 
+	// The record reuses the ID the resource was tagged with
+	if serviceID, ok := session["service_id"].(primitive.ObjectID); ok {
+		delete(session, "service_id")
+		update["$setOnInsert"] = bson.M{"_id": serviceID}
+	}
+
 	_, err := GetServicesCollection().UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
 	if err != nil {
 		return fmt.Errorf("failed to upsert into services collection: %w", err)
@@ -218,167 +265,45 @@ func PushToServicesCollection(session bson.M, config bson.M) error {
 	return nil
 }
 
-// updates the service_status if service is deleted
-func UpdateawsServiceStatus(username, serviceType, identifier, status string) error {
-	var filter bson.M
-
-	// Build the filter dynamically based on the service type
-	switch serviceType {
-	case "Amazon S3 (Simple Storage Service)":
-		filter = bson.M{
-			"username":           username,
-			"service":            serviceType,
-			"config.bucket_name": identifier,
-		}
-	case "Amazon EC2 (Elastic Compute Cloud)":
-		filter = bson.M{
-			"username":             username,
-			"service":              serviceType,
-			"config.instance_name": identifier,
-		}
-	case "AWS Lambda":
-		filter = bson.M{
-			"username":             username,
-			"service":              serviceType,
-			"config.function_name": identifier,
-		}
-	case "Amazon RDS (Relational Database Service)":
-		filter = bson.M{
-			"username":           username,
-			"service":            serviceType,
-			"config.instance_id": identifier,
-		}
-	case "AWS CloudFront":
-		filter = bson.M{
-			"username":               username,
-			"service":                serviceType,
-			"config.distribution_id": identifier,
-		}
-	case "Amazon VPC (Virtual Private Cloud)":
-		filter = bson.M{
-			"username":    username,
-			"service":     serviceType,
-			"config.name": identifier,
-		}
-	default:
-		return fmt.Errorf("unsupported service type: '%s'", serviceType)
+// GetServiceByID fetches a services record by its ID
+func GetServiceByID(id primitive.ObjectID) (bson.M, error) {
+	var service bson.M
+	err := GetServicesCollection().FindOne(context.Background(), bson.M{"_id": id}).Decode(&service)
+	if err != nil {
+		return nil, err
 	}
+	return service, nil
+}
 
-	// Log the filter for debugging
-	fmt.Printf("Filter used for update: %+v\n", filter)
-
-	// Update query
-	update := bson.M{
-		"$set": bson.M{
-			"service_status": status,
-			"end_timestamp":  time.Now(),
-		},
+// SetServiceStatus updates the status of a services record, stamping the end time once the resource is gone
+func SetServiceStatus(id primitive.ObjectID, status string) error {
+	set := bson.M{"service_status": status}
+	if status == "deleted" || status == "expired" {
+		set["end_timestamp"] = time.Now()
 	}
 
-	// Execute the update operation
-	result, err := GetServicesCollection().UpdateOne(context.Background(), filter, update)
+	result, err := GetServicesCollection().UpdateOne(context.Background(), bson.M{"_id": id}, bson.M{"$set": set})
 	if err != nil {
 		return fmt.Errorf("failed to update service status: %w", err)
 	}
-
 	if result.MatchedCount == 0 {
-		fmt.Printf("No documents matched the filter. Debugging...\n")
-		var document bson.M
-		err = GetServicesCollection().FindOne(context.Background(), bson.M{
-			"username": username,
-			"service":  serviceType,
-		}).Decode(&document)
-		if err == nil {
-			fmt.Printf("Fetched document for debugging: %+v\n", document)
-		} else {
-			fmt.Printf("Failed to fetch document for debugging: %v\n", err)
-		}
-
-		return fmt.Errorf("no matching service found for user '%s' with service type '%s' and identifier '%s'",
-			username, serviceType, identifier)
+		return fmt.Errorf("service %s not found", id.Hex())
 	}
-
-	fmt.Printf("Update successful. Matched: %d, Modified: %d\n", result.MatchedCount, result.ModifiedCount)
 	return nil
 }
 
-func UpdategcpServiceStatus(username, serviceType, identifier, status string) error {
-	var filter bson.M
-
-	// Build the filter dynamically based on the service type
-	switch serviceType {
-	case "Compute Engine":
-		filter = bson.M{
-			"username":    username,
-			"service":     serviceType,
-			"config.name": identifier, // Match by the "name" field in the config
-		}
-	case "Cloud Storage":
-		filter = bson.M{
-			"username":           username,
-			"service":            serviceType,
-			"config.bucket_name": identifier,
-		}
-	case "Google Kubernetes Engine (GKE)":
-		filter = bson.M{
-			"username":            username,
-			"service":             serviceType,
-			"config.cluster_name": identifier,
-		}
-	case "BigQuery":
-		filter = bson.M{
-			"username":          username,
-			"service":           serviceType,
-			"config.dataset_id": identifier,
-		}
-	case "Cloud SQL":
-		filter = bson.M{
-			"username":             username,
-			"service":              serviceType,
-			"config.instance_name": identifier,
-		}
-	default:
-		return fmt.Errorf("unsupported service type: '%s'", serviceType)
-	}
-
-	// Log the filter for debugging
-	fmt.Printf("Filter used for update: %+v\n", filter)
-
-	// Update query
-	update := bson.M{
-		"$set": bson.M{
-			"service_status": status,
-			"end_timestamp":  time.Now(),
-		},
-	}
-
-	// Execute the update operation
-	result, err := GetServicesCollection().UpdateOne(context.Background(), filter, update)
+// GetServiceByIdentifier returns the latest record of a user's service matched by the identifier field of its service type
+func GetServiceByIdentifier(username, serviceType, identifierField, identifier string) (*models.ServiceRecord, error) {
+	var record models.ServiceRecord
+	err := GetServicesCollection().FindOne(context.Background(), bson.M{
+		"username":                  username,
+		"service":                   serviceType,
+		"config." + identifierField: identifier,
+	}, options.FindOne().SetSort(bson.M{"timestamp": -1})).Decode(&record)
 	if err != nil {
-		return fmt.Errorf("failed to update service status: %w", err)
-	}
-
-	// Log the result of the update
-	fmt.Printf("Matched Count: %d, Modified Count: %d\n", result.MatchedCount, result.ModifiedCount)
-
-	// If no matching documents were found, debug deeper
-	if result.MatchedCount == 0 {
-		// Debug: Fetch the document to see why it isn't matching
-		var document bson.M
-		err = GetServicesCollection().FindOne(context.Background(), bson.M{
-			"username": username,
-		}).Decode(&document)
-		if err == nil {
-			fmt.Printf("Fetched document for debugging: %+v\n", document)
-		} else {
-			fmt.Printf("Failed to fetch document for debugging: %v\n", err)
-		}
-
-		return fmt.Errorf("no matching service found for user '%s' with service type '%s' and identifier '%s'",
-			username, serviceType, identifier)
+		return nil, err
 	}
-
-	return nil
+	return &record, nil
 }
 
 // based on the username and instance name.
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"multitenant/db"
+	"multitenant/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// setAccrued records the actual spend of the test group
+func setAccrued(t *testing.T, amount float64) {
+	t.Helper()
+	_, err := db.GetGroupsCollection().UpdateOne(context.Background(),
+		bson.M{"group_id": testGroupID}, bson.M{"$set": bson.M{"budget_accrued": amount}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectFired evaluates the test group's alert rules and checks how many fired
+func expectFired(t *testing.T, want int) {
+	t.Helper()
+	fired, err := db.EvaluateBudgetAlerts(testGroupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fired) != want {
+		t.Fatalf("%d alerts fired, want %d: %+v", len(fired), want, fired)
+	}
+}
+
+func TestAlertsWithoutPeriodsRearm(t *testing.T) {
+	setupFakeCloud(t)
+
+	// The test group has a $100 budget without periods
+	if err := db.SetBudgetAlertRules(testGroupID, []models.BudgetAlertRule{{Threshold: 50, Basis: db.AlertBasisActual}}); err != nil {
+		t.Fatal(err)
+	}
+	setAccrued(t, 60)
+	expectFired(t, 1)
+	expectFired(t, 0)
+
+	// Spend falling back under the threshold re-arms the rule
+	setAccrued(t, 40)
+	expectFired(t, 0)
+	setAccrued(t, 60)
+	expectFired(t, 1)
+
+	// So does a budget change, even when spend stays over the threshold
+	if response := db.UpdateBudget(testManager, "platform", 110); response.Status != "success" {
+		t.Fatalf("UpdateBudget: %s", response.Message)
+	}
+	expectFired(t, 1)
+	expectFired(t, 0)
+}
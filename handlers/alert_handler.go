@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"multitenant/db"
+	"multitenant/models"
+	"net/http"
+)
+
+// maxAlertRules caps the rules of one group
+const maxAlertRules = 10
+
+// GetBudgetAlertsHandler returns a managed group's alert rules, its actual and forecast spend, the rules fired in the
+// current period and any hard-limit block
+func GetBudgetAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := db.GetBudgetAlertStatus(groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Budget alerts fetched successfully",
+		Data:    status,
+	})
+}
+
+// SetBudgetAlertRulesHandler replaces a managed group's alert rules and evaluates them at once.
+// {"rules": [{"threshold": 80, "basis": "actual"}, {"threshold": 100, "basis": "forecast", "hard_limit": true}]}
+func SetBudgetAlertRulesHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Rules []models.BudgetAlertRule `json:"rules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if len(req.Rules) > maxAlertRules {
+		http.Error(w, fmt.Sprintf("A group has at most %d alert rules", maxAlertRules), http.StatusBadRequest)
+		return
+	}
+	seen := map[models.BudgetAlertRule]bool{}
+	for i, rule := range req.Rules {
+		if rule.Basis == "" {
+			rule.Basis = db.AlertBasisActual
+			req.Rules[i] = rule
+		}
+		if !db.ValidAlertBasis(rule.Basis) {
+			http.Error(w, "basis must be actual or forecast", http.StatusBadRequest)
+			return
+		}
+		if rule.Threshold <= 0 || rule.Threshold > 1000 {
+			http.Error(w, "threshold must be a percentage above 0 and at most 1000", http.StatusBadRequest)
+			return
+		}
+		key := models.BudgetAlertRule{Threshold: rule.Threshold, Basis: rule.Basis}
+		if seen[key] {
+			http.Error(w, fmt.Sprintf("Duplicate rule for %g%% of %s spend", rule.Threshold, rule.Basis), http.StatusBadRequest)
+			return
+		}
+		seen[key] = true
+	}
+
+	if err := db.SetBudgetAlertRules(groupID, req.Rules); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err := db.EvaluateBudgetAlerts(groupID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status, err := db.GetBudgetAlertStatus(groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Budget alert rules updated successfully",
+		Data:    status,
+	})
+}
+
+// LiftSessionBlockHandler lets a managed group start sessions again after a hard-limit alert blocked them.
+// The rule does not block again before the next period.
+func LiftSessionBlockHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	lifted, err := db.LiftSessionBlock(groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !lifted {
+		http.Error(w, "New sessions are not blocked in this group", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":  "Session block lifted",
+		"group_id": groupID,
+	})
+}
+
+// ListManagerNotificationsHandler returns the latest notifications of a manager or admin, such as budget alerts and
+// delivered reports
+func ListManagerNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value("username").(string)
+
+	notifications, err := db.ListManagerNotifications(username, 100)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Notifications fetched successfully",
+		Data:    notifications,
+	})
+}
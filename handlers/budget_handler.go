@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"multitenant/db"
+	"multitenant/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxLedgerEntries caps one ledger listing
+const maxLedgerEntries = 1000
+
+// GetGroupBudgetHandler returns the budget of a managed group with its committed, accrued and remaining amounts
+func GetGroupBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	balance, err := db.GetBudgetBalance(groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Group budget fetched successfully",
+		Data:    balance,
+	})
+}
+
+// ListBudgetLedgerHandler returns the latest commitments, releases and accruals of a managed group, newest first
+func ListBudgetLedgerHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		if limit > maxLedgerEntries {
+			limit = maxLedgerEntries
+		}
+	}
+
+	entries, err := db.ListBudgetLedger(groupID, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Budget ledger fetched successfully",
+		Data:    entries,
+	})
+}
+
+// RecordAccrualHandler records actual cost against a managed group's budget, e.g. from an invoice.
+// A key makes the accrual idempotent; a service_id draws down that service's commitment. Cost incurred before the
+// current period is added to the closed period it belongs to.
+func RecordAccrualHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Amount     float64 `json:"amount"`
+		ServiceID  string  `json:"service_id"`
+		IncurredAt string  `json:"incurred_at"` // RFC 3339; now when omitted
+		Key        string  `json:"key"`
+		Note       string  `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if req.Amount == 0 {
+		http.Error(w, "amount is required", http.StatusBadRequest)
+		return
+	}
+
+	var incurredAt time.Time
+	if req.IncurredAt != "" {
+		var err error
+		incurredAt, err = time.Parse(time.RFC3339, req.IncurredAt)
+		if err != nil {
+			http.Error(w, "incurred_at must be an RFC 3339 time such as 2024-06-01T00:00:00Z", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var serviceID *primitive.ObjectID
+	if req.ServiceID != "" {
+		id, err := primitive.ObjectIDFromHex(req.ServiceID)
+		if err != nil {
+			http.Error(w, "Invalid service ID", http.StatusBadRequest)
+			return
+		}
+		service, err := db.GetServiceByID(id)
+		if err != nil || service["group_id"] != groupID {
+			http.Error(w, "Service not found in this group", http.StatusNotFound)
+			return
+		}
+		serviceID = &id
+	}
+
+	recorded, err := db.RecordAccrual(groupID, serviceID, req.Amount, incurredAt, req.Key, req.Note)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !recorded {
+		http.Error(w, fmt.Sprintf("An accrual with key '%s' was already recorded", req.Key), http.StatusConflict)
+		return
+	}
+	if _, err := db.EvaluateBudgetAlerts(groupID); err != nil {
+		log.Printf("Failed to evaluate budget alerts of group %s: %v", groupID, err)
+	}
+
+	balance, err := db.GetBudgetBalance(groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Accrual recorded successfully",
+		Data:    balance,
+	})
+}
+
+// SetBudgetPeriodHandler makes a managed group's budget reset every month, quarter or year.
+// {"period": "quarterly", "start": "2024-04-01", "rollover_cap": 500}: periods are counted from start (the first of
+// January by default) and at most rollover_cap of unspent budget is carried into the next period. An empty period
+// turns resets off.
+func SetBudgetPeriodHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Period      string  `json:"period"`
+		Start       string  `json:"start"`
+		RolloverCap float64 `json:"rollover_cap"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if !db.ValidBudgetPeriod(req.Period) {
+		http.Error(w, "period must be monthly, quarterly or yearly", http.StatusBadRequest)
+		return
+	}
+	if req.RolloverCap < 0 {
+		http.Error(w, "rollover_cap must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	anchor := time.Date(time.Now().UTC().Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	if req.Start != "" {
+		var err error
+		anchor, err = time.Parse("2006-01-02", req.Start)
+		if err != nil {
+			http.Error(w, "start must be a date such as 2024-04-01", http.StatusBadRequest)
+			return
+		}
+		// Later days do not exist in every month
+		if anchor.Day() > 28 {
+			http.Error(w, "start must be on one of the first 28 days of a month", http.StatusBadRequest)
+			return
+		}
+	}
+
+	balance, err := db.SetBudgetPeriod(groupID, req.Period, anchor, req.RolloverCap)
+	if err != nil {
+		if errors.Is(err, db.ErrCommitmentConflict) {
+			http.Error(w, "The budget changed while the period was set; try again", http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Budget period updated successfully",
+		Data:    balance,
+	})
+}
+
+// ListBudgetPeriodsHandler returns the closed budget periods of a managed group, latest first
+func ListBudgetPeriodsHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	periods, err := db.ListBudgetPeriods(groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Budget periods fetched successfully",
+		Data:    periods,
+	})
+}
+
+// GetGroupForecastHandler returns a managed group's expected spend by the end of its budget period, with an 80%
+// confidence band and the part each service adds
+func GetGroupForecastHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	forecast, err := db.ForecastGroupSpend(groupID, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Spend forecast fetched successfully",
+		Data:    forecast,
+	})
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"multitenant/config"
+	"multitenant/secrets"
+	"net/http"
+)
+
+// allowedOrigins are the frontend origins allowed to call the API
+var allowedOrigins = []string{config.DefaultAllowedOrigins}
+
+// secretStore holds the JWT signing keys
+var secretStore *secrets.Cache
+
+// Configure applies the server settings to the handlers and gives them the secrets they sign tokens with
+func Configure(cfg *config.Config, store *secrets.Cache) {
+	allowedOrigins = cfg.Server.AllowedOrigins
+	secretStore = store
+}
+
+// setAllowedOrigin allows the request's origin when it is one of the configured origins. Other origins are answered
+// with the first configured one, which browsers reject.
+func setAllowedOrigin(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || allowed == origin {
+			if allowed == "*" {
+				origin = "*"
+			}
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+			return
+		}
+	}
+	if len(allowedOrigins) > 0 {
+		w.Header().Set("Access-Control-Allow-Origin", allowedOrigins[0])
+		w.Header().Add("Vary", "Origin")
+	}
+}
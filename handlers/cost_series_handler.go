@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"multitenant/db"
+	"multitenant/jobs"
+	"multitenant/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// defaultCostDays is the range of a cost series without from and to
+	defaultCostDays = 30
+
+	maxCostDays = 366
+
+	// maxCostSyncDays bounds a manual backfill; billing APIs keep about a year of daily cost
+	maxCostSyncDays = 90
+)
+
+// GetGroupCostsHandler returns a managed group's actual cost per day and per service, for dashboards.
+// from and to are inclusive dates such as 2024-06-01; the last 30 days by default.
+func GetGroupCostsHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+	start, end, ok := costRange(w, r)
+	if !ok {
+		return
+	}
+
+	series, err := db.GetGroupCostSeries(groupID, start, end)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Group costs fetched successfully",
+		Data:    series,
+	})
+}
+
+// SyncGroupCostsHandler collects a managed group's actual costs now instead of waiting for the scheduled sync.
+// days (3 by default) sets how far back to collect, e.g. to backfill a group whose cost tags were just activated.
+func SyncGroupCostsHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	days := 3
+	if value := r.URL.Query().Get("days"); value != "" {
+		var err error
+		days, err = strconv.Atoi(value)
+		if err != nil || days <= 0 || days > maxCostSyncDays {
+			http.Error(w, "days must be a number from 1 to 90", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// The sync continues if the client disconnects so the costs are still recorded
+	result, err := jobs.SyncCosts(context.Background(), groupID, days)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Group costs synced",
+		Data:    result,
+	})
+}
+
+// ListCostAnomaliesHandler lists the latest days whose cost stood out in a managed group, per service or overall
+func ListCostAnomaliesHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	anomalies, err := db.ListCostAnomalies(groupID, 100)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Cost anomalies fetched successfully",
+		Data:    anomalies,
+	})
+}
+
+// GetServiceCostsHandler returns the actual cost per day of one of the caller's services
+func GetServiceCostsHandler(w http.ResponseWriter, r *http.Request) {
+	service, ok := ownedService(w, r)
+	if !ok {
+		return
+	}
+	start, end, ok := costRange(w, r)
+	if !ok {
+		return
+	}
+
+	id, _ := service["_id"].(primitive.ObjectID)
+	record, err := db.GetServiceRecord(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	series, err := db.GetServiceCostSeries(*record, start, end)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Service costs fetched successfully",
+		Data:    series,
+	})
+}
+
+// costRange reads the from and to query dates into a range of whole days, with an exclusive end
+func costRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	end := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if value := r.URL.Query().Get("to"); value != "" {
+		to, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "to must be a date such as 2024-06-30", http.StatusBadRequest)
+			return time.Time{}, time.Time{}, false
+		}
+		end = to.AddDate(0, 0, 1)
+	}
+
+	start := end.AddDate(0, 0, -defaultCostDays)
+	if value := r.URL.Query().Get("from"); value != "" {
+		from, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "from must be a date such as 2024-06-01", http.StatusBadRequest)
+			return time.Time{}, time.Time{}, false
+		}
+		start = from
+	}
+
+	if !start.Before(end) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return time.Time{}, time.Time{}, false
+	}
+	if end.Sub(start) > maxCostDays*24*time.Hour {
+		http.Error(w, "A cost series covers at most 366 days", http.StatusBadRequest)
+		return time.Time{}, time.Time{}, false
+	}
+	return start, end, true
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"multitenant/cloud"
+	"multitenant/db"
+	"multitenant/models"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	awsRoleARNPattern        = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:role/[\w+=,.@/-]+$`)
+	gcpServiceAccountPattern = regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]@[a-z0-9-]+\.iam\.gserviceaccount\.com$`)
+	gcpProjectIDPattern      = regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+)
+
+// credentialsRequest is the payload accepted by the credential endpoints
+type credentialsRequest struct {
+	AWSRoleARN        string            `json:"aws_role_arn"`
+	AWSExternalID     string            `json:"aws_external_id"`
+	GCPProjectID      string            `json:"gcp_project_id"`
+	GCPServiceAccount string            `json:"gcp_service_account"`
+	AWSRegions        []string          `json:"aws_regions"`
+	GCPRegions        []string          `json:"gcp_regions"`
+	Tags              map[string]string `json:"tags"`
+}
+
+// validate checks the formats of the supplied credentials
+func (req credentialsRequest) validate() error {
+	if req.AWSRoleARN == "" && req.GCPServiceAccount == "" && req.GCPProjectID == "" && len(req.AWSRegions) == 0 && len(req.GCPRegions) == 0 && len(req.Tags) == 0 {
+		return fmt.Errorf("at least an AWS role ARN, a GCP project/service account, a region allow-list or custom tags are required")
+	}
+	if req.AWSRoleARN != "" && !awsRoleARNPattern.MatchString(req.AWSRoleARN) {
+		return fmt.Errorf("invalid AWS role ARN")
+	}
+	if req.AWSExternalID != "" && req.AWSRoleARN == "" {
+		return fmt.Errorf("an external ID requires an AWS role ARN")
+	}
+	if len(req.AWSExternalID) > 1224 {
+		return fmt.Errorf("AWS external ID is too long")
+	}
+	if req.GCPProjectID != "" && !gcpProjectIDPattern.MatchString(req.GCPProjectID) {
+		return fmt.Errorf("invalid GCP project ID")
+	}
+	if req.GCPServiceAccount != "" && !gcpServiceAccountPattern.MatchString(req.GCPServiceAccount) {
+		return fmt.Errorf("invalid GCP service account email")
+	}
+	for _, region := range req.AWSRegions {
+		if !cloud.ValidRegion("aws", region) {
+			return fmt.Errorf("invalid AWS region '%s'", region)
+		}
+	}
+	for _, region := range req.GCPRegions {
+		if !cloud.ValidRegion("gcp", region) {
+			return fmt.Errorf("invalid GCP region '%s'", region)
+		}
+	}
+	return cloud.ValidateCustomTags(req.Tags)
+}
+
+// tenantContext returns a context whose cloud calls run under the credentials of the group, or of its organization
+func tenantContext(groupID string) (context.Context, error) {
+	return db.TenantContext(context.Background(), groupID)
+}
+
+// maskedCredentials hides the external ID, which acts as a shared secret with the customer account
+func maskedCredentials(creds *models.TenantCredentials) models.TenantCredentials {
+	masked := *creds
+	if masked.AWSExternalID != "" {
+		masked.AWSExternalID = strings.Repeat("*", 8)
+	}
+	return masked
+}
+
+// saveCredentials decodes, validates and stores credentials for a scope
+func saveCredentials(w http.ResponseWriter, r *http.Request, scope, scopeID string) {
+	var req credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Without an external ID any manager could have the server assume a role another customer trusts it with
+	if scope == models.CredentialScopeGroup && req.AWSRoleARN != "" && req.AWSExternalID == "" {
+		http.Error(w, "an external ID is required with a group's AWS role ARN", http.StatusBadRequest)
+		return
+	}
+
+	username, _ := r.Context().Value("username").(string)
+	creds := models.TenantCredentials{
+		Scope:             scope,
+		ScopeID:           scopeID,
+		AWSRoleARN:        req.AWSRoleARN,
+		AWSExternalID:     req.AWSExternalID,
+		GCPProjectID:      req.GCPProjectID,
+		GCPServiceAccount: req.GCPServiceAccount,
+		AWSRegions:        req.AWSRegions,
+		GCPRegions:        req.GCPRegions,
+		Tags:              req.Tags,
+		UpdatedBy:         username,
+	}
+	if err := db.SaveTenantCredentials(creds); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Drop cached sessions so the next call assumes the new identity
+	cloud.InvalidateTenant(creds.Key())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Credentials saved successfully",
+		Data:    maskedCredentials(&creds),
+	})
+}
+
+// getCredentials responds with the masked credentials of a scope
+func getCredentials(w http.ResponseWriter, scope, scopeID string) {
+	creds, err := db.GetTenantCredentials(scope, scopeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if creds == nil {
+		http.Error(w, "No credentials registered", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Credentials fetched successfully",
+		Data:    maskedCredentials(creds),
+	})
+}
+
+// deleteCredentials removes the credentials of a scope
+func deleteCredentials(w http.ResponseWriter, scope, scopeID string) {
+	deleted, err := db.DeleteTenantCredentials(scope, scopeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "No credentials registered", http.StatusNotFound)
+		return
+	}
+
+	cloud.InvalidateTenant(scope + ":" + scopeID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Credentials deleted successfully",
+	})
+}
+
+// managedGroupID returns the group ID from the path once the caller is confirmed as its manager
+func managedGroupID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	groupID := mux.Vars(r)["id"]
+	username, _ := r.Context().Value("username").(string)
+
+	manager, err := db.GetManagerByGroupID(groupID)
+	if err != nil || manager != username {
+		http.Error(w, fmt.Sprintf("Group '%s' not found for manager '%s'", groupID, username), http.StatusNotFound)
+		return "", false
+	}
+	return groupID, true
+}
+
+// SetGroupCredentialsHandler registers the AWS role, GCP service account and allowed regions a group provisions with
+func SetGroupCredentialsHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+	saveCredentials(w, r, models.CredentialScopeGroup, groupID)
+}
+
+// GetGroupCredentialsHandler returns the credentials registered for a group
+func GetGroupCredentialsHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+	getCredentials(w, models.CredentialScopeGroup, groupID)
+}
+
+// DeleteGroupCredentialsHandler removes a group's credentials so it falls back to the organization's
+func DeleteGroupCredentialsHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+	deleteCredentials(w, models.CredentialScopeGroup, groupID)
+}
+
+// SetOrgCredentialsHandler registers the organization wide credentials used by groups without their own
+func SetOrgCredentialsHandler(w http.ResponseWriter, r *http.Request) {
+	saveCredentials(w, r, models.CredentialScopeOrg, db.OrgCredentialsID)
+}
+
+// GetOrgCredentialsHandler returns the organization wide credentials
+func GetOrgCredentialsHandler(w http.ResponseWriter, r *http.Request) {
+	getCredentials(w, models.CredentialScopeOrg, db.OrgCredentialsID)
+}
+
+// DeleteOrgCredentialsHandler removes the organization wide credentials
+func DeleteOrgCredentialsHandler(w http.ResponseWriter, r *http.Request) {
+	deleteCredentials(w, models.CredentialScopeOrg, db.OrgCredentialsID)
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"multitenant/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGroupAWSRoleRequiresExternalID(t *testing.T) {
+	body := `{"aws_role_arn": "arn:aws:iam::123456789012:role/provisioner"}`
+	req := httptest.NewRequest(http.MethodPut, "/manager/groups/group-1/credentials", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	saveCredentials(rec, req, models.CredentialScopeGroup, "group-1")
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "external ID") {
+		t.Fatalf("body = %q, want the missing external ID reported", rec.Body.String())
+	}
+}
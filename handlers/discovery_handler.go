@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"multitenant/cloud"
+	"multitenant/db"
+	"multitenant/jobs"
+	"multitenant/models"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DiscoverGroupResourcesHandler scans a managed group's cloud accounts for resources created outside the platform.
+// The optional body {"regions": {"aws": [...], "gcp": [...]}} limits the scan; otherwise the allowed regions are used.
+func DiscoverGroupResourcesHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Regions map[string][]string `json:"regions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	for provider, regions := range req.Regions {
+		if _, ok := cloud.GetProvider(provider); !ok {
+			http.Error(w, fmt.Sprintf("unknown provider '%s'", provider), http.StatusBadRequest)
+			return
+		}
+		for _, region := range regions {
+			if !cloud.ValidRegion(provider, region) {
+				http.Error(w, fmt.Sprintf("invalid %s region '%s'", provider, region), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	// The scan continues if the client disconnects so the results are still stored
+	summary, err := jobs.Discover(context.Background(), groupID, req.Regions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Discovery completed",
+		Data:    summary,
+	})
+}
+
+// ListDiscoveredResourcesHandler lists the untracked resources found for a managed group.
+// Adopted resources are included with ?include_adopted=true.
+func ListDiscoveredResourcesHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	resources, err := db.ListDiscoveredResources(groupID, r.URL.Query().Get("include_adopted") == "true")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Discovered resources fetched successfully",
+		Data:    resources,
+	})
+}
+
+// AdoptResourceHandler adds a discovered resource to a managed group's services, owned by the member in the
+// optional body {"username": ...} or by the manager
+func AdoptResourceHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+	manager, _ := r.Context().Value("username").(string)
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["resource_id"])
+	if err != nil {
+		http.Error(w, "Invalid resource ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	owner := manager
+	if req.Username != "" {
+		memberOf, err := db.GetGroupIDByMember(req.Username)
+		if err != nil || memberOf != groupID {
+			http.Error(w, fmt.Sprintf("User '%s' is not a member of the group", req.Username), http.StatusBadRequest)
+			return
+		}
+		owner = req.Username
+	}
+
+	resource, err := db.GetDiscoveredResource(groupID, id)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.Error(w, "Discovered resource not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if resource.AdoptedServiceID != nil {
+		http.Error(w, db.ErrAlreadyAdopted.Error(), http.StatusConflict)
+		return
+	}
+
+	tracked, err := db.IsResourceTracked(resource.Provider, resource.Service, resource.IDField, resource.ResourceID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if tracked {
+		http.Error(w, "Resource is already tracked by a services record", http.StatusConflict)
+		return
+	}
+
+	svc, err := cloud.LookupService(resource.Provider, resource.Service)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Confirm the resource still exists before it starts counting against the budget
+	ctx, err := tenantContext(groupID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to resolve cloud credentials: %v", err), http.StatusInternalServerError)
+		return
+	}
+	config := cloud.Params(resource.Config)
+	live, err := svc.Describe(ctx, config)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to describe resource: %v", err), http.StatusBadGateway)
+		return
+	}
+	if !live.Exists {
+		http.Error(w, "Resource no longer exists", http.StatusGone)
+		return
+	}
+
+	estimatedCost, err := svc.EstimateCost(config)
+	if err != nil {
+		if !errors.Is(err, cloud.ErrCostNotSupported) {
+			log.Printf("Failed to estimate cost of adopted %s %s: %v", svc.Name(), resource.ResourceID, err)
+		}
+		estimatedCost = 0
+	}
+
+	serviceID, err := db.AdoptDiscoveredResource(resource, owner, manager, cloud.LiveStatus(live), estimatedCost)
+	if err != nil {
+		if errors.Is(err, db.ErrAlreadyAdopted) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("%s %s adopted successfully", svc.Name(), resource.ResourceID),
+		Data: map[string]interface{}{
+			"service_id":     serviceID.Hex(),
+			"username":       owner,
+			"estimated_cost": estimatedCost,
+		},
+	})
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"multitenant/db"
+	"multitenant/models"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// defaultExtension is how long a one-click extension adds when the group has no default TTL
+const defaultExtension = 24 * time.Hour
+
+// resolveExpiry checks a requested expires_at (RFC 3339, may be empty) against the group's TTLs.
+// Without a request the group's default TTL applies, or its maximum when it only has one; nil means the service never expires.
+func resolveExpiry(groupID, requested string, now time.Time) (*time.Time, error) {
+	defaultTTL, maxTTL, err := db.GetGroupTTL(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	var expiresAt time.Time
+	switch {
+	case requested != "":
+		expiresAt, err = time.Parse(time.RFC3339, requested)
+		if err != nil {
+			return nil, errors.New("expires_at must be an RFC 3339 time such as 2024-06-01T18:00:00Z")
+		}
+		if !expiresAt.After(now) {
+			return nil, errors.New("expires_at must be in the future")
+		}
+		if maxTTL > 0 && expiresAt.After(now.Add(maxTTL)) {
+			return nil, fmt.Errorf("expires_at may be at most %s from now in this group", maxTTL)
+		}
+	case defaultTTL > 0:
+		expiresAt = now.Add(defaultTTL)
+	case maxTTL > 0:
+		expiresAt = now.Add(maxTTL)
+	default:
+		return nil, nil
+	}
+
+	expiresAt = expiresAt.UTC()
+	return &expiresAt, nil
+}
+
+// ExtendServiceHandler moves the expiry of one of the caller's services. Without a body it adds the group's
+// default TTL, or a day, to the current expiry; {"hours": n} adds n hours and {"expires_at": ...} sets it.
+// Extensions are capped at the group's maximum TTL from now.
+func ExtendServiceHandler(w http.ResponseWriter, r *http.Request) {
+	service, ok := ownedService(w, r)
+	if !ok {
+		return
+	}
+	if status, _ := service["service_status"].(string); status == "deleted" || status == "expired" {
+		http.Error(w, fmt.Sprintf("Service is already %s", status), http.StatusConflict)
+		return
+	}
+	id, _ := service["_id"].(primitive.ObjectID)
+	groupID, _ := service["group_id"].(string)
+
+	var req struct {
+		Hours     int    `json:"hours"`
+		ExpiresAt string `json:"expires_at"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Hours < 0 {
+		http.Error(w, "hours must be positive", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	var expiresAt time.Time
+	if req.ExpiresAt != "" {
+		resolved, err := resolveExpiry(groupID, req.ExpiresAt, now)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		expiresAt = *resolved
+	} else {
+		defaultTTL, maxTTL, err := db.GetGroupTTL(groupID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		extension := time.Duration(req.Hours) * time.Hour
+		if extension == 0 {
+			extension = defaultTTL
+		}
+		if extension == 0 {
+			extension = defaultExtension
+		}
+
+		from := now
+		if current, ok := service["expires_at"].(primitive.DateTime); ok && current.Time().After(now) {
+			from = current.Time()
+		}
+		expiresAt = from.Add(extension)
+		if maxTTL > 0 && expiresAt.After(now.Add(maxTTL)) {
+			expiresAt = now.Add(maxTTL)
+		}
+		expiresAt = expiresAt.UTC()
+	}
+
+	if err := db.SetServiceExpiry(id, expiresAt); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Service expiry extended successfully",
+		"service_id": id.Hex(),
+		"expires_at": expiresAt,
+	})
+}
+
+// SetGroupTTLHandler sets the default and maximum lifetime of services created in a managed group.
+// Zero or omitted values remove the limit.
+func SetGroupTTLHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		DefaultTTLHours int `json:"default_ttl_hours"`
+		MaxTTLHours     int `json:"max_ttl_hours"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if req.DefaultTTLHours < 0 || req.MaxTTLHours < 0 {
+		http.Error(w, "TTLs must not be negative", http.StatusBadRequest)
+		return
+	}
+	if req.MaxTTLHours > 0 && req.DefaultTTLHours > req.MaxTTLHours {
+		http.Error(w, "default_ttl_hours must not exceed max_ttl_hours", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.SetGroupTTL(groupID, req.DefaultTTLHours, req.MaxTTLHours); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Group TTL updated successfully",
+		Data:    req,
+	})
+}
+
+// ListNotificationsHandler returns the caller's latest notifications, such as expiry warnings
+func ListNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value("username").(string)
+
+	notifications, err := db.ListUserNotifications(username, 100)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Notifications fetched successfully",
+		Data:    notifications,
+	})
+}
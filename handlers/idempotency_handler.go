@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"multitenant/db"
+	"net/http"
+	"time"
+)
+
+// how long a request may hold an Idempotency-Key before another retry may take it over
+const idempotencyLockTTL = 5 * time.Minute
+
+// largest request body read for fingerprinting; create and delete payloads are far smaller
+const idempotentBodyLimit = 1 << 20
+
+// responseRecorder passes the response through while keeping a copy for replays
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	rec.statusCode = statusCode
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Idempotent wraps a create/delete handler with Idempotency-Key support.
+// Requests without the header are passed through unchanged.
+func Idempotent(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > 255 {
+			http.Error(w, "Idempotency-Key must be at most 255 characters", http.StatusBadRequest)
+			return
+		}
+
+		username, _ := r.Context().Value("username").(string)
+
+		// Read the body so it can be fingerprinted and handed on to the handler
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, idempotentBodyLimit))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request payload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := requestFingerprint(r, body)
+
+		record, acquired, err := db.AcquireIdempotencyKey(username, key, fingerprint, r.Method, r.URL.Path, idempotencyLockTTL)
+		if err != nil {
+			log.Printf("Failed to acquire idempotency key: %v", err)
+			http.Error(w, "Failed to process Idempotency-Key", http.StatusInternalServerError)
+			return
+		}
+
+		if !acquired {
+			switch {
+			case record.Fingerprint != fingerprint:
+				http.Error(w, "Idempotency-Key has already been used for a different request", http.StatusUnprocessableEntity)
+			case record.Status == db.IdempotencyStatusProcessing:
+				http.Error(w, "A request with this Idempotency-Key is already in progress", http.StatusConflict)
+			default:
+				// Replay the original response
+				if record.ContentType != "" {
+					w.Header().Set("Content-Type", record.ContentType)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(record.StatusCode)
+				w.Write(record.ResponseBody)
+			}
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.statusCode == 0 {
+			rec.statusCode = http.StatusOK
+		}
+
+		// Server errors are not cached, the key is released so the client can retry it
+		if rec.statusCode >= http.StatusInternalServerError {
+			if err := db.ReleaseIdempotencyKey(username, key); err != nil {
+				log.Printf("Failed to release idempotency key: %v", err)
+			}
+			return
+		}
+
+		if err := db.CompleteIdempotencyKey(username, key, rec.statusCode, rec.Header().Get("Content-Type"), rec.body.Bytes()); err != nil {
+			log.Printf("Failed to store idempotent response: %v", err)
+		}
+	})
+}
+
+// requestFingerprint hashes the parts of a request that must match for a retry
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(r.URL.RequestURI()))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIdempotentRejectsLargeBodies(t *testing.T) {
+	called := false
+	handler := Idempotent(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	req := httptest.NewRequest(http.MethodPost, "/user/create-ec2", bytes.NewReader(make([]byte, idempotentBodyLimit+1)))
+	req.Header.Set("Idempotency-Key", "large-body")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	if called {
+		t.Fatal("handler ran for a body over the limit")
+	}
+}
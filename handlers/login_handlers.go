@@ -6,7 +6,6 @@ import (
     "multitenant/db"
     "multitenant/models"
     "net/http"
-    "os"
     "time"
  
     "github.com/golang-jwt/jwt/v4"
@@ -22,7 +21,7 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
  
     // Set necessary headers
     w.Header().Set("Content-Type", "application/json")
-    w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
+    setAllowedOrigin(w, r)
     w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
     w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
  
@@ -56,9 +55,7 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
         }
  
         // Create the JWT token
-        jwtKey := []byte(os.Getenv("JWT_SECRET")) // Fetch JWT secret from environment
-        token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-        tokenString, err := token.SignedString(jwtKey)
+        tokenString, err := signToken(r.Context(), claims) // Sign with the current key from the secrets provider
         if err != nil {
             http.Error(w, "Failed to generate token", http.StatusInternalServerError)
             return
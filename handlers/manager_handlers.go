@@ -25,7 +25,7 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Send the response
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
+	setAllowedOrigin(w, r)
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
@@ -59,7 +59,7 @@ func CreateGroupHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Send the response
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
+	setAllowedOrigin(w, r)
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
@@ -85,7 +85,7 @@ func AddUserHandler(w http.ResponseWriter, r *http.Request) {
 	response := db.AddUserToGroup(input.Manager, input.GroupID, input.Username)
 	// Send the response
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
+	setAllowedOrigin(w, r)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, "Failed to send response", http.StatusInternalServerError)
 	}
@@ -108,7 +108,7 @@ func RemoveUserHandler(w http.ResponseWriter, r *http.Request) {
 	response := db.RemoveUserFromGroup(input.Manager, input.GroupID, input.Username)
 	// Send the response
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
+	setAllowedOrigin(w, r)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, "Failed to send response", http.StatusInternalServerError)
 	}
@@ -133,7 +133,7 @@ func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
+	setAllowedOrigin(w, r)
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 }
@@ -150,7 +150,7 @@ func ListGroupsHandler(w http.ResponseWriter, r *http.Request) {
 
     // Set headers for the response
     w.Header().Set("Content-Type", "application/json")
-    w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
+    setAllowedOrigin(w, r)
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
@@ -181,7 +181,7 @@ func AddBudgetHandler(w http.ResponseWriter, r *http.Request) {
 	response := db.AddBudget(input.Manager, input.GroupID, input.Budget)
 	// Send the response
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
+	setAllowedOrigin(w, r)
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 	
@@ -226,7 +226,7 @@ func UpdateBudgetHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to send response", http.StatusInternalServerError)
 	}
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
+	setAllowedOrigin(w, r)
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 }
@@ -245,7 +245,7 @@ func CheckUserGroupHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Set headers for CORS
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
+	setAllowedOrigin(w, r)
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
@@ -3,7 +3,6 @@ package handlers
 import (
     "context"
     "net/http"
-    "os"
     "strings"
  
     "github.com/golang-jwt/jwt/v4"
@@ -20,9 +19,9 @@ type Claims struct {
 func CORSMiddleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
         // Set CORS headers
-        w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200") //  frontend's origin
+        setAllowedOrigin(w, r)
         w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-        w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+        w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
  
         // Handle preflight requests
         if r.Method == http.MethodOptions {
@@ -46,11 +45,8 @@ func Authenticate(next http.Handler) http.Handler {
         tokenStr = strings.TrimPrefix(tokenStr, "Bearer ")
  
         claims := &Claims{}
-        jwtKey := []byte(os.Getenv("JWT_SECRET")) // Fetch JWT secret from environment
-        token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-            return jwtKey, nil
-        })
-        if err != nil || !token.Valid {
+        err := parseToken(r.Context(), tokenStr, claims) // Verify against the current and recently rotated keys
+        if err != nil {
             http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
             return
         }
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go/modules/mongodb"
+)
+
+var (
+	mongoOnce      sync.Once
+	mongoContainer *mongodb.MongoDBContainer
+	mongoURI       string
+	mongoErr       error
+)
+
+// testMongoURI returns MONGO_TEST_URI, or else starts a MongoDB container shared by the package's tests.
+// Without Docker the test is skipped, except in CI where it fails.
+func testMongoURI(t *testing.T) string {
+	t.Helper()
+	if uri := os.Getenv("MONGO_TEST_URI"); uri != "" {
+		return uri
+	}
+
+	mongoOnce.Do(func() {
+		// testcontainers panics when it finds no Docker host
+		defer func() {
+			if r := recover(); r != nil {
+				mongoErr = fmt.Errorf("%v", r)
+			}
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+		defer cancel()
+		mongoContainer, mongoErr = mongodb.Run(ctx, "mongo:7")
+		if mongoErr == nil {
+			mongoURI, mongoErr = mongoContainer.ConnectionString(ctx)
+		}
+	})
+	if mongoErr != nil {
+		if os.Getenv("CI") != "" {
+			t.Fatalf("failed to start MongoDB: %v", mongoErr)
+		}
+		t.Skipf("set MONGO_TEST_URI or start Docker to run this test: %v", mongoErr)
+	}
+	return mongoURI
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if mongoContainer != nil {
+		mongoContainer.Terminate(context.Background())
+	}
+	os.Exit(code)
+}
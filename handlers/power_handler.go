@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"multitenant/cloud"
+	"multitenant/db"
+	"multitenant/jobs"
+	"multitenant/models"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// StartServiceHandler starts one of the caller's stopped services
+func StartServiceHandler(w http.ResponseWriter, r *http.Request) {
+	setServicePower(w, r, jobs.PowerStart)
+}
+
+// StopServiceHandler stops one of the caller's services, keeping its disks and data
+func StopServiceHandler(w http.ResponseWriter, r *http.Request) {
+	setServicePower(w, r, jobs.PowerStop)
+}
+
+func setServicePower(w http.ResponseWriter, r *http.Request, action string) {
+	service, ok := ownedService(w, r)
+	if !ok {
+		return
+	}
+	id, _ := service["_id"].(primitive.ObjectID)
+	username, _ := r.Context().Value("username").(string)
+
+	// The call continues if the client disconnects so the new status is still recorded
+	result, err := jobs.SetPower(context.Background(), id, action, username)
+	if err != nil {
+		switch {
+		case errors.Is(err, jobs.ErrServiceInactive):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, cloud.ErrPowerNotSupported):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, fmt.Sprintf("Failed to %s service: %v", action, err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	status := "running"
+	if action == jobs.PowerStop {
+		status = "stopped"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        fmt.Sprintf("Service %s requested successfully", action),
+		"result":         result.Result,
+		"service_id":     id.Hex(),
+		"service_status": status,
+		"live_state":     result.State,
+	})
+}
+
+// scheduleRequest is the body of the schedule endpoints
+type scheduleRequest struct {
+	StartCron string `json:"start_cron"`
+	StopCron  string `json:"stop_cron"`
+	Timezone  string `json:"timezone"` // IANA name, UTC when empty
+	Enabled   *bool  `json:"enabled"`  // true when omitted
+}
+
+// decodeSchedule validates a schedule request and computes its first runs
+func decodeSchedule(r *http.Request, groupID, username string) (*models.PowerSchedule, error) {
+	var req scheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errors.New("Invalid request payload")
+	}
+	if req.StartCron == "" && req.StopCron == "" {
+		return nil, errors.New("at least one of start_cron and stop_cron is required")
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+
+	schedule := &models.PowerSchedule{
+		GroupID:   groupID,
+		StartCron: req.StartCron,
+		StopCron:  req.StopCron,
+		Timezone:  req.Timezone,
+		Enabled:   req.Enabled == nil || *req.Enabled,
+		CreatedBy: username,
+		UpdatedAt: time.Now(),
+	}
+	nextStart, nextStop, err := jobs.NextPowerRuns(*schedule, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	schedule.NextStartAt, schedule.NextStopAt = nextStart, nextStop
+	return schedule, nil
+}
+
+// GetServiceScheduleHandler returns the start/stop schedule of one of the caller's services
+func GetServiceScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	service, ok := ownedService(w, r)
+	if !ok {
+		return
+	}
+	id, _ := service["_id"].(primitive.ObjectID)
+
+	schedule, err := db.GetServiceSchedule(id)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.Error(w, "Service has no schedule", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Schedule fetched successfully",
+		Data:    schedule,
+	})
+}
+
+// SetServiceScheduleHandler creates or replaces the start/stop schedule of one of the caller's services
+func SetServiceScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	service, ok := ownedService(w, r)
+	if !ok {
+		return
+	}
+	id, _ := service["_id"].(primitive.ObjectID)
+	groupID, _ := service["group_id"].(string)
+	provider, _ := service["provider"].(string)
+	serviceType, _ := service["service"].(string)
+	username, _ := r.Context().Value("username").(string)
+
+	svc, err := cloud.LookupService(provider, serviceType)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unsupported service '%s' for provider '%s'", serviceType, provider), http.StatusUnprocessableEntity)
+		return
+	}
+	if !cloud.SupportsPower(svc) {
+		http.Error(w, cloud.ErrPowerNotSupported.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	schedule, err := decodeSchedule(r, groupID, username)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	schedule.ServiceID = &id
+
+	if err := db.SaveServiceSchedule(schedule); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Schedule saved successfully",
+		Data:    schedule,
+	})
+}
+
+// DeleteServiceScheduleHandler removes the start/stop schedule of one of the caller's services
+func DeleteServiceScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	service, ok := ownedService(w, r)
+	if !ok {
+		return
+	}
+	id, _ := service["_id"].(primitive.ObjectID)
+
+	deleted, err := db.DeleteServiceSchedule(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Service has no schedule", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Schedule deleted successfully",
+	})
+}
+
+// ListGroupSchedulesHandler lists the group-wide and per-service schedules of a managed group
+func ListGroupSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	schedules, err := db.ListGroupSchedules(groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Schedules fetched successfully",
+		Data:    schedules,
+	})
+}
+
+// CreateGroupScheduleHandler adds a schedule applying to every service of a managed group that can be stopped
+func CreateGroupScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+	manager, _ := r.Context().Value("username").(string)
+
+	schedule, err := decodeSchedule(r, groupID, manager)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := db.CreateGroupSchedule(schedule); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Schedule created successfully",
+		Data:    schedule,
+	})
+}
+
+// DeleteGroupScheduleHandler removes a schedule of a managed group
+func DeleteGroupScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["schedule_id"])
+	if err != nil {
+		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := db.DeleteGroupSchedule(groupID, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Schedule deleted successfully",
+	})
+}
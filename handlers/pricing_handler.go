@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"multitenant/cloud"
+	"multitenant/jobs"
+	"multitenant/models"
+	"net/http"
+)
+
+// GetPricingCatalogHandler reports how many prices the catalog holds, how fresh they are and the last refresh
+func GetPricingCatalogHandler(w http.ResponseWriter, r *http.Request) {
+	status, err := cloud.PricingCatalogStatus()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if status == nil {
+		http.Error(w, "Estimates are not using a pricing catalog", http.StatusNotFound)
+		return
+	}
+	status.LastRefresh = jobs.LastPricingRefresh()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Pricing catalog status fetched successfully",
+		Data:    status,
+	})
+}
+
+// RefreshPricingCatalogHandler refetches every cached price now instead of waiting for the scheduled refresh
+func RefreshPricingCatalogHandler(w http.ResponseWriter, r *http.Request) {
+	result, err := jobs.RefreshPricing()
+	if err != nil && result == nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Pricing catalog refreshed",
+		Data:    result,
+	})
+}
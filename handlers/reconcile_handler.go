@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"multitenant/jobs"
+	"multitenant/models"
+	"net/http"
+)
+
+// ListGroupDriftHandler lists the services of a managed group whose resources drifted from their records
+func ListGroupDriftHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+	filter, err := parseServiceFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.GroupID = groupID
+	filter.Drifted = true
+
+	listServices(w, filter)
+}
+
+// ReconcileGroupHandler reconciles a managed group's services now instead of waiting for the next scheduled run
+func ReconcileGroupHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+
+	// The run continues if the client disconnects so the results are still recorded
+	summary, err := jobs.Reconcile(context.Background(), groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Group reconciled successfully",
+		Data:    summary,
+	})
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"multitenant/db"
+	"multitenant/jobs"
+	"multitenant/models"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// reportScope returns the manager whose groups the caller may report on: managers see their own groups, admins
+// the whole organization or the groups of the manager they name
+func reportScope(r *http.Request, requested string) string {
+	username, _ := r.Context().Value("username").(string)
+	if tag, _ := r.Context().Value("tag").(string); tag == "admin" {
+		return requested
+	}
+	return username
+}
+
+// GetChargebackReportHandler returns the actual cost by manager, group, user and service over a range of days.
+// from and to are inclusive dates (the last 30 days by default); format=csv or format=json downloads the report.
+func GetChargebackReportHandler(w http.ResponseWriter, r *http.Request) {
+	start, end, ok := costRange(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := db.GenerateChargebackReport(reportScope(r, r.URL.Query().Get("manager")), start, end)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeReport(w, r, report, "Chargeback report generated successfully")
+}
+
+// ListChargebackReportsHandler lists the latest reports delivered to the caller by their schedules
+func ListChargebackReportsHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value("username").(string)
+
+	reports, err := db.ListChargebackReports(username, 100)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Reports fetched successfully",
+		Data:    reports,
+	})
+}
+
+// GetDeliveredReportHandler returns a report delivered to the caller, downloadable like a generated one
+func GetDeliveredReportHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value("username").(string)
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+
+	report, err := db.GetChargebackReport(username, id)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.Error(w, "Report not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeReport(w, r, report, "Report fetched successfully")
+}
+
+// writeReport responds with a report as an API response, or as a file when a format is asked for
+func writeReport(w http.ResponseWriter, r *http.Request, report *models.ChargebackReport, message string) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.UserResponse{
+			Status:  "success",
+			Message: message,
+			Data:    report,
+		})
+		return
+	}
+	if format != jobs.ReportCSV && format != jobs.ReportJSON {
+		http.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	filename := fmt.Sprintf("chargeback-%s-%s-%s.%s", report.Org,
+		report.Start.Format("2006-01-02"), report.End.AddDate(0, 0, -1).Format("2006-01-02"), format)
+	w.Header().Set("Content-Type", jobs.ReportContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if err := jobs.ExportChargeback(w, report, format); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// reportScheduleRequest is the body of the report schedule endpoint
+type reportScheduleRequest struct {
+	Manager    string `json:"manager"`  // admins only; the whole organization when empty
+	Cron       string `json:"cron"`     // e.g. "0 6 1 * *" for 06:00 on the first of the month
+	Timezone   string `json:"timezone"` // IANA name, UTC when empty
+	Range      string `json:"range"`    // previous_month when empty
+	Format     string `json:"format"`   // csv when empty
+	WebhookURL string `json:"webhook_url"`
+	Enabled    *bool  `json:"enabled"` // true when omitted
+}
+
+// CreateReportScheduleHandler schedules the delivery of a chargeback report to the caller
+func CreateReportScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value("username").(string)
+
+	var req reportScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if req.Cron == "" {
+		http.Error(w, "cron is required", http.StatusBadRequest)
+		return
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if req.Range == "" {
+		req.Range = "previous_month"
+	}
+	if req.Format == "" {
+		req.Format = jobs.ReportCSV
+	}
+	if req.Format != jobs.ReportCSV && req.Format != jobs.ReportJSON {
+		http.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+	if _, _, err := jobs.ReportRange(req.Range, time.Now(), time.UTC); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.WebhookURL != "" {
+		target, err := url.Parse(req.WebhookURL)
+		if err != nil || (target.Scheme != "https" && target.Scheme != "http") || target.Host == "" {
+			http.Error(w, "webhook_url must be an http or https URL", http.StatusBadRequest)
+			return
+		}
+	}
+
+	schedule := &models.ReportSchedule{
+		Owner:      username,
+		Manager:    reportScope(r, req.Manager),
+		Cron:       req.Cron,
+		Timezone:   req.Timezone,
+		Range:      req.Range,
+		Format:     req.Format,
+		WebhookURL: req.WebhookURL,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+		UpdatedAt:  time.Now(),
+	}
+	next, err := jobs.NextReportRun(*schedule, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	schedule.NextRunAt = next
+
+	if err := db.CreateReportSchedule(schedule); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Report schedule created successfully",
+		Data:    schedule,
+	})
+}
+
+// ListReportSchedulesHandler lists the caller's report schedules with their last delivery
+func ListReportSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value("username").(string)
+
+	schedules, err := db.ListReportSchedules(username)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Report schedules fetched successfully",
+		Data:    schedules,
+	})
+}
+
+// DeleteReportScheduleHandler removes one of the caller's report schedules
+func DeleteReportScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value("username").(string)
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := db.DeleteReportSchedule(username, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Report schedule not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Report schedule deleted successfully",
+	})
+}
@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"multitenant/cloud"
+	"multitenant/config"
+	"multitenant/db"
+	"multitenant/secrets"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	testUser    = "alice"
+	testManager = "bob"
+	testGroupID = "group-1"
+)
+
+// setupFakeCloud connects to the test MongoDB, using a database of its own that is dropped afterwards, and serves
+// every cloud service from the fake backend. It returns a router with the user routes.
+func setupFakeCloud(t *testing.T) http.Handler {
+	t.Helper()
+
+	uri := testMongoURI(t)
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("MONGO_URI", uri)
+	t.Setenv("MONGO_DATABASE", fmt.Sprintf("multitenant_test_%d", time.Now().UnixNano()))
+	t.Setenv("JWT_SECRET", "test-signing-key")
+	t.Setenv("CLOUD_BACKEND", "fake")
+	t.Setenv("SECRETS_BACKEND", "env")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load configuration: %v", err)
+	}
+	store := secrets.NewCache(secrets.EnvProvider{}, 0, 0)
+	if err := db.ConnectMongoDB(cfg, store); err != nil {
+		t.Fatalf("failed to connect to MongoDB: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Client.Database(cfg.Mongo.Database).Drop(context.Background())
+		db.DisconnectMongoDB()
+	})
+	// The Idempotency-Key lock is the unique index
+	for _, ensure := range []func() error{db.EnsureIdempotencyIndexes, db.EnsureBudgetLedgerIndexes, db.EnsureBudgetAlertIndexes} {
+		if err := ensure(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cloud.Configure(cfg, store); err != nil {
+		t.Fatalf("failed to select the fake backend: %v", err)
+	}
+	t.Cleanup(func() { cloud.UseBackend("live") })
+	Configure(cfg, store)
+
+	_, err = db.GetGroupsCollection().InsertOne(context.Background(), bson.M{
+		"group_id":   testGroupID,
+		"group_name": "platform",
+		"manager":    testManager,
+		"members":    bson.A{testUser},
+		"budget":     100.0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	user := router.PathPrefix("/user").Subrouter()
+	user.Use(Authenticate)
+	user.Use(Authorize("user"))
+	user.HandleFunc("/start-session", StartSessionHandler).Methods("GET")
+	user.HandleFunc("/update-session", UpdateSessionHandler).Methods("POST")
+	user.HandleFunc("/calculate-cost", CalculateCostHandler).Methods("POST")
+	user.HandleFunc("/complete-session", CompleteSessionHandler).Methods("POST")
+	for _, svc := range cloud.AllServices() {
+		user.Handle("/"+svc.Path(), Idempotent(CreateServiceHandler(svc))).Methods("POST")
+	}
+	user.Handle("/services/{id}", Idempotent(DeleteServiceByIDHandler)).Methods("DELETE")
+	user.Handle("/delete-aws-service", Idempotent(DeleteAWSServiceHandler)).Methods("POST")
+	return router
+}
+
+// call sends a request as testUser and returns the response
+func call(t *testing.T, router http.Handler, method, path string, body interface{}, idempotencyKey string) *httptest.ResponseRecorder {
+	t.Helper()
+	return callAs(t, router, testUser, method, path, body, idempotencyKey)
+}
+
+// callAs sends a request signed for username
+func callAs(t *testing.T, router http.Handler, username, method, path string, body interface{}, idempotencyKey string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	token, err := signToken(context.Background(), &Claims{Username: username, Tag: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("expected status %d, got %d: %s", status, rec.Code, rec.Body.String())
+	}
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", rec.Body.String(), err)
+	}
+	return body
+}
+
+// committed returns the budget the test group holds for sessions and services
+func committed(t *testing.T) float64 {
+	t.Helper()
+	balance, err := db.GetBudgetBalance(testGroupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance.Committed
+}
+
+func TestServiceLifecycleOnFakeCloud(t *testing.T) {
+	router := setupFakeCloud(t)
+
+	// Fake resources are never billed
+	for _, provider := range []string{"aws", "gcp"} {
+		if _, ok := cloud.GetCostSource(provider); ok {
+			t.Errorf("the fake backend left the %s cost source enabled", provider)
+		}
+	}
+
+	svc, err := cloud.LookupService("aws", cloud.AWSS3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := map[string]interface{}{"bucket_name": "reports-bucket", "region": "us-east-1"}
+
+	rec := call(t, router, "GET", "/user/start-session?username="+testUser+"&provider=aws", nil, "")
+	expectStatus(t, rec, http.StatusOK)
+	sessionID, _ := decode(t, rec)["session_id"].(string)
+	if sessionID == "" {
+		t.Fatalf("no session_id in %s", rec.Body.String())
+	}
+
+	rec = call(t, router, "POST", "/user/update-session", map[string]interface{}{"session_id": sessionID, "service": svc.Name()}, "")
+	expectStatus(t, rec, http.StatusOK)
+
+	rec = call(t, router, "POST", "/user/calculate-cost", map[string]interface{}{"session_id": sessionID, "config": config}, "")
+	expectStatus(t, rec, http.StatusOK)
+	estimate := decode(t, rec)
+	if estimate["status"] != "ok" {
+		t.Fatalf("estimate was not approved: %s", rec.Body.String())
+	}
+	if cost, _ := estimate["estimated_cost"].(float64); cost <= 0 {
+		t.Fatalf("expected a positive estimate, got %v", estimate["estimated_cost"])
+	}
+	held := committed(t)
+	if held <= 0 {
+		t.Fatalf("expected the estimate to be held against the budget, got %v", held)
+	}
+
+	// Create, then replay the same request with its Idempotency-Key
+	create := map[string]interface{}{"session_id": sessionID}
+	for key, value := range config {
+		create[key] = value
+	}
+	rec = call(t, router, "POST", "/user/"+svc.Path(), create, "create-1")
+	expectStatus(t, rec, http.StatusOK)
+	created := decode(t, rec)
+	serviceID, _ := created["service_id"].(string)
+	if serviceID == "" {
+		t.Fatalf("no service_id in %s", rec.Body.String())
+	}
+
+	replay := call(t, router, "POST", "/user/"+svc.Path(), create, "create-1")
+	expectStatus(t, replay, http.StatusOK)
+	if replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("the retried create was not replayed")
+	}
+	if replay.Body.String() != rec.Body.String() {
+		t.Errorf("replayed body %q differs from the original %q", replay.Body.String(), rec.Body.String())
+	}
+
+	// A different request under the same key is refused
+	other := map[string]interface{}{"session_id": sessionID, "bucket_name": "other-bucket", "region": "us-east-1"}
+	rec = call(t, router, "POST", "/user/"+svc.Path(), other, "create-1")
+	expectStatus(t, rec, http.StatusUnprocessableEntity)
+
+	description, err := svc.Describe(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !description.Exists {
+		t.Fatal("the bucket was not created in the fake cloud")
+	}
+
+	rec = call(t, router, "POST", "/user/complete-session", map[string]interface{}{"session_id": sessionID, "status": "ok"}, "")
+	expectStatus(t, rec, http.StatusOK)
+
+	id, err := primitive.ObjectIDFromHex(serviceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := db.GetServiceByID(id)
+	if err != nil {
+		t.Fatalf("the completed session has no services record: %v", err)
+	}
+	if record["service_status"] != "running" {
+		t.Errorf("expected the service to be running, got %v", record["service_status"])
+	}
+	if got := committed(t); got != held {
+		t.Errorf("completing the session changed the held budget from %v to %v", held, got)
+	}
+
+	// Delete, then replay the delete
+	rec = call(t, router, "DELETE", "/user/services/"+serviceID, nil, "delete-1")
+	expectStatus(t, rec, http.StatusOK)
+	if status := decode(t, rec)["service_status"]; status != "deleted" {
+		t.Fatalf("expected the service to be deleted, got %v", status)
+	}
+	replay = call(t, router, "DELETE", "/user/services/"+serviceID, nil, "delete-1")
+	expectStatus(t, replay, http.StatusOK)
+	if replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("the retried delete was not replayed")
+	}
+
+	// Without the key the second delete reaches the handler and finds the service gone
+	rec = call(t, router, "DELETE", "/user/services/"+serviceID, nil, "")
+	expectStatus(t, rec, http.StatusConflict)
+
+	description, err = svc.Describe(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if description.Exists {
+		t.Error("the bucket is still in the fake cloud")
+	}
+	if got := committed(t); got != 0 {
+		t.Errorf("expected the deleted service to release its budget, %v is still held", got)
+	}
+}
+
+func TestCreateServiceRequiresApprovedSession(t *testing.T) {
+	router := setupFakeCloud(t)
+
+	rec := call(t, router, "GET", "/user/start-session?username="+testUser+"&provider=aws", nil, "")
+	expectStatus(t, rec, http.StatusOK)
+	sessionID, _ := decode(t, rec)["session_id"].(string)
+
+	rec = call(t, router, "POST", "/user/update-session", map[string]interface{}{"session_id": sessionID, "service": cloud.AWSS3}, "")
+	expectStatus(t, rec, http.StatusOK)
+
+	// The session was never priced, so it is still in progress
+	svc, err := cloud.LookupService("aws", cloud.AWSS3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := map[string]interface{}{"session_id": sessionID, "bucket_name": "unapproved-bucket", "region": "us-east-1"}
+	rec = call(t, router, "POST", "/user/"+svc.Path(), create, "")
+	expectStatus(t, rec, http.StatusForbidden)
+}
+
+func TestSessionsOnlyServeTheirOwner(t *testing.T) {
+	router := setupFakeCloud(t)
+
+	// A session cannot be started on another user's behalf
+	rec := call(t, router, "GET", "/user/start-session?username=carol&provider=aws", nil, "")
+	expectStatus(t, rec, http.StatusForbidden)
+
+	rec = call(t, router, "GET", "/user/start-session?provider=aws", nil, "")
+	expectStatus(t, rec, http.StatusOK)
+	sessionID, _ := decode(t, rec)["session_id"].(string)
+
+	svc, err := cloud.LookupService("aws", cloud.AWSS3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := map[string]interface{}{"bucket_name": "alice-bucket", "region": "us-east-1"}
+	rec = call(t, router, "POST", "/user/update-session", map[string]interface{}{"session_id": sessionID, "service": svc.Name()}, "")
+	expectStatus(t, rec, http.StatusOK)
+	rec = call(t, router, "POST", "/user/calculate-cost", map[string]interface{}{"session_id": sessionID, "config": config}, "")
+	expectStatus(t, rec, http.StatusOK)
+
+	// Knowing the session ID does not let another user price, create with or complete it
+	create := map[string]interface{}{"session_id": sessionID, "bucket_name": "carol-bucket", "region": "us-east-1"}
+	requests := []struct {
+		method, path string
+		body         interface{}
+	}{
+		{"POST", "/user/update-session", map[string]interface{}{"session_id": sessionID, "service": svc.Name()}},
+		{"POST", "/user/calculate-cost", map[string]interface{}{"session_id": sessionID, "config": config}},
+		{"POST", "/user/" + svc.Path(), create},
+		{"POST", "/user/complete-session", map[string]interface{}{"session_id": sessionID, "status": "ok"}},
+	}
+	for _, req := range requests {
+		rec = callAs(t, router, "carol", req.method, req.path, req.body, "")
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s by another user: expected status %d, got %d: %s", req.method, req.path, http.StatusNotFound, rec.Code, rec.Body.String())
+		}
+	}
+
+	description, err := svc.Describe(context.Background(), cloud.Params{"bucket_name": "carol-bucket", "region": "us-east-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if description.Exists {
+		t.Error("another user created a bucket with the session")
+	}
+}
+
+func TestLegacyDeleteOnlyDeletesOwnServices(t *testing.T) {
+	router := setupFakeCloud(t)
+
+	svc, err := cloud.LookupService("aws", cloud.AWSS3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := cloud.Params{"bucket_name": "carol-bucket", "region": "us-east-1"}
+	if _, err := svc.Create(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+	id := primitive.NewObjectID()
+	_, err = db.GetServicesCollection().InsertOne(context.Background(), bson.M{
+		"_id":            id,
+		"username":       "carol",
+		"group_id":       testGroupID,
+		"provider":       "aws",
+		"service":        svc.Name(),
+		"service_status": "running",
+		"config":         bson.M(config),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Naming another user in the query does not act on their behalf
+	body := map[string]interface{}{"service_type": svc.Name(), "service_name": "carol-bucket"}
+	rec := call(t, router, "POST", "/user/delete-aws-service?username=carol", body, "")
+	expectStatus(t, rec, http.StatusNotFound)
+
+	// Without it the caller's own services are searched
+	rec = call(t, router, "POST", "/user/delete-aws-service", body, "")
+	expectStatus(t, rec, http.StatusNotFound)
+
+	record, err := db.GetServiceByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record["service_status"] != "running" {
+		t.Errorf("another user's service was changed to %v", record["service_status"])
+	}
+	description, err := svc.Describe(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !description.Exists {
+		t.Error("another user's bucket was deleted")
+	}
+
+	// The caller's own service of the same name is deleted
+	own := cloud.Params{"bucket_name": "alice-bucket", "region": "us-east-1"}
+	if _, err := svc.Create(context.Background(), own); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.GetServicesCollection().InsertOne(context.Background(), bson.M{
+		"username":       testUser,
+		"group_id":       testGroupID,
+		"provider":       "aws",
+		"service":        svc.Name(),
+		"service_status": "running",
+		"config":         bson.M(own),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body["service_name"] = "alice-bucket"
+	rec = call(t, router, "POST", "/user/delete-aws-service?username="+testUser, body, "")
+	expectStatus(t, rec, http.StatusOK)
+	if status := decode(t, rec)["service_status"]; status != "deleted" {
+		t.Errorf("expected the service to be deleted, got %v", status)
+	}
+}
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"multitenant/cloud"
+	"multitenant/db"
+	"multitenant/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CreateServiceHandler returns the handler that provisions a registered service type for an approved session
+func CreateServiceHandler(svc cloud.ServiceType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params cloud.Params
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		sessionID := params.String("session_id")
+		requestedExpiry := params.String("expires_at")
+		delete(params, "session_id")
+		delete(params, "expires_at")
+		if sessionID == "" {
+			http.Error(w, "Session ID is required", http.StatusBadRequest)
+			return
+		}
+
+		// Fetch session details; its group selects the credentials used below
+		session, ok := ownedSession(w, r, sessionID)
+		if !ok {
+			return
+		}
+
+		// Check if the session is approved
+		if status, _ := session["status"].(string); status != "ok" {
+			http.Error(w, "Session is not approved for service creation", http.StatusForbidden)
+			return
+		}
+
+		// The session must have been started for this service
+		if service, _ := session["service"].(string); service != svc.Name() {
+			http.Error(w, fmt.Sprintf("Session was approved for '%s', not '%s'", service, svc.Name()), http.StatusBadRequest)
+			return
+		}
+
+		if err := svc.Validate(params); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// The session was approved for an estimate; a create request with a different priced configuration is priced again
+		estimated, _ := session["estimate_config"].(bson.M)
+		if len(pricedFieldsChanged(svc, cloud.Params(estimated), params)) > 0 {
+			username, _ := r.Context().Value("username").(string)
+			if err := reestimateSession(svc, session, params, username); err != nil {
+				if errors.Is(err, errEstimateOverBudget) {
+					http.Error(w, err.Error(), http.StatusForbidden)
+					return
+				}
+				if errors.Is(err, errSessionNotOwned) {
+					http.Error(w, "Session not found", http.StatusNotFound)
+					return
+				}
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		// Provision under the credentials of the session's group
+		groupID, _ := session["group_id"].(string)
+
+		// The expiry must respect the group's TTLs, which also supply it when none was requested
+		expiresAt, err := resolveExpiry(groupID, requestedExpiry, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, err := tenantContext(groupID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to resolve cloud credentials: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		// Sessions started before service IDs were assigned get one now
+		serviceID, ok := session["service_id"].(primitive.ObjectID)
+		if !ok {
+			serviceID = primitive.NewObjectID()
+		}
+
+		// Tag the resource with who created it and the services record that will track it
+		var custom map[string]string
+		if tenant := cloud.TenantFromContext(ctx); tenant != nil {
+			custom = tenant.Tags
+		}
+		username, _ := session["username"].(string)
+		tags := cloud.StandardTags(custom, cloud.OrgTag(), groupID, username, sessionID, serviceID.Hex())
+		ctx = cloud.WithTags(ctx, tags)
+
+		// The location must be on the tenant's allow-list
+		if err := cloud.CheckRegionAllowed(ctx, svc.Provider(), params.String("region")); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		// Proceed with service creation
+		created, err := svc.Create(ctx, params)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to create %s: %v", svc.Name(), err), http.StatusInternalServerError)
+			return
+		}
+
+		// Store configuration in the user_sessions collection
+		filter := bson.M{"session_id": sessionID}
+		set := bson.M{
+			"config":     bson.M(created.Config),
+			"service_id": serviceID,
+			"tags":       tags,
+		}
+		if expiresAt != nil {
+			set["expires_at"] = *expiresAt
+		}
+		update := bson.M{"$set": set}
+
+		_, err = db.GetUserSessionCollection().UpdateOne(context.Background(), filter, update)
+		if err != nil {
+			http.Error(w, "Failed to store configuration in user_sessions", http.StatusInternalServerError)
+			return
+		}
+
+		// Respond with the creation result (service creation successful)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":             fmt.Sprintf("%s created successfully", svc.Name()),
+			"result":              created.Result,
+			"config":              created.Config,
+			"service_id":          serviceID.Hex(),
+			"expires_at":          expiresAt,
+			svc.IdentifierField(): created.Config[svc.IdentifierField()],
+		})
+	}
+}
+
+// deleteService deletes one of the caller's services of the given provider identified by its registry identifier field.
+// It backs the legacy delete-aws-service and delete-gcp-service routes.
+func deleteService(w http.ResponseWriter, r *http.Request, provider string) {
+	username, _ := r.Context().Value("username").(string)
+
+	// The owner is the caller; older clients still send ?username=, which must name the caller
+	if requested := r.URL.Query().Get("username"); requested != "" && requested != username {
+		http.Error(w, "Service not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		ServiceType string `json:"service_type"`
+		ServiceName string `json:"service_name"`
+		ServiceID   string `json:"service_id"` // For CloudFront only
+		Region      string `json:"region"`     // Only used when the stored config has no region
+		Zone        string `json:"zone"`       // Only used when the stored config has no zone
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	identifier := req.ServiceName
+	if req.ServiceID != "" {
+		identifier = req.ServiceID
+	}
+
+	if username == "" || req.ServiceType == "" || identifier == "" {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+
+	svc, err := cloud.LookupService(provider, req.ServiceType)
+	if err != nil {
+		http.Error(w, "Invalid service type", http.StatusBadRequest)
+		return
+	}
+
+	// Fetch the stored service so the delete uses its recorded configuration
+	var service bson.M
+	err = db.GetServicesCollection().FindOne(context.Background(), bson.M{
+		"username":                        username,
+		"service":                         svc.Name(),
+		"config." + svc.IdentifierField(): identifier,
+		"service_status":                  bson.M{"$nin": bson.A{"deleted", "expired"}},
+	}).Decode(&service)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, fmt.Sprintf("No active %s found with %s '%s'", svc.Name(), svc.IdentifierField(), identifier), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to fetch service details: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	config, _ := service["config"].(bson.M)
+	if config == nil {
+		config = bson.M{}
+		service["config"] = config
+	}
+
+	// Deletes run in the stored location; older services may not have recorded it
+	if _, ok := config["region"]; !ok && req.Region != "" {
+		config["region"] = req.Region
+	}
+	if _, ok := config["zone"]; !ok && req.Zone != "" {
+		config["zone"] = req.Zone
+	}
+
+	deleteServiceRecord(w, service)
+}
+
+// ownedService fetches the services record named by the "id" route variable if the caller owns it.
+// Otherwise it writes the error response and returns false.
+func ownedService(w http.ResponseWriter, r *http.Request) (bson.M, bool) {
+	username, _ := r.Context().Value("username").(string)
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid service ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	service, err := db.GetServiceByID(id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Service not found", http.StatusNotFound)
+			return nil, false
+		}
+		http.Error(w, fmt.Sprintf("Failed to fetch service details: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	// Other users' services are reported as missing rather than forbidden
+	if owner, _ := service["username"].(string); owner != username {
+		http.Error(w, "Service not found", http.StatusNotFound)
+		return nil, false
+	}
+	return service, true
+}
+
+// ownedSession fetches the caller's session with the given ID. Otherwise it writes the error response and returns false.
+func ownedSession(w http.ResponseWriter, r *http.Request, sessionID string) (bson.M, bool) {
+	username, _ := r.Context().Value("username").(string)
+
+	// Other users' sessions are reported as missing rather than forbidden
+	var session bson.M
+	err := db.GetUserSessionCollection().FindOne(context.Background(), bson.M{"session_id": sessionID, "username": username}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch session: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	return session, true
+}
+
+// DeleteServiceByIDHandler deletes the cloud resource behind one of the caller's services records
+func DeleteServiceByIDHandler(w http.ResponseWriter, r *http.Request) {
+	service, ok := ownedService(w, r)
+	if !ok {
+		return
+	}
+	if status, _ := service["service_status"].(string); status == "deleted" || status == "expired" {
+		http.Error(w, fmt.Sprintf("Service is already %s", status), http.StatusConflict)
+		return
+	}
+
+	deleteServiceRecord(w, service)
+}
+
+// deleteServiceRecord deletes the resource described by a services record using its stored config and location,
+// then records the new status on it
+func deleteServiceRecord(w http.ResponseWriter, service bson.M) {
+	id, _ := service["_id"].(primitive.ObjectID)
+	username, _ := service["username"].(string)
+	groupID, _ := service["group_id"].(string)
+	provider, _ := service["provider"].(string)
+	serviceType, _ := service["service"].(string)
+
+	svc, err := cloud.LookupService(provider, serviceType)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unsupported service '%s' for provider '%s'", serviceType, provider), http.StatusUnprocessableEntity)
+		return
+	}
+
+	config, _ := service["config"].(bson.M)
+	if config == nil {
+		config = bson.M{}
+	}
+	identifier := cloud.Params(config).String(svc.IdentifierField())
+	if identifier == "" {
+		http.Error(w, fmt.Sprintf("Stored config has no %s to delete by", svc.IdentifierField()), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Delete under the credentials of the group that owns the service
+	ctx, err := tenantContext(groupID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to resolve cloud credentials: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	deleted, err := svc.Delete(ctx, cloud.Params(config))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete service: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	serviceStatus, _ := service["service_status"].(string)
+	if deleted.Deleted {
+		serviceStatus = "deleted"
+		if err := db.SetServiceStatus(id, serviceStatus); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to update service status: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if err := db.ReleaseServiceBudget(id, "service deleted"); err != nil {
+			log.Printf("Failed to release budget of service %s: %v", id.Hex(), err)
+		}
+
+		notifyServiceDeleted(username, identifier, svc, groupID, time.Now())
+	}
+
+	// Respond with the result
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        deleted.Message,
+		"result":         deleted.Result,
+		"service_id":     id.Hex(),
+		"service_status": serviceStatus,
+	})
+}
+
+// notifyServiceDeleted saves a notification for the manager of the group owning a deleted service
+func notifyServiceDeleted(username, identifier string, svc cloud.ServiceType, groupID string, timestamp time.Time) {
+	manager, err := db.GetManagerByGroupID(groupID)
+	if err != nil {
+		log.Printf("Failed to fetch manager for group ID %s: %v", groupID, err)
+		return
+	}
+
+	notification := models.Notification{
+		Manager:   manager,
+		Message:   fmt.Sprintf("%s has deleted the service %s (%s) from %s.", username, identifier, svc.Name(), strings.ToUpper(svc.Provider())),
+		Timestamp: timestamp,
+	}
+
+	_, err = db.GetNotificationsCollection().InsertOne(context.Background(), notification)
+	if err != nil {
+		log.Printf("Failed to save notification: %v", err)
+	} else {
+		log.Println("Notification saved successfully")
+	}
+}
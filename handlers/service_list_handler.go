@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"multitenant/cloud"
+	"multitenant/db"
+	"multitenant/models"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// parseServiceFilter reads the filter, sort and pagination query parameters shared by the service listings:
+// provider, service, status, from, to, sort, order, cursor and limit
+func parseServiceFilter(query url.Values) (db.ServiceFilter, error) {
+	filter := db.ServiceFilter{
+		Provider: query.Get("provider"),
+		Service:  query.Get("service"),
+		Status:   query.Get("status"),
+		Sort:     query.Get("sort"),
+		Cursor:   query.Get("cursor"),
+		Desc:     true,
+	}
+
+	if filter.Provider != "" {
+		if _, ok := cloud.GetProvider(filter.Provider); !ok {
+			return filter, fmt.Errorf("unknown provider '%s'", filter.Provider)
+		}
+	}
+	if !db.ValidServiceSort(filter.Sort) {
+		return filter, fmt.Errorf("cannot sort by '%s'", filter.Sort)
+	}
+
+	switch order := query.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		filter.Desc = false
+	default:
+		return filter, fmt.Errorf("order must be 'asc' or 'desc'")
+	}
+
+	var err error
+	if filter.From, _, err = parseDateParam(query.Get("from")); err != nil {
+		return filter, fmt.Errorf("invalid from: %v", err)
+	}
+	var dateOnly bool
+	if filter.To, dateOnly, err = parseDateParam(query.Get("to")); err != nil {
+		return filter, fmt.Errorf("invalid to: %v", err)
+	}
+	if dateOnly {
+		// a plain date includes the whole day
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+
+	if value := query.Get("limit"); value != "" {
+		filter.Limit, err = strconv.Atoi(value)
+		if err != nil || filter.Limit <= 0 {
+			return filter, fmt.Errorf("limit must be a positive number")
+		}
+	}
+	return filter, nil
+}
+
+// parseDateParam accepts an RFC 3339 timestamp or a YYYY-MM-DD date
+func parseDateParam(value string) (time.Time, bool, error) {
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, true, nil
+	}
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("expected YYYY-MM-DD or RFC 3339, got '%s'", value)
+	}
+	return timestamp, false, nil
+}
+
+// listServices responds with a page of services records and the cost each has accrued
+func listServices(w http.ResponseWriter, filter db.ServiceFilter) {
+	page, err := db.ListServices(filter)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for i := range page.Services {
+		record := &page.Services[i]
+		var end time.Time
+		if record.EndTimestamp != nil {
+			end = *record.EndTimestamp
+		}
+		record.AccruedCost = cloud.AccruedCost(record.EstimatedCost, record.Timestamp, end)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Services fetched successfully",
+		Data:    page,
+	})
+}
+
+// ListUserServicesHandler lists the services created by the calling user
+func ListUserServicesHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseServiceFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.Username, _ = r.Context().Value("username").(string)
+
+	listServices(w, filter)
+}
+
+// GetGroupServiceHandler returns one services record of a group managed by the caller, e.g. from a notification
+func GetGroupServiceHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["service_id"])
+	if err != nil {
+		http.Error(w, "Invalid service ID", http.StatusBadRequest)
+		return
+	}
+
+	record, err := db.GetServiceRecord(id)
+	if err != nil || record.GroupID != groupID {
+		if err == nil || errors.Is(err, mongo.ErrNoDocuments) {
+			http.Error(w, "Service not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Service fetched successfully",
+		Data:    record,
+	})
+}
+
+// ListGroupServicesHandler lists the services of a group managed by the caller
+func ListGroupServicesHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := managedGroupID(w, r)
+	if !ok {
+		return
+	}
+	filter, err := parseServiceFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.GroupID = groupID
+	filter.Username = r.URL.Query().Get("username")
+
+	listServices(w, filter)
+}
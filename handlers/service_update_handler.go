@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"multitenant/cloud"
+	"multitenant/db"
+	"multitenant/models"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UpdateServiceHandler changes one of the caller's services in place, e.g. its instance type or node count.
+// The body holds the config fields to change. The new configuration is priced first and refused when the increase
+// does not fit in the group's remaining budget; with ?dry_run=true only the estimate is returned.
+func UpdateServiceHandler(w http.ResponseWriter, r *http.Request) {
+	service, ok := ownedService(w, r)
+	if !ok {
+		return
+	}
+	id, _ := service["_id"].(primitive.ObjectID)
+	username, _ := service["username"].(string)
+	groupID, _ := service["group_id"].(string)
+	provider, _ := service["provider"].(string)
+	serviceType, _ := service["service"].(string)
+
+	if status, _ := service["service_status"].(string); status == "deleted" || status == "expired" {
+		http.Error(w, fmt.Sprintf("Service is %s", status), http.StatusConflict)
+		return
+	}
+
+	var changes cloud.Params
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil || len(changes) == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	svc, err := cloud.LookupService(provider, serviceType)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unsupported service '%s' for provider '%s'", serviceType, provider), http.StatusUnprocessableEntity)
+		return
+	}
+
+	config, _ := service["config"].(bson.M)
+	if config == nil {
+		config = bson.M{}
+	}
+	plan, err := svc.PlanUpdate(cloud.Params(config), changes)
+	if err != nil {
+		if errors.Is(err, cloud.ErrUpdateNotSupported) {
+			http.Error(w, fmt.Sprintf("%s cannot be updated in place", svc.Name()), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Re-estimate with the new configuration; services without a pricing model keep their estimate
+	previousCost, _ := service["estimated_cost"].(float64)
+	estimatedCost, err := svc.EstimateCost(plan.Config)
+	if errors.Is(err, cloud.ErrCostNotSupported) {
+		estimatedCost = previousCost
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to calculate cost: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Increases must fit in the remaining budget of the current period
+	balance, err := db.GetBudgetBalance(groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	increase := db.PeriodCost(balance.PeriodEnd, estimatedCost-previousCost, plan.Config)
+	if increase > 0 {
+		if increase > balance.Remaining {
+			http.Error(w, fmt.Sprintf(
+				"Estimated cost after the update is $%.2f, which adds $%.2f in this budget period and exceeds the remaining budget of $%.2f. Update denied.",
+				estimatedCost, increase, balance.Remaining,
+			), http.StatusForbidden)
+			return
+		}
+	}
+
+	if r.URL.Query().Get("dry_run") == "true" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":                 "Update is within budget",
+			"service_id":              id.Hex(),
+			"changes":                 plan.Changes,
+			"config":                  plan.Config,
+			"estimated_cost":          estimatedCost,
+			"previous_estimated_cost": previousCost,
+		})
+		return
+	}
+
+	if err := db.LockServiceForUpdate(id); err != nil {
+		if errors.Is(err, db.ErrServiceBusy) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The plan was built from the record as read before the lock; refuse it if another update landed since
+	if current, err := db.GetServiceByID(id); err != nil || db.ServiceRevisionNumber(current) != db.ServiceRevisionNumber(service) {
+		db.UnlockService(id)
+		http.Error(w, db.ErrRevisionConflict.Error(), http.StatusConflict)
+		return
+	}
+
+	// Hold the increase before changing anything so concurrent requests cannot spend the same budget
+	if increase > 0 {
+		if err := db.AdjustServiceCommitment(id, increase, false, "service updated"); err != nil {
+			db.UnlockService(id)
+			if errors.Is(err, db.ErrBudgetExceeded) {
+				http.Error(w, "The update no longer fits in the remaining budget. Update denied.", http.StatusForbidden)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Update under the credentials of the group that owns the service
+	ctx, err := tenantContext(groupID)
+	if err != nil {
+		releaseUpdateCommitment(id, increase)
+		db.UnlockService(id)
+		http.Error(w, fmt.Sprintf("Failed to resolve cloud credentials: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := svc.Update(ctx, plan)
+	if err != nil {
+		releaseUpdateCommitment(id, increase)
+		db.UnlockService(id)
+		http.Error(w, fmt.Sprintf("Failed to update %s: %v", svc.Name(), err), http.StatusInternalServerError)
+		return
+	}
+
+	revision := models.ServiceRevision{
+		ServiceID:             id,
+		Revision:              db.ServiceRevisionNumber(service) + 1,
+		Changes:               plan.Changes,
+		Config:                updated.Config,
+		PreviousConfig:        config,
+		EstimatedCost:         estimatedCost,
+		PreviousEstimatedCost: previousCost,
+		ChangedBy:             username,
+		ChangedAt:             time.Now(),
+	}
+	if err := db.RecordServiceRevision(revision); err != nil {
+		db.UnlockService(id)
+		http.Error(w, fmt.Sprintf("Service updated but the new configuration could not be saved: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// A cheaper configuration gives the difference back
+	if increase < 0 {
+		if err := db.AdjustServiceCommitment(id, increase, true, "service updated"); err != nil {
+			log.Printf("Failed to release budget of service %s: %v", id.Hex(), err)
+		}
+	}
+
+	identifier := cloud.Params(config).String(svc.IdentifierField())
+	message := fmt.Sprintf("%s has updated the service %s (%s): %s. Estimated cost changed from $%.2f to $%.2f.",
+		username, identifier, svc.Name(), describeChanges(plan.Changes), previousCost, estimatedCost)
+	if err := db.NotifyGroupManager(groupID, message); err != nil {
+		log.Printf("Failed to notify manager of group %s: %v", groupID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":                 fmt.Sprintf("%s updated successfully", svc.Name()),
+		"result":                  updated.Result,
+		"service_id":              id.Hex(),
+		"revision":                revision.Revision,
+		"config":                  updated.Config,
+		"estimated_cost":          estimatedCost,
+		"previous_estimated_cost": previousCost,
+	})
+}
+
+// ListServiceRevisionsHandler returns the config revisions recorded for one of the caller's services
+func ListServiceRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	service, ok := ownedService(w, r)
+	if !ok {
+		return
+	}
+	id, _ := service["_id"].(primitive.ObjectID)
+
+	revisions, err := db.ListServiceRevisions(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  "success",
+		Message: "Service revisions fetched successfully",
+		Data:    revisions,
+	})
+}
+
+// releaseUpdateCommitment gives back the budget held for an update that was not applied
+func releaseUpdateCommitment(id primitive.ObjectID, increase float64) {
+	if increase <= 0 {
+		return
+	}
+	if err := db.AdjustServiceCommitment(id, -increase, true, "service update failed"); err != nil {
+		log.Printf("Failed to release budget of service %s: %v", id.Hex(), err)
+	}
+}
+
+// describeChanges formats changed fields as "key=value" pairs for notifications
+func describeChanges(changes cloud.Params) string {
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%v", key, changes[key]))
+	}
+	return strings.Join(parts, ", ")
+}
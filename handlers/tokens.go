@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"multitenant/secrets"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// keyID names a signing key in the kid header of the tokens it signs without revealing it
+func keyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// signToken signs claims with the current JWT signing key
+func signToken(ctx context.Context, claims *Claims) (string, error) {
+	key, err := secretStore.Get(ctx, secrets.JWTSecret)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = keyID(key)
+	return token.SignedString([]byte(key))
+}
+
+// parseToken verifies a token and decodes its claims. Besides the current key, keys replaced by a rotation are
+// accepted until their grace ends, so tokens issued before a rotation stay valid until they expire.
+func parseToken(ctx context.Context, tokenStr string, claims *Claims) error {
+	keys, err := secretStore.Values(ctx, secrets.JWTSecret)
+	if err != nil {
+		return err
+	}
+
+	// The kid header picks the key; tokens without one, or with an unknown one, are tried against every key
+	unverified, _, err := new(jwt.Parser).ParseUnverified(tokenStr, claims)
+	if err != nil {
+		return err
+	}
+	if kid, _ := unverified.Header["kid"].(string); kid != "" {
+		for _, key := range keys {
+			if keyID(key) == kid {
+				keys = []string{key}
+				break
+			}
+		}
+	}
+
+	for _, key := range keys {
+		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+			}
+			return []byte(key), nil
+		})
+		if err == nil && token.Valid {
+			return nil
+		}
+		if !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+			return err
+		}
+	}
+	return jwt.ErrTokenSignatureInvalid
+}
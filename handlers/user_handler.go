@@ -3,8 +3,10 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"multitenant/cloud"
 	"multitenant/db"
 	"net/http"
 	"time"
@@ -18,35 +20,18 @@ func GetCloudServicesHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract the provider from the query parameters
 	provider := r.URL.Query().Get("provider")
 
-	var services []string
-
-	// Determine services based on the provider
-	switch provider {
-	case "aws":
-		services = []string{
-			"Amazon EC2 (Elastic Compute Cloud)",
-			"Amazon S3 (Simple Storage Service)",
-			"AWS Lambda",
-			"Amazon RDS (Relational Database Service)",
-			// "Amazon DynamoDB",
-			"AWS CloudFront",
-			"Amazon VPC (Virtual Private Cloud)",
-		}
-	case "gcp":
-		services = []string{
-			"Compute Engine",
-			"Cloud Storage",
-			"Google Kubernetes Engine (GKE)",
-			"BigQuery",
-			// "Cloud Functions",
-			"Cloud SQL",
-			// "Cloud Pub/Sub",
-		}
-	default:
+	p, ok := cloud.GetProvider(provider)
+	if !ok {
 		http.Error(w, "Invalid provider. Supported values are 'aws' and 'gcp'.", http.StatusBadRequest)
 		return
 	}
 
+	// The catalog is driven by the service registry
+	services := []string{}
+	for _, svc := range p.Services() {
+		services = append(services, svc.Name())
+	}
+
 	// Return the list of services as JSON
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string][]string{
@@ -56,15 +41,24 @@ func GetCloudServicesHandler(w http.ResponseWriter, r *http.Request) {
 
 // StartSessionHandler starts a new session for the user
 func StartSessionHandler(w http.ResponseWriter, r *http.Request) {
-	username := r.URL.Query().Get("username")
+	username, _ := r.Context().Value("username").(string)
 	provider := r.URL.Query().Get("provider")
 
+	// Sessions are started for the caller; the username parameter older clients send must name them
+	if requested := r.URL.Query().Get("username"); requested != "" && requested != username {
+		http.Error(w, "Sessions can only be started for yourself", http.StatusForbidden)
+		return
+	}
 	if username == "" || provider == "" {
 		http.Error(w, "Missing username or provider", http.StatusBadRequest)
 		return
 	}
 
 	sessionID, err := db.StartSession(username, provider)
+	if errors.Is(err, db.ErrSessionsBlocked) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to start session: %v", err), http.StatusInternalServerError)
 		return
@@ -93,6 +87,10 @@ func UpdateSessionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, ok := ownedSession(w, r, req.SessionID); !ok {
+		return
+	}
+
 	err := db.UpdateSession(req.SessionID, req.Service)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to update session: %v", err), http.StatusInternalServerError)
@@ -119,11 +117,8 @@ func CompleteSessionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("Decoded request: %+v\n", req)
 
-	var session bson.M
-	err := db.GetUserSessionCollection().FindOne(context.Background(), bson.M{"session_id": req.SessionID}).Decode(&session)
-	if err != nil {
-		log.Printf("Session not found: %v\n", err)
-		http.Error(w, "Session not found", http.StatusNotFound)
+	session, ok := ownedSession(w, r, req.SessionID)
+	if !ok {
 		return
 	}
 	log.Printf("Fetched session: %+v\n", session)
@@ -152,7 +147,7 @@ func CompleteSessionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Add session to `services` collection
-	err = db.PushToServicesCollection(session, config)
+	err := db.PushToServicesCollection(session, config)
 	if err != nil {
 		log.Printf("Failed to move session to services collection: %v\n", err)
 		http.Error(w, fmt.Sprintf("Failed to move session to services collection: %v", err), http.StatusInternalServerError)
@@ -0,0 +1,182 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"multitenant/cloud"
+	"multitenant/config"
+	"multitenant/db"
+	"multitenant/models"
+	"sync"
+	"time"
+)
+
+const (
+	// providers revise the cost of the last few days, so every sync collects them again
+	costSyncLookback = 3
+
+	costSyncTimeout = 5 * time.Minute
+)
+
+var costSyncMu sync.Mutex
+
+// StartCostSync collects the actual daily cost of every tracked service on the COST_SYNC_INTERVAL schedule.
+// GCP costs are read from the billing export table in GCP_BILLING_EXPORT_TABLE, or from the export rows in the
+// GCP_BILLING_FIXTURE JSON file; without either they are not collected. The fake cloud backend only reads the fixture.
+func StartCostSync(ctx context.Context, cfg *config.Config) error {
+	if err := useGCPBillingSource(cfg); err != nil {
+		return err
+	}
+
+	interval := cfg.Jobs.CostSyncInterval
+	if interval == 0 {
+		log.Println("Cost sync disabled")
+		return nil
+	}
+
+	Every(ctx, "Cost sync", interval, func(ctx context.Context) error {
+		result, err := SyncCosts(ctx, "", costSyncLookback)
+		if err != nil {
+			return err
+		}
+		log.Printf("Cost sync: %d points for %d services in %d groups, %.2f accrued, %d alerts, %d anomalies, %d errors",
+			result.Points, result.Services, result.Groups, result.Accrued, result.Alerts, result.Anomalies, len(result.Errors))
+		return nil
+	})
+	return nil
+}
+
+// SyncCosts collects the actual cost of the last days, up to yesterday, for the services of a group, or of every
+// group when groupID is empty. Costs are stored as daily points and accrued to each group's budget ledger, then
+// each group's budget alert rules are evaluated and the days collected are checked for cost anomalies.
+// Concurrent calls wait for each other rather than query the billing APIs twice.
+func SyncCosts(ctx context.Context, groupID string, days int) (*models.CostSyncResult, error) {
+	costSyncMu.Lock()
+	defer costSyncMu.Unlock()
+
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	start := end.AddDate(0, 0, -days)
+	result := &models.CostSyncResult{StartedAt: time.Now(), Start: start, End: end}
+
+	records, err := db.ListServicesToCost(start)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each group's costs are read with its own cloud credentials
+	byGroup := map[string][]models.ServiceRecord{}
+	for _, record := range records {
+		if groupID != "" && record.GroupID != groupID {
+			continue
+		}
+		if record.GroupID == "" {
+			continue
+		}
+		byGroup[record.GroupID] = append(byGroup[record.GroupID], record)
+	}
+
+	for group, records := range byGroup {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		result.Groups++
+		syncGroupCosts(ctx, group, records, result)
+
+		// Alert rules see the costs just accrued
+		alerts, err := db.EvaluateBudgetAlerts(group)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("group %s: failed to evaluate budget alerts: %v", group, err))
+		}
+		result.Alerts += len(alerts)
+
+		// A revised day is checked again, but an anomaly is only notified once
+		anomalies, err := db.DetectCostAnomalies(group, start, end)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("group %s: failed to detect cost anomalies: %v", group, err))
+		}
+		result.Anomalies += len(anomalies)
+	}
+	return result, nil
+}
+
+// syncGroupCosts collects the cost of one group's services from each provider's billing data
+func syncGroupCosts(ctx context.Context, groupID string, records []models.ServiceRecord, result *models.CostSyncResult) {
+	tenantCtx, err := db.TenantContext(ctx, groupID)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("group %s: failed to resolve cloud credentials: %v", groupID, err))
+		return
+	}
+
+	byProvider := map[string][]models.ServiceRecord{}
+	for _, record := range records {
+		byProvider[record.Provider] = append(byProvider[record.Provider], record)
+	}
+
+	for provider, records := range byProvider {
+		source, ok := cloud.GetCostSource(provider)
+		if !ok {
+			continue
+		}
+
+		byID := map[string]models.ServiceRecord{}
+		resources := make([]cloud.CostResource, 0, len(records))
+		for _, record := range records {
+			byID[record.ID.Hex()] = record
+			resources = append(resources, cloud.CostResource{
+				ServiceID: record.ID.Hex(),
+				Service:   record.Service,
+				Config:    cloud.Params(record.Config),
+			})
+		}
+		result.Services += len(resources)
+
+		fetchCtx, cancel := context.WithTimeout(tenantCtx, costSyncTimeout)
+		costs, err := source.DailyCosts(fetchCtx, resources, result.Start, result.End)
+		cancel()
+		if err != nil {
+			// Costs read before the failure are still stored
+			result.Errors = append(result.Errors, fmt.Sprintf("group %s, %s: %v", groupID, source.Name(), err))
+		}
+
+		for _, cost := range costs {
+			record, ok := byID[cost.ServiceID]
+			if !ok {
+				continue
+			}
+			accrued, err := db.RecordDailyCost(record, source.Name(), cost)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("service %s: %v", cost.ServiceID, err))
+				continue
+			}
+			result.Points++
+			result.Accrued += accrued
+		}
+	}
+}
+
+// useGCPBillingSource selects where GCP costs are read from
+func useGCPBillingSource(cfg *config.Config) error {
+	if path := cfg.Cloud.GCPBillingFixture; path != "" {
+		source, err := cloud.LoadGCPBillingFixture(path)
+		if err != nil {
+			return err
+		}
+		cloud.UseCostSource("gcp", source)
+		log.Printf("GCP costs read from the billing fixture %s", path)
+		return nil
+	}
+	if table := cfg.Cloud.GCPBillingExportTable; table != "" {
+		// Fake resources are never billed, so the live export has nothing to match them with
+		if cfg.Cloud.Backend == "fake" {
+			log.Printf("GCP billing export %s not read with the fake cloud backend", table)
+			return nil
+		}
+		source, err := cloud.NewGCPBillingSource(table)
+		if err != nil {
+			return err
+		}
+		cloud.UseCostSource("gcp", source)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed five-field cron expression: minute, hour, day of month, month and day of week.
+// Fields accept *, numbers, ranges (1-5), steps (*/15, 8-18/2), lists (1,15) and the names JAN-DEC and SUN-SAT.
+// Day of week 7 is also Sunday. As in Vixie cron, when both day fields are restricted a day matching either runs;
+// a day field starting with * (including */2) does not restrict the other.
+//
+// Times are wall-clock times in the expression's location. Across daylight saving changes, a time skipped when the
+// clocks go forward runs at the first minute after the change, and a time repeated when they go back runs once,
+// the first time it occurs.
+type Cron struct {
+	expr              string
+	minute, hour, dom uint64
+	month, dow        uint64
+	domAny, dowAny    bool // a * day field does not restrict the other
+	location          *time.Location
+}
+
+// cronField describes the accepted values of one field
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}},
+	{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}},
+}
+
+// cronSearchLimit bounds the search for the next run of expressions that never match, such as 30 February
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// ParseCron parses a cron expression evaluated in the given location, or UTC when nil
+func ParseCron(expr string, location *time.Location) (*Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields: minute hour day-of-month month day-of-week", expr)
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	c := &Cron{expr: expr, location: location}
+	sets := []*uint64{&c.minute, &c.hour, &c.dom, &c.month, &c.dow}
+	for i, field := range cronFields {
+		set, err := field.parse(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+		}
+		*sets[i] = set
+	}
+
+	// 7 is also Sunday
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAny = strings.HasPrefix(fields[2], "*")
+	c.dowAny = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// parse returns the values of a field as a bit set
+func (f cronField) parse(value string) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(value, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field: %q", f.name, item)
+			}
+			rangePart, step = item[:i], n
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if high, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range in %s field: %q", f.name, rangePart)
+			}
+		default:
+			v, err := f.value(rangePart)
+			if err != nil {
+				return 0, err
+			}
+			low = v
+			if !strings.Contains(item, "/") {
+				high = v
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// value parses one number or name of a field
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s: %q", f.name, s)
+	}
+	return v, nil
+}
+
+// String returns the expression as written
+func (c *Cron) String() string {
+	return c.expr
+}
+
+// Next returns the first time after t that matches the expression, or the zero time if none does within five years
+func (c *Cron) Next(t time.Time) time.Time {
+	// Matching walks the wall clock, held as a UTC time so it never jumps, and maps each match back to the location
+	local := t.In(c.location)
+	wall := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), 0, 0, time.UTC).Add(time.Minute)
+	limit := wall.Add(cronSearchLimit)
+
+	for wall.Before(limit) {
+		if c.month&(1<<uint(wall.Month())) == 0 {
+			wall = time.Date(wall.Year(), wall.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.dayMatches(wall) {
+			wall = time.Date(wall.Year(), wall.Month(), wall.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.hour&(1<<uint(wall.Hour())) == 0 {
+			wall = time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour()+1, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.minute&(1<<uint(wall.Minute())) == 0 {
+			wall = wall.Add(time.Minute)
+			continue
+		}
+		// A repeated wall-clock time whose first occurrence is not after t has already run
+		if next := c.instant(wall); next.After(t) {
+			return next
+		}
+		wall = wall.Add(time.Minute)
+	}
+	return time.Time{}
+}
+
+// instant returns the first time the location's clock shows a wall-clock time, given as a UTC time with the same
+// fields. When the clocks skip over it, it returns the first time after the jump.
+func (c *Cron) instant(wall time.Time) time.Time {
+	// Any offset in effect within a day of the wall-clock time may be the one that shows it
+	var offsets []int
+	for _, probe := range []time.Time{wall.Add(-24 * time.Hour), wall, wall.Add(24 * time.Hour)} {
+		_, offset := probe.In(c.location).Zone()
+		offsets = append(offsets, offset)
+	}
+
+	var first, latest time.Time
+	for _, offset := range offsets {
+		candidate := wall.Add(-time.Duration(offset) * time.Second).In(c.location)
+		if latest.IsZero() || candidate.After(latest) {
+			latest = candidate
+		}
+		shown := time.Date(candidate.Year(), candidate.Month(), candidate.Day(), candidate.Hour(), candidate.Minute(), 0, 0, time.UTC)
+		if shown.Equal(wall) && (first.IsZero() || candidate.Before(first)) {
+			first = candidate
+		}
+	}
+	if !first.IsZero() {
+		return first
+	}
+
+	// Skipped: read with the offset before the jump, the time falls after it, in the period the jump starts
+	start, _ := latest.ZoneBounds()
+	return start
+}
+
+// dayMatches applies the cron rule that a restricted day of month and day of week match either way
+func (c *Cron) dayMatches(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
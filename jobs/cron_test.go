@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	utc := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name     string
+		expr     string
+		location *time.Location
+		from     string
+		want     string // empty when nothing matches
+	}{
+		{"step", "*/15 * * * *", nil, "2024-01-10T10:07:00Z", "2024-01-10T10:15:00Z"},
+		{"step wraps the hour", "*/15 * * * *", nil, "2024-01-10T10:45:00Z", "2024-01-10T11:00:00Z"},
+		{"ranged step", "0 8-18/5 * * *", nil, "2024-01-10T14:00:00Z", "2024-01-10T18:00:00Z"},
+		{"ranged step wraps the day", "0 8-18/5 * * *", nil, "2024-01-10T18:30:00Z", "2024-01-11T08:00:00Z"},
+		{"weekday range", "0 9 * * 1-5", nil, "2024-01-12T10:00:00Z", "2024-01-15T09:00:00Z"},
+		{"list", "0 0 1,15 * *", nil, "2024-01-02T00:00:00Z", "2024-01-15T00:00:00Z"},
+		{"month and day names", "30 6 * JUL,DEC sat", nil, "2024-01-01T00:00:00Z", "2024-07-06T06:30:00Z"},
+		{"day of week 7 is Sunday", "0 12 * * 7", nil, "2024-01-13T00:00:00Z", "2024-01-14T12:00:00Z"},
+		{"30 February never matches", "0 0 30 2 *", nil, "2024-01-01T00:00:00Z", ""},
+		{"29 February in a leap year", "0 0 29 2 *", nil, "2025-01-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		{"restricted day fields match either", "0 0 13 * FRI", nil, "2024-01-01T00:00:00Z", "2024-01-05T00:00:00Z"},
+		{"restricted day of month alone", "0 0 13 * FRI", nil, "2024-01-12T00:00:00Z", "2024-01-13T00:00:00Z"},
+		{"starred step does not restrict the day", "0 0 */2 * MON", nil, "2024-01-01T00:00:00Z", "2024-01-15T00:00:00Z"},
+		{"strictly after the given time", "0 9 * * *", nil, "2024-01-10T09:00:00Z", "2024-01-11T09:00:00Z"},
+		{"location", "0 9 * * *", newYork, "2024-01-10T12:00:00Z", "2024-01-10T14:00:00Z"},
+
+		// Clocks go forward at 2:00 EST (07:00Z) on 10 March 2024
+		{"spring forward skipped time runs after the change", "30 2 * * *", newYork, "2024-03-10T05:00:00Z", "2024-03-10T07:00:00Z"},
+		{"spring forward next day", "30 2 * * *", newYork, "2024-03-10T07:00:00Z", "2024-03-11T06:30:00Z"},
+		{"spring forward later hour", "0 5 * * *", newYork, "2024-03-10T05:00:00Z", "2024-03-10T09:00:00Z"},
+		{"spring forward step", "*/30 * * * *", newYork, "2024-03-10T06:45:00Z", "2024-03-10T07:00:00Z"},
+		{"spring forward step after the change", "*/30 * * * *", newYork, "2024-03-10T07:00:00Z", "2024-03-10T07:30:00Z"},
+
+		// Clocks go back at 2:00 EDT (06:00Z) on 3 November 2024, repeating 1:00-1:59
+		{"fall back first occurrence", "30 1 * * *", newYork, "2024-11-03T04:00:00Z", "2024-11-03T05:30:00Z"},
+		{"fall back runs once", "30 1 * * *", newYork, "2024-11-03T05:30:00Z", "2024-11-04T06:30:00Z"},
+		{"fall back repeated hour is skipped", "0 * * * *", newYork, "2024-11-03T05:30:00Z", "2024-11-03T07:00:00Z"},
+		{"fall back from the repeated hour", "45 1 * * *", newYork, "2024-11-03T06:10:00Z", "2024-11-04T06:45:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.expr, tt.location)
+			if err != nil {
+				t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+			}
+			got := c.Next(utc(tt.from))
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Fatalf("Next(%s) = %s, want the zero time", tt.from, got)
+				}
+				return
+			}
+			if want := utc(tt.want); !got.Equal(want) {
+				t.Fatalf("Next(%s) = %s, want %s", tt.from, got.UTC().Format(time.RFC3339), tt.want)
+			}
+			if tt.location != nil && got.Location() != tt.location {
+				t.Fatalf("Next(%s) is in %s, want %s", tt.from, got.Location(), tt.location)
+			}
+		})
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * FOO *",
+		"1,,2 * * * *",
+	} {
+		if _, err := ParseCron(expr, nil); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
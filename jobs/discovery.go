@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multitenant/cloud"
+	"multitenant/db"
+	"multitenant/models"
+	"time"
+)
+
+// discoverTimeout bounds the listing of one service type in one region
+const discoverTimeout = 2 * time.Minute
+
+// DiscoverySummary reports the outcome of a discovery scan
+type DiscoverySummary struct {
+	Regions   map[string][]string `json:"regions"`   // regions scanned per provider
+	Found     int                 `json:"found"`     // resources listed in the account
+	Untracked int                 `json:"untracked"` // resources without an active services record
+	Errors    []string            `json:"errors,omitempty"`
+}
+
+// Discover lists the resources of every discoverable service type in a group's cloud accounts and stores the
+// ones no services record tracks. Without explicit regions a provider is scanned in the tenant's allowed regions.
+func Discover(ctx context.Context, groupID string, regions map[string][]string) (DiscoverySummary, error) {
+	summary := DiscoverySummary{Regions: map[string][]string{}}
+
+	tenantCtx, err := db.TenantContext(ctx, groupID)
+	if err != nil {
+		return summary, fmt.Errorf("failed to resolve cloud credentials: %v", err)
+	}
+
+	for _, provider := range cloud.Providers() {
+		scan, requested := regions[provider.Name()]
+		if !requested {
+			if len(regions) > 0 {
+				continue
+			}
+			scan = cloud.AllowedRegions(tenantCtx, provider.Name())
+		}
+		if len(scan) == 0 {
+			summary.Errors = append(summary.Errors, fmt.Sprintf("%s: no regions to scan; pass them explicitly or set an allow-list", provider.Name()))
+			continue
+		}
+		var allowed []string
+		for _, region := range scan {
+			if err := cloud.CheckRegionAllowed(tenantCtx, provider.Name(), region); err != nil {
+				summary.Errors = append(summary.Errors, err.Error())
+				continue
+			}
+			allowed = append(allowed, region)
+		}
+		scan = allowed
+		summary.Regions[provider.Name()] = scan
+
+		for _, svc := range provider.Services() {
+			for _, region := range scan {
+				if err := discoverService(tenantCtx, groupID, svc, region, &summary); err != nil {
+					if errors.Is(err, cloud.ErrDiscoveryNotSupported) {
+						break
+					}
+					summary.Errors = append(summary.Errors, fmt.Sprintf("%s in %s: %v", svc.Name(), region, err))
+				}
+			}
+		}
+	}
+	return summary, nil
+}
+
+// discoverService scans one service type in one region
+func discoverService(ctx context.Context, groupID string, svc cloud.ServiceType, region string, summary *DiscoverySummary) error {
+	scanStart := time.Now()
+
+	listCtx, cancel := context.WithTimeout(ctx, discoverTimeout)
+	defer cancel()
+
+	resources, err := svc.Discover(listCtx, region)
+	if err != nil {
+		return err
+	}
+
+	for _, resource := range resources {
+		summary.Found++
+
+		tracked, err := db.IsResourceTracked(svc.Provider(), svc.Name(), resource.IDField, resource.ID)
+		if err != nil {
+			return err
+		}
+		if tracked {
+			continue
+		}
+
+		summary.Untracked++
+		err = db.SaveDiscoveredResource(models.DiscoveredResource{
+			GroupID:    groupID,
+			Provider:   svc.Provider(),
+			Service:    svc.Name(),
+			ResourceID: resource.ID,
+			IDField:    resource.IDField,
+			Region:     region,
+			State:      resource.State,
+			Config:     resource.Config,
+			LastSeen:   time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Resources deleted or adopted elsewhere since the last scan are dropped
+	return db.PruneDiscoveredResources(groupID, svc.Provider(), svc.Name(), region, scanStart)
+}
@@ -0,0 +1,192 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"multitenant/cloud"
+	"multitenant/config"
+	"multitenant/db"
+	"multitenant/models"
+	"time"
+)
+
+const (
+	// a failed expiry is retried after this long
+	expiryRetryDelay = time.Hour
+
+	deleteTimeout = 5 * time.Minute
+)
+
+// ExpirySummary counts the outcome of a reaper run
+type ExpirySummary struct {
+	Warned  int `json:"warned"`
+	Expired int `json:"expired"`
+	Failed  int `json:"failed"` // expired services that could not be deleted
+}
+
+// StartExpiryReaper warns owners of services about to expire and deletes expired services every EXPIRY_REAP_INTERVAL.
+// Owners are warned EXPIRY_WARNING_HOURS ahead.
+func StartExpiryReaper(ctx context.Context, cfg *config.Config) error {
+	interval, warning := cfg.Jobs.ExpiryInterval, cfg.Jobs.ExpiryWarning
+	if interval == 0 {
+		log.Println("Expiry reaper disabled")
+		return nil
+	}
+
+	Every(ctx, "Expiry reaper", interval, func(ctx context.Context) error {
+		summary, err := ReapExpiredServices(ctx, time.Now(), warning)
+		if err != nil {
+			return err
+		}
+		log.Printf("Expiry reaper: %d warned, %d expired, %d failed", summary.Warned, summary.Expired, summary.Failed)
+		return nil
+	})
+	return nil
+}
+
+// ReapExpiredServices warns the owners of services expiring within warning of now, then deletes the services
+// whose expiry has passed and marks them "expired"
+func ReapExpiredServices(ctx context.Context, now time.Time, warning time.Duration) (ExpirySummary, error) {
+	var summary ExpirySummary
+
+	if warning > 0 {
+		records, err := db.ListServicesToWarn(now.Add(warning))
+		if err != nil {
+			return summary, err
+		}
+		for _, record := range records {
+			if record.ExpiresAt.After(now) && warnExpiry(record) {
+				summary.Warned++
+			}
+		}
+	}
+
+	records, err := db.ListExpiredServices(now, now.Add(-expiryRetryDelay))
+	if err != nil {
+		return summary, err
+	}
+	for _, record := range records {
+		if ctx.Err() != nil {
+			return summary, ctx.Err()
+		}
+		expired, err := expireService(ctx, record)
+		if err != nil {
+			summary.Failed++
+			log.Printf("Failed to expire service %s: %v", record.ID.Hex(), err)
+			reportExpiryFailure(record, err)
+			continue
+		}
+		if expired {
+			summary.Expired++
+		}
+	}
+	return summary, nil
+}
+
+// warnExpiry notifies the owner of a service that it will be deleted, with the call that extends it
+func warnExpiry(record models.ServiceRecord) bool {
+	marked, err := db.MarkExpiryWarned(record.ID, *record.ExpiresAt)
+	if err != nil {
+		log.Printf("Failed to record expiry warning for service %s: %v", record.ID.Hex(), err)
+		return false
+	}
+	if !marked {
+		return false
+	}
+
+	message := fmt.Sprintf("Your %s %s expires on %s UTC and will then be deleted. Extend it to keep it.",
+		record.Service, serviceIdentifier(record), record.ExpiresAt.UTC().Format("Jan 02, 2006 15:04"))
+	action := fmt.Sprintf("POST /user/services/%s/extend", record.ID.Hex())
+	if err := db.NotifyUser(record.Username, message, action); err != nil {
+		log.Printf("Failed to notify %s of expiring service %s: %v", record.Username, record.ID.Hex(), err)
+	}
+	return true
+}
+
+// expireService deletes the resource behind an expired record. It reports false when the record no longer needs it,
+// because it was extended, deleted or is being updated.
+func expireService(ctx context.Context, record models.ServiceRecord) (bool, error) {
+	if err := db.LockServiceForUpdate(record.ID); err != nil {
+		if errors.Is(err, db.ErrServiceBusy) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer func() {
+		if err := db.UnlockService(record.ID); err != nil {
+			log.Printf("Failed to unlock service %s: %v", record.ID.Hex(), err)
+		}
+	}()
+
+	// The owner may have extended or deleted the service since it was listed
+	current, err := db.GetServiceRecord(record.ID)
+	if err != nil {
+		return false, err
+	}
+	if current.ServiceStatus == "deleted" || current.ServiceStatus == "expired" ||
+		current.ExpiresAt == nil || current.ExpiresAt.After(time.Now()) {
+		return false, nil
+	}
+
+	svc, err := cloud.LookupService(current.Provider, current.Service)
+	if err != nil {
+		return false, err
+	}
+	tenantCtx, err := db.TenantContext(ctx, current.GroupID)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve cloud credentials: %v", err)
+	}
+	deleteCtx, cancel := context.WithTimeout(tenantCtx, deleteTimeout)
+	defer cancel()
+
+	deleted, err := svc.Delete(deleteCtx, cloud.Params(current.Config))
+	if err != nil {
+		return false, err
+	}
+	if !deleted.Deleted {
+		return false, errors.New(deleted.Message)
+	}
+	if err := db.SetServiceStatus(current.ID, "expired"); err != nil {
+		return false, err
+	}
+	if err := db.ReleaseServiceBudget(current.ID, "service expired"); err != nil {
+		log.Printf("Failed to release budget of service %s: %v", current.ID.Hex(), err)
+	}
+
+	message := fmt.Sprintf("The %s %s of %s expired and was deleted.", current.Service, serviceIdentifier(*current), current.Username)
+	if err := db.NotifyGroupManager(current.GroupID, message); err != nil {
+		log.Printf("Failed to notify manager of group %s: %v", current.GroupID, err)
+	}
+	if err := db.NotifyUser(current.Username, fmt.Sprintf("Your %s %s expired and was deleted.", current.Service, serviceIdentifier(*current)), ""); err != nil {
+		log.Printf("Failed to notify %s of expired service %s: %v", current.Username, current.ID.Hex(), err)
+	}
+	return true, nil
+}
+
+// reportExpiryFailure tells the group's manager the first time an expired service could not be deleted
+func reportExpiryFailure(record models.ServiceRecord, cause error) {
+	first, err := db.RecordExpiryFailure(record.ID)
+	if err != nil {
+		log.Printf("Failed to record expiry failure of service %s: %v", record.ID.Hex(), err)
+		return
+	}
+	if !first {
+		return
+	}
+	message := fmt.Sprintf("The %s %s of %s expired but could not be deleted: %v", record.Service, serviceIdentifier(record), record.Username, cause)
+	if err := db.NotifyGroupManager(record.GroupID, message); err != nil {
+		log.Printf("Failed to notify manager of group %s: %v", record.GroupID, err)
+	}
+}
+
+// serviceIdentifier names a service by its registry identifier, falling back to the record ID
+func serviceIdentifier(record models.ServiceRecord) string {
+	if svc, err := cloud.LookupService(record.Provider, record.Service); err == nil {
+		if identifier := cloud.Params(record.Config).String(svc.IdentifierField()); identifier != "" {
+			return identifier
+		}
+	}
+	return record.ID.Hex()
+}
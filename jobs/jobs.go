@@ -0,0 +1,32 @@
+// Package jobs runs the background work of the server: reconciling services against the clouds and other periodic tasks.
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Every runs fn once at start and then on every tick of interval until ctx is cancelled.
+// Runs never overlap; a run that takes longer than the interval delays the next one.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			started := time.Now()
+			if err := fn(ctx); err != nil {
+				log.Printf("%s failed: %v", name, err)
+			} else {
+				log.Printf("%s finished in %s", name, time.Since(started).Round(time.Millisecond))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"multitenant/db"
+	"time"
+)
+
+const (
+	// an approved session that created nothing for this long gives its budget back
+	sessionCommitmentTTL = 24 * time.Hour
+
+	ledgerMaintenanceInterval = time.Hour
+)
+
+// StartBudgetLedger commits the estimates of services created before the ledger existed, then every hour starts
+// the new budget period of groups whose period ended and releases the budget held by abandoned sessions
+func StartBudgetLedger(ctx context.Context) error {
+	backfilled, err := db.BackfillBudgetCommitments()
+	if err != nil {
+		return err
+	}
+	if backfilled > 0 {
+		log.Printf("Budget ledger: committed the estimates of %d existing services", backfilled)
+	}
+
+	Every(ctx, "Budget ledger maintenance", ledgerMaintenanceInterval, func(ctx context.Context) error {
+		// Periods also roll on the first budget check after they end; this records quiet groups' history on time
+		rolled, err := db.RollBudgetPeriods()
+		if err != nil {
+			return err
+		}
+		if rolled > 0 {
+			log.Printf("Started a new budget period for %d groups", rolled)
+		}
+
+		released, err := db.ReleaseStaleSessionCommitments(time.Now().Add(-sessionCommitmentTTL))
+		if err != nil {
+			return err
+		}
+		if released > 0 {
+			log.Printf("Released the budget of %d abandoned sessions", released)
+		}
+		return nil
+	})
+	return nil
+}
@@ -0,0 +1,213 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"multitenant/cloud"
+	"multitenant/config"
+	"multitenant/db"
+	"multitenant/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// powerTimeout bounds one start or stop call
+const powerTimeout = 2 * time.Minute
+
+// Power actions
+const (
+	PowerStart = "start"
+	PowerStop  = "stop"
+)
+
+// ErrServiceInactive is returned when starting or stopping a service whose resource is gone
+var ErrServiceInactive = errors.New("the service is not active")
+
+// SetPower starts or stops the resource behind a services record and writes the resulting status back to it.
+// by names who asked: a username, or "schedule".
+func SetPower(ctx context.Context, id primitive.ObjectID, action, by string) (*cloud.PowerResult, error) {
+	record, err := db.GetServiceRecord(id)
+	if err != nil {
+		return nil, err
+	}
+	return setPower(ctx, *record, action, by)
+}
+
+func setPower(ctx context.Context, record models.ServiceRecord, action, by string) (*cloud.PowerResult, error) {
+	switch record.ServiceStatus {
+	case "deleted", "expired", "missing":
+		return nil, ErrServiceInactive
+	}
+
+	svc, err := cloud.LookupService(record.Provider, record.Service)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantCtx, err := db.TenantContext(ctx, record.GroupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve cloud credentials: %v", err)
+	}
+	powerCtx, cancel := context.WithTimeout(tenantCtx, powerTimeout)
+	defer cancel()
+
+	config := cloud.Params(record.Config)
+	var result *cloud.PowerResult
+	status := "running"
+	if action == PowerStart {
+		result, err = svc.Start(powerCtx, config)
+	} else {
+		result, err = svc.Stop(powerCtx, config)
+		status = "stopped"
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.RecordPowerAction(record.ID, status, result.State, models.PowerAction{Action: action, By: by, At: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// StartPowerScheduler runs the start/stop schedules every POWER_SCHEDULE_INTERVAL ("off" disables it)
+func StartPowerScheduler(ctx context.Context, cfg *config.Config) error {
+	interval := cfg.Jobs.PowerScheduleInterval
+	if interval == 0 {
+		log.Println("Power scheduler disabled")
+		return nil
+	}
+
+	Every(ctx, "Power schedules", interval, func(ctx context.Context) error {
+		return RunPowerSchedules(ctx, time.Now())
+	})
+	return nil
+}
+
+// NextPowerRuns returns the next start and stop of a schedule after t; nil when the schedule has no such cron
+func NextPowerRuns(schedule models.PowerSchedule, t time.Time) (nextStart, nextStop *time.Time, err error) {
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid timezone %q", schedule.Timezone)
+	}
+	next := func(expr string) (*time.Time, error) {
+		if expr == "" {
+			return nil, nil
+		}
+		cron, err := ParseCron(expr, location)
+		if err != nil {
+			return nil, err
+		}
+		at := cron.Next(t)
+		if at.IsZero() {
+			return nil, fmt.Errorf("cron expression %q never matches", expr)
+		}
+		at = at.UTC()
+		return &at, nil
+	}
+
+	if nextStart, err = next(schedule.StartCron); err != nil {
+		return nil, nil, err
+	}
+	if nextStop, err = next(schedule.StopCron); err != nil {
+		return nil, nil, err
+	}
+	return nextStart, nextStop, nil
+}
+
+// RunPowerSchedules applies the schedules due at now. Runs missed while the server was down are applied once;
+// when both a start and a stop are due, only the later one is.
+func RunPowerSchedules(ctx context.Context, now time.Time) error {
+	schedules, err := db.ListDuePowerSchedules(now)
+	if err != nil {
+		return err
+	}
+
+	for _, schedule := range schedules {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		action := PowerStart
+		if schedule.NextStopAt != nil && !schedule.NextStopAt.After(now) &&
+			(schedule.NextStartAt == nil || schedule.NextStartAt.After(now) || schedule.NextStopAt.After(*schedule.NextStartAt)) {
+			action = PowerStop
+		}
+
+		nextStart, nextStop, err := NextPowerRuns(schedule, now)
+		if err != nil {
+			log.Printf("Skipping power schedule %s: %v", schedule.ID.Hex(), err)
+			continue
+		}
+		claimed, err := db.ClaimPowerSchedule(schedule, nextStart, nextStop)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		run := runPowerSchedule(ctx, schedule, action)
+		if err := db.RecordPowerScheduleRun(schedule.ID, run); err != nil {
+			log.Printf("Failed to record run of power schedule %s: %v", schedule.ID.Hex(), err)
+		}
+		if len(run.Errors) > 0 {
+			message := fmt.Sprintf("A scheduled %s failed for %d service(s): %v", action, len(run.Errors), run.Errors)
+			if err := db.NotifyGroupManager(schedule.GroupID, message); err != nil {
+				log.Printf("Failed to notify manager of group %s: %v", schedule.GroupID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// runPowerSchedule applies an action to the services a schedule covers
+func runPowerSchedule(ctx context.Context, schedule models.PowerSchedule, action string) models.PowerScheduleRun {
+	run := models.PowerScheduleRun{Action: action, At: time.Now()}
+
+	var targets []models.ServiceRecord
+	if schedule.ServiceID != nil {
+		record, err := db.GetServiceRecord(*schedule.ServiceID)
+		if err != nil {
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", schedule.ServiceID.Hex(), err))
+			return run
+		}
+		targets = append(targets, *record)
+	} else {
+		records, err := db.ListPowerTargets(schedule.GroupID)
+		if err != nil {
+			run.Errors = append(run.Errors, err.Error())
+			return run
+		}
+		// Services with a schedule of their own follow it instead
+		own, err := db.ListScheduledServiceIDs(schedule.GroupID)
+		if err != nil {
+			run.Errors = append(run.Errors, err.Error())
+			return run
+		}
+		for _, record := range records {
+			svc, err := cloud.LookupService(record.Provider, record.Service)
+			if err != nil || !cloud.SupportsPower(svc) || own[record.ID] {
+				continue
+			}
+			targets = append(targets, record)
+		}
+	}
+
+	for _, record := range targets {
+		// Nothing to do for services already in the requested state
+		if (action == PowerStart && record.ServiceStatus == "running") || (action == PowerStop && record.ServiceStatus == "stopped") {
+			continue
+		}
+		if _, err := setPower(ctx, record, action, "schedule"); err != nil {
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", record.ID.Hex(), err))
+			continue
+		}
+		run.Services++
+	}
+	return run
+}
@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"multitenant/cloud"
+	"multitenant/config"
+	"multitenant/db"
+	"multitenant/models"
+	"sync"
+)
+
+var (
+	pricingRefreshMu   sync.Mutex
+	lastPricingRefresh *models.PricingRefreshResult
+)
+
+// StartPricingCatalog makes estimates read prices from the pricing catalog. With PRICING_FIXTURE set the catalog is
+// loaded from that JSON file and kept offline; otherwise prices are cached in MongoDB, refetched when older than
+// PRICING_MAX_AGE and refreshed every PRICING_REFRESH_INTERVAL.
+func StartPricingCatalog(ctx context.Context, cfg *config.Config) error {
+	if path := cfg.Cloud.PricingFixture; path != "" {
+		prices, err := cloud.LoadPriceFixture(path)
+		if err != nil {
+			return err
+		}
+		cloud.UsePriceStore(cloud.NewMemoryPriceStore(prices), 0, true)
+		log.Printf("Pricing catalog loaded from %s: %d prices, offline", path, len(prices))
+		return nil
+	}
+
+	maxAge, interval := cfg.Jobs.PricingMaxAge, cfg.Jobs.PricingRefreshInterval
+	cloud.UsePriceStore(db.PricingCatalog{}, maxAge, false)
+	if interval == 0 {
+		log.Println("Pricing catalog refresh disabled")
+		return nil
+	}
+
+	Every(ctx, "Pricing catalog refresh", interval, func(ctx context.Context) error {
+		result, err := RefreshPricing()
+		if err != nil {
+			return err
+		}
+		log.Printf("Pricing catalog refresh: %d refreshed, %d failed", result.Refreshed, result.Failed)
+		return nil
+	})
+	return nil
+}
+
+// RefreshPricing refetches the pricing catalog now. Concurrent calls wait for each other rather than hit the APIs twice.
+func RefreshPricing() (*models.PricingRefreshResult, error) {
+	pricingRefreshMu.Lock()
+	defer pricingRefreshMu.Unlock()
+
+	result, err := cloud.RefreshPricingCatalog()
+	if result != nil {
+		lastPricingRefresh = result
+	}
+	if err != nil {
+		return result, fmt.Errorf("pricing catalog refresh failed: %v", err)
+	}
+	return result, nil
+}
+
+// LastPricingRefresh returns the outcome of the latest refresh since the server started, or nil
+func LastPricingRefresh() *models.PricingRefreshResult {
+	pricingRefreshMu.Lock()
+	defer pricingRefreshMu.Unlock()
+	return lastPricingRefresh
+}
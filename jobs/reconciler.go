@@ -0,0 +1,146 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"multitenant/cloud"
+	"multitenant/config"
+	"multitenant/db"
+	"multitenant/models"
+	"strings"
+	"time"
+)
+
+const (
+	// deleted records are re-checked for this long in case the resource survived the delete
+	reconcileDeletedLookback = 7 * 24 * time.Hour
+
+	describeTimeout = 30 * time.Second
+)
+
+// ReconcileSummary counts the outcome of a reconcile run
+type ReconcileSummary struct {
+	Checked int `json:"checked"`
+	Drifted int `json:"drifted"`
+	Failed  int `json:"failed"` // records that could not be described, left unchanged
+}
+
+// StartReconciler reconciles every group on the RECONCILE_INTERVAL schedule
+func StartReconciler(ctx context.Context, cfg *config.Config) error {
+	interval := cfg.Jobs.ReconcileInterval
+	if interval == 0 {
+		log.Println("Service reconciler disabled")
+		return nil
+	}
+
+	Every(ctx, "Service reconcile", interval, func(ctx context.Context) error {
+		summary, err := Reconcile(ctx, "")
+		if err != nil {
+			return err
+		}
+		log.Printf("Reconciled %d services: %d drifted, %d failed", summary.Checked, summary.Drifted, summary.Failed)
+		return nil
+	})
+	return nil
+}
+
+// Reconcile describes the resource behind each tracked record of a group, or of every group when groupID is empty,
+// and records its live state and drift
+func Reconcile(ctx context.Context, groupID string) (ReconcileSummary, error) {
+	var summary ReconcileSummary
+
+	records, err := db.ListServicesToReconcile(groupID, time.Now().Add(-reconcileDeletedLookback))
+	if err != nil {
+		return summary, err
+	}
+
+	for _, record := range records {
+		if ctx.Err() != nil {
+			return summary, ctx.Err()
+		}
+
+		drift, err := reconcileService(ctx, record)
+		summary.Checked++
+		if err != nil {
+			summary.Failed++
+			log.Printf("Failed to reconcile service %s: %v", record.ID.Hex(), err)
+			continue
+		}
+		if drift != nil {
+			summary.Drifted++
+		}
+	}
+	return summary, nil
+}
+
+// reconcileService describes one record's resource and stores what it found
+func reconcileService(ctx context.Context, record models.ServiceRecord) (*models.ServiceDrift, error) {
+	svc, err := cloud.LookupService(record.Provider, record.Service)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantCtx, err := db.TenantContext(ctx, record.GroupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve cloud credentials: %v", err)
+	}
+	describeCtx, cancel := context.WithTimeout(tenantCtx, describeTimeout)
+	defer cancel()
+
+	config := cloud.Params(record.Config)
+	live, err := svc.Describe(describeCtx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	active := record.ServiceStatus != "deleted" && record.ServiceStatus != "expired"
+	status := ""
+	var drift *models.ServiceDrift
+
+	switch {
+	case active && !live.Exists:
+		drift = &models.ServiceDrift{Type: cloud.DriftMissing}
+		status = cloud.LiveStatus(live)
+	case active:
+		if differences := cloud.ConfigDrift(config, live.Attributes); len(differences) > 0 {
+			drift = &models.ServiceDrift{Type: cloud.DriftModified, Details: differences}
+		}
+		status = cloud.LiveStatus(live)
+	case live.Exists:
+		drift = &models.ServiceDrift{Type: cloud.DriftUnexpected}
+	}
+
+	if drift != nil {
+		drift.DetectedAt = time.Now()
+		if record.Drift != nil && record.Drift.Type == drift.Type {
+			drift.DetectedAt = record.Drift.DetectedAt
+		} else {
+			notifyDrift(record, svc, drift)
+		}
+	}
+
+	if err := db.RecordReconciliation(record.ID, live.State, status, drift); err != nil {
+		return nil, err
+	}
+	return drift, nil
+}
+
+// notifyDrift tells the group's manager about newly detected drift
+func notifyDrift(record models.ServiceRecord, svc cloud.ServiceType, drift *models.ServiceDrift) {
+	identifier := cloud.Params(record.Config).String(svc.IdentifierField())
+
+	var message string
+	switch drift.Type {
+	case cloud.DriftMissing:
+		message = fmt.Sprintf("The %s %s of %s no longer exists in %s.", svc.Name(), identifier, record.Username, strings.ToUpper(record.Provider))
+	case cloud.DriftModified:
+		message = fmt.Sprintf("The %s %s of %s was changed outside the platform: %s.", svc.Name(), identifier, record.Username, strings.Join(drift.Details, "; "))
+	case cloud.DriftUnexpected:
+		message = fmt.Sprintf("The %s %s of %s was deleted but still exists in %s.", svc.Name(), identifier, record.Username, strings.ToUpper(record.Provider))
+	}
+
+	if err := db.NotifyGroupManager(record.GroupID, message); err != nil {
+		log.Printf("Failed to notify manager of group %s about drift: %v", record.GroupID, err)
+	}
+}
@@ -0,0 +1,233 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"multitenant/config"
+	"multitenant/db"
+	"multitenant/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// reportWebhookTimeout bounds one delivery to a webhook
+const reportWebhookTimeout = 30 * time.Second
+
+// Report formats
+const (
+	ReportCSV  = "csv"
+	ReportJSON = "json"
+)
+
+// ReportRanges are the ranges a schedule can report, ending at the day of the run
+var ReportRanges = []string{"previous_day", "previous_week", "previous_month", "month_to_date"}
+
+// ReportRange returns the days a scheduled report covers when run at t, with an exclusive end. Days are whole
+// days of the schedule's timezone, expressed in UTC as cost points are.
+func ReportRange(name string, t time.Time, location *time.Location) (time.Time, time.Time, error) {
+	local := t.In(location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch name {
+	case "previous_day":
+		return today.AddDate(0, 0, -1), today, nil
+	case "previous_week":
+		// Monday to Sunday
+		monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+		return monday.AddDate(0, 0, -7), monday, nil
+	case "previous_month":
+		first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return first.AddDate(0, -1, 0), first, nil
+	case "month_to_date":
+		first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if first.Equal(today) {
+			// On the first of the month, the month so far is the whole previous month
+			return first.AddDate(0, -1, 0), first, nil
+		}
+		return first, today, nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid range %q, expected one of %v", name, ReportRanges)
+}
+
+// NextReportRun returns the next delivery of a schedule after t
+func NextReportRun(schedule models.ReportSchedule, t time.Time) (*time.Time, error) {
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q", schedule.Timezone)
+	}
+	cron, err := ParseCron(schedule.Cron, location)
+	if err != nil {
+		return nil, err
+	}
+	next := cron.Next(t)
+	if next.IsZero() {
+		return nil, fmt.Errorf("cron expression %q never matches", schedule.Cron)
+	}
+	next = next.UTC()
+	return &next, nil
+}
+
+// ExportChargeback writes a report as JSON, or as CSV with one row per service
+func ExportChargeback(w io.Writer, report *models.ChargebackReport, format string) error {
+	if format == ReportJSON {
+		return json.NewEncoder(w).Encode(report)
+	}
+	if format != ReportCSV {
+		return fmt.Errorf("invalid format %q, expected csv or json", format)
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{
+		"org", "manager", "group_id", "group_name", "username", "service_id", "provider", "service",
+		"service_status", "start", "end", "days", "amount", "currency",
+	})
+	start := report.Start.Format("2006-01-02")
+	end := report.End.AddDate(0, 0, -1).Format("2006-01-02")
+	for _, manager := range report.Managers {
+		for _, group := range manager.Groups {
+			for _, user := range group.Users {
+				for _, service := range user.Services {
+					writer.Write([]string{
+						report.Org, manager.Manager, group.GroupID, group.GroupName, user.Username,
+						service.ServiceID.Hex(), service.Provider, service.Service, service.ServiceStatus,
+						start, end, strconv.Itoa(service.Days),
+						strconv.FormatFloat(service.Amount, 'f', 2, 64), report.Currency,
+					})
+				}
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReportContentType is the media type of a report format
+func ReportContentType(format string) string {
+	if format == ReportJSON {
+		return "application/json"
+	}
+	return "text/csv"
+}
+
+// StartReportScheduler delivers the scheduled chargeback reports every REPORT_SCHEDULE_INTERVAL ("off" disables it)
+func StartReportScheduler(ctx context.Context, cfg *config.Config) error {
+	interval := cfg.Jobs.ReportScheduleInterval
+	if interval == 0 {
+		log.Println("Report scheduler disabled")
+		return nil
+	}
+
+	Every(ctx, "Report schedules", interval, func(ctx context.Context) error {
+		return RunReportSchedules(ctx, time.Now())
+	})
+	return nil
+}
+
+// RunReportSchedules delivers the reports due at now. Deliveries missed while the server was down are made once.
+func RunReportSchedules(ctx context.Context, now time.Time) error {
+	schedules, err := db.ListDueReportSchedules(now)
+	if err != nil {
+		return err
+	}
+
+	for _, schedule := range schedules {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		next, err := NextReportRun(schedule, now)
+		if err != nil {
+			log.Printf("Skipping report schedule %s: %v", schedule.ID.Hex(), err)
+			continue
+		}
+		claimed, err := db.ClaimReportSchedule(schedule, next)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		run := deliverReport(ctx, schedule, now)
+		if err := db.RecordReportRun(schedule.ID, run); err != nil {
+			log.Printf("Failed to record run of report schedule %s: %v", schedule.ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// deliverReport generates the report of a schedule, stores it for its owner and posts it to the webhook
+func deliverReport(ctx context.Context, schedule models.ReportSchedule, now time.Time) models.ReportRun {
+	run := models.ReportRun{At: time.Now()}
+
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		run.Error = fmt.Sprintf("invalid timezone %q", schedule.Timezone)
+		return run
+	}
+	start, end, err := ReportRange(schedule.Range, now, location)
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+
+	report, err := db.GenerateChargebackReport(schedule.Manager, start, end)
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	report.ScheduleID = &schedule.ID
+	report.Owner = schedule.Owner
+	if err := db.SaveChargebackReport(report); err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	run.ReportID = &report.ID
+	run.Total = report.Total
+
+	if err := db.NotifyReportDelivered(*report); err != nil {
+		log.Printf("Failed to notify %s of report %s: %v", schedule.Owner, report.ID.Hex(), err)
+	}
+
+	if schedule.WebhookURL != "" {
+		if err := postReport(ctx, schedule.WebhookURL, report, schedule.Format); err != nil {
+			run.Error = fmt.Sprintf("webhook: %v", err)
+			return run
+		}
+		run.Posted = true
+	}
+	return run
+}
+
+// postReport sends a report to a webhook in the schedule's format
+func postReport(ctx context.Context, url string, report *models.ChargebackReport, format string) error {
+	var body bytes.Buffer
+	if err := ExportChargeback(&body, report, format); err != nil {
+		return err
+	}
+
+	postCtx, cancel := context.WithTimeout(ctx, reportWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(postCtx, http.MethodPost, url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ReportContentType(format))
+	req.Header.Set("X-Report-ID", report.ID.Hex())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -1,11 +1,18 @@
 package main
  
 import (
+    "context"
     "fmt"
     "log"
+    "multitenant/cloud"
+    "multitenant/config"
     "multitenant/db"
+    "multitenant/handlers"
+    "multitenant/jobs"
     "multitenant/routes"
+    "multitenant/secrets"
     "net/http"
+    "os"
  
     "github.com/rs/cors"
     "github.com/joho/godotenv"
@@ -13,28 +20,113 @@ import (
  
 func main() {
  
-    // Load environment variables from .env file
+    // Load environment variables from .env file, when there is one
     err1 := godotenv.Load()
-    if err1 != nil {
+    if err1 != nil && !os.IsNotExist(err1) {
         log.Fatalf("Error loading .env file: %v", err1)
     }
- 
-    db.InitMongoDB()
+
+    // Settings come from the environment and the optional CONFIG_FILE; invalid settings stop the server here
+    cfg, err := config.Load()
+    if err != nil {
+        log.Fatal("Failed to load configuration:", err)
+    }
+
+    // Secrets come from SECRETS_BACKEND; the database URI and the JWT signing key must be readable to start
+    store, err := secrets.New(context.Background(), cfg.Secrets)
+    if err != nil {
+        log.Fatal("Failed to configure secrets:", err)
+    }
+    if err := secrets.Require(context.Background(), store, secrets.MongoURI, secrets.JWTSecret); err != nil {
+        log.Fatal("Failed to read secrets:", err)
+    }
+
     // Initialize MongoDB connection
-    err := db.ConnectMongoDB()
+    err = db.ConnectMongoDB(cfg, store)
     if err != nil {
         log.Fatal("Failed to connect to MongoDB:", err)
     }
     defer db.DisconnectMongoDB()
+
+    // Indexes backing the Idempotency-Key locks
+    if err := db.EnsureIdempotencyIndexes(); err != nil {
+        log.Fatal("Failed to create idempotency indexes:", err)
+    }
+    if err := db.EnsureTenantCredentialsIndexes(); err != nil {
+        log.Fatal("Failed to create tenant credentials indexes:", err)
+    }
+    if err := db.EnsureServicesIndexes(); err != nil {
+        log.Fatal("Failed to create services indexes:", err)
+    }
+    if err := db.EnsureDiscoveryIndexes(); err != nil {
+        log.Fatal("Failed to create discovery indexes:", err)
+    }
+    if err := db.EnsureRevisionIndexes(); err != nil {
+        log.Fatal("Failed to create revision indexes:", err)
+    }
+    if err := db.EnsurePowerScheduleIndexes(); err != nil {
+        log.Fatal("Failed to create power schedule indexes:", err)
+    }
+    if err := db.EnsurePricingCatalogIndexes(); err != nil {
+        log.Fatal("Failed to create pricing catalog indexes:", err)
+    }
+    if err := db.EnsureBudgetLedgerIndexes(); err != nil {
+        log.Fatal("Failed to create budget ledger indexes:", err)
+    }
+    if err := db.EnsureBudgetPeriodIndexes(); err != nil {
+        log.Fatal("Failed to create budget period indexes:", err)
+    }
+    if err := db.EnsureCostSeriesIndexes(); err != nil {
+        log.Fatal("Failed to create cost series indexes:", err)
+    }
+    if err := db.EnsureBudgetAlertIndexes(); err != nil {
+        log.Fatal("Failed to create budget alert indexes:", err)
+    }
+    if err := db.EnsureCostAnomalyIndexes(); err != nil {
+        log.Fatal("Failed to create cost anomaly indexes:", err)
+    }
+    if err := db.EnsureReportIndexes(); err != nil {
+        log.Fatal("Failed to create report indexes:", err)
+    }
+ 
+    // CLOUD_BACKEND=fake serves every cloud service from memory so the API runs without AWS/GCP credentials
+    if err := cloud.Configure(cfg, store); err != nil {
+        log.Fatal("Failed to select cloud backend:", err)
+    }
+    handlers.Configure(cfg, store)
+
+    // Background jobs run for the lifetime of the process
+    if err := jobs.StartReconciler(context.Background(), cfg); err != nil {
+        log.Fatal("Failed to start service reconciler:", err)
+    }
+    if err := jobs.StartPowerScheduler(context.Background(), cfg); err != nil {
+        log.Fatal("Failed to start power scheduler:", err)
+    }
+    if err := jobs.StartExpiryReaper(context.Background(), cfg); err != nil {
+        log.Fatal("Failed to start expiry reaper:", err)
+    }
+    if err := jobs.StartPricingCatalog(context.Background(), cfg); err != nil {
+        log.Fatal("Failed to start pricing catalog:", err)
+    }
+    if err := jobs.StartBudgetLedger(context.Background()); err != nil {
+        log.Fatal("Failed to start budget ledger:", err)
+    }
+    if err := jobs.StartCostSync(context.Background(), cfg); err != nil {
+        log.Fatal("Failed to start cost sync:", err)
+    }
+    if err := jobs.StartReportScheduler(context.Background(), cfg); err != nil {
+        log.Fatal("Failed to start report scheduler:", err)
+    }
  
     // Initialize routes
     router := routes.InitializeRoutes()
  
     // Setup CORS with the allowed origin for Angular
     c := cors.New(cors.Options{
-        AllowedOrigins:   []string{"http://localhost:4200"}, // Allow requests from Angular
-        AllowedMethods:   []string{"GET", "POST", "DELETE", "PUT", "OPTIONS"}, // Allow HTTP methods
-        AllowedHeaders:   []string{"Content-Type", "Authorization"},          // Allow specific headers
+        AllowedOrigins:   cfg.Server.AllowedOrigins, // Allow requests from the frontend
+        AllowedMethods:   []string{"GET", "POST", "DELETE", "PUT", "PATCH", "OPTIONS"}, // Allow HTTP methods
+        AllowedHeaders:   []string{"Content-Type", "Authorization", "Idempotency-Key"}, // Allow specific headers
+        ExposedHeaders:   []string{"Idempotent-Replayed"},                               // Let the UI detect replayed responses
         AllowCredentials: true,                                               // Allow cookies and credentials
     })
  
@@ -43,6 +135,6 @@ func main() {
     handler := c.Handler(router)
  
     // Start the server
-    fmt.Println("Server running on port 8080")
-    log.Fatal(http.ListenAndServe(":8080", handler))
+    fmt.Println("Server listening on", cfg.Server.Addr)
+    log.Fatal(http.ListenAndServe(cfg.Server.Addr, handler))
 }
\ No newline at end of file
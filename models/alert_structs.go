@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BudgetAlertRule notifies a group's manager when its spend crosses a percentage of the budget
+type BudgetAlertRule struct {
+	Threshold float64 `bson:"threshold" json:"threshold"`                       // percent of the budget plus rollover, e.g. 80
+	Basis     string  `bson:"basis" json:"basis"`                               // "actual" (accrued cost) or "forecast" (expected spend by the period end)
+	HardLimit bool    `bson:"hard_limit,omitempty" json:"hard_limit,omitempty"` // block new sessions once crossed
+}
+
+// BudgetAlert is a rule that fired, in the "budget_alerts" collection. A rule fires at most once per budget period.
+type BudgetAlert struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	GroupID     string             `bson:"group_id" json:"group_id"`
+	Threshold   float64            `bson:"threshold" json:"threshold"`
+	Basis       string             `bson:"basis" json:"basis"`
+	HardLimit   bool               `bson:"hard_limit,omitempty" json:"hard_limit,omitempty"`
+	PeriodStart time.Time          `bson:"period_start" json:"period_start"` // zero for budgets without periods
+	Spend       float64            `bson:"spend" json:"spend"`               // actual or forecast spend when the rule fired
+	Budget      float64            `bson:"budget" json:"budget"`             // budget plus rollover
+	Percent     float64            `bson:"percent" json:"percent"`
+	FiredAt     time.Time          `bson:"fired_at" json:"fired_at"`
+}
+
+// SessionBlock stops a group from starting sessions after a hard-limit rule fired, until the period ends,
+// spend falls back under the rule or the manager lifts it
+type SessionBlock struct {
+	Threshold float64   `bson:"threshold" json:"threshold"`
+	Basis     string    `bson:"basis" json:"basis"`
+	BlockedAt time.Time `bson:"blocked_at" json:"blocked_at"`
+}
+
+// BudgetAlertStatus is a group's alert rules with the spend they are evaluated against
+type BudgetAlertStatus struct {
+	GroupID         string            `json:"group_id"`
+	Rules           []BudgetAlertRule `json:"rules"`
+	Budget          float64           `json:"budget"` // budget plus rollover
+	Actual          float64           `json:"actual"`
+	ActualPercent   float64           `json:"actual_percent"`
+	Forecast        float64           `json:"forecast"`
+	ForecastPercent float64           `json:"forecast_percent"`
+	Fired           []BudgetAlert     `json:"fired"` // rules fired in the current period
+	Block           *SessionBlock     `json:"block,omitempty"`
+}
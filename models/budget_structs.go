@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LedgerEntry is one movement of a group's budget in the "budget_ledger" collection.
+// Commitments hold budget for an approved service, releases give it back and accruals record actual cost.
+type LedgerEntry struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	GroupID    string              `bson:"group_id" json:"group_id"`
+	Type       string              `bson:"type" json:"type"`     // commitment, release or accrual
+	Amount     float64             `bson:"amount" json:"amount"` // USD; only accruals correcting revised billing data are negative
+	ServiceID  *primitive.ObjectID `bson:"service_id,omitempty" json:"service_id,omitempty"`
+	SessionID  string              `bson:"session_id,omitempty" json:"session_id,omitempty"`
+	Username   string              `bson:"username,omitempty" json:"username,omitempty"`
+	Key        string              `bson:"key,omitempty" json:"key,omitempty"` // unique; makes recording an accrual idempotent
+	Note       string              `bson:"note,omitempty" json:"note,omitempty"`
+	IncurredAt *time.Time          `bson:"incurred_at,omitempty" json:"incurred_at,omitempty"` // accruals: when the cost was incurred
+	Timestamp  time.Time           `bson:"timestamp" json:"timestamp"`
+}
+
+// BudgetBalance is the state of a group's budget in its current period: remaining = budget + rollover - committed - accrued
+type BudgetBalance struct {
+	GroupID     string     `json:"group_id"`
+	Budget      float64    `json:"budget"`
+	Rollover    float64    `json:"rollover,omitempty"` // unspent budget carried from the previous period
+	Committed   float64    `json:"committed"`
+	Accrued     float64    `json:"accrued"`
+	Remaining   float64    `json:"remaining"`
+	Period      string     `json:"period,omitempty"`
+	PeriodStart *time.Time `json:"period_start,omitempty"`
+	PeriodEnd   *time.Time `json:"period_end,omitempty"`
+}
+
+// BudgetPeriod is a closed budget period of a group, kept in the "budget_periods" collection
+type BudgetPeriod struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	GroupID     string             `bson:"group_id" json:"group_id"`
+	Period      string             `bson:"period" json:"period"`
+	Start       time.Time          `bson:"start" json:"start"`
+	End         time.Time          `bson:"end" json:"end"`
+	Budget      float64            `bson:"budget" json:"budget"`
+	Rollover    float64            `bson:"rollover" json:"rollover"`         // carried into this period
+	Committed   float64            `bson:"committed" json:"committed"`       // still held when the period closed
+	Accrued     float64            `bson:"accrued" json:"accrued"`           // includes accruals recorded after the close
+	CarriedOver float64            `bson:"carried_over" json:"carried_over"` // carried into the next period
+	ClosedAt    time.Time          `bson:"closed_at" json:"closed_at"`
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CostPoint is the actual cost of one service on one day in the "cost_series" collection, as billed by its provider.
+// Points are unique per service, day and source; billing data for recent days is revised, so points are overwritten.
+type CostPoint struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ServiceID primitive.ObjectID `bson:"service_id" json:"service_id"`
+	GroupID   string             `bson:"group_id" json:"group_id"`
+	Username  string             `bson:"username" json:"username"`
+	Provider  string             `bson:"provider" json:"provider"`
+	Service   string             `bson:"service" json:"service"`
+	Source    string             `bson:"source" json:"source"` // e.g. "aws-cost-explorer"
+	Date      time.Time          `bson:"date" json:"date"`     // midnight UTC
+	Amount    float64            `bson:"amount" json:"amount"`
+	Currency  string             `bson:"currency" json:"currency"`
+	Accrued   float64            `bson:"accrued" json:"-"`  // part of the amount already accrued to the budget ledger
+	Revision  int                `bson:"revision" json:"-"` // number of accruals recorded for the point
+	SyncedAt  time.Time          `bson:"synced_at" json:"synced_at"`
+}
+
+// CostSeries is the actual cost of a group, or of one of its services, over a range of days
+type CostSeries struct {
+	GroupID   string        `json:"group_id"`
+	ServiceID string        `json:"service_id,omitempty"`
+	Start     time.Time     `json:"start"`
+	End       time.Time     `json:"end"` // exclusive
+	Total     float64       `json:"total"`
+	Currency  string        `json:"currency"`
+	Daily     []DailyCost   `json:"daily"`
+	Services  []ServiceCost `json:"services,omitempty"` // group series only, most expensive first
+	LastSync  *time.Time    `json:"last_sync,omitempty"`
+	Points    []CostPoint   `json:"points,omitempty"` // service series only
+}
+
+// DailyCost is the total cost of one day
+type DailyCost struct {
+	Date   time.Time `json:"date"`
+	Amount float64   `json:"amount"`
+}
+
+// ServiceCost is the total cost of one service over a series
+type ServiceCost struct {
+	ServiceID primitive.ObjectID `json:"service_id"`
+	Service   string             `json:"service"`
+	Username  string             `json:"username"`
+	Amount    float64            `json:"amount"`
+}
+
+// CostSyncResult is the outcome of collecting actual costs
+type CostSyncResult struct {
+	StartedAt time.Time `json:"started_at"`
+	Start     time.Time `json:"start"` // first day collected
+	End       time.Time `json:"end"`   // exclusive
+	Groups    int       `json:"groups"`
+	Services  int       `json:"services"`
+	Points    int       `json:"points"`
+	Accrued   float64   `json:"accrued"`   // net amount accrued to budget ledgers
+	Alerts    int       `json:"alerts"`    // budget alert rules fired
+	Anomalies int       `json:"anomalies"` // cost anomalies detected
+	Errors    []string  `json:"errors,omitempty"`
+}
+
+// SpendForecast is a group's expected spend by the end of its budget period, with a confidence band.
+// Expected = accrued + the unbilled cost of each service up to the end + approved sessions not created yet.
+type SpendForecast struct {
+	GroupID     string            `json:"group_id"`
+	PeriodStart *time.Time        `json:"period_start,omitempty"` // absent for budgets without periods, which are forecast 90 days ahead
+	PeriodEnd   time.Time         `json:"period_end"`
+	Budget      float64           `json:"budget"` // budget plus rollover
+	Accrued     float64           `json:"accrued"`
+	Pending     float64           `json:"pending"` // held by approved sessions that have not created their service
+	Expected    float64           `json:"expected"`
+	Low         float64           `json:"low"`
+	High        float64           `json:"high"`
+	Confidence  float64           `json:"confidence"` // probability the spend falls between low and high
+	Remaining   float64           `json:"remaining"`  // budget - expected
+	OverBudget  bool              `json:"over_budget"`
+	Services    []ServiceForecast `json:"services"`
+}
+
+// ServiceForecast is the cost a service is expected to add to its group's spend by the end of the period
+type ServiceForecast struct {
+	ServiceID primitive.ObjectID `json:"service_id"`
+	Service   string             `json:"service"`
+	Username  string             `json:"username"`
+	Method    string             `json:"method"`     // "history" (recent actual cost) or "estimate" (hourly rate of the estimate)
+	DailyRate float64            `json:"daily_rate"` // expected cost per running day
+	From      time.Time          `json:"from"`       // end of the cost already billed
+	Until     time.Time          `json:"until"`      // period end, or earlier when the service ends or expires
+	Expected  float64            `json:"expected"`   // unbilled cost from From to Until
+	Low       float64            `json:"low"`
+	High      float64            `json:"high"`
+}
+
+// CostAnomaly is a day whose actual cost stands out from the days before it, for one service or a whole group,
+// stored in the "cost_anomalies" collection. Group anomalies are only raised when no single service explains them.
+type CostAnomaly struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	GroupID    string              `bson:"group_id" json:"group_id"`
+	ServiceID  *primitive.ObjectID `bson:"service_id" json:"service_id,omitempty"` // nil for group anomalies
+	Service    string              `bson:"service,omitempty" json:"service,omitempty"`
+	Resource   string              `bson:"resource,omitempty" json:"resource,omitempty"` // e.g. the instance ID or bucket name
+	Username   string              `bson:"username,omitempty" json:"username,omitempty"`
+	Date       time.Time           `bson:"date" json:"date"`
+	Amount     float64             `bson:"amount" json:"amount"`
+	Baseline   float64             `bson:"baseline" json:"baseline"` // mean daily cost of the days before
+	StdDev     float64             `bson:"std_dev" json:"std_dev"`
+	ZScore     float64             `bson:"z_score" json:"z_score"`
+	DetectedAt time.Time           `bson:"detected_at" json:"detected_at"`
+}
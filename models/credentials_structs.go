@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Credential scopes; group credentials take precedence over the organization default
+const (
+	CredentialScopeOrg   = "org"
+	CredentialScopeGroup = "group"
+)
+
+// TenantCredentials represents the cloud identity and allowed locations of an organization or group in the "tenant_credentials" collection
+type TenantCredentials struct {
+	Scope             string            `bson:"scope" json:"scope"`                                                 // "org" or "group"
+	ScopeID           string            `bson:"scope_id" json:"scope_id"`                                           // Group ID, or "default" for the organization
+	AWSRoleARN        string            `bson:"aws_role_arn,omitempty" json:"aws_role_arn,omitempty"`               // Role assumed for AWS calls
+	AWSExternalID     string            `bson:"aws_external_id,omitempty" json:"aws_external_id,omitempty"`         // External ID required by the role trust policy
+	GCPProjectID      string            `bson:"gcp_project_id,omitempty" json:"gcp_project_id,omitempty"`           // Project GCP resources are created in
+	GCPServiceAccount string            `bson:"gcp_service_account,omitempty" json:"gcp_service_account,omitempty"` // Service account impersonated for GCP calls
+	AWSRegions        []string          `bson:"aws_regions,omitempty" json:"aws_regions,omitempty"`                 // Allow-list of AWS regions, empty for any
+	GCPRegions        []string          `bson:"gcp_regions,omitempty" json:"gcp_regions,omitempty"`                 // Allow-list of GCP regions, empty for any
+	Tags              map[string]string `bson:"tags,omitempty" json:"tags,omitempty"`                               // Custom tags/labels applied to every resource created
+	UpdatedBy         string            `bson:"updated_by" json:"updated_by"`
+	UpdatedAt         time.Time         `bson:"updated_at" json:"updated_at"`
+}
+
+// Key identifies the credentials in the cloud credential cache
+func (c TenantCredentials) Key() string {
+	return c.Scope + ":" + c.ScopeID
+}
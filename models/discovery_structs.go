@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DiscoveredResource is an untracked cloud resource found by a discovery scan, stored in the "discovered_resources" collection
+type DiscoveredResource struct {
+	ID               primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	GroupID          string                 `bson:"group_id" json:"group_id"` // group whose credentials found the resource
+	Provider         string                 `bson:"provider" json:"provider"`
+	Service          string                 `bson:"service" json:"service"`
+	ResourceID       string                 `bson:"resource_id" json:"resource_id"` // provider assigned unique ID
+	IDField          string                 `bson:"id_field" json:"id_field"`       // config key holding the resource ID
+	Region           string                 `bson:"region" json:"region"`
+	State            string                 `bson:"state" json:"state"`
+	Config           map[string]interface{} `bson:"config" json:"config"`
+	FirstSeen        time.Time              `bson:"first_seen" json:"first_seen"`
+	LastSeen         time.Time              `bson:"last_seen" json:"last_seen"`
+	AdoptedServiceID *primitive.ObjectID    `bson:"adopted_service_id,omitempty" json:"adopted_service_id,omitempty"` // services record created on adoption
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// IdempotencyRecord represents a stored Idempotency-Key in the "idempotency_keys" collection
+type IdempotencyRecord struct {
+	Key          string    `bson:"key"`           // Client supplied Idempotency-Key header
+	Username     string    `bson:"username"`      // Keys are scoped per user
+	Fingerprint  string    `bson:"fingerprint"`   // Hash of method, path and body of the original request
+	Method       string    `bson:"method"`        // HTTP method of the original request
+	Path         string    `bson:"path"`          // Request path of the original request
+	Status       string    `bson:"status"`        // "processing" or "completed"
+	StatusCode   int       `bson:"status_code"`   // Recorded response status code
+	ContentType  string    `bson:"content_type"`  // Recorded response content type
+	ResponseBody []byte    `bson:"response_body"` // Recorded response body, replayed for retries
+	LockedUntil  time.Time `bson:"locked_until"`  // Lock expiry while the original request is processing
+	CreatedAt    time.Time `bson:"created_at"`
+	CompletedAt  time.Time `bson:"completed_at,omitempty"`
+	ExpiresAt    time.Time `bson:"expires_at"` // Record is removed by a TTL index after this time
+}
@@ -1,4 +1,6 @@
 package models
+
+import "time"
  
 // Struct for groups
 type Group struct {
@@ -7,6 +9,18 @@ type Group struct {
     GroupName string   `json:"group_name" bson:"group_name"`    // Name of the group
     Members   []string `json:"members" bson:"members"`          // List of group members
     Budget    float64  `json:"budget,omitempty" bson:"budget"`  // Optional budget field
+    DefaultTTLHours int `json:"default_ttl_hours,omitempty" bson:"default_ttl_hours,omitempty"` // Lifetime of new services created without expires_at
+    MaxTTLHours     int `json:"max_ttl_hours,omitempty" bson:"max_ttl_hours,omitempty"`         // Furthest a service's expiry may be set from now
+    BudgetCommitted float64 `json:"budget_committed,omitempty" bson:"budget_committed,omitempty"` // Budget held for approved and running services
+    BudgetAccrued   float64 `json:"budget_accrued,omitempty" bson:"budget_accrued,omitempty"`     // Actual cost recorded against the budget
+    BudgetPeriod    string     `json:"budget_period,omitempty" bson:"budget_period,omitempty"`     // monthly, quarterly or yearly; empty budgets never reset
+    PeriodAnchor    *time.Time `json:"period_anchor,omitempty" bson:"period_anchor,omitempty"`     // Start of the first period; later periods follow on
+    PeriodStart     *time.Time `json:"period_start,omitempty" bson:"period_start,omitempty"`       // Current period
+    PeriodEnd       *time.Time `json:"period_end,omitempty" bson:"period_end,omitempty"`
+    RolloverCap     float64    `json:"rollover_cap,omitempty" bson:"rollover_cap,omitempty"`       // Most unspent budget carried into the next period
+    BudgetRollover  float64    `json:"budget_rollover,omitempty" bson:"budget_rollover,omitempty"` // Carried into the current period, on top of the budget
+    AlertRules      []BudgetAlertRule `json:"alert_rules,omitempty" bson:"alert_rules,omitempty"`     // Spend thresholds that notify the manager
+    SessionBlock    *SessionBlock     `json:"session_block,omitempty" bson:"session_block,omitempty"` // Set while a hard limit blocks new sessions
 }
  
 // Response structure
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PowerSchedule starts and stops services on cron schedules, stored in the "power_schedules" collection.
+// A schedule applies to one service, or to every service of a group that can be stopped when ServiceID is nil.
+// A service's own schedule takes precedence over its group's.
+type PowerSchedule struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	GroupID     string              `bson:"group_id" json:"group_id"`
+	ServiceID   *primitive.ObjectID `bson:"service_id,omitempty" json:"service_id,omitempty"`
+	StartCron   string              `bson:"start_cron,omitempty" json:"start_cron,omitempty"` // e.g. "0 8 * * MON-FRI"
+	StopCron    string              `bson:"stop_cron,omitempty" json:"stop_cron,omitempty"`   // e.g. "0 20 * * MON-FRI"
+	Timezone    string              `bson:"timezone" json:"timezone"`                         // IANA name the crons are evaluated in
+	Enabled     bool                `bson:"enabled" json:"enabled"`
+	NextStartAt *time.Time          `bson:"next_start_at,omitempty" json:"next_start_at,omitempty"`
+	NextStopAt  *time.Time          `bson:"next_stop_at,omitempty" json:"next_stop_at,omitempty"`
+	LastRun     *PowerScheduleRun   `bson:"last_run,omitempty" json:"last_run,omitempty"`
+	CreatedBy   string              `bson:"created_by" json:"created_by"`
+	UpdatedAt   time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+// PowerScheduleRun is the outcome of the last action a schedule took
+type PowerScheduleRun struct {
+	Action   string    `bson:"action" json:"action"` // "start" or "stop"
+	At       time.Time `bson:"at" json:"at"`
+	Services int       `bson:"services" json:"services"` // services the action was applied to
+	Errors   []string  `bson:"errors,omitempty" json:"errors,omitempty"`
+}
+
+// PowerAction records the last start or stop of a service on its services record
+type PowerAction struct {
+	Action string    `bson:"action" json:"action"` // "start" or "stop"
+	By     string    `bson:"by" json:"by"`         // username, or "schedule" for the scheduler
+	At     time.Time `bson:"at" json:"at"`
+}
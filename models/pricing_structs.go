@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// CatalogPrice is a unit price cached in the "pricing_catalog" collection, so estimates do not call the pricing APIs.
+// Prices are keyed by provider, service, region and the attributes that select the SKU.
+type CatalogPrice struct {
+	Key        string            `bson:"key" json:"key"` // canonical form of provider, service, region and attributes; unique
+	Provider   string            `bson:"provider" json:"provider"`
+	Service    string            `bson:"service" json:"service"` // AWS service code such as "AmazonEC2", or GCP catalog service name
+	Region     string            `bson:"region" json:"region"`
+	Attributes map[string]string `bson:"attributes" json:"attributes"` // e.g. {"instanceType": "t3.small", "operatingSystem": "Linux"}
+	Price      float64           `bson:"price" json:"price"`           // USD per unit
+	Unit       string            `bson:"unit" json:"unit"`             // e.g. "Hrs", "GB-Mo", "h", "GiBy.mo"
+	Source     string            `bson:"source" json:"source"`         // "aws-pricing-api", "gcp-cloud-catalog" or "fixture"
+	FetchedAt  time.Time         `bson:"fetched_at" json:"fetched_at"`
+}
+
+// PricingCatalogStatus describes how fresh the cached prices are
+type PricingCatalogStatus struct {
+	Entries     int                   `json:"entries"`
+	Stale       int                   `json:"stale"`             // entries older than MaxAge, refetched on next use
+	MaxAge      string                `json:"max_age,omitempty"` // empty when prices never go stale
+	Offline     bool                  `json:"offline"`           // misses fail instead of calling the pricing APIs
+	Oldest      *time.Time            `json:"oldest,omitempty"`
+	Newest      *time.Time            `json:"newest,omitempty"`
+	LastRefresh *PricingRefreshResult `json:"last_refresh,omitempty"`
+}
+
+// PricingRefreshResult is the outcome of a pricing catalog refresh
+type PricingRefreshResult struct {
+	StartedAt time.Time `json:"started_at"`
+	Refreshed int       `json:"refreshed"`
+	Failed    int       `json:"failed"`
+	Errors    []string  `json:"errors,omitempty"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChargebackReport is the actual cost of an organization over a range of days, broken down by manager, group,
+// user and service. Delivered reports are kept in the "chargeback_reports" collection.
+type ChargebackReport struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	ScheduleID  *primitive.ObjectID `bson:"schedule_id,omitempty" json:"schedule_id,omitempty"`
+	Owner       string              `bson:"owner,omitempty" json:"-"` // username the report was delivered to
+	Org         string              `bson:"org" json:"org"`
+	Manager     string              `bson:"manager,omitempty" json:"manager,omitempty"` // set when the report covers one manager's groups
+	Start       time.Time           `bson:"start" json:"start"`
+	End         time.Time           `bson:"end" json:"end"` // exclusive
+	Total       float64             `bson:"total" json:"total"`
+	Currency    string              `bson:"currency" json:"currency"`
+	Managers    []ManagerCost       `bson:"managers" json:"managers"` // most expensive first
+	LastSync    *time.Time          `bson:"last_sync,omitempty" json:"last_sync,omitempty"`
+	GeneratedAt time.Time           `bson:"generated_at" json:"generated_at"`
+}
+
+// ManagerCost is the cost of the groups of one manager. Services of groups that no longer exist are reported
+// under an empty manager.
+type ManagerCost struct {
+	Manager string      `bson:"manager" json:"manager"`
+	Total   float64     `bson:"total" json:"total"`
+	Groups  []GroupCost `bson:"groups" json:"groups"`
+}
+
+// GroupCost is the cost of one group's users
+type GroupCost struct {
+	GroupID   string     `bson:"group_id" json:"group_id"`
+	GroupName string     `bson:"group_name" json:"group_name"`
+	Total     float64    `bson:"total" json:"total"`
+	Users     []UserCost `bson:"users" json:"users"`
+}
+
+// UserCost is the cost of the services one user owns in a group
+type UserCost struct {
+	Username string              `bson:"username" json:"username"`
+	Total    float64             `bson:"total" json:"total"`
+	Services []ServiceChargeback `bson:"services" json:"services"`
+}
+
+// ServiceChargeback is the cost of one service over the report's range. Services that ran in the range without
+// billed cost are listed with a zero amount.
+type ServiceChargeback struct {
+	ServiceID     primitive.ObjectID `bson:"service_id" json:"service_id"`
+	Provider      string             `bson:"provider" json:"provider"`
+	Service       string             `bson:"service" json:"service"`
+	ServiceStatus string             `bson:"service_status" json:"service_status"`
+	Amount        float64            `bson:"amount" json:"amount"`
+	Days          int                `bson:"days" json:"days"` // days with billed cost
+}
+
+// ReportSchedule delivers a chargeback report on a cron schedule, stored in the "report_schedules" collection.
+// Each delivery is stored and notified to the owner, and posted to WebhookURL when set.
+type ReportSchedule struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Owner      string             `bson:"owner" json:"owner"`                         // admin or manager who created the schedule
+	Manager    string             `bson:"manager,omitempty" json:"manager,omitempty"` // groups covered; empty for the whole organization
+	Cron       string             `bson:"cron" json:"cron"`                           // e.g. "0 6 1 * *"
+	Timezone   string             `bson:"timezone" json:"timezone"`                   // IANA name the cron and range are evaluated in
+	Range      string             `bson:"range" json:"range"`                         // previous_day, previous_week, previous_month or month_to_date
+	Format     string             `bson:"format" json:"format"`                       // csv or json, for the webhook
+	WebhookURL string             `bson:"webhook_url,omitempty" json:"webhook_url,omitempty"`
+	Enabled    bool               `bson:"enabled" json:"enabled"`
+	NextRunAt  *time.Time         `bson:"next_run_at,omitempty" json:"next_run_at,omitempty"`
+	LastRun    *ReportRun         `bson:"last_run,omitempty" json:"last_run,omitempty"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// ReportRun is the outcome of the last delivery of a schedule
+type ReportRun struct {
+	At       time.Time           `bson:"at" json:"at"`
+	ReportID *primitive.ObjectID `bson:"report_id,omitempty" json:"report_id,omitempty"`
+	Total    float64             `bson:"total" json:"total"`
+	Posted   bool                `bson:"posted" json:"posted"` // the webhook accepted the report
+	Error    string              `bson:"error,omitempty" json:"error,omitempty"`
+}
@@ -1,5 +1,11 @@
 package models
 
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
 // Service represents a cloud service document in MongoDB
 type Service struct {
 	UserEmail     string  `bson:"user_email" json:"user_email"`         // Email of the user owning the service
@@ -10,3 +16,57 @@ type Service struct {
 	Cost          float64 `bson:"cost" json:"cost"`                     // Cost of the service
 	Usage         float64 `bson:"usage" json:"usage"`                   // Current usage of the service
 }
+
+// ServiceRecord is a document of the services collection, written when a session completes
+type ServiceRecord struct {
+	ID            primitive.ObjectID     `bson:"_id" json:"id"`
+	Username      string                 `bson:"username" json:"username"`
+	GroupName     string                 `bson:"groupname" json:"groupname"`
+	GroupID       string                 `bson:"group_id" json:"group_id"`
+	Provider      string                 `bson:"provider" json:"provider"`
+	SessionID     string                 `bson:"session_id" json:"session_id"`
+	Service       string                 `bson:"service" json:"service"`
+	EstimatedCost float64                `bson:"estimated_cost" json:"estimated_cost"` // quarterly estimate approved for the session
+	Config        map[string]interface{} `bson:"config" json:"config"`
+	Tags          map[string]string      `bson:"tags,omitempty" json:"tags,omitempty"` // tags or labels applied to the resource on create
+	ServiceStatus string                 `bson:"service_status" json:"service_status"`
+	Timestamp     time.Time              `bson:"timestamp" json:"timestamp"`
+	EndTimestamp  *time.Time             `bson:"end_timestamp,omitempty" json:"end_timestamp,omitempty"`
+	AdoptedBy     string                 `bson:"adopted_by,omitempty" json:"adopted_by,omitempty"` // manager who adopted a resource created outside the platform
+	LiveState     string                 `bson:"live_state,omitempty" json:"live_state,omitempty"` // provider state seen by the last reconcile
+	ReconciledAt  *time.Time             `bson:"reconciled_at,omitempty" json:"reconciled_at,omitempty"`
+	Drift         *ServiceDrift          `bson:"drift,omitempty" json:"drift,omitempty"`
+	Revision      int                    `bson:"revision,omitempty" json:"revision,omitempty"` // config revision, absent until the first update
+	LastPower     *PowerAction           `bson:"last_power_action,omitempty" json:"last_power_action,omitempty"`
+	ExpiresAt     *time.Time             `bson:"expires_at,omitempty" json:"expires_at,omitempty"`             // the reaper deletes the resource after this time
+	ExpiryWarned  *time.Time             `bson:"expiry_warned_at,omitempty" json:"expiry_warned_at,omitempty"` // when the owner was warned of the current expiry
+	CommittedCost float64                `bson:"committed_cost,omitempty" json:"committed_cost,omitempty"`     // budget still held for the service in the ledger
+	AccruedCost   float64                `bson:"-" json:"accrued_cost"`                                        // cost accrued so far, filled in when listing
+}
+
+// ServiceDrift records how a tracked resource differs from its services record
+type ServiceDrift struct {
+	Type       string    `bson:"type" json:"type"`                           // missing, modified or unexpected
+	Details    []string  `bson:"details,omitempty" json:"details,omitempty"` // differing attributes for modified resources
+	DetectedAt time.Time `bson:"detected_at" json:"detected_at"`             // first reconcile that saw this drift
+}
+
+// ServicePage is one page of a services listing
+type ServicePage struct {
+	Services   []ServiceRecord `json:"services"`
+	NextCursor string          `json:"next_cursor,omitempty"` // pass as cursor to fetch the next page
+}
+
+// ServiceRevision records one in-place update of a service's config
+type ServiceRevision struct {
+	ID                    primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	ServiceID             primitive.ObjectID     `bson:"service_id" json:"service_id"`
+	Revision              int                    `bson:"revision" json:"revision"` // the created config is revision 1
+	Changes               map[string]interface{} `bson:"changes" json:"changes"`
+	Config                map[string]interface{} `bson:"config" json:"config"`
+	PreviousConfig        map[string]interface{} `bson:"previous_config" json:"previous_config"`
+	EstimatedCost         float64                `bson:"estimated_cost" json:"estimated_cost"`
+	PreviousEstimatedCost float64                `bson:"previous_estimated_cost" json:"previous_estimated_cost"`
+	ChangedBy             string                 `bson:"changed_by" json:"changed_by"`
+	ChangedAt             time.Time              `bson:"changed_at" json:"changed_at"`
+}
@@ -3,7 +3,12 @@ package models
 import "time"
 
 type Notification struct {
-	Manager   string    `bson:"manager"`
-	Message   string    `bson:"message"`
-	Timestamp time.Time `bson:"timestamp"`
+	Manager   string                 `bson:"manager,omitempty" json:"manager,omitempty"`
+	Username  string                 `bson:"username,omitempty" json:"username,omitempty"` // set instead of Manager for notifications to a user
+	Message   string                 `bson:"message" json:"message"`
+	Action    string                 `bson:"action,omitempty" json:"action,omitempty"`     // API call acting on the notification, e.g. "POST /user/services/{id}/extend"
+	Type      string                 `bson:"type,omitempty" json:"type,omitempty"`         // kind of structured notification, e.g. "budget_alert"
+	GroupID   string                 `bson:"group_id,omitempty" json:"group_id,omitempty"` // group a structured notification is about
+	Details   map[string]interface{} `bson:"details,omitempty" json:"details,omitempty"`   // fields of a structured notification, by type
+	Timestamp time.Time              `bson:"timestamp" json:"timestamp"`
 }
@@ -1,6 +1,7 @@
 package routes
  
 import (
+    "multitenant/cloud"
     "multitenant/handlers"
  
     "github.com/gorilla/mux"
@@ -17,22 +18,67 @@ func InitializeRoutes() *mux.Router {
     adminRouter := router.PathPrefix("/admin").Subrouter()
     adminRouter.Use(handlers.Authenticate)          // Middleware to verify JWT token
     adminRouter.Use(handlers.Authorize("admin"))   // Middleware to allow only Admin
-    adminRouter.HandleFunc("/create-manager", handlers.CreateManagerHandler).Methods("POST")
-    adminRouter.HandleFunc("/delete-manager", handlers.RemoveManagerHandler).Methods("DELETE")
+    adminRouter.Handle("/create-manager", handlers.Idempotent(handlers.CreateManagerHandler)).Methods("POST")
+    adminRouter.Handle("/delete-manager", handlers.Idempotent(handlers.RemoveManagerHandler)).Methods("DELETE")
+    adminRouter.HandleFunc("/credentials", handlers.GetOrgCredentialsHandler).Methods("GET")
+    adminRouter.HandleFunc("/credentials", handlers.SetOrgCredentialsHandler).Methods("PUT")
+    adminRouter.Handle("/credentials", handlers.Idempotent(handlers.DeleteOrgCredentialsHandler)).Methods("DELETE")
+    adminRouter.HandleFunc("/pricing-catalog", handlers.GetPricingCatalogHandler).Methods("GET")
+    adminRouter.HandleFunc("/pricing-catalog/refresh", handlers.RefreshPricingCatalogHandler).Methods("POST")
+    adminRouter.HandleFunc("/reports/chargeback", handlers.GetChargebackReportHandler).Methods("GET")
+    adminRouter.HandleFunc("/reports", handlers.ListChargebackReportsHandler).Methods("GET")
+    adminRouter.HandleFunc("/reports/{id}", handlers.GetDeliveredReportHandler).Methods("GET")
+    adminRouter.HandleFunc("/report-schedules", handlers.ListReportSchedulesHandler).Methods("GET")
+    adminRouter.Handle("/report-schedules", handlers.Idempotent(handlers.CreateReportScheduleHandler)).Methods("POST")
+    adminRouter.Handle("/report-schedules/{id}", handlers.Idempotent(handlers.DeleteReportScheduleHandler)).Methods("DELETE")
+    adminRouter.HandleFunc("/notifications", handlers.ListManagerNotificationsHandler).Methods("GET")
  
     // Manager routes
     managerRouter := router.PathPrefix("/manager").Subrouter()
     managerRouter.Use(handlers.Authenticate)       // Middleware to verify JWT token
     managerRouter.Use(handlers.Authorize("manager")) // Middleware to allow only Managers
-    managerRouter.HandleFunc("/create-group", handlers.CreateGroupHandler).Methods("POST")
-    managerRouter.HandleFunc("/create-user", handlers.CreateUserHandler).Methods("POST")
-    managerRouter.HandleFunc("/delete-user", handlers.DeleteUserHandler).Methods("DELETE")
+    managerRouter.Handle("/create-group", handlers.Idempotent(handlers.CreateGroupHandler)).Methods("POST")
+    managerRouter.Handle("/create-user", handlers.Idempotent(handlers.CreateUserHandler)).Methods("POST")
+    managerRouter.Handle("/delete-user", handlers.Idempotent(handlers.DeleteUserHandler)).Methods("DELETE")
     managerRouter.HandleFunc("/add-user", handlers.AddUserHandler).Methods("POST")
     managerRouter.HandleFunc("/remove-user", handlers.RemoveUserHandler).Methods("DELETE")
     managerRouter.HandleFunc("/list-groups", handlers.ListGroupsHandler).Methods("GET")
     managerRouter.HandleFunc("/check-user-group", handlers.CheckUserGroupHandler).Methods("GET")
     managerRouter.HandleFunc("/add-budget", handlers.AddBudgetHandler).Methods("POST")
     managerRouter.HandleFunc("/update-budget", handlers.UpdateBudgetHandler).Methods("PUT")
+    managerRouter.HandleFunc("/groups/{id}/credentials", handlers.GetGroupCredentialsHandler).Methods("GET")
+    managerRouter.HandleFunc("/groups/{id}/credentials", handlers.SetGroupCredentialsHandler).Methods("PUT")
+    managerRouter.Handle("/groups/{id}/credentials", handlers.Idempotent(handlers.DeleteGroupCredentialsHandler)).Methods("DELETE")
+    managerRouter.HandleFunc("/groups/{id}/services", handlers.ListGroupServicesHandler).Methods("GET")
+    managerRouter.HandleFunc("/groups/{id}/services/{service_id}", handlers.GetGroupServiceHandler).Methods("GET")
+    managerRouter.HandleFunc("/groups/{id}/drift", handlers.ListGroupDriftHandler).Methods("GET")
+    managerRouter.HandleFunc("/groups/{id}/reconcile", handlers.ReconcileGroupHandler).Methods("POST")
+    managerRouter.HandleFunc("/groups/{id}/discover", handlers.DiscoverGroupResourcesHandler).Methods("POST")
+    managerRouter.HandleFunc("/groups/{id}/discovered", handlers.ListDiscoveredResourcesHandler).Methods("GET")
+    managerRouter.Handle("/groups/{id}/discovered/{resource_id}/adopt", handlers.Idempotent(handlers.AdoptResourceHandler)).Methods("POST")
+    managerRouter.HandleFunc("/groups/{id}/schedules", handlers.ListGroupSchedulesHandler).Methods("GET")
+    managerRouter.Handle("/groups/{id}/schedules", handlers.Idempotent(handlers.CreateGroupScheduleHandler)).Methods("POST")
+    managerRouter.Handle("/groups/{id}/schedules/{schedule_id}", handlers.Idempotent(handlers.DeleteGroupScheduleHandler)).Methods("DELETE")
+    managerRouter.HandleFunc("/groups/{id}/ttl", handlers.SetGroupTTLHandler).Methods("PUT")
+    managerRouter.HandleFunc("/groups/{id}/budget", handlers.GetGroupBudgetHandler).Methods("GET")
+    managerRouter.HandleFunc("/groups/{id}/budget/period", handlers.SetBudgetPeriodHandler).Methods("PUT")
+    managerRouter.HandleFunc("/groups/{id}/budget/history", handlers.ListBudgetPeriodsHandler).Methods("GET")
+    managerRouter.HandleFunc("/groups/{id}/forecast", handlers.GetGroupForecastHandler).Methods("GET")
+    managerRouter.HandleFunc("/groups/{id}/ledger", handlers.ListBudgetLedgerHandler).Methods("GET")
+    managerRouter.Handle("/groups/{id}/ledger/accruals", handlers.Idempotent(handlers.RecordAccrualHandler)).Methods("POST")
+    managerRouter.HandleFunc("/groups/{id}/costs", handlers.GetGroupCostsHandler).Methods("GET")
+    managerRouter.HandleFunc("/groups/{id}/costs/sync", handlers.SyncGroupCostsHandler).Methods("POST")
+    managerRouter.HandleFunc("/groups/{id}/anomalies", handlers.ListCostAnomaliesHandler).Methods("GET")
+    managerRouter.HandleFunc("/groups/{id}/alerts", handlers.GetBudgetAlertsHandler).Methods("GET")
+    managerRouter.HandleFunc("/groups/{id}/alerts", handlers.SetBudgetAlertRulesHandler).Methods("PUT")
+    managerRouter.HandleFunc("/groups/{id}/alerts/block", handlers.LiftSessionBlockHandler).Methods("DELETE")
+    managerRouter.HandleFunc("/notifications", handlers.ListManagerNotificationsHandler).Methods("GET")
+    managerRouter.HandleFunc("/reports/chargeback", handlers.GetChargebackReportHandler).Methods("GET")
+    managerRouter.HandleFunc("/reports", handlers.ListChargebackReportsHandler).Methods("GET")
+    managerRouter.HandleFunc("/reports/{id}", handlers.GetDeliveredReportHandler).Methods("GET")
+    managerRouter.HandleFunc("/report-schedules", handlers.ListReportSchedulesHandler).Methods("GET")
+    managerRouter.Handle("/report-schedules", handlers.Idempotent(handlers.CreateReportScheduleHandler)).Methods("POST")
+    managerRouter.Handle("/report-schedules/{id}", handlers.Idempotent(handlers.DeleteReportScheduleHandler)).Methods("DELETE")
  
     // User routes
     userRouter := router.PathPrefix("/user").Subrouter()
@@ -48,27 +94,29 @@ func InitializeRoutes() *mux.Router {
  
     // userRouter.HandleFunc("/fetch-aws-price", handlers.FetchAWSServicePriceHandler).Methods("POST")
    
-    // AWS Service routes
-    userRouter.HandleFunc("/create-ec2-instance", handlers.CreateEC2InstanceHandler).Methods("POST")
-    userRouter.HandleFunc("/create-s3-bucket", handlers.CreateS3BucketHandler).Methods("POST")
-    userRouter.HandleFunc("/create-lambda-function", handlers.CreateLambdaFunctionHandler).Methods("POST")
-    userRouter.HandleFunc("/create-rds-instance", handlers.CreateRDSInstanceHandler).Methods("POST")
+    // AWS and GCP service creation routes come from the cloud registry (create and delete routes accept an Idempotency-Key header)
+    for _, svc := range cloud.AllServices() {
+        userRouter.Handle("/"+svc.Path(), handlers.Idempotent(handlers.CreateServiceHandler(svc))).Methods("POST")
+    }
     // userRouter.HandleFunc("/create-dynamodb-table", handlers.CreateDynamoDBTableHandler).Methods("POST")
-    userRouter.HandleFunc("/create-cloudfront-distribution", handlers.CreateCloudFrontDistributionHandler).Methods("POST")
-    userRouter.HandleFunc("/create-vpc", handlers.CreateVPCHandler).Methods("POST")
- 
-    // routes for GCP service creation
-    userRouter.HandleFunc("/create-compute-engine", handlers.CreateComputeEngineHandler).Methods("POST")
-    userRouter.HandleFunc("/create-cloud-storage", handlers.CreateCloudStorageHandler).Methods("POST")
-    userRouter.HandleFunc("/create-GKE-cluster", handlers.CreateGKEClusterHandler).Methods("POST")
-    userRouter.HandleFunc("/create-bigquery-dataset", handlers.CreateBigQueryDatasetHandler).Methods("POST")
-    userRouter.HandleFunc("/create-cloud-SQL", handlers.CreateCloudSQLHandler).Methods("POST")
  
     // router.HandleFunc("/fetch-aws-price", handlers.FetchAWSServicePriceHandler).Methods("POST")
     // router.HandleFunc("/fetch-gcp-price", handlers.FetchGCPServicePriceHandler).Methods("POST")
 
-	userRouter.HandleFunc("/delete-aws-service", handlers.DeleteAWSServiceHandler).Methods("POST")
-    userRouter.HandleFunc("/delete-gcp-service", handlers.DeleteGCPServiceHandler).Methods("POST")
+	userRouter.Handle("/delete-aws-service", handlers.Idempotent(handlers.DeleteAWSServiceHandler)).Methods("POST")
+    userRouter.Handle("/delete-gcp-service", handlers.Idempotent(handlers.DeleteGCPServiceHandler)).Methods("POST")
+    userRouter.HandleFunc("/services", handlers.ListUserServicesHandler).Methods("GET")
+    userRouter.Handle("/services/{id}", handlers.Idempotent(handlers.DeleteServiceByIDHandler)).Methods("DELETE")
+    userRouter.Handle("/services/{id}", handlers.Idempotent(handlers.UpdateServiceHandler)).Methods("PATCH")
+    userRouter.HandleFunc("/services/{id}/revisions", handlers.ListServiceRevisionsHandler).Methods("GET")
+    userRouter.HandleFunc("/services/{id}/costs", handlers.GetServiceCostsHandler).Methods("GET")
+    userRouter.Handle("/services/{id}/start", handlers.Idempotent(handlers.StartServiceHandler)).Methods("POST")
+    userRouter.Handle("/services/{id}/stop", handlers.Idempotent(handlers.StopServiceHandler)).Methods("POST")
+    userRouter.HandleFunc("/services/{id}/schedule", handlers.GetServiceScheduleHandler).Methods("GET")
+    userRouter.Handle("/services/{id}/schedule", handlers.Idempotent(handlers.SetServiceScheduleHandler)).Methods("PUT")
+    userRouter.Handle("/services/{id}/schedule", handlers.Idempotent(handlers.DeleteServiceScheduleHandler)).Methods("DELETE")
+    userRouter.Handle("/services/{id}/extend", handlers.Idempotent(handlers.ExtendServiceHandler)).Methods("POST")
+    userRouter.HandleFunc("/notifications", handlers.ListNotificationsHandler).Methods("GET")
 
     userRouter.HandleFunc("/send-notification", handlers.SendNotificationHandler).Methods("POST")
 
@@ -0,0 +1,98 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+// awsRequestTimeout bounds one call to Secrets Manager
+const awsRequestTimeout = 10 * time.Second
+
+// AWSProvider reads secrets from AWS Secrets Manager. The AWSCURRENT version is the value and the AWSPREVIOUS
+// version is still accepted, so a rotation by Secrets Manager keeps the replaced value valid.
+type AWSProvider struct {
+	Client *secretsmanager.Client
+	Prefix string // prepended to secret names to form their IDs
+}
+
+// NewAWSProvider returns a provider calling Secrets Manager with the server's default AWS credentials.
+// endpoint replaces the regional endpoint, e.g. with a local stub such as cmd/secrets-stub.
+func NewAWSProvider(ctx context.Context, region, endpoint, prefix string) (*AWSProvider, error) {
+	var optFns []func(*awsconfig.LoadOptions) error
+	if region != "" {
+		optFns = append(optFns, awsconfig.WithRegion(region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config for Secrets Manager: %v", err)
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("no AWS region for Secrets Manager; set SECRETS_AWS_REGION")
+	}
+
+	client := secretsmanager.NewFromConfig(cfg, func(o *secretsmanager.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+	return &AWSProvider{Client: client, Prefix: prefix}, nil
+}
+
+func (p *AWSProvider) GetSecret(ctx context.Context, name string) (*Secret, error) {
+	ctx, cancel := context.WithTimeout(ctx, awsRequestTimeout)
+	defer cancel()
+
+	id := p.Prefix + name
+	current, err := p.getSecretValue(ctx, id, "AWSCURRENT")
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, fmt.Errorf("%w: %s has no current version", ErrNotFound, id)
+	}
+
+	secret := &Secret{Value: secretString(current), Version: aws.ToString(current.VersionId)}
+	previous, err := p.getSecretValue(ctx, id, "AWSPREVIOUS")
+	if err != nil {
+		return nil, err
+	}
+	// A secret that was never rotated has no previous version
+	if value := secretString(previous); value != "" {
+		secret.Previous = []string{value}
+	}
+	return secret, nil
+}
+
+// getSecretValue returns one stage of a secret, or nil when the secret has no version in that stage
+func (p *AWSProvider) getSecretValue(ctx context.Context, id, stage string) (*secretsmanager.GetSecretValueOutput, error) {
+	output, err := p.Client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(id),
+		VersionStage: aws.String(stage),
+	})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret %s: %v", id, err)
+	}
+	return output, nil
+}
+
+// secretString returns the value of a secret version, stored as a string or as binary
+func secretString(output *secretsmanager.GetSecretValueOutput) string {
+	if output == nil {
+		return ""
+	}
+	if output.SecretString != nil {
+		return *output.SecretString
+	}
+	return string(output.SecretBinary)
+}
@@ -0,0 +1,95 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"multitenant/secrets/secretstest"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// startStub serves secrets/testdata/secrets.json from a stub Secrets Manager and returns a provider calling it
+func startStub(t *testing.T) (*secretstest.SecretsManager, *AWSProvider) {
+	t.Helper()
+	data, err := os.ReadFile("testdata/secrets.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		t.Fatal(err)
+	}
+
+	stub := secretstest.NewSecretsManager(values)
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	client := secretsmanager.New(secretsmanager.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		HTTPClient:   server.Client(),
+	})
+	return stub, &AWSProvider{Client: client, Prefix: "multitenant/"}
+}
+
+func TestAWSProviderAgainstStub(t *testing.T) {
+	ctx := context.Background()
+	stub, provider := startStub(t)
+
+	secret, err := provider.GetSecret(ctx, MongoURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Value != "mongodb://localhost:27017" || len(secret.Previous) != 0 || secret.Version == "" {
+		t.Fatalf("mongo_uri = %+v, want the fixture value with a version and no previous value", secret)
+	}
+
+	if _, err := provider.GetSecret(ctx, AWSCredentials); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing secret: %v, want ErrNotFound", err)
+	}
+
+	// A rotation makes the replaced version AWSPREVIOUS
+	stub.Put("multitenant/jwt_secret", "rotated-signing-key")
+	secret, err = provider.GetSecret(ctx, JWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Value != "rotated-signing-key" || !reflect.DeepEqual(secret.Previous, []string{"local-development-signing-key"}) {
+		t.Fatalf("jwt_secret after rotation = %+v", secret)
+	}
+}
+
+func TestCacheRotationThroughStub(t *testing.T) {
+	ctx := context.Background()
+	_, provider := startStub(t)
+	cache := NewCache(provider, time.Hour, time.Hour)
+	if err := Require(ctx, cache, MongoURI, JWTSecret); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate twice with PutSecretValue calls; Secrets Manager only keeps one previous version
+	for _, value := range []string{"second-key", "third-key"} {
+		_, err := provider.Client.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+			SecretId:     aws.String("multitenant/jwt_secret"),
+			SecretString: aws.String(value),
+		})
+		if err != nil {
+			t.Fatalf("PutSecretValue: %v", err)
+		}
+		cache.Refresh(JWTSecret)
+		if _, err := cache.Get(ctx, JWTSecret); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The cache still accepts the first key for the grace, after Secrets Manager dropped it
+	expectValues(t, cache, "third-key", "second-key", "local-development-signing-key")
+}
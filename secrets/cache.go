@@ -0,0 +1,143 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Cache keeps the secrets read from a provider for the refresh interval. When a refresh finds a new value, the
+// replaced one is still returned by Values for the rotation grace, so tokens signed with an old key keep verifying
+// until they expire even with backends that do not keep previous versions.
+type Cache struct {
+	provider SecretsProvider
+	refresh  time.Duration // zero keeps values for good
+	grace    time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	secret  Secret
+	missing error // why the backend does not hold the secret
+	fetched time.Time
+	retired []retiredValue
+}
+
+// retiredValue is a value replaced by a rotation, accepted until a deadline
+type retiredValue struct {
+	value string
+	until time.Time
+}
+
+// NewCache caches the secrets of provider for refresh, accepting replaced values for grace after a rotation
+func NewCache(provider SecretsProvider, refresh, grace time.Duration) *Cache {
+	return &Cache{
+		provider: provider,
+		refresh:  refresh,
+		grace:    grace,
+		entries:  map[string]*cacheEntry{},
+	}
+}
+
+// Get returns the current value of a secret. Secrets the backend does not hold return an error wrapping ErrNotFound.
+func (c *Cache) Get(ctx context.Context, name string) (string, error) {
+	values, err := c.lookup(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return values[0], nil
+}
+
+// Values returns the current value of a secret followed by the earlier values still accepted, newest first
+func (c *Cache) Values(ctx context.Context, name string) ([]string, error) {
+	return c.lookup(ctx, name)
+}
+
+// Refresh drops the cached value of a secret so the next read fetches it, e.g. after a rotation
+func (c *Cache) Refresh(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[name]; ok {
+		entry.fetched = time.Time{}
+	}
+}
+
+func (c *Cache) lookup(ctx context.Context, name string) ([]string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[name]
+	if ok && (c.refresh == 0 || time.Since(entry.fetched) < c.refresh) && !entry.fetched.IsZero() {
+		defer c.mu.Unlock()
+		return entry.values()
+	}
+	c.mu.Unlock()
+
+	// The provider is called without the lock so a slow backend only holds up readers of this secret
+	secret, err := c.provider.GetSecret(ctx, name)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry = c.entries[name]
+	if entry == nil {
+		entry = &cacheEntry{}
+		c.entries[name] = entry
+	}
+	now := time.Now()
+	entry.fetched = now
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		entry.missing = err
+	case err != nil:
+		if entry.secret.Value == "" {
+			delete(c.entries, name)
+			return nil, fmt.Errorf("failed to read secret %s: %w", name, err)
+		}
+		// A backend outage does not take the server down; the cached value is used until the next refresh
+		log.Printf("Failed to refresh secret %s, using the cached value: %v", name, err)
+	default:
+		if entry.secret.Value != "" && entry.secret.Value != secret.Value {
+			log.Printf("Secret %s was rotated", name)
+			if c.grace > 0 {
+				entry.retired = append([]retiredValue{{value: entry.secret.Value, until: now.Add(c.grace)}}, entry.retired...)
+			}
+		}
+		entry.secret = *secret
+		entry.missing = nil
+	}
+	return entry.values()
+}
+
+// values lists the current value first, then the previous values of the backend and the values retired by the cache
+func (e *cacheEntry) values() ([]string, error) {
+	if e.missing != nil {
+		return nil, e.missing
+	}
+
+	values := []string{e.secret.Value}
+	seen := map[string]bool{e.secret.Value: true}
+	now := time.Now()
+	kept := e.retired[:0]
+	for _, retired := range e.retired {
+		if now.After(retired.until) {
+			continue
+		}
+		kept = append(kept, retired)
+		if !seen[retired.value] {
+			seen[retired.value] = true
+			values = append(values, retired.value)
+		}
+	}
+	e.retired = kept
+	for _, previous := range e.secret.Previous {
+		if !seen[previous] {
+			seen[previous] = true
+			values = append(values, previous)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,156 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedProvider returns whatever secret or error it was last given and counts the reads
+type scriptedProvider struct {
+	mu     sync.Mutex
+	secret Secret
+	err    error
+	reads  int
+}
+
+func (p *scriptedProvider) set(secret Secret, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.secret, p.err = secret, err
+}
+
+func (p *scriptedProvider) readCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reads
+}
+
+func (p *scriptedProvider) GetSecret(ctx context.Context, name string) (*Secret, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reads++
+	if p.err != nil {
+		return nil, p.err
+	}
+	secret := p.secret
+	return &secret, nil
+}
+
+func expectValues(t *testing.T, cache *Cache, want ...string) {
+	t.Helper()
+	got, err := cache.Values(context.Background(), JWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Values = %q, want %q", got, want)
+	}
+}
+
+func TestCacheRefreshInterval(t *testing.T) {
+	ctx := context.Background()
+	provider := &scriptedProvider{secret: Secret{Value: "first"}}
+	cache := NewCache(provider, 50*time.Millisecond, 0)
+
+	for i := 0; i < 3; i++ {
+		if value, err := cache.Get(ctx, JWTSecret); err != nil || value != "first" {
+			t.Fatalf("Get = %q, %v, want first", value, err)
+		}
+	}
+	if reads := provider.readCount(); reads != 1 {
+		t.Fatalf("provider read %d times within the refresh interval, want 1", reads)
+	}
+
+	provider.set(Secret{Value: "second"}, nil)
+	if value, _ := cache.Get(ctx, JWTSecret); value != "first" {
+		t.Fatalf("Get = %q before the refresh interval, want the cached first", value)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if value, _ := cache.Get(ctx, JWTSecret); value != "second" {
+		t.Fatalf("Get = %q after the refresh interval, want second", value)
+	}
+	if reads := provider.readCount(); reads != 2 {
+		t.Fatalf("provider read %d times, want 2", reads)
+	}
+}
+
+func TestCacheWithoutRefreshInterval(t *testing.T) {
+	ctx := context.Background()
+	provider := &scriptedProvider{secret: Secret{Value: "first"}}
+	cache := NewCache(provider, 0, 0)
+
+	cache.Get(ctx, JWTSecret)
+	provider.set(Secret{Value: "second"}, nil)
+	if value, _ := cache.Get(ctx, JWTSecret); value != "first" {
+		t.Fatalf("Get = %q, want first kept until restart", value)
+	}
+
+	// Refresh forces the next read to reach the provider
+	cache.Refresh(JWTSecret)
+	if value, _ := cache.Get(ctx, JWTSecret); value != "second" {
+		t.Fatalf("Get = %q after Refresh, want second", value)
+	}
+	if reads := provider.readCount(); reads != 2 {
+		t.Fatalf("provider read %d times, want 2", reads)
+	}
+}
+
+func TestCacheRotationGrace(t *testing.T) {
+	provider := &scriptedProvider{secret: Secret{Value: "first"}}
+	cache := NewCache(provider, time.Hour, 80*time.Millisecond)
+	expectValues(t, cache, "first")
+
+	provider.set(Secret{Value: "second"}, nil)
+	cache.Refresh(JWTSecret)
+	expectValues(t, cache, "second", "first")
+
+	provider.set(Secret{Value: "third", Previous: []string{"second", "backend-kept"}}, nil)
+	cache.Refresh(JWTSecret)
+	expectValues(t, cache, "third", "second", "first", "backend-kept")
+
+	// Retired values expire after the grace; values the backend lists as previous stay
+	time.Sleep(100 * time.Millisecond)
+	expectValues(t, cache, "third", "second", "backend-kept")
+}
+
+func TestCacheWithoutRotationGrace(t *testing.T) {
+	provider := &scriptedProvider{secret: Secret{Value: "first"}}
+	cache := NewCache(provider, time.Hour, 0)
+	expectValues(t, cache, "first")
+
+	provider.set(Secret{Value: "second"}, nil)
+	cache.Refresh(JWTSecret)
+	expectValues(t, cache, "second")
+}
+
+func TestCacheBackendFailures(t *testing.T) {
+	ctx := context.Background()
+	outage := errors.New("backend unavailable")
+
+	provider := &scriptedProvider{err: outage}
+	cache := NewCache(provider, time.Hour, 0)
+	if _, err := cache.Get(ctx, JWTSecret); !errors.Is(err, outage) {
+		t.Fatalf("Get with nothing cached = %v, want the backend error", err)
+	}
+
+	// A failed refresh keeps the cached value
+	provider.set(Secret{Value: "first"}, nil)
+	cache.Get(ctx, JWTSecret)
+	provider.set(Secret{}, outage)
+	cache.Refresh(JWTSecret)
+	if value, err := cache.Get(ctx, JWTSecret); err != nil || value != "first" {
+		t.Fatalf("Get during an outage = %q, %v, want the cached first", value, err)
+	}
+
+	provider.set(Secret{}, ErrNotFound)
+	if _, err := cache.Get(ctx, MongoURI); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of a missing secret = %v, want ErrNotFound", err)
+	}
+	if err := Require(ctx, cache, JWTSecret, MongoURI); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Require with a missing secret = %v, want ErrNotFound", err)
+	}
+}
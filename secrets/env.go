@@ -0,0 +1,28 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvProvider reads a secret from the environment variable of its name in upper case, e.g. JWT_SECRET. The
+// comma separated values of <NAME>_PREVIOUS are still accepted during a rotation.
+type EnvProvider struct{}
+
+func (EnvProvider) GetSecret(ctx context.Context, name string) (*Secret, error) {
+	variable := strings.ToUpper(name)
+	value, ok := os.LookupEnv(variable)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("%w: %s is not set", ErrNotFound, variable)
+	}
+
+	secret := &Secret{Value: value}
+	for _, previous := range strings.Split(os.Getenv(variable+"_PREVIOUS"), ",") {
+		if previous = strings.TrimSpace(previous); previous != "" {
+			secret.Previous = append(secret.Previous, previous)
+		}
+	}
+	return secret, nil
+}
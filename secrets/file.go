@@ -0,0 +1,47 @@
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileProvider reads a secret from the file of its name in Dir, as mounted by Docker or Kubernetes secrets. The
+// lines of <name>.previous are still accepted during a rotation. Rotating is rewriting the files; the new value
+// is read on the next refresh.
+type FileProvider struct {
+	Dir string
+}
+
+func (p FileProvider) GetSecret(ctx context.Context, name string) (*Secret, error) {
+	path := filepath.Join(p.Dir, name)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s does not exist", ErrNotFound, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret %s: %v", name, err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return nil, fmt.Errorf("%w: %s is empty", ErrNotFound, path)
+	}
+
+	secret := &Secret{Value: value}
+	previous, err := os.ReadFile(path + ".previous")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read previous values of secret %s: %v", name, err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(previous))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			secret.Previous = append(secret.Previous, line)
+		}
+	}
+	return secret, nil
+}
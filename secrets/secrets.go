@@ -0,0 +1,65 @@
+// Package secrets reads the server's secrets, such as the MongoDB URI and the JWT signing key, from the environment,
+// from files or from AWS Secrets Manager. Values are cached and refreshed, and a value replaced by a rotation stays
+// available for verification for a grace period.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multitenant/config"
+)
+
+// Names of the secrets the server reads
+const (
+	MongoURI       = "mongo_uri"       // connection string of the database, required
+	JWTSecret      = "jwt_secret"      // HMAC key login tokens are signed with, required
+	AWSCredentials = "aws_credentials" // JSON access key the server calls AWS with; the AWS default chain when absent
+	GCPCredentials = "gcp_credentials" // JSON service account key the server calls GCP with; the default when absent
+)
+
+// ErrNotFound is returned for a secret the backend does not hold
+var ErrNotFound = errors.New("secret not found")
+
+// Secret is the value of a secret at one point in time
+type Secret struct {
+	Value    string   // current value
+	Previous []string // earlier values still accepted while a rotation completes, newest first
+	Version  string   // identifies the current value, when the backend versions secrets
+}
+
+// SecretsProvider fetches secrets by name from a backend
+type SecretsProvider interface {
+	GetSecret(ctx context.Context, name string) (*Secret, error)
+}
+
+// New returns the provider selected by the settings, wrapped in a cache
+func New(ctx context.Context, cfg config.Secrets) (*Cache, error) {
+	var provider SecretsProvider
+	switch cfg.Backend {
+	case "", "env":
+		provider = EnvProvider{}
+	case "file":
+		provider = FileProvider{Dir: cfg.Dir}
+	case "aws":
+		aws, err := NewAWSProvider(ctx, cfg.AWSRegion, cfg.AWSEndpoint, cfg.AWSPrefix)
+		if err != nil {
+			return nil, err
+		}
+		provider = aws
+	default:
+		return nil, fmt.Errorf("unknown secrets backend %q, expected env, file or aws", cfg.Backend)
+	}
+	return NewCache(provider, cfg.RefreshInterval, cfg.RotationGrace), nil
+}
+
+// Require checks that every named secret can be read, so a missing secret stops the server at startup
+func Require(ctx context.Context, cache *Cache, names ...string) error {
+	var errs []error
+	for _, name := range names {
+		if _, err := cache.Get(ctx, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,136 @@
+// Package secretstest provides a local Secrets Manager for running the aws secrets backend without an AWS account,
+// used by the secrets tests and cmd/secrets-stub.
+package secretstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// SecretsManager serves the GetSecretValue and PutSecretValue calls of the Secrets Manager JSON API from memory.
+// Requests are not authenticated. PutSecretValue rotates like Secrets Manager: the new version becomes AWSCURRENT
+// and the replaced one AWSPREVIOUS.
+type SecretsManager struct {
+	mu       sync.Mutex
+	secrets  map[string][]stubVersion // newest version last
+	versions int
+}
+
+type stubVersion struct {
+	id     string
+	value  string
+	stages []string
+}
+
+// NewSecretsManager returns a stub holding the given secrets by ID
+func NewSecretsManager(values map[string]string) *SecretsManager {
+	stub := &SecretsManager{secrets: map[string][]stubVersion{}}
+	for id, value := range values {
+		stub.Put(id, value)
+	}
+	return stub
+}
+
+// Put stores a new current version of a secret, moving the current one to AWSPREVIOUS
+func (s *SecretsManager) Put(id, value string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions := s.secrets[id]
+	for i := range versions {
+		var stages []string
+		for _, stage := range versions[i].stages {
+			switch stage {
+			case "AWSCURRENT":
+				stages = append(stages, "AWSPREVIOUS")
+			case "AWSPREVIOUS":
+			default:
+				stages = append(stages, stage)
+			}
+		}
+		versions[i].stages = stages
+	}
+
+	s.versions++
+	version := stubVersion{id: fmt.Sprintf("v%d", s.versions), value: value, stages: []string{"AWSCURRENT"}}
+	s.secrets[id] = append(versions, version)
+	return version.id
+}
+
+func (s *SecretsManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		SecretId     string
+		VersionId    string
+		VersionStage string
+		SecretString string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		stubError(w, "InvalidRequestException", "invalid request body")
+		return
+	}
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "secretsmanager.GetSecretValue":
+		s.getSecretValue(w, req.SecretId, req.VersionId, req.VersionStage)
+	case "secretsmanager.PutSecretValue":
+		if req.SecretString == "" {
+			stubError(w, "InvalidParameterException", "SecretString is required")
+			return
+		}
+		id := s.Put(req.SecretId, req.SecretString)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"Name":          req.SecretId,
+			"VersionId":     id,
+			"VersionStages": []string{"AWSCURRENT"},
+		})
+	default:
+		stubError(w, "UnknownOperationException", "unsupported operation "+r.Header.Get("X-Amz-Target"))
+	}
+}
+
+func (s *SecretsManager) getSecretValue(w http.ResponseWriter, id, versionID, stage string) {
+	if stage == "" && versionID == "" {
+		stage = "AWSCURRENT"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, version := range s.secrets[id] {
+		if (versionID != "" && version.id != versionID) || (stage != "" && !contains(version.stages, stage)) {
+			continue
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"Name":          id,
+			"VersionId":     version.id,
+			"VersionStages": version.stages,
+			"SecretString":  version.value,
+		})
+		return
+	}
+	stubError(w, "ResourceNotFoundException", "Secrets Manager can't find the specified secret.")
+}
+
+func stubError(w http.ResponseWriter, kind, message string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"__type": kind, "message": message})
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}